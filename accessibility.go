@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/a11y"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// accessibilityChecker runs the a11y quick checks on every crawled page, reporting one row
+// per kind of issue on a page with how many times it occurred and the first example
+type accessibilityChecker struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newAccessibilityChecker() *accessibilityChecker {
+	return &accessibilityChecker{
+		seen:   make(map[string]bool),
+		report: report.New("accessibility", "url", "issue", "count", "example"),
+	}
+}
+
+func (checker *accessibilityChecker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	// Revisits would only report the same page again
+	checker.mutex.Lock()
+	seen := checker.seen[crawled.String()]
+	checker.seen[crawled.String()] = true
+	checker.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	var kinds []string
+	counts := make(map[string]int)
+	examples := make(map[string]string)
+	for _, issue := range a11y.Check(crawled.body) {
+		if counts[issue.Kind] == 0 {
+			kinds = append(kinds, issue.Kind)
+			examples[issue.Kind] = issue.Detail
+		}
+		counts[issue.Kind]++
+	}
+
+	for _, kind := range kinds {
+		checker.report.Add(crawled.String(), kind, strconv.Itoa(counts[kind]), examples[kind])
+	}
+}
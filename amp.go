@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// ampChecker reports AMP pages whose canonical link doesn't lead back to the page that declared them
+// Every AMP page needs a canonical, and one reached via rel="amphtml" should name the page it came from
+type ampChecker struct {
+	canonical canonical.Chain
+
+	mutex sync.Mutex
+
+	// Each AMP URL seen in a rel="amphtml" link, mapped to the page declaring it
+	declaredBy map[string]string
+
+	report *report.Report
+}
+
+func newAMPChecker(chain canonical.Chain) *ampChecker {
+	return &ampChecker{
+		canonical:  chain,
+		declaredBy: make(map[string]string),
+		report:     report.New("amp", "amp page", "declared by", "canonical", "problem"),
+	}
+}
+
+func (checker *ampChecker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	checker.mutex.Lock()
+	for _, link := range crawled.links {
+		if link.relation == relationAMP {
+			ampURL := checker.canonical.Apply(link.URL)
+			checker.declaredBy[ampURL.String()] = crawled.String()
+		}
+	}
+	declaredBy, declared := checker.declaredBy[crawled.String()]
+	checker.mutex.Unlock()
+
+	if !declared && !extract.IsAMP(crawled.body) {
+		return
+	}
+
+	href := extract.Canonical(crawled.body)
+	if href == "" {
+		checker.report.Add(crawled.String(), declaredBy, "", "missing canonical")
+		return
+	}
+
+	target, err := crawled.final.Parse(href)
+	if err != nil {
+		checker.report.Add(crawled.String(), declaredBy, href, "invalid canonical")
+		return
+	}
+	resolved := checker.canonical.Apply(*target)
+	canonicalURL := resolved.String()
+
+	switch {
+	case canonicalURL == crawled.String() && declared:
+		checker.report.Add(crawled.String(), declaredBy, canonicalURL, "canonical points to itself")
+	case declared && canonicalURL != declaredBy:
+		checker.report.Add(crawled.String(), declaredBy, canonicalURL, fmt.Sprintf("canonical doesn't point back to %s", declaredBy))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/wayback"
+)
+
+type archiveRequest struct {
+	site     website
+	crawlErr error
+}
+
+// archiver checks crawled (and broken) pages against the Wayback Machine in the background
+// When save is set, pages without a snapshot are submitted to Save Page Now
+type archiver struct {
+	wayback *wayback.Client
+	save    bool
+
+	requests chan archiveRequest
+	dropped  droppedWork
+	report   *report.Report
+}
+
+func newArchiver(client *http.Client, save bool, queueSize int) *archiver {
+	archive := &archiver{
+		wayback:  wayback.New(client),
+		save:     save,
+		requests: make(chan archiveRequest, queueSize),
+		report:   report.New("wayback", "url", "crawl", "archived", "snapshot", "timestamp", "saved"),
+	}
+
+	go archive.run()
+	return archive
+}
+
+// observe queues a crawl attempt to be checked, dropping it rather than holding up the other observers
+// while the archive's rate limits keep the worker behind
+func (archive *archiver) observe(crawled page, crawlErr error) {
+	select {
+	case archive.requests <- archiveRequest{crawled.website, crawlErr}:
+	default:
+		archive.dropped.add()
+	}
+}
+
+// The archive is checked one page at a time to stay friendly with its rate limits
+func (archive *archiver) run() {
+	for request := range archive.requests {
+		pageURL := request.site.String()
+
+		crawlStatus := "ok"
+		if request.crawlErr != nil {
+			crawlStatus = request.crawlErr.Error()
+		}
+
+		snapshot, err := archive.wayback.Lookup(pageURL)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		saved := ""
+		if !snapshot.Available && archive.save {
+			saved = "yes"
+			if err := archive.wayback.Save(pageURL); err != nil {
+				fmt.Println(err)
+				saved = "failed"
+			}
+		}
+
+		archive.report.Add(pageURL, crawlStatus, fmt.Sprint(snapshot.Available), snapshot.URL, snapshot.Timestamp, saved)
+	}
+}
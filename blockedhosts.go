@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// hostPushback is how a host pushed back on the crawl in one particular way
+type hostPushback struct {
+	vendor     string
+	responses  int
+	retryAfter string
+
+	// The first URL it happened on, and how many pages the host had served fine before then
+	firstURL string
+	okBefore int
+}
+
+// blockedHostTracker watches for hosts that rate limit the crawl or put it behind bot protection,
+// so the pages missing from the results can be put down to the hosts that held them back
+type blockedHostTracker struct {
+	mutex sync.Mutex
+	ok    map[string]int
+	// By host, then by kind of pushback
+	hosts map[string]map[string]*hostPushback
+
+	report *report.Report
+}
+
+func newBlockedHostTracker() *blockedHostTracker {
+	return &blockedHostTracker{
+		ok:     make(map[string]int),
+		hosts:  make(map[string]map[string]*hostPushback),
+		report: report.New("blocked-hosts", "host", "kind", "vendor", "responses", "ok before", "first url", "retry after"),
+	}
+}
+
+func (tracker *blockedHostTracker) observe(crawled page, crawlErr error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if crawlErr == nil {
+		tracker.ok[crawled.Host]++
+		return
+	}
+	if crawled.pushback.Kind == "" {
+		return
+	}
+
+	kinds, ok := tracker.hosts[crawled.Host]
+	if !ok {
+		kinds = make(map[string]*hostPushback)
+		tracker.hosts[crawled.Host] = kinds
+	}
+	pushback, ok := kinds[crawled.pushback.Kind]
+	if !ok {
+		pushback = &hostPushback{firstURL: crawled.String(), okBefore: tracker.ok[crawled.Host]}
+		kinds[crawled.pushback.Kind] = pushback
+	}
+
+	pushback.responses++
+	if crawled.pushback.Vendor != "" {
+		pushback.vendor = crawled.pushback.Vendor
+	}
+	if crawled.pushback.RetryAfter != "" {
+		pushback.retryAfter = crawled.pushback.RetryAfter
+	}
+}
+
+// summarize fills the report with every host that pushed back, by host and then kind
+func (tracker *blockedHostTracker) summarize() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	names := make([]string, 0, len(tracker.hosts))
+	for name := range tracker.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		kinds := make([]string, 0, len(tracker.hosts[name]))
+		for kind := range tracker.hosts[name] {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+
+		for _, kind := range kinds {
+			pushback := tracker.hosts[name][kind]
+			tracker.report.Add(name, kind, pushback.vendor, strconv.Itoa(pushback.responses), strconv.Itoa(pushback.okBefore), pushback.firstURL, pushback.retryAfter)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/soft404"
+)
+
+type brokenLink struct {
+	status   int
+	reason   string
+	referrer string
+}
+
+// brokenLinkTracker collects every page that failed, or that succeeded but looks like an error page
+// Only the latest attempt at a page counts, so a page that recovers on retry isn't reported
+type brokenLinkTracker struct {
+	// Soft 404 heuristics, optional
+	detector *soft404.Detector
+	// Every page linking to a broken one, rather than the first, optional
+	inlinks *inlinkIndex
+
+	mutex  sync.Mutex
+	broken map[string]brokenLink
+
+	report *report.Report
+}
+
+func newBrokenLinkTracker(detector *soft404.Detector, inlinks *inlinkIndex) *brokenLinkTracker {
+	return &brokenLinkTracker{
+		detector: detector,
+		inlinks:  inlinks,
+		broken:   make(map[string]brokenLink),
+		report:   report.New("broken-links", "url", "status", "reason", "linked from"),
+	}
+}
+
+func (tracker *brokenLinkTracker) observe(crawled page, crawlErr error) {
+	link := brokenLink{status: crawled.status}
+	if crawled.referrer.Hostname() != "" {
+		link.referrer = crawled.referrer.String()
+	}
+
+	isBroken := false
+	switch {
+	case crawlErr != nil && crawled.pushback.Kind != "":
+		// The link may well be fine, but there's no telling from behind a challenge or block
+		link.reason = crawled.pushback.Kind
+		isBroken = true
+	case crawlErr != nil && crawled.status != 0:
+		link.reason = http.StatusText(crawled.status)
+		isBroken = true
+	case crawlErr != nil:
+		link.reason = crawlErr.Error()
+		isBroken = true
+	case tracker.detector != nil:
+		var reason string
+		if reason, isBroken = tracker.detector.Detect(crawled.URL, crawled.final, crawled.body); isBroken {
+			link.reason = "soft 404: " + reason
+		}
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if isBroken {
+		tracker.broken[crawled.String()] = link
+	} else {
+		delete(tracker.broken, crawled.String())
+	}
+}
+
+func (tracker *brokenLinkTracker) summarize() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	urls := make([]string, 0, len(tracker.broken))
+	for url := range tracker.broken {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		link := tracker.broken[url]
+
+		status := ""
+		if link.status != 0 {
+			status = strconv.Itoa(link.status)
+		}
+		referrers := link.referrer
+		if tracker.inlinks != nil {
+			referrers = tracker.inlinks.linkedFrom(url, link.referrer)
+		}
+		tracker.report.Add(url, status, link.reason, referrers)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync"
+)
+
+// byteBudget caps how much a crawl downloads, counting response bodies as they came over the wire
+// Once it's spent no more pages are handed out, see -max-bytes
+type byteBudget struct {
+	limit int64
+
+	mutex sync.Mutex
+	used  int64
+}
+
+func newByteBudget(limit int64) *byteBudget {
+	return &byteBudget{limit: limit}
+}
+
+func (budget *byteBudget) spend(bytes int64) {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	budget.used += bytes
+}
+
+// spent is true once at least the limit has been downloaded
+func (budget *byteBudget) spent() bool {
+	return budget.downloaded() >= budget.limit
+}
+
+func (budget *byteBudget) downloaded() int64 {
+	budget.mutex.Lock()
+	defer budget.mutex.Unlock()
+	return budget.used
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/caching"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+type hostCaching struct {
+	responses   int
+	uncacheable int
+
+	// Distinct Cache-Control values by content type, a type with several is cached inconsistently
+	policies map[string]map[string]bool
+
+	cdns    map[string]bool
+	cdnHits int
+	cdnSeen int
+}
+
+// cacheAuditor reports responses shared caches can't store and summarizes caching per host,
+// flagging hosts that cache the same kind of content in different ways
+type cacheAuditor struct {
+	mutex sync.Mutex
+	hosts map[string]*hostCaching
+
+	uncacheable *report.Report
+	summary     *report.Report
+}
+
+func newCacheAuditor() *cacheAuditor {
+	return &cacheAuditor{
+		hosts:       make(map[string]*hostCaching),
+		uncacheable: report.New("uncacheable", "url", "content type", "cache-control", "reason", "cdn"),
+		summary:     report.New("caching", "host", "responses", "uncacheable", "cdn", "cdn hit rate", "problem"),
+	}
+}
+
+func (auditor *cacheAuditor) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || crawled.header == nil {
+		return
+	}
+
+	policy := caching.Analyze(crawled.header, time.Now())
+	contentType, _, err := mime.ParseMediaType(crawled.header.Get("Content-Type"))
+	if err != nil {
+		contentType = "unknown"
+	}
+
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+
+	host, ok := auditor.hosts[crawled.Host]
+	if !ok {
+		host = &hostCaching{policies: make(map[string]map[string]bool), cdns: make(map[string]bool)}
+		auditor.hosts[crawled.Host] = host
+	}
+
+	host.responses++
+	if host.policies[contentType] == nil {
+		host.policies[contentType] = make(map[string]bool)
+	}
+	host.policies[contentType][policy.CacheControl] = true
+
+	if policy.CDN != "" {
+		host.cdns[policy.CDN] = true
+		host.cdnSeen++
+		if policy.CDNStatus == "hit" {
+			host.cdnHits++
+		}
+	}
+
+	if !policy.Cacheable {
+		host.uncacheable++
+		auditor.uncacheable.Add(crawled.String(), contentType, policy.CacheControl, policy.Reason, policy.CDN)
+	}
+}
+
+func (auditor *cacheAuditor) summarize() {
+	auditor.mutex.Lock()
+	defer auditor.mutex.Unlock()
+
+	names := make([]string, 0, len(auditor.hosts))
+	for name := range auditor.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		host := auditor.hosts[name]
+
+		var problems []string
+		for _, contentType := range sortedKeys(host.policies) {
+			if count := len(host.policies[contentType]); count > 1 {
+				problems = append(problems, fmt.Sprintf("%d different Cache-Control policies for %s", count, contentType))
+			}
+		}
+		if host.uncacheable == host.responses {
+			problems = append(problems, "nothing is cacheable")
+		}
+
+		cdns := make([]string, 0, len(host.cdns))
+		for cdn := range host.cdns {
+			cdns = append(cdns, cdn)
+		}
+		sort.Strings(cdns)
+
+		hitRate := ""
+		if host.cdnSeen > 0 {
+			hitRate = fmt.Sprintf("%.0f%%", 100*float64(host.cdnHits)/float64(host.cdnSeen))
+		}
+
+		auditor.summary.Add(name, strconv.Itoa(host.responses), strconv.Itoa(host.uncacheable),
+			strings.Join(cdns, " "), hitRate, strings.Join(problems, "; "))
+	}
+}
+
+func sortedKeys(values map[string]map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
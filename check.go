@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Removed on exit, holds the database and output of `grawler check` unless told to put them elsewhere
+var temporaryDir string
+
+// How many pages `grawler check` crawls at most, unless given -max-pages
+const checkMaxPages string = "1000"
+
+// checkArgs turns `grawler check -start URL [-fail-on-broken] [flags]` into a crawl suited to CI link checks:
+// the start URL's host only, a bounded number of pages, exiting once done, and printing just the broken links
+// -fail-on-broken makes any broken link fail the run, and any other flag is passed along, overriding these
+func checkArgs(args []string) []string {
+	dir, err := ioutil.TempDir("", "grawler-check")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	temporaryDir = dir
+
+	checkArgs := []string{
+		args[0],
+		"-scope", scopeHost,
+		"-max-pages", checkMaxPages,
+		"-exitWhenDone",
+		"-quiet",
+		"-retries", "2",
+		"-retryDelay", "1s",
+		"-db", filepath.Join(dir, "grawler.db"),
+		"-output", filepath.Join(dir, "grawled"),
+		"-reportDir", dir,
+	}
+
+	for _, arg := range args[2:] {
+		switch strings.TrimLeft(arg, "-") {
+		case "fail-on-broken", "fail-on-broken=true":
+			checkArgs = append(checkArgs, "-fail-on", "broken-links")
+		case "fail-on-broken=false":
+		default:
+			checkArgs = append(checkArgs, arg)
+		}
+	}
+	return checkArgs
+}
+
+// printBrokenLink writes a row of the broken-links report as status, URL, referrer and reason, separated by tabs
+// Failures without a status, like timeouts, show "-" instead
+func printBrokenLink(out io.Writer, row []string) {
+	url, status, reason, referrer := row[0], row[1], row[2], row[3]
+	if status == "" {
+		status = "-"
+	}
+	fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", status, url, referrer, reason)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// runPath implements `grawler path <crawl> <from> <to> [selector]`, printing the fewest clicks it takes to
+// get from one crawled page to another, read from the output of -output-format bolt (.db), jsonl (.jsonl) or sqlite (.sqlite)
+// Crawls stored without -recordLinks only know the page each URL was first found on, so paths through
+// them can be longer than the real shortest ones
+func runPath(args []string) {
+	if len(args) != 3 && len(args) != 4 {
+		fmt.Println("usage: grawler path <crawl.db|crawl.jsonl|crawl.sqlite> <from> <to> [name|tag=value+...]")
+		os.Exit(exitFatal)
+	}
+
+	selector := ""
+	if len(args) == 4 {
+		selector = args[3]
+	}
+	records, err := readStoredCrawl(args[0], selector)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	from, to := normalizedArg(args[1]), normalizedArg(args[2])
+	path := shortestPath(records, from, to)
+	if path == nil {
+		fmt.Printf("No path from %s to %s in the crawl\n", from, to)
+		os.Exit(exitThreshold)
+	}
+
+	// Pages linked to but never crawled have no status to show
+	statuses := make(map[string]string, len(records))
+	for _, record := range records {
+		statuses[record.URL] = strconv.Itoa(record.Status)
+	}
+	for clicks, pageURL := range path {
+		status, ok := statuses[pageURL]
+		if !ok {
+			status = "-"
+		}
+		fmt.Printf("%d %s %s\n", clicks, status, pageURL)
+	}
+}
+
+// normalizedArg normalizes a URL given on the command line the way the crawl normalizes the URLs it
+// stores, with the default -canonicalize steps, so it matches them
+func normalizedArg(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	chain, err := canonical.Parse(canonical.Default)
+	if err != nil {
+		return parsed.String()
+	}
+	normalized := chain.Apply(*parsed)
+	return normalized.String()
+}
+
+// shortestPath finds the fewest links leading from one page to another, nil when none do
+// Pages are searched breadth first, following each page's links in the order it links to them
+func shortestPath(records []sink.Record, from string, to string) []string {
+	links := make(map[string][]string, len(records))
+	recorded := make(map[string]bool, len(records))
+	for _, record := range records {
+		links[record.URL] = append(links[record.URL], record.Links...)
+		recorded[record.URL] = record.Links != nil
+	}
+	// Without its links recorded, a page is only known to link to the pages it led the crawl to
+	for _, record := range records {
+		if record.Referrer != "" && !recorded[record.Referrer] {
+			links[record.Referrer] = append(links[record.Referrer], record.URL)
+		}
+	}
+
+	previous := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current == to {
+			var path []string
+			for step := to; step != ""; step = previous[step] {
+				path = append([]string{step}, path...)
+			}
+			return path
+		}
+
+		for _, next := range links[current] {
+			if _, ok := previous[next]; !ok {
+				previous[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+func TestShortestPath(t *testing.T) {
+	records := []sink.Record{
+		{URL: "http://example.com/", Links: []string{"http://example.com/blog", "http://example.com/about"}},
+		{URL: "http://example.com/blog", Referrer: "http://example.com/", Links: []string{"http://example.com/blog/post"}},
+		{URL: "http://example.com/about", Referrer: "http://example.com/", Links: []string{"http://example.com/team"}},
+		{URL: "http://example.com/blog/post", Referrer: "http://example.com/blog", Links: []string{"http://example.com/team"}},
+		// Recorded without its links, so only known to link to the page it led the crawl to
+		{URL: "http://example.com/team", Referrer: "http://example.com/about"},
+		{URL: "http://example.com/careers", Referrer: "http://example.com/team"},
+	}
+
+	for _, test := range []struct {
+		from, to string
+		expected []string
+	}{
+		{"http://example.com/", "http://example.com/", []string{"http://example.com/"}},
+		{"http://example.com/", "http://example.com/team", []string{"http://example.com/", "http://example.com/about", "http://example.com/team"}},
+		{"http://example.com/", "http://example.com/careers", []string{"http://example.com/", "http://example.com/about", "http://example.com/team", "http://example.com/careers"}},
+		{"http://example.com/team", "http://example.com/", nil},
+	} {
+		if path := shortestPath(records, test.from, test.to); !reflect.DeepEqual(path, test.expected) {
+			t.Errorf("Expected the path from %s to %s to be %v, got %v", test.from, test.to, test.expected, path)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// compressionTransport asks for compressed responses and decompresses them itself
+// net/http would do this for gzip on its own, but then there's no telling how big the response was on the wire.
+// Brotli isn't asked for since there's nothing in the standard library to decode it.
+type compressionTransport struct {
+	next http.RoundTripper
+}
+
+func (transport *compressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Leave requests that already negotiated their own encoding alone
+	if req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return transport.next.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	response, err := transport.next.RoundTrip(req)
+	if err != nil {
+		return response, err
+	}
+
+	// Redirects share the request's context, so the last response is the one left measured
+	size, _ := req.Context().Value(transferKey{}).(*transferSize)
+	if size == nil {
+		size = &transferSize{}
+	}
+	*size = transferSize{}
+
+	wire := &countingReader{reader: response.Body, size: size}
+	body := &measuredBody{closer: response.Body}
+
+	switch encoding := strings.ToLower(response.Header.Get("Content-Encoding")); encoding {
+	case "gzip":
+		body.decoded = &lazyReader{open: func() (io.Reader, error) { return gzip.NewReader(wire) }}
+		size.encoding = encoding
+	case "deflate":
+		body.decoded = &lazyReader{open: func() (io.Reader, error) { return deflateReader(wire) }}
+		size.encoding = encoding
+	case "", "identity":
+		body.decoded = wire
+	default:
+		// Something we didn't ask for and can't decode, pass it along untouched
+		return response, nil
+	}
+
+	// The body is decoded now, so its length is no longer known
+	response.Body = body
+	response.ContentLength = -1
+	response.Header.Del("Content-Length")
+	response.Uncompressed = true
+	return response, nil
+}
+
+// measuredBody is a decoded response body that still closes the original
+type measuredBody struct {
+	decoded io.Reader
+	closer  io.Closer
+}
+
+func (body *measuredBody) Read(p []byte) (int, error) {
+	return body.decoded.Read(p)
+}
+
+func (body *measuredBody) Close() error {
+	return body.closer.Close()
+}
+
+// countingReader keeps the wire size of a transferSize up to date as the body is read
+type countingReader struct {
+	reader io.Reader
+	size   *transferSize
+}
+
+func (counting *countingReader) Read(p []byte) (int, error) {
+	n, err := counting.reader.Read(p)
+	counting.size.wire += int64(n)
+	return n, err
+}
+
+// deflateReader decodes a deflate body, which is zlib-wrapped as the standard says it is, though some
+// servers send raw deflate data instead
+func deflateReader(body io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && len(header) < 2 {
+		return nil, err
+	}
+
+	// A zlib header says deflate with a window of at most 32K, and is a multiple of 31 read as a number
+	if header[0]&0x0f == 8 && header[0]>>4 <= 7 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// lazyReader opens its reader on first use, since a gzip reader reads the header as soon as it's created
+type lazyReader struct {
+	open   func() (io.Reader, error)
+	reader io.Reader
+	err    error
+}
+
+func (lazy *lazyReader) Read(p []byte) (int, error) {
+	if lazy.reader == nil && lazy.err == nil {
+		lazy.reader, lazy.err = lazy.open()
+	}
+	if lazy.err != nil {
+		return 0, lazy.err
+	}
+	return lazy.reader.Read(p)
+}
+
+// transferSize is how a response body came over the wire
+type transferSize struct {
+	// Content-Encoding, empty when the body wasn't compressed
+	encoding string
+
+	// Bytes on the wire, and once decoded
+	wire    int64
+	decoded int64
+}
+
+type transferKey struct{}
+
+// measureTransfer returns a request whose response's transferSize is filled in as its body is read
+// Only the wire size and encoding are, the decoded size is left to whoever reads the body.
+func measureTransfer(req *http.Request) (*http.Request, *transferSize) {
+	size := &transferSize{}
+	return req.WithContext(context.WithValue(req.Context(), transferKey{}, size)), size
+}
+
+// compressionAuditor reports large text responses served without compression
+type compressionAuditor struct {
+	// Smaller responses aren't worth compressing
+	minBytes int64
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newCompressionAuditor(minBytes int64) *compressionAuditor {
+	return &compressionAuditor{
+		minBytes: minBytes,
+		seen:     make(map[string]bool),
+		report:   report.New("uncompressed", "url", "content type", "bytes"),
+	}
+}
+
+func (auditor *compressionAuditor) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || crawled.transfer.encoding != "" || crawled.transfer.decoded < auditor.minBytes {
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(crawled.header.Get("Content-Type"))
+	if !isText(contentType) {
+		return
+	}
+
+	auditor.mutex.Lock()
+	seen := auditor.seen[crawled.String()]
+	auditor.seen[crawled.String()] = true
+	auditor.mutex.Unlock()
+
+	if !seen {
+		auditor.report.Add(crawled.String(), contentType, strconv.FormatInt(crawled.transfer.decoded, 10))
+	}
+}
+
+// isText is true for content types that compress well
+func isText(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	switch contentType {
+	case "application/json", "application/javascript", "application/xml", "application/xhtml+xml",
+		"application/rss+xml", "application/atom+xml", "application/ld+json", "image/svg+xml":
+		return true
+	}
+	return false
+}
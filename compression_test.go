@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeflateBodies(t *testing.T) {
+	page := strings.Repeat("<p>Deflated page</p>", 100)
+
+	var zlibBody, rawBody bytes.Buffer
+	zlibWriter := zlib.NewWriter(&zlibBody)
+	zlibWriter.Write([]byte(page))
+	zlibWriter.Close()
+	rawWriter, _ := flate.NewWriter(&rawBody, flate.DefaultCompression)
+	rawWriter.Write([]byte(page))
+	rawWriter.Close()
+
+	bodies := map[string][]byte{"/zlib": zlibBody.Bytes(), "/raw": rawBody.Bytes()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "deflate")
+		w.Write(bodies[r.URL.Path])
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &compressionTransport{http.DefaultTransport}}
+	for path, body := range bodies {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		request, transfer := measureTransfer(request)
+
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if err != nil || string(decoded) != page {
+			t.Errorf("%s: expected the page back, got %d bytes and %v", path, len(decoded), err)
+		}
+		if transfer.encoding != "deflate" || transfer.wire != int64(len(body)) {
+			t.Errorf("%s: expected %d deflated bytes on the wire, got %+v", path, len(body), *transfer)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Every flag can be supplied in three ways, listed here from highest to lowest precedence:
+// 1) On the command line, e.g. -queueSize 50
+// 2) As a GRAWLER_* environment variable, e.g. GRAWLER_QUEUE_SIZE=50
+// 3) In a JSON config file given by -config (or GRAWLER_CONFIG), e.g. {"queueSize": 50}
+//
+// Anything not provided by one of these falls back to the flag's default.
+const envPrefix string = "GRAWLER_"
+
+// envName converts a flag name into its environment variable, e.g. queueSize -> GRAWLER_QUEUE_SIZE
+// A run of capitals is one word, so expectedURLs is GRAWLER_EXPECTED_URLS
+func envName(flagName string) string {
+	name := envPrefix
+	previous := '-'
+	for _, r := range flagName {
+		if unicode.IsUpper(r) && !unicode.IsUpper(previous) && previous != '-' {
+			name += "_"
+		}
+		previous = r
+		if r == '-' {
+			r = '_'
+		}
+		name += string(unicode.ToUpper(r))
+	}
+	return name
+}
+
+// applyConfig fills in every flag that wasn't explicitly set on the command line,
+// first from the environment and then from the config file (if there is one)
+func applyConfig(flags *flag.FlagSet, configPath string) error {
+	setOnCommandLine := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	if configPath == "" {
+		configPath = os.Getenv(envName("config"))
+	}
+
+	fileValues, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	for name := range fileValues {
+		if flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in config file %s", name, configPath)
+		}
+	}
+
+	var applyErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if applyErr != nil || setOnCommandLine[f.Name] {
+			return
+		}
+
+		value, ok := os.LookupEnv(envName(f.Name))
+		source := envName(f.Name)
+		if !ok {
+			value, ok = fileValues[f.Name]
+			source = configPath
+		}
+		if !ok {
+			return
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			applyErr = fmt.Errorf("invalid value %q for %s from %s: %v", value, f.Name, source, err)
+		}
+	})
+
+	return applyErr
+}
+
+// readConfigFile loads a flat JSON object of flag names to values
+// Values are normalized to the string form the flag package expects
+func readConfigFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are kept verbatim so large integers aren't mangled into floats
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+
+	raw := make(map[string]interface{})
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %v", path, err)
+	}
+
+	for name, value := range raw {
+		switch value := value.(type) {
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+
+	return values, nil
+}
+
+// configUsage prints the regular flag defaults along with their environment variables
+func configUsage(flags *flag.FlagSet) func() {
+	return func() {
+		output := flags.Output()
+		fmt.Fprintf(output, "Usage of %s:\n", flags.Name())
+		flags.PrintDefaults()
+
+		fmt.Fprintf(output, "\nOptions are read from flags, then %s* environment variables, then the -config file.\n", envPrefix)
+		flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(output, "  -%s\t%s\n", f.Name, envName(f.Name))
+		})
+	}
+}
+
+// readLines reads a list file, one entry per line
+// Blank lines and lines starting with # are skipped
+func readLines(path string) ([]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// configHash identifies the options a crawl ran with, whichever way they were given, leaving out the
+// flags named, so crawls run the same way can be told apart from those that weren't
+func configHash(flags *flag.FlagSet, except ...string) string {
+	skip := make(map[string]bool, len(except))
+	for _, name := range except {
+		skip[name] = true
+	}
+
+	hash := sha256.New()
+	flags.VisitAll(func(f *flag.Flag) {
+		if !skip[f.Name] {
+			fmt.Fprintf(hash, "%s=%s\n", f.Name, f.Value.String())
+		}
+	})
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
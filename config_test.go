@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	for flagName, expected := range map[string]string{
+		"queueSize":     "GRAWLER_QUEUE_SIZE",
+		"expectedURLs":  "GRAWLER_EXPECTED_URLS",
+		"duplicateURLs": "GRAWLER_DUPLICATE_URLS",
+		"trendCSV":      "GRAWLER_TREND_CSV",
+		"max-pages":     "GRAWLER_MAX_PAGES",
+		"output-format": "GRAWLER_OUTPUT_FORMAT",
+		"db":            "GRAWLER_DB",
+	} {
+		if name := envName(flagName); name != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, flagName, name)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.json")
+	config := `{"fromFile": "file", "fromEnv": "file", "fromFlag": "file", "expectedURLs": 5}`
+	if err := ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("GRAWLER_FROM_ENV", "env")
+	os.Setenv("GRAWLER_FROM_FLAG", "env")
+	defer os.Unsetenv("GRAWLER_FROM_ENV")
+	defer os.Unsetenv("GRAWLER_FROM_FLAG")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	fromFile := flags.String("fromFile", "default", "")
+	fromEnv := flags.String("fromEnv", "default", "")
+	fromFlag := flags.String("fromFlag", "default", "")
+	untouched := flags.String("untouched", "default", "")
+	expectedURLs := flags.Int("expectedURLs", 0, "")
+	if err := flags.Parse([]string{"-fromFlag", "flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyConfig(flags, configPath); err != nil {
+		t.Fatal(err)
+	}
+	if *fromFile != "file" || *fromEnv != "env" || *fromFlag != "flag" || *untouched != "default" || *expectedURLs != 5 {
+		t.Errorf("Expected flags over the environment over the config file over defaults, got %s %s %s %s %d",
+			*fromFile, *fromEnv, *fromFlag, *untouched, *expectedURLs)
+	}
+
+	// The environment variable of a flag with a run of capitals in its name
+	os.Setenv("GRAWLER_EXPECTED_URLS", "7")
+	defer os.Unsetenv("GRAWLER_EXPECTED_URLS")
+	if err := applyConfig(flags, configPath); err != nil || *expectedURLs != 7 {
+		t.Errorf("Expected GRAWLER_EXPECTED_URLS to set expectedURLs, got %d (%v)", *expectedURLs, err)
+	}
+
+	if err := applyConfig(flags, filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing config file")
+	}
+	if err := ioutil.WriteFile(configPath, []byte(`{"unknown": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(flags, configPath); err == nil {
+		t.Errorf("Expected an error for an option no flag has")
+	}
+}
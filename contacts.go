@@ -0,0 +1,42 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// contactExtractor collects the email addresses and phone numbers found on each page
+// Off unless asked for, since it gathers personal data
+type contactExtractor struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newContactExtractor() *contactExtractor {
+	return &contactExtractor{
+		seen:   make(map[string]bool),
+		report: report.New("contacts", "url", "kind", "value", "source"),
+	}
+}
+
+func (extractor *contactExtractor) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	extractor.mutex.Lock()
+	seen := extractor.seen[crawled.String()]
+	extractor.seen[crawled.String()] = true
+	extractor.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	for _, contact := range extract.Contacts(crawled.body) {
+		extractor.report.Add(crawled.String(), contact.Kind, contact.Value, contact.Source)
+	}
+}
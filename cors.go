@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/cors"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// Origins each host is probed with, one nobody trusts and the null origin
+var corsProbeOrigins = []string{"https://grawler-canary.example", "null"}
+
+// corsInspector sends a cross-origin request to the first page crawled on every host, and reports the
+// CORS policy it gets back along with any problems
+type corsInspector struct {
+	client *http.Client
+	side   sideRequests
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	pages   chan page
+	dropped droppedWork
+	report  *report.Report
+}
+
+func newCORSInspector(client *http.Client, side sideRequests, workers int, queueSize int) *corsInspector {
+	inspector := &corsInspector{
+		client: client,
+		side:   side,
+		seen:   make(map[string]bool),
+		pages:  make(chan page, queueSize),
+		report: report.New("cors", "host", "url", "origin", "allow origin", "allow credentials", "problems", "error"),
+	}
+
+	for i := 0; i < workers; i++ {
+		go inspector.run()
+	}
+	return inspector
+}
+
+func (inspector *corsInspector) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	inspector.mutex.Lock()
+	seen := inspector.seen[crawled.Host]
+	inspector.seen[crawled.Host] = true
+	inspector.mutex.Unlock()
+
+	if seen {
+		return
+	}
+
+	select {
+	case inspector.pages <- crawled:
+	default:
+		// Left for another page of the host to try again
+		inspector.dropped.add()
+		inspector.mutex.Lock()
+		delete(inspector.seen, crawled.Host)
+		inspector.mutex.Unlock()
+	}
+}
+
+func (inspector *corsInspector) run() {
+	for crawled := range inspector.pages {
+		// The page may have redirected somewhere the crawl wouldn't go
+		if !inspector.side.allows(crawled.final) {
+			inspector.report.Add(crawled.Host, crawled.final.String(), "", "", "", "", "not probed, outside the crawl")
+			continue
+		}
+
+		for _, origin := range corsProbeOrigins {
+			policy, err := inspector.probe(crawled.final.String(), origin)
+			if err != nil {
+				inspector.report.Add(crawled.Host, crawled.final.String(), origin, "", "", "", err.Error())
+				continue
+			}
+
+			inspector.report.Add(crawled.Host, crawled.final.String(), origin, policy.AllowOrigin,
+				strconv.FormatBool(policy.AllowCredentials), strings.Join(policy.Problems(), " "), "")
+		}
+	}
+}
+
+func (inspector *corsInspector) probe(link string, origin string) (cors.Policy, error) {
+	request, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return cors.Policy{}, err
+	}
+	request.Header.Set("Origin", origin)
+
+	response, err := inspector.client.Do(request)
+	if err != nil {
+		return cors.Policy{}, err
+	}
+	response.Body.Close()
+
+	return cors.Read(origin, response.Header), nil
+}
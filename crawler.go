@@ -0,0 +1,694 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/antibot"
+	"github.com/jrokun/crawler/pkg/breaker"
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/document"
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/headless"
+	"github.com/jrokun/crawler/pkg/redirects"
+	"github.com/jrokun/crawler/pkg/revisit"
+	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/score"
+)
+
+// page is what we learned from crawling a website
+type page struct {
+	website
+
+	// Zero when no response was received at all
+	status int
+	header http.Header
+	body   []byte
+
+	// How big the body was on the wire, and whether it was compressed
+	transfer transferSize
+
+	// How long the server took to respond, up to the response headers
+	elapsed time.Duration
+
+	// Where the request ended up after following any redirects, and every URL on the way there
+	final     url.URL
+	redirects []string
+
+	// Set when an error response looks like throttling or bot protection, see pkg/antibot
+	pushback antibot.Verdict
+
+	// Whether the body is an HTML document, by its Content-Type or, when that's missing or generic, its contents
+	html bool
+
+	links []website
+}
+
+// addLink resolves a link found on the page and adds it to the page's links, false if it couldn't be
+func (crawled *page) addLink(link string, relation string) bool {
+	parsedURL, err := url.Parse(link)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	// Relative and protocol-relative (//host/path) links inherit the page's scheme
+	// Links with any other scheme, like mailto: or ftp:, are left alone and dropped during vetting
+	if parsedURL.Scheme == "" {
+		// ! Relative links need to use the crawling Host, port and all
+		if parsedURL.Hostname() == "" {
+			parsedURL.Host = crawled.Host
+
+			// Links to just a #fragment or ?query, like single page app routes, stay on this page
+			if parsedURL.Path == "" {
+				parsedURL.Path = crawled.Path
+			}
+		}
+
+		parsedURL.Scheme = crawled.Scheme
+		if parsedURL.Scheme == "" {
+			parsedURL.Scheme = "http"
+		}
+	}
+
+	toVet := website{referrer: crawled.URL, relation: relation, score: score.Neutral, URL: *parsedURL}
+	crawled.links = append(crawled.links, toVet)
+	return true
+}
+
+// crawlable is true for the schemes the crawler can fetch
+func crawlable(link url.URL) bool {
+	scheme := strings.ToLower(link.Scheme)
+	return (scheme == "http" || scheme == "https") && link.Host != ""
+}
+
+// crawlObserver is told about every crawl attempt once it finishes, see observeEvents
+type crawlObserver func(crawled page, crawlErr error)
+
+// crawler holds everything the manager and its workers share
+type crawler struct {
+	client *http.Client
+
+	// Renders pages before links are extracted, optional
+	browser *headless.Browser
+	// Renders only the pages that come back as bot challenges, in the hope a real browser gets past them, optional
+	challengeBrowser *headless.Browser
+
+	pending *frontier
+
+	// URLs a previous crawl already visited, which aren't crawled again, optional
+	seen robots.Set
+
+	// Pages unchanged since an earlier crawl, which aren't fetched again, with the links they had then
+	// so what's only reachable through them is still crawled, optional
+	stale map[string][]revisit.Link
+
+	// Sitemap lastmods, for skipping pages unchanged since an earlier crawl, optional
+	lastmods *sitemapLastmods
+
+	// Normalizes every URL before it's checked against those already visited
+	canonical canonical.Chain
+
+	// Whether links keep single page app routes like #/about, which canonical mustn't strip either
+	routeFragments bool
+
+	// The robots.txt rules followed, fetched as vetting reaches each host, with the longest Crawl-delay
+	// they honor, and whether hosts asking for longer are skipped rather than crawled at that delay
+	rules         robots.RulesIndex
+	skipSlowHosts bool
+
+	// Links outside of scope are skipped, or HEAD-checked when there's an external checker
+	scope    *crawlScope
+	external *externalChecker
+
+	// Links to domains it filters out are skipped without any request at all, optional
+	domains *domainFilter
+
+	// Crawls slow and erroring hosts one page at a time, optional
+	throttle *hostThrottle
+
+	// Holds back crawls of hosts that keep failing, optional
+	breaker *breaker.Breaker
+
+	// Stops the crawl once enough has been downloaded, optional
+	budget *byteBudget
+
+	// Stops the crawl once this many pages have been handed out, 0 for no limit
+	maxPages int
+
+	// Only crawl what's already in the frontier, without following links
+	failedOnly bool
+
+	// Stop once there's nothing left to vet, queued or in flight
+	stopWhenDone bool
+
+	// Batches of links sent to the vetting queue and not yet vetted, updated atomically
+	unvetted int64
+
+	// Closed when the crawl stops by itself, after the pages in flight are done
+	stopped chan struct{}
+
+	// Schedules recrawls of visited pages, optional
+	revisits *revisit.Scheduler
+
+	// More pages to start from besides the start URL, like those -discoverSeeds finds
+	seeds []website
+
+	// Everything that happens during the crawl is published here
+	events *eventBus
+
+	// Ranks links so the best are crawled first, links scoring 0 or less are dropped
+	scorers []score.Func
+
+	// Also follow the sources of frames and iframes
+	followFrames bool
+
+	// Also follow AMP and media-specific alternate versions of pages
+	followAlternates bool
+
+	// Parse the links out of these kinds of documents too, which would otherwise lead nowhere
+	documents document.Parsers
+
+	// How many robots.txt files of the hosts the crawl starts on are fetched at once before it does
+	robotsPrefetch int
+
+	status *health
+}
+
+func (c *crawler) manager(initialURL url.URL, queueSize int) (visited robots.Set, rulesIndex robots.RulesIndex) {
+	visited = make(robots.Set, len(c.seen))
+	for seenURL := range c.seen {
+		visited[seenURL] = true
+	}
+	// The start URL is crawled regardless, or there'd be nothing to find new URLs from
+	start := c.canonical.Apply(initialURL)
+	delete(visited, start.String())
+	rulesIndex = c.rules
+
+	vettingQueue := make(chan []website, queueSize)
+	c.status.setFrontier(vettingQueue, c.pending.jobs)
+
+	// Without a scheduler this stays nil and never fires
+	var revisitTicks <-chan time.Time
+	if c.revisits != nil {
+		revisitTicks = time.NewTicker(30 * time.Second).C
+	}
+
+	// The starting points count as unvetted from the outset, or with -exitWhenDone the crawl could look
+	// done while robots.txt files are still being prefetched
+	if !c.failedOnly {
+		atomic.AddInt64(&c.unvetted, 1)
+	}
+
+	go func() {
+		if !c.failedOnly {
+			startingPoints := append([]website{website{score: score.Neutral, URL: initialURL}}, c.seeds...)
+			if c.robotsPrefetch > 0 {
+				c.prefetchRobots(&rulesIndex, startingPoints)
+			}
+			vettingQueue <- startingPoints
+		}
+
+		for {
+			var toVetBatch []website
+			queued := false
+			select {
+			case toVetBatch = <-vettingQueue:
+				queued = true
+			case now := <-revisitTicks:
+				toVetBatch = dueRevisits(c.revisits, now)
+			}
+
+			for _, toVet := range toVetBatch {
+				// We can only crawl the web
+				if !crawlable(toVet.URL) {
+					continue
+				}
+
+				toVet.URL = c.canonical.Apply(toVet.URL)
+				fullURL := toVet.String()
+
+				// We don't want to crawl sites we've already visited, unless it's time to check on them again
+				// or they're back from waiting on their host's sitemaps
+				if _, ok := visited[fullURL]; ok && !toVet.revisit && !toVet.waited {
+					continue
+				}
+				if !toVet.revisit && !toVet.waited {
+					c.events.publish(urlDiscovered{toVet})
+				}
+				visited[fullURL] = true
+
+				if c.domains != nil && !c.domains.allows(toVet.Hostname()) {
+					continue
+				}
+
+				if !c.scope.contains(toVet.URL) {
+					if c.external != nil && !toVet.revisit {
+						c.external.check(toVet)
+					}
+					continue
+				}
+
+				// Load or fetch the robots.txt rules for this site
+				rules, err := rulesIndex.Get(toVet.Hostname())
+				if err != nil {
+					// An unreachable robots.txt usually means an unreachable host, which observers want to hear about
+					fmt.Println(err)
+					c.events.publish(fetchFailed{page{website: toVet}, err})
+					continue
+				}
+
+				if ok := rules.Test(toVet.Path); !ok {
+					fmt.Printf("Skipping %s\n", fullURL)
+					c.events.publish(robotsDenied{site: toVet})
+					continue
+				}
+
+				if links, ok := c.stale[fullURL]; ok && fullURL != start.String() {
+					fmt.Printf("Skipping %s, unchanged since an earlier crawl\n", fullURL)
+					// Sent from another goroutine, since this one is what empties the queue
+					atomic.AddInt64(&c.unvetted, 1)
+					go func(batch []website) { vettingQueue <- batch }(c.followUnchanged(page{website: toVet}, links))
+					continue
+				}
+
+				if c.lastmods != nil {
+					loaded, batch := c.lastmods.wait(toVet, rules.Sitemaps)
+					if batch != nil {
+						// Sent from another goroutine, since this one is what empties the queue
+						atomic.AddInt64(&c.unvetted, 1)
+						go func() { vettingQueue <- <-batch }()
+					}
+					if !loaded {
+						continue
+					}
+					if links, ok := c.lastmods.unchanged(fullURL); ok && fullURL != start.String() {
+						fmt.Printf("Skipping %s, unchanged since its sitemap lastmod\n", fullURL)
+						atomic.AddInt64(&c.unvetted, 1)
+						go func(batch []website) { vettingQueue <- batch }(c.followUnchanged(page{website: toVet}, links))
+						continue
+					}
+				}
+
+				if c.skipSlowHosts && rules.RequestedDelay > rules.Delay {
+					fmt.Printf("Skipping %s, its robots.txt asks for a %v Crawl-delay\n", fullURL, rules.RequestedDelay)
+					c.events.publish(robotsDenied{site: toVet, slow: true})
+					continue
+				}
+
+				if err := c.pending.push(toVet, rules.Delay); err != nil {
+					fmt.Println(err)
+				}
+			}
+
+			if queued {
+				atomic.AddInt64(&c.unvetted, -1)
+			}
+		}
+	}()
+
+	// Hand out queued websites to crawling workers
+	go func() {
+		var inFlight sync.WaitGroup
+		handedOut := 0
+
+		for {
+			limitReached := false
+			if c.budget != nil && c.budget.spent() {
+				fmt.Printf("Download budget of %d bytes spent, finishing the pages in flight\n", c.budget.limit)
+				limitReached = true
+			} else if c.maxPages > 0 && handedOut >= c.maxPages {
+				fmt.Printf("Page limit of %d reached, finishing the pages in flight\n", c.maxPages)
+				limitReached = true
+			}
+			if limitReached {
+				inFlight.Wait()
+				close(c.stopped)
+				return
+			}
+
+			// Rather than fetch pages nobody can keep up with, hold off until the backlog clears
+			if c.events.congested() || isCongested(len(vettingQueue), cap(vettingQueue)) {
+				<-time.NewTimer(250 * time.Millisecond).C
+				continue
+			}
+
+			job, toCrawl, delay, ok, err := c.pending.pop()
+			if err != nil {
+				fmt.Println(err)
+			}
+			if !ok {
+				if c.stopWhenDone && atomic.LoadInt64(&c.unvetted) == 0 && c.pending.drained() {
+					fmt.Println("Nothing left to crawl")
+					close(c.stopped)
+					return
+				}
+				<-time.NewTimer(250 * time.Millisecond).C
+				continue
+			}
+
+			handedOut++
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+				<-time.NewTimer(delay).C
+				if c.breaker != nil {
+					if ok, until := c.breaker.Allow(toCrawl.Hostname(), time.Now()); !ok {
+						fmt.Printf("Circuit open for %s, requeueing %s\n", toCrawl.Hostname(), toCrawl.String())
+						if err := c.pending.postpone(job, time.Until(until)); err != nil {
+							fmt.Println(err)
+						}
+						return
+					}
+				}
+				if c.throttle != nil {
+					release := c.throttle.acquire(toCrawl.Hostname())
+					defer release()
+				}
+				if err := c.pending.start(job); err != nil {
+					fmt.Println(err)
+				}
+				c.events.publish(fetchStarted{toCrawl})
+
+				crawled, crawlErr := c.crawl(toCrawl)
+				if c.budget != nil {
+					c.budget.spend(crawled.transfer.wire)
+				}
+				if crawled.status == http.StatusNotModified {
+					fmt.Printf("Unchanged: %s%s\n", crawled.Hostname(), crawled.Path)
+					// Only sitemap lastmods ask for one, see sitemapLastmods.ifModifiedSince
+					if c.lastmods != nil {
+						batch := c.followUnchanged(crawled, c.lastmods.links(toCrawl.String()))
+						if !c.failedOnly {
+							c.sendToVet(vettingQueue, batch)
+						}
+					}
+				} else if crawlErr != nil {
+					fmt.Println(crawlErr)
+					c.events.publish(fetchFailed{crawled, crawlErr})
+				} else {
+					if !c.failedOnly {
+						c.sendToVet(vettingQueue, c.scoreLinks(crawled))
+					}
+					c.events.publish(fetchCompleted{crawled})
+
+					if !toCrawl.revisit {
+						fmt.Printf("Crawled: %s%s\n", crawled.Hostname(), crawled.Path)
+					}
+				}
+
+				if c.breaker != nil {
+					_, failed := crawlErr.(retryableError)
+					if c.breaker.Record(toCrawl.Hostname(), failed, time.Now()) {
+						fmt.Printf("Circuit opened for %s after %d consecutive failures, pausing it for %v\n", toCrawl.Hostname(), c.breaker.Threshold, c.breaker.Cooldown)
+					}
+				}
+
+				if err := c.pending.finish(job, toCrawl, crawlErr); err != nil {
+					fmt.Println(err)
+				}
+			}()
+		}
+	}()
+
+	return
+}
+
+// trimFragment cuts the #fragment off a link, which never changes what the server sends, unless it's a
+// single page app route the crawl keeps, see -spaRoutes
+func (c *crawler) trimFragment(link string) string {
+	hash := strings.Index(link, "#")
+	if hash < 0 || c.routeFragments && canonical.Route(link[hash+1:]) {
+		return link
+	}
+	return link[:hash]
+}
+
+// prefetchRobots fetches the robots.txt of every host the crawl starts on at once, rather than one by
+// one as vetting first reaches each
+func (c *crawler) prefetchRobots(rulesIndex *robots.RulesIndex, startingPoints []website) {
+	hostnames := make(robots.Set)
+	for _, site := range startingPoints {
+		if !crawlable(site.URL) {
+			continue
+		}
+		// Vetting canonicalizes before it looks up robots.txt, so an alias step may point elsewhere
+		normalized := c.canonical.Apply(site.URL)
+		if c.domains != nil && !c.domains.allows(normalized.Hostname()) || !c.scope.contains(normalized) {
+			continue
+		}
+		hostnames[normalized.Hostname()] = true
+	}
+	if len(hostnames) < 2 {
+		return
+	}
+
+	started := time.Now()
+	failed := rulesIndex.Prefetch(setItems(hostnames), c.robotsPrefetch)
+	fmt.Printf("Fetched robots.txt for %d of %d hosts in %v\n", len(hostnames)-len(failed), len(hostnames), time.Since(started).Round(time.Millisecond))
+}
+
+// sendToVet queues a batch of links for vetting, counting it as unvetted until it has been
+func (c *crawler) sendToVet(vettingQueue chan<- []website, batch []website) {
+	atomic.AddInt64(&c.unvetted, 1)
+	vettingQueue <- batch
+}
+
+// scoreLinks scores the links found on a page, keeping those that score above 0
+func (c *crawler) scoreLinks(crawled page) []website {
+	scorer := score.Combine(c.scorers...)
+	from := score.Page{
+		URL:      crawled.URL,
+		Referrer: crawled.referrer,
+		Status:   crawled.status,
+		Header:   crawled.header,
+		Body:     crawled.body,
+		Links:    make([]score.Link, len(crawled.links)),
+	}
+	for i, link := range crawled.links {
+		from.Links[i] = score.Link{URL: link.URL, Relation: link.relation}
+	}
+
+	kept := make([]website, 0, len(crawled.links))
+	for i, link := range crawled.links {
+		link.score = scorer(from, from.Links[i])
+		if link.score > 0 {
+			kept = append(kept, link)
+		}
+	}
+	return kept
+}
+
+// followUnchanged publishes a page found unchanged since an earlier crawl, returning the links it had then
+// to be vetted as though it had been fetched again, so nothing only reachable through it is lost
+func (c *crawler) followUnchanged(unchanged page, links []revisit.Link) []website {
+	batch := staleLinks(unchanged.website, links)
+	unchanged.links = batch
+	c.events.publish(fetchUnchanged{unchanged})
+	return batch
+}
+
+// staleLinks turns the links a skipped page had when it was last crawled back into links to vet
+func staleLinks(skipped website, links []revisit.Link) []website {
+	batch := make([]website, 0, len(links))
+	for _, link := range links {
+		parsedURL, err := url.Parse(link.URL)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		batch = append(batch, website{referrer: skipped.URL, relation: link.Relation, score: score.Neutral, URL: *parsedURL})
+	}
+	return batch
+}
+
+// dueRevisits collects the pages the scheduler wants crawled again, postponing each
+// so it isn't queued a second time while the revisit is still pending
+func dueRevisits(revisits *revisit.Scheduler, now time.Time) []website {
+	due, err := revisits.Due(now)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	toVet := make([]website, 0, len(due))
+	for _, record := range due {
+		parsedURL, err := url.Parse(record.URL)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		if err := revisits.Postpone(record.URL, now.Add(record.Interval)); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		toVet = append(toVet, website{revisit: true, score: score.Neutral, URL: *parsedURL})
+	}
+
+	return toVet
+}
+
+// crawl fetches a website and extracts its links
+// With a browser, the body is the document as rendered after running the page's scripts
+func (c *crawler) crawl(toCrawl website) (page, error) {
+	crawled := page{website: toCrawl}
+
+	request, err := http.NewRequest(http.MethodGet, toCrawl.String(), nil)
+	if err != nil {
+		return crawled, err
+	}
+	if c.lastmods != nil {
+		if since, ok := c.lastmods.ifModifiedSince(toCrawl.String()); ok {
+			request.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+		}
+	}
+	request, transfer := measureTransfer(request)
+
+	started := time.Now()
+	response, err := c.client.Do(request)
+	crawled.elapsed = time.Since(started)
+	if err != nil {
+		// Following a loop again will only go around it again
+		var loop *redirectLoopError
+		if errors.As(err, &loop) {
+			return crawled, err
+		}
+		return crawled, retryableError{err}
+	}
+	defer response.Body.Close()
+
+	crawled.status = response.StatusCode
+	crawled.header = response.Header
+	crawled.final = *response.Request.URL
+	crawled.redirects = redirectChain(response)
+
+	// Only ever the answer to If-Modified-Since, the page is as the last crawl left it
+	if response.StatusCode == http.StatusNotModified {
+		return crawled, nil
+	}
+
+	if response.StatusCode > 399 || response.StatusCode < 200 {
+		err := fmt.Errorf("Status code %d %s", response.StatusCode, toCrawl.String())
+
+		// Challenge and block pages give themselves away early on, no need to read all of one
+		start, _ := ioutil.ReadAll(io.LimitReader(response.Body, 16*1024))
+		crawled.pushback, _ = antibot.Detect(response.StatusCode, response.Header, start)
+
+		// Server errors and rate limiting are usually transient
+		if response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests {
+			return crawled, retryableError{err}
+		}
+		return crawled, err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return crawled, retryableError{err}
+	}
+	crawled.transfer = *transfer
+	crawled.transfer.decoded = int64(len(body))
+
+	if c.browser != nil {
+		if body, err = c.browser.DOM(toCrawl.String()); err != nil {
+			return crawled, retryableError{err}
+		}
+	}
+
+	// Challenge interstitials aren't content, so they're failures rather than pages with odd contents
+	verdict, challenged := antibot.Challenge(response.Header, body)
+	if challenged && c.challengeBrowser != nil {
+		if body, err = c.challengeBrowser.DOM(toCrawl.String()); err != nil {
+			return crawled, retryableError{err}
+		}
+		verdict, challenged = antibot.Challenge(response.Header, body)
+	}
+	if challenged {
+		crawled.pushback = verdict
+		return crawled, fmt.Errorf("Challenge page instead of content %s", toCrawl.String())
+	}
+	crawled.body = body
+
+	// Rendered pages are HTML whatever was served, anything else needs to say so or look like it
+	crawled.html = c.browser != nil || extract.IsHTML(response.Header.Get("Content-Type"), body)
+	if !crawled.html {
+		// There are no links to parse out of images and the like, though a Refresh header navigates all the same
+		if target, _, ok := redirects.ParseRefresh(response.Header.Get("Refresh")); ok {
+			crawled.addLink(target, relationRefresh)
+		}
+
+		links, _, err := c.documents.Links(response.Header.Get("Content-Type"), crawled.final.Path, body)
+		if err != nil {
+			fmt.Printf("Reading the links in %s: %v\n", toCrawl.String(), err)
+		}
+		for _, link := range links {
+			crawled.addLink(link, relationDocument)
+		}
+		return crawled, nil
+	}
+
+	hrefs := extract.Links(body)
+
+	// Pagination links are edges of their own, whether they came from an <a> or a <link>
+	paginated := make(map[string]string)
+	for _, link := range extract.Pagination(body) {
+		if _, ok := paginated[link.URL]; !ok {
+			paginated[link.URL] = link.Rel
+		}
+	}
+
+	placements := extract.LinkPlacements(body)
+	crawled.links = make([]website, 0, len(hrefs))
+	added := make(map[string]bool, len(hrefs))
+	for _, href := range hrefs {
+		link := c.trimFragment(href)
+		if !added[link] && crawled.addLink(link, paginated[href]) {
+			crawled.links[len(crawled.links)-1].placement = placements[href]
+		}
+		added[link] = true
+		delete(paginated, href)
+	}
+	for link, rel := range paginated {
+		if crawled.addLink(link, rel) {
+			crawled.links[len(crawled.links)-1].placement = placements[link]
+		}
+	}
+
+	// Refreshes navigate just like redirects, so they're followed too
+	if target, _, ok := redirects.ParseRefresh(response.Header.Get("Refresh")); ok {
+		crawled.addLink(target, relationRefresh)
+	}
+	for _, pseudo := range redirects.Find(body) {
+		if pseudo.Kind == redirects.MetaRefresh {
+			crawled.addLink(pseudo.Target, relationRefresh)
+		}
+	}
+
+	// Framed pages are children of the page framing them
+	if c.followFrames {
+		for _, frame := range extract.Frames(body) {
+			crawled.addLink(frame, relationFrame)
+		}
+	}
+
+	// Alternate versions are the same page for other devices
+	if c.followAlternates {
+		for _, alternate := range extract.Alternates(body) {
+			relation := relationAlternate
+			if alternate.Rel == "amphtml" {
+				relation = relationAMP
+			}
+			crawled.addLink(alternate.URL, relation)
+		}
+	}
+
+	return crawled, nil
+}
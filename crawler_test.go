@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/queue"
+	"github.com/jrokun/crawler/pkg/robots"
+	bolt "go.etcd.io/bbolt"
+)
+
+// testCrawler is a crawler of everything the start URL links to, queueing into a throwaway bolt file
+func testCrawler(t *testing.T, start url.URL, canonicalSteps string) (*crawler, func()) {
+	dir, err := ioutil.TempDir("", "grawler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "frontier.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := queue.New(db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalize, err := canonical.Parse(canonicalSteps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope, err := newCrawlScope(scopeAll, []url.URL{start})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := newEventBus()
+	c := &crawler{
+		client:         &http.Client{Timeout: 5 * time.Second},
+		pending:        &frontier{jobs, 1, time.Second},
+		canonical:      normalize,
+		routeFragments: strings.Contains(canonicalSteps, "route-fragment"),
+		rules:          robots.NewRulesIndex(nil),
+		scope:          scope,
+		events:         events,
+		stopWhenDone:   true,
+		stopped:        make(chan struct{}),
+		status:         newHealth(events),
+	}
+	return c, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// discovered crawls from start until nothing is left, returning every URL found on the way
+func discovered(t *testing.T, c *crawler, start url.URL) map[string]bool {
+	events, err := c.events.subscribe(100, overflowBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.manager(start, 10)
+
+	found := make(map[string]bool)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event, ok := event.(urlDiscovered); ok {
+				found[event.site.String()] = true
+			}
+		case <-c.stopped:
+			return found
+		case <-timeout:
+			t.Fatal("the crawl didn't finish")
+		}
+	}
+}
+
+func TestRouteFragmentsQueued(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<a href="#/about">About</a><a href="#!/contact">Contact</a><a href="#top">Top</a>`))
+		}
+	}))
+	defer server.Close()
+	start, _ := url.Parse(server.URL + "/")
+
+	c, cleanup := testCrawler(t, *start, keepRouteFragments(canonical.Default))
+	defer cleanup()
+	found := discovered(t, c, *start)
+
+	for _, route := range []string{"#/about", "#!/contact"} {
+		if !found[start.String()+route] {
+			t.Errorf("expected the %s route to be queued, found %v", route, found)
+		}
+	}
+	if found[start.String()+"#top"] {
+		t.Errorf("expected #top to be stripped, found %v", found)
+	}
+
+	// Without route fragments kept, every link leads back to the start URL
+	c, cleanup = testCrawler(t, *start, canonical.Default)
+	defer cleanup()
+	if found := discovered(t, c, *start); len(found) != 1 {
+		t.Errorf("expected only the start URL, found %v", found)
+	}
+}
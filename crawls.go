@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// crawlTagsFor is the tags a crawl is stored with, the site it started on, the day it started and its
+// config hash, along with any given with -tags, which take precedence
+func crawlTagsFor(spec string, start url.URL, started time.Time, config string) (map[string]string, error) {
+	tags := map[string]string{
+		"site":   start.Hostname(),
+		"date":   started.Format("2006-01-02"),
+		"config": config,
+	}
+	for _, tag := range strings.Split(spec, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		pair := strings.SplitN(tag, "=", 2)
+		if len(pair) != 2 || pair[0] == "" || strings.ContainsAny(tag, "+") {
+			return nil, fmt.Errorf("invalid tag %q for -tags, expected key=value without +", tag)
+		}
+		tags[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return tags, nil
+}
+
+// previousCrawl reads the content hashes of the pages of the latest crawl of a site stored at path by URL,
+// nil when there's no such crawl
+func previousCrawl(path string, site string) (map[string]string, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	crawls, err := sink.ListCrawls(path)
+	if err != nil {
+		return nil, err
+	}
+	latest := ""
+	found := false
+	for _, crawl := range crawls {
+		if crawl.Tags["site"] == site {
+			latest, found = crawl.Name, true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	records, _, err := sink.ReadCrawl(path, latest)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(records))
+	for _, record := range records {
+		if record.Error == "" {
+			hashes[record.URL] = record.ContentHash
+		}
+	}
+	return hashes, nil
+}
+
+// runCrawls implements `grawler crawls <crawl.db>`, listing the crawls stored in a database oldest first,
+// with the tags other commands can pick them by
+func runCrawls(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: grawler crawls <crawl.db>")
+		os.Exit(exitFatal)
+	}
+
+	crawls, err := sink.ListCrawls(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	for _, crawl := range crawls {
+		name := crawl.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Printf("%s\t%s\t%d pages, %d failed\t%s\n", name, crawl.Started.Format(time.RFC3339), crawl.Pages, crawl.Failed, formatTags(crawl.Tags))
+	}
+}
+
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + tags[key]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// runDiff implements `grawler diff <crawl.db> <crawl> <crawl>`, comparing two crawls stored in a database,
+// each picked by name or by tags like site=example.com+date=2021-03-01, with the latest matching crawl used
+// Pages only in the later crawl are listed with +, only in the earlier with -, and those whose status or
+// content changed with ~
+func runDiff(args []string) {
+	if len(args) != 3 {
+		fmt.Println("usage: grawler diff <crawl.db> <crawl> <crawl>")
+		os.Exit(exitFatal)
+	}
+
+	before, from, err := sink.ReadCrawl(args[0], args[1])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	after, to, err := sink.ReadCrawl(args[0], args[2])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	fmt.Printf("--- %s %s\n+++ %s %s\n", from.Name, formatTags(from.Tags), to.Name, formatTags(to.Tags))
+
+	changes := diffCrawls(before, after)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if len(changes) > 0 {
+		os.Exit(exitThreshold)
+	}
+}
+
+// diffCrawls lists how pages differ between two crawls, by URL
+func diffCrawls(before []sink.Record, after []sink.Record) []string {
+	earlier := make(map[string]sink.Record, len(before))
+	for _, record := range before {
+		earlier[record.URL] = record
+	}
+	later := make(map[string]sink.Record, len(after))
+	for _, record := range after {
+		later[record.URL] = record
+	}
+
+	urls := make([]string, 0, len(earlier)+len(later))
+	for pageURL := range earlier {
+		urls = append(urls, pageURL)
+	}
+	for pageURL := range later {
+		if _, ok := earlier[pageURL]; !ok {
+			urls = append(urls, pageURL)
+		}
+	}
+	sort.Strings(urls)
+
+	var changes []string
+	for _, pageURL := range urls {
+		old, wasThere := earlier[pageURL]
+		current, isThere := later[pageURL]
+		switch {
+		case !wasThere:
+			changes = append(changes, "+ "+pageURL+" "+strconv.Itoa(current.Status))
+		case !isThere:
+			changes = append(changes, "- "+pageURL+" "+strconv.Itoa(old.Status))
+		case old.Status != current.Status:
+			changes = append(changes, fmt.Sprintf("~ %s status %d -> %d", pageURL, old.Status, current.Status))
+		case old.ContentHash != "" && current.ContentHash != "" && old.ContentHash != current.ContentHash:
+			changes = append(changes, "~ "+pageURL+" content changed")
+		}
+	}
+	return changes
+}
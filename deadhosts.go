@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// Kinds of failure that mean a host is unreachable, rather than just serving errors
+const (
+	failureDNS        string = "dns"
+	failureConnection string = "connection"
+)
+
+type hostHealth struct {
+	failure   string
+	lastError string
+	failures  int
+	successes int
+
+	// Pages that linked to this host
+	referrers map[string]bool
+}
+
+// deadHostTracker watches for hosts that never resolve or never accept a connection
+// Unlike a 404 these produce no page at all, so they'd otherwise only show up as log lines
+type deadHostTracker struct {
+	mutex sync.Mutex
+	hosts map[string]*hostHealth
+
+	report *report.Report
+}
+
+func newDeadHostTracker() *deadHostTracker {
+	return &deadHostTracker{
+		hosts:  make(map[string]*hostHealth),
+		report: report.New("dead-hosts", "host", "failure", "attempts", "error", "linked from"),
+	}
+}
+
+func (tracker *deadHostTracker) observe(crawled page, crawlErr error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	// By hostname, as robots.txt failures are, so a host counts as one whatever port a page used
+	host, ok := tracker.hosts[crawled.Hostname()]
+	if !ok {
+		host = &hostHealth{referrers: make(map[string]bool)}
+		tracker.hosts[crawled.Hostname()] = host
+	}
+
+	failure := classifyHostFailure(crawlErr)
+	if failure == "" {
+		host.successes++
+		return
+	}
+
+	host.failures++
+	host.failure = failure
+	host.lastError = crawlErr.Error()
+	if crawled.referrer.Hostname() != "" {
+		host.referrers[crawled.referrer.String()] = true
+	}
+}
+
+// summarize fills the report with every host that failed and never once succeeded
+func (tracker *deadHostTracker) summarize() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	names := make([]string, 0, len(tracker.hosts))
+	for name := range tracker.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		host := tracker.hosts[name]
+		if host.failures == 0 || host.successes > 0 {
+			continue
+		}
+
+		referrers := make([]string, 0, len(host.referrers))
+		for referrer := range host.referrers {
+			referrers = append(referrers, referrer)
+		}
+		sort.Strings(referrers)
+
+		tracker.report.Add(name, host.failure, strconv.Itoa(host.failures), host.lastError, strings.Join(referrers, " "))
+	}
+}
+
+// classifyHostFailure works out whether an error means the host itself is unreachable
+func classifyHostFailure(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return failureDNS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return failureConnection
+	}
+
+	return ""
+}
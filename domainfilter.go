@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/jrokun/crawler/pkg/domains"
+)
+
+// domainFilter keeps the crawl away from domains, by an allowlist, a blocklist, or both
+// Links to filtered domains are dropped before robots.txt is fetched, so those domains see no traffic at all
+type domainFilter struct {
+	allowPath string
+	blockPath string
+
+	// Nil when there's no such list
+	mutex sync.RWMutex
+	allow *domains.List
+	block *domains.List
+}
+
+func newDomainFilter(allowPath string, blockPath string) (*domainFilter, error) {
+	filter := &domainFilter{allowPath: allowPath, blockPath: blockPath}
+	if err := filter.load(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// load reads both lists, leaving the current ones in place if either can't be read
+func (filter *domainFilter) load() error {
+	var allow, block *domains.List
+	if filter.allowPath != "" {
+		lines, err := readLines(filter.allowPath)
+		if err != nil {
+			return err
+		}
+		allow = domains.Parse(lines)
+	}
+	if filter.blockPath != "" {
+		lines, err := readLines(filter.blockPath)
+		if err != nil {
+			return err
+		}
+		block = domains.Parse(lines)
+	}
+
+	filter.mutex.Lock()
+	defer filter.mutex.Unlock()
+	filter.allow, filter.block = allow, block
+	return nil
+}
+
+// reloadOnHangup reloads the lists whenever the process receives SIGHUP
+func (filter *domainFilter) reloadOnHangup() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	go func() {
+		for range hangups {
+			if err := filter.load(); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println("Reloaded the domain allowlist and blocklist")
+		}
+	}()
+}
+
+// allows is true when host is on the allowlist, if there is one, and isn't on the blocklist
+func (filter *domainFilter) allows(host string) bool {
+	filter.mutex.RLock()
+	defer filter.mutex.RUnlock()
+
+	if filter.allow != nil && !filter.allow.Match(host) {
+		return false
+	}
+	return filter.block == nil || !filter.block.Match(host)
+}
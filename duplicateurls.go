@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/fingerprint"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// How far apart the SimHashes of two variants can be for them to count as the same content
+const variantMaxDistance int = 3
+
+// urlVariant is a crawled page, remembered to compare against other variants of its URL
+type urlVariant struct {
+	link    url.URL
+	content string
+
+	simHash    uint64
+	hasSimHash bool
+}
+
+// duplicateURLTracker reports pages served under URLs that only differ by a trailing slash, the case of
+// their path or an index file, when both answer 200 with the same or nearly the same content
+// The default canonicalization doesn't merge these, as they aren't always the same page
+type duplicateURLTracker struct {
+	mutex    sync.Mutex
+	seen     map[string]bool
+	variants map[string][]urlVariant
+
+	report *report.Report
+}
+
+func newDuplicateURLTracker() *duplicateURLTracker {
+	return &duplicateURLTracker{
+		seen:     make(map[string]bool),
+		variants: make(map[string][]urlVariant),
+		report:   report.New("duplicate-urls", "url", "duplicate of", "differs by", "content"),
+	}
+}
+
+func (tracker *duplicateURLTracker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || crawled.status != 200 {
+		return
+	}
+
+	variant := urlVariant{link: crawled.URL, content: fingerprint.Content(crawled.body)}
+	variant.simHash, variant.hasSimHash = fingerprint.SimHash(crawled.body)
+
+	key := canonical.Variants.Apply(crawled.URL)
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if tracker.seen[crawled.String()] {
+		return
+	}
+	tracker.seen[crawled.String()] = true
+
+	for _, other := range tracker.variants[key.String()] {
+		similarity, ok := variant.similarity(other)
+		if !ok {
+			continue
+		}
+
+		differences := canonical.Differences(crawled.URL, other.link)
+		tracker.report.Add(crawled.String(), other.link.String(), strings.Join(differences, " "), similarity)
+	}
+	tracker.variants[key.String()] = append(tracker.variants[key.String()], variant)
+}
+
+// similarity describes how alike two variants' content is, ok is false when they aren't alike at all
+func (variant urlVariant) similarity(other urlVariant) (string, bool) {
+	if variant.content == other.content {
+		return "identical", true
+	}
+
+	if variant.hasSimHash && other.hasSimHash {
+		if distance := fingerprint.Distance(variant.simHash, other.simHash); distance <= variantMaxDistance {
+			return "near-identical, " + strconv.Itoa(distance) + " bits apart", true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// endpointDiscoverer records URLs referenced from inline scripts without crawling them
+// Handy for mapping out the API surface a site's front end talks to
+type endpointDiscoverer struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newEndpointDiscoverer() *endpointDiscoverer {
+	return &endpointDiscoverer{
+		seen:   make(map[string]bool),
+		report: report.New("endpoints", "endpoint", "kind", "method", "found on"),
+	}
+}
+
+func (discoverer *endpointDiscoverer) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	for _, endpoint := range extract.Endpoints(crawled.body) {
+		resolved := endpoint.URL
+		if parsed, err := crawled.final.Parse(endpoint.URL); err == nil {
+			resolved = parsed.String()
+		}
+
+		discoverer.mutex.Lock()
+		seen := discoverer.seen[resolved]
+		discoverer.seen[resolved] = true
+		discoverer.mutex.Unlock()
+
+		if !seen {
+			discoverer.report.Add(resolved, endpoint.Kind, endpoint.Method, crawled.String())
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// crawlEvent is something that happened during a crawl, published on the eventBus
+type crawlEvent interface {
+	// Name of the event, also marks the types that are events
+	eventName() string
+}
+
+// urlDiscovered is published the first time a website is seen, before it is vetted
+type urlDiscovered struct {
+	site website
+}
+
+// fetchStarted is published when a worker starts crawling a website
+type fetchStarted struct {
+	site website
+}
+
+// fetchCompleted is published when a website was crawled successfully
+type fetchCompleted struct {
+	crawled page
+}
+
+// fetchUnchanged is published for a page found unchanged since an earlier crawl, either skipped or
+// answered 304 Not Modified, with the links it had then
+type fetchUnchanged struct {
+	crawled page
+}
+
+// fetchFailed is published when a website couldn't be crawled, or its host couldn't be reached at all
+type fetchFailed struct {
+	crawled page
+	err     error
+}
+
+// robotsDenied is published when robots.txt keeps us from crawling a website
+type robotsDenied struct {
+	site website
+
+	// Turned away for asking for a Crawl-delay over -maxCrawlDelay rather than by a rule
+	slow bool
+}
+
+// outputFlushed is published after every attempt to flush the output sinks
+type outputFlushed struct {
+	err error
+}
+
+// crawlFinished is published once the crawl has stopped, observed is closed once observers have seen
+// every event published before it
+type crawlFinished struct {
+	observed chan struct{}
+}
+
+func (urlDiscovered) eventName() string  { return "url discovered" }
+func (fetchStarted) eventName() string   { return "fetch started" }
+func (fetchCompleted) eventName() string { return "fetch completed" }
+func (fetchUnchanged) eventName() string { return "fetch unchanged" }
+func (fetchFailed) eventName() string    { return "fetch failed" }
+func (robotsDenied) eventName() string   { return "robots denied" }
+func (outputFlushed) eventName() string  { return "output flushed" }
+func (crawlFinished) eventName() string  { return "crawl finished" }
+
+// How long the end of a crawl waits for observers and subscribers to catch up on what was published
+const catchUpTimeout = 10 * time.Second
+
+// caughtUp tells when a subscriber has seen crawlFinished, so what it gathered is only summarized once
+// every event published during the crawl is out of its buffer
+type caughtUp struct {
+	once     *sync.Once
+	finished chan struct{}
+}
+
+func newCaughtUp() caughtUp {
+	return caughtUp{&sync.Once{}, make(chan struct{})}
+}
+
+// see is called with every event the subscriber receives
+func (c caughtUp) see(event crawlEvent) {
+	if _, ok := event.(crawlFinished); ok {
+		c.once.Do(func() { close(c.finished) })
+	}
+}
+
+// wait blocks until the subscriber has seen crawlFinished, or as long as observers are given
+func (c caughtUp) wait() {
+	select {
+	case <-c.finished:
+	case <-time.NewTimer(catchUpTimeout).C:
+	}
+}
+
+// How a subscriber that has fallen behind is treated
+const (
+	// Publishing waits for room in the subscriber's buffer
+	overflowBlock string = "block"
+
+	// Publishing waits too, but the crawler stops handing out work until the subscriber catches up
+	overflowSlow string = "slow"
+
+	// Events that don't fit in the subscriber's buffer are dropped
+	overflowDrop string = "drop"
+)
+
+// A slow subscriber whose buffer is at least this full holds up the crawl
+const congestedFill float64 = 0.75
+
+type subscription struct {
+	events chan crawlEvent
+	policy string
+
+	// Events dropped so far, only ever non-zero for overflowDrop
+	dropped int
+}
+
+// eventBus hands every published event to every subscriber, in the order they were published
+// What happens when a subscriber falls behind is up to its overflow policy
+type eventBus struct {
+	mutex         sync.RWMutex
+	subscriptions []*subscription
+
+	// Publishers only hold a read lock, so drop counts need their own
+	dropMutex sync.Mutex
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// subscribe returns a channel receiving every event published from now on, subject to policy
+func (bus *eventBus) subscribe(buffer int, policy string) (<-chan crawlEvent, error) {
+	switch policy {
+	case overflowBlock, overflowSlow, overflowDrop:
+	default:
+		return nil, fmt.Errorf("unknown overflow policy %q, expected %s, %s or %s", policy, overflowBlock, overflowSlow, overflowDrop)
+	}
+
+	sub := &subscription{events: make(chan crawlEvent, buffer), policy: policy}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.subscriptions = append(bus.subscriptions, sub)
+
+	return sub.events, nil
+}
+
+func (bus *eventBus) publish(event crawlEvent) {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for _, sub := range bus.subscriptions {
+		// Even a subscriber that drops events has to hear the crawl finished, or shutdown waits on it in vain
+		_, finished := event.(crawlFinished)
+		if sub.policy != overflowDrop || finished {
+			sub.events <- event
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			bus.countDrop(sub)
+		}
+	}
+}
+
+func (bus *eventBus) countDrop(sub *subscription) {
+	bus.dropMutex.Lock()
+	defer bus.dropMutex.Unlock()
+	sub.dropped++
+}
+
+// dropped is how many events have been dropped across every subscriber
+func (bus *eventBus) dropped() int {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	bus.dropMutex.Lock()
+	defer bus.dropMutex.Unlock()
+
+	dropped := 0
+	for _, sub := range bus.subscriptions {
+		dropped += sub.dropped
+	}
+	return dropped
+}
+
+// congested is true while any slow subscriber is falling behind
+func (bus *eventBus) congested() bool {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for _, sub := range bus.subscriptions {
+		if sub.policy == overflowSlow && isCongested(len(sub.events), cap(sub.events)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCongested(depth, capacity int) bool {
+	return capacity > 0 && float64(depth) >= congestedFill*float64(capacity)
+}
+
+// observeEvents hands every crawl attempt on the bus to the observers, one at a time
+func observeEvents(events <-chan crawlEvent, observers []crawlObserver) {
+	for event := range events {
+		var crawled page
+		var crawlErr error
+
+		switch event := event.(type) {
+		case fetchCompleted:
+			crawled = event.crawled
+		case fetchFailed:
+			crawled, crawlErr = event.crawled, event.err
+		case crawlFinished:
+			close(event.observed)
+			continue
+		default:
+			continue
+		}
+
+		for _, observe := range observers {
+			observe(crawled, crawlErr)
+		}
+	}
+}
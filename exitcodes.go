@@ -0,0 +1,16 @@
+package main
+
+// Exit codes of a crawl, so scripts and CI can tell how it went
+const (
+	// The crawl completed without going over any -fail-on threshold
+	exitClean int = 0
+
+	// The crawl finished, but a -fail-on threshold was exceeded, whether or not it was aborted
+	exitThreshold int = 1
+
+	// The configuration was invalid or the crawl couldn't be set up
+	exitFatal int = 2
+
+	// The crawl was stopped by a signal before it completed by itself, without exceeding a threshold
+	exitAborted int = 3
+)
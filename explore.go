@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+const exploreHelp string = `Commands:
+  pages [filter]   list pages, filtered by a status like 301 or a class like 3xx, or by text in the URL
+  open <n|url>     show a page from the last list, with the pages it links to
+  links            list the pages the current page links to
+  ref              jump to the page that linked to the current page
+  back             go back to the previous page
+  help             show this help
+  quit             leave`
+
+// explorer browses a stored crawl from the terminal, see runExplore
+type explorer struct {
+	pages    map[string]sink.Record
+	children map[string][]string
+	urls     []string
+
+	// The page being looked at, the pages visited before it, and the last list shown so it can be picked from by number
+	current string
+	history []string
+	listed  []string
+
+	out io.Writer
+}
+
+// runExplore implements `grawler explore <crawl> [selector]`, an interactive browser for a crawl's pages and
+// the links between them, read from the output of -output-format bolt (.db), jsonl (.jsonl) or sqlite (.sqlite)
+// A database holding several crawls is browsed at its latest, or the latest the selector picks by name or tags
+func runExplore(args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		fmt.Println("usage: grawler explore <crawl.db|crawl.jsonl|crawl.sqlite> [name|tag=value+...]")
+		os.Exit(exitFatal)
+	}
+
+	selector := ""
+	if len(args) == 2 {
+		selector = args[1]
+	}
+	records, err := readStoredCrawl(args[0], selector)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	explore := newExplorer(records, os.Stdout)
+	fmt.Printf("%d pages, type help for commands\n", len(explore.urls))
+	explore.run(os.Stdin)
+}
+
+func readStoredCrawl(path string, selector string) ([]sink.Record, error) {
+	if strings.HasSuffix(path, ".jsonl") {
+		if selector != "" {
+			return nil, fmt.Errorf("%s holds a single crawl, only bolt databases hold several to pick from", path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return sink.ReadJSONL(file)
+	}
+	if strings.HasSuffix(path, ".sqlite") {
+		if selector != "" {
+			return nil, fmt.Errorf("%s holds a single crawl, only bolt databases hold several to pick from", path)
+		}
+		return sink.ReadSQLite(path)
+	}
+	records, _, err := sink.ReadCrawl(path, selector)
+	return records, err
+}
+
+func newExplorer(records []sink.Record, out io.Writer) *explorer {
+	explore := &explorer{pages: make(map[string]sink.Record), children: make(map[string][]string), out: out}
+	for _, record := range records {
+		if _, ok := explore.pages[record.URL]; !ok {
+			explore.urls = append(explore.urls, record.URL)
+		}
+		explore.pages[record.URL] = record
+	}
+	sort.Strings(explore.urls)
+
+	for _, pageURL := range explore.urls {
+		if referrer := explore.pages[pageURL].Referrer; referrer != "" {
+			explore.children[referrer] = append(explore.children[referrer], pageURL)
+		}
+	}
+	return explore
+}
+
+// run reads commands until quit or the end of input
+func (explore *explorer) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(explore.out, "> ")
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			if !explore.command(fields[0], strings.Join(fields[1:], " ")) {
+				return
+			}
+		}
+		fmt.Fprint(explore.out, "> ")
+	}
+}
+
+// command runs one command, returning false once it's time to leave
+func (explore *explorer) command(name string, argument string) bool {
+	switch name {
+	case "pages", "ls":
+		explore.list(explore.filter(argument))
+	case "open", "o":
+		explore.open(argument)
+	case "links", "l":
+		if explore.current == "" {
+			fmt.Fprintln(explore.out, "No page open")
+			break
+		}
+		explore.list(explore.children[explore.current])
+	case "ref", "r":
+		referrer := explore.pages[explore.current].Referrer
+		if referrer == "" {
+			fmt.Fprintln(explore.out, "No referrer")
+			break
+		}
+		explore.show(referrer)
+	case "back", "b":
+		if len(explore.history) == 0 {
+			fmt.Fprintln(explore.out, "Nowhere to go back to")
+			break
+		}
+		previous := explore.history[len(explore.history)-1]
+		explore.history = explore.history[:len(explore.history)-1]
+		explore.display(previous)
+	case "help", "?":
+		fmt.Fprintln(explore.out, exploreHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(explore.out, "Unknown command %q, type help for commands\n", name)
+	}
+	return true
+}
+
+// filter picks the pages matching a status (301), a status class (3xx), or otherwise text found in the URL
+func (explore *explorer) filter(argument string) []string {
+	var matches []string
+	for _, pageURL := range explore.urls {
+		status := explore.pages[pageURL].Status
+		var match bool
+		switch {
+		case argument == "":
+			match = true
+		case len(argument) == 3 && strings.HasSuffix(argument, "xx"):
+			match = strconv.Itoa(status/100) == argument[:1]
+		default:
+			if code, err := strconv.Atoi(argument); err == nil {
+				match = status == code
+			} else {
+				match = strings.Contains(pageURL, argument)
+			}
+		}
+		if match {
+			matches = append(matches, pageURL)
+		}
+	}
+	return matches
+}
+
+// list numbers pages so they can be opened by number
+func (explore *explorer) list(urls []string) {
+	explore.listed = urls
+	if len(urls) == 0 {
+		fmt.Fprintln(explore.out, "No pages")
+		return
+	}
+	for i, pageURL := range urls {
+		fmt.Fprintf(explore.out, "%4d  %s  %s\n", i+1, statusLabel(explore.pages[pageURL].Status), pageURL)
+	}
+}
+
+// open shows a page by its number in the last list or by URL
+func (explore *explorer) open(argument string) {
+	if n, err := strconv.Atoi(argument); err == nil {
+		if n < 1 || n > len(explore.listed) {
+			fmt.Fprintf(explore.out, "No page %d in the last list\n", n)
+			return
+		}
+		argument = explore.listed[n-1]
+	}
+	explore.show(argument)
+}
+
+// show moves on to a page, remembering the current one for back
+func (explore *explorer) show(pageURL string) {
+	previous := explore.current
+	if explore.display(pageURL) && previous != "" && previous != pageURL {
+		explore.history = append(explore.history, previous)
+	}
+}
+
+// display makes a page current and prints it along with the pages it links to, false if it wasn't crawled
+func (explore *explorer) display(pageURL string) bool {
+	record, ok := explore.pages[pageURL]
+	if !ok {
+		fmt.Fprintf(explore.out, "%s wasn't crawled\n", pageURL)
+		return false
+	}
+	explore.current = pageURL
+
+	fmt.Fprintf(explore.out, "%s\n  status:   %s\n", record.URL, statusLabel(record.Status))
+	if record.Error != "" {
+		fmt.Fprintf(explore.out, "  error:    %s\n", record.Error)
+	}
+	if record.Referrer != "" {
+		fmt.Fprintf(explore.out, "  referrer: %s\n", record.Referrer)
+	}
+	if record.Relation != "" {
+		fmt.Fprintf(explore.out, "  relation: %s\n", record.Relation)
+	}
+	if !record.CrawledAt.IsZero() {
+		fmt.Fprintf(explore.out, "  crawled:  %s\n", record.CrawledAt.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(explore.out, "  links:    %d crawled pages\n", len(explore.children[pageURL]))
+	explore.list(explore.children[pageURL])
+	return true
+}
+
+func statusLabel(status int) string {
+	if status == 0 {
+		return "---"
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// externalChecker HEAD-checks out-of-scope links without crawling their content
+type externalChecker struct {
+	client *http.Client
+
+	links  chan website
+	report *report.Report
+}
+
+func newExternalChecker(client *http.Client, workers int, queueSize int) *externalChecker {
+	checker := &externalChecker{
+		client: client,
+		links:  make(chan website, queueSize),
+		report: report.New("outbound-links", "url", "status", "error", "linked from"),
+	}
+
+	for i := 0; i < workers; i++ {
+		go checker.run()
+	}
+	return checker
+}
+
+func (checker *externalChecker) check(link website) {
+	checker.links <- link
+}
+
+func (checker *externalChecker) run() {
+	for link := range checker.links {
+		status, err := headCheck(checker.client, link.String())
+
+		statusText, errText := "", ""
+		if status != 0 {
+			statusText = strconv.Itoa(status)
+		}
+		if err != nil {
+			errText = err.Error()
+		}
+
+		checker.report.Add(link.String(), statusText, errText, link.referrer.String())
+		if err != nil || status > 399 {
+			fmt.Printf("Broken outbound link %s (%s%s) on %s\n", link.String(), statusText, errText, link.referrer.String())
+		}
+	}
+}
+
+// headCheck requests just the headers for a link, falling back to GET for servers that don't allow HEAD
+func headCheck(client *http.Client, link string) (int, error) {
+	response, err := client.Head(link)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusMethodNotAllowed && response.StatusCode != http.StatusNotImplemented {
+		return response.StatusCode, nil
+	}
+
+	response, err = client.Get(link)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+
+	return response.StatusCode, nil
+}
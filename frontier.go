@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/queue"
+	bolt "go.etcd.io/bbolt"
+)
+
+// frontier is the persistent queue of vetted websites waiting to be crawled
+// Failed crawls are rescheduled on the same queue until they run out of attempts, then set aside
+// for `grawler retry-failures`
+type frontier struct {
+	jobs *queue.Queue
+
+	// How many times a website is attempted before we give up on it
+	maxAttempts int
+
+	// Base delay before a failed crawl is retried, doubled on every attempt
+	retryDelay time.Duration
+}
+
+// The serialized form of a website in the queue
+type frontierEntry struct {
+	URL      string        `json:"url"`
+	Referrer string        `json:"referrer,omitempty"`
+	Delay    time.Duration `json:"delay"`
+	Revisit  bool          `json:"revisit,omitempty"`
+	Relation string        `json:"relation,omitempty"`
+	Score    float64       `json:"score"`
+
+	// How the referrer links here, see extract.LinkPlacement
+	Links    int      `json:"links,omitempty"`
+	Sections []string `json:"sections,omitempty"`
+}
+
+// retryableError marks a crawl failure that may succeed if attempted again
+type retryableError struct {
+	error
+}
+
+func (err retryableError) Unwrap() error {
+	return err.error
+}
+
+func (f *frontier) push(site website, delay time.Duration) error {
+	entry := frontierEntry{URL: site.String(), Delay: delay, Revisit: site.revisit, Relation: site.relation, Score: site.score}
+	entry.Links, entry.Sections = site.placement.Count, site.placement.Sections
+	if site.referrer.Hostname() != "" {
+		entry.Referrer = site.referrer.String()
+	}
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return f.jobs.Push(payload, site.score)
+}
+
+// pop returns the next website to crawl along with how long to wait before crawling it
+func (f *frontier) pop() (queue.Job, website, time.Duration, bool, error) {
+	job, ok, err := f.jobs.Pop()
+	if err != nil || !ok {
+		return job, website{}, 0, ok, err
+	}
+
+	site, delay, err := decodeEntry(job.Payload)
+	if err != nil {
+		// There's no point handing out an entry we can't read
+		f.jobs.Ack(job.ID)
+		return job, site, delay, false, err
+	}
+
+	return job, site, delay, true, nil
+}
+
+// finish acknowledges a crawled job, or reschedules it if the failure is worth retrying
+func (f *frontier) finish(job queue.Job, site website, crawlErr error) error {
+	if _, retryable := crawlErr.(retryableError); !retryable {
+		return f.jobs.Ack(job.ID)
+	}
+
+	if job.Attempts >= f.maxAttempts {
+		fmt.Printf("Giving up on %s after %d attempts\n", site.String(), job.Attempts)
+		return f.jobs.Fail(job.ID)
+	}
+
+	delay := f.retryDelay * time.Duration(1<<uint(job.Attempts-1))
+	fmt.Printf("Retrying %s in %v\n", site.String(), delay)
+	return f.jobs.Retry(job.ID, delay)
+}
+
+// start restarts a job's visibility timeout once work on it begins, so the time spent waiting out its
+// delay beforehand doesn't count against it
+func (f *frontier) start(job queue.Job) error {
+	return f.jobs.Touch(job.ID)
+}
+
+// postpone puts a job that wasn't crawled back in the queue for after delay, without counting an attempt
+func (f *frontier) postpone(job queue.Job, delay time.Duration) error {
+	return f.jobs.Postpone(job.ID, delay)
+}
+
+// drained is true when nothing is waiting or in flight
+func (f *frontier) drained() bool {
+	ready, inflight, err := f.jobs.Len()
+	return err == nil && ready == 0 && inflight == 0
+}
+
+func decodeEntry(payload []byte) (website, time.Duration, error) {
+	var entry frontierEntry
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return website{}, 0, err
+	}
+
+	parsedURL, err := url.Parse(entry.URL)
+	if err != nil {
+		return website{}, 0, err
+	}
+	site := website{revisit: entry.Revisit, relation: entry.Relation, score: entry.Score, URL: *parsedURL}
+	site.placement = extract.LinkPlacement{Count: entry.Links, Sections: entry.Sections}
+
+	if entry.Referrer != "" {
+		referrer, err := url.Parse(entry.Referrer)
+		if err != nil {
+			return website{}, 0, err
+		}
+		site.referrer = *referrer
+	}
+
+	return site, entry.Delay, nil
+}
+
+// exportedEntry is a frontier entry as written by `grawler frontier export`, one JSON object per line
+type exportedEntry struct {
+	frontierEntry
+	Attempts  int       `json:"attempts,omitempty"`
+	NotBefore time.Time `json:"notBefore"`
+}
+
+// runFrontier implements `grawler frontier export` and `grawler frontier import`, which dump the
+// pending queue as JSON lines and load it back, so it can be inspected, edited or moved to another machine
+// The crawler holds the database open, so stop it first; it picks up where it left off when restarted.
+func runFrontier(args []string) {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fmt.Println("Usage: grawler frontier export|import [flags] [file]")
+		os.Exit(2)
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("frontier "+action, flag.ExitOnError)
+	dbPath := flags.String("db", "grawler.db", "BoltDB file holding the crawl frontier")
+	configPath := flags.String("config", "", "JSON file to read options from")
+	flags.Usage = configUsage(flags)
+	flags.Parse(args[1:])
+
+	if err := applyConfig(flags, *configPath); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	db, err := bolt.Open(*dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	// The visibility timeout only matters to a running crawl
+	jobs, err := queue.New(db, time.Minute)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Reads from stdin and writes to stdout unless given a file
+	path := flags.Arg(0)
+	if action == "export" {
+		out := os.Stdout
+		if path != "" {
+			if out, err = os.Create(path); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer out.Close()
+		}
+		err = exportFrontier(jobs, out)
+	} else {
+		in := os.Stdin
+		if path != "" {
+			if in, err = os.Open(path); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			defer in.Close()
+		}
+		err = importFrontier(jobs, in)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func exportFrontier(jobs *queue.Queue, out io.Writer) error {
+	all, err := jobs.Jobs()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	for _, job := range all {
+		exported := exportedEntry{Attempts: job.Attempts, NotBefore: job.NotBefore}
+		if err := json.Unmarshal(job.Payload, &exported.frontierEntry); err != nil {
+			return err
+		}
+		if err := encoder.Encode(exported); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importFrontier(jobs *queue.Queue, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+
+	imported := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry exportedEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		payload, err := json.Marshal(entry.frontierEntry)
+		if err != nil {
+			return err
+		}
+		// Catch bad URLs now rather than when the crawler gets to them
+		if _, _, err := decodeEntry(payload); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+
+		job := queue.Job{Payload: payload, Priority: entry.Score, Attempts: entry.Attempts, NotBefore: entry.NotBefore}
+		if err := jobs.Restore(job); err != nil {
+			return err
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %d entries\n", imported)
+	return nil
+}
@@ -1,9 +1,23 @@
 module github.com/jrokun/crawler
 
-go 1.13
+go 1.25.0
 
 require (
-	github.com/awalterschulze/gographviz v0.0.0-20190522210029-fa59802746ab
-	github.com/jackdanger/collectlinks v0.0.0-20160421202702-24c4ee2870ba
-	golang.org/x/net v0.0.0-20200202094626-16171245cfb2 // indirect
+	go.etcd.io/bbolt v1.3.6
+	go.starlark.net v0.0.0-20210223155950-e043a3d3c984
+	golang.org/x/crypto v0.54.0
+	golang.org/x/net v0.56.0
+	modernc.org/sqlite v1.58.0
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.24 // indirect
+	github.com/ncruces/go-strftime v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	modernc.org/libc v1.75.6 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.12.1 // indirect
 )
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/robots"
+)
+
+// Used by -googlebot in place of our own robots.txt token and User-Agent
+const (
+	googlebotAgent     string = "googlebot"
+	googlebotUserAgent string = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+// robotsComparison reports every in-scope URL that robots.txt treats differently for Googlebot than for us
+// With -googlebot the crawl follows Googlebot's rules, so ours are read from the same robots.txt
+type robotsComparison struct {
+	scope *crawlScope
+	rules robots.RulesView
+
+	// Our own rules by host, read once each, only ever used from watch so this doesn't need locking
+	grawler map[string]robots.CrawlRules
+
+	report *report.Report
+}
+
+func newRobotsComparison(c *crawler) *robotsComparison {
+	return &robotsComparison{
+		scope:   c.scope,
+		rules:   c.rules.View(),
+		grawler: make(map[string]robots.CrawlRules),
+		report:  report.New("robots-comparison", "url", googlebotAgent, robots.DefaultAgent),
+	}
+}
+
+// Every in-scope URL vetted is either turned away by robots.txt or crawled, by which time the crawl has its rules
+func (comparison *robotsComparison) watch(events <-chan crawlEvent) {
+	for event := range events {
+		switch event := event.(type) {
+		case robotsDenied:
+			comparison.compare(event.site)
+		case fetchCompleted:
+			if !event.crawled.revisit {
+				comparison.compare(event.crawled.website)
+			}
+		}
+	}
+}
+
+func (comparison *robotsComparison) compare(site website) {
+	if !comparison.scope.contains(site.URL) {
+		return
+	}
+	// Unreachable robots.txt files are already reported by the crawl itself
+	googlebotRules, ok := comparison.rules.Get(site.Hostname())
+	if !ok {
+		return
+	}
+	grawlerRules, ok := comparison.grawler[site.Hostname()]
+	if !ok {
+		grawlerRules = googlebotRules.ForAgent(robots.DefaultAgent)
+		comparison.grawler[site.Hostname()] = grawlerRules
+	}
+
+	googlebotAllowed, grawlerAllowed := googlebotRules.Test(site.Path), grawlerRules.Test(site.Path)
+	if googlebotAllowed != grawlerAllowed {
+		comparison.report.Add(site.String(), verdict(googlebotAllowed), verdict(grawlerAllowed))
+	}
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "disallowed"
+}
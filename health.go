@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/queue"
+	"github.com/jrokun/crawler/pkg/revisit"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// Crawl states reported by the health endpoints
+const (
+	stateStarting string = "starting"
+	stateCrawling string = "crawling"
+	stateStopping string = "stopping"
+)
+
+// health is the shared view of the crawler that the orchestration probes report on
+type health struct {
+	mutex sync.RWMutex
+
+	state   string
+	started time.Time
+	crawled int
+
+	// The crawled count is only final once the subscription has caught up
+	caughtUp caughtUp
+
+	// The queue of pages waiting to be vetted and the queue of vetted pages waiting to be crawled
+	// Both are set once the manager is running
+	frontier chan []website
+	jobs     *queue.Queue
+
+	// Result of the most recent attempt to flush output
+	lastFlush time.Time
+	sinkErr   error
+
+	// Tells whether output is falling behind
+	events *eventBus
+
+	// Where the status page finds upcoming revisits and earlier crawls, when there are any
+	revisits *revisit.Scheduler
+	stored   sink.Lister
+}
+
+type healthReport struct {
+	State    string         `json:"state"`
+	Uptime   string         `json:"uptime"`
+	Crawled  int            `json:"crawled"`
+	Frontier frontierReport `json:"frontier"`
+	Sink     sinkReport     `json:"sink"`
+}
+
+type frontierReport struct {
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Queued   int    `json:"queued"`
+	InFlight int    `json:"inFlight"`
+	Error    string `json:"error,omitempty"`
+	Healthy  bool   `json:"healthy"`
+}
+
+type sinkReport struct {
+	LastFlush *time.Time `json:"lastFlush,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Congested bool       `json:"congested"`
+	Dropped   int        `json:"dropped"`
+	Healthy   bool       `json:"healthy"`
+}
+
+func newHealth(events *eventBus) *health {
+	return &health{state: stateStarting, started: time.Now(), events: events, caughtUp: newCaughtUp()}
+}
+
+func (h *health) setState(state string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.state = state
+}
+
+func (h *health) setHistory(revisits *revisit.Scheduler, stored sink.Lister) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.revisits = revisits
+	h.stored = stored
+}
+
+func (h *health) setFrontier(frontier chan []website, jobs *queue.Queue) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.frontier = frontier
+	h.jobs = jobs
+}
+
+// watch keeps the crawl and flush stats up to date from the event bus
+func (h *health) watch(events <-chan crawlEvent) {
+	for event := range events {
+		switch event := event.(type) {
+		case fetchCompleted:
+			if !event.crawled.revisit {
+				h.recordCrawl()
+			}
+		case outputFlushed:
+			h.recordFlush(event.err)
+		}
+		h.caughtUp.see(event)
+	}
+}
+
+func (h *health) recordCrawl() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.crawled++
+}
+
+func (h *health) recordFlush(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lastFlush = time.Now()
+	h.sinkErr = err
+}
+
+func (h *health) report() healthReport {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	report := healthReport{
+		State:   h.state,
+		Uptime:  time.Since(h.started).Round(time.Second).String(),
+		Crawled: h.crawled,
+	}
+
+	// A frontier with no room left means the workers are going to start blocking
+	if h.frontier != nil {
+		report.Frontier.Depth = len(h.frontier)
+		report.Frontier.Capacity = cap(h.frontier)
+		report.Frontier.Healthy = report.Frontier.Depth < report.Frontier.Capacity
+	}
+
+	// An unreadable queue is just as bad
+	if h.jobs != nil {
+		queued, inFlight, err := h.jobs.Len()
+		report.Frontier.Queued, report.Frontier.InFlight = queued, inFlight
+		if err != nil {
+			report.Frontier.Error = err.Error()
+			report.Frontier.Healthy = false
+		}
+	}
+
+	report.Sink.Healthy = h.sinkErr == nil
+	if h.sinkErr != nil {
+		report.Sink.Error = h.sinkErr.Error()
+	}
+	if h.events != nil {
+		report.Sink.Congested = h.events.congested()
+		report.Sink.Dropped = h.events.dropped()
+	}
+	if !h.lastFlush.IsZero() {
+		lastFlush := h.lastFlush
+		report.Sink.LastFlush = &lastFlush
+	}
+
+	return report
+}
+
+// ready is true once the crawler is running with a frontier that has room and a working output sink
+func (report healthReport) ready() bool {
+	return report.State == stateCrawling && report.Frontier.Healthy && report.Sink.Healthy
+}
+
+// healthHandler serves the /healthz and /readyz probes, /frontier for debugging and /status for people
+// /healthz only fails once the crawler is shutting down, /readyz additionally requires ready()
+func healthHandler(h *health) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.report()
+		writeHealthReport(w, report, report.State != stateStopping)
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.report()
+		writeHealthReport(w, report, report.ready())
+	})
+
+	mux.HandleFunc("/frontier", frontierHandler(h))
+	mux.HandleFunc("/status", statusHandler(h))
+
+	return mux
+}
+
+// listen opens the listener for -listen, a host:port, with the host picking the interface, or unix:path
+// for a unix socket, replacing any socket left behind at path by an earlier run
+func listen(address string) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix:") {
+		return net.Listen("tcp", address)
+	}
+
+	path := strings.TrimPrefix(address, "unix:")
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// isClosedListener is whether err is http.Serve giving up because the listener was closed on exit
+func isClosedListener(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+func writeHealthReport(w http.ResponseWriter, report healthReport, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
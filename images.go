@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// imageReference is an image as used on one page
+type imageReference struct {
+	page  string
+	src   string
+	image extract.Image
+}
+
+// imageSize is what a HEAD request tells us about an image
+type imageSize struct {
+	status      int
+	bytes       int64
+	contentType string
+	err         error
+
+	// Set for images the crawl wouldn't go to, which aren't checked at all
+	unchecked bool
+}
+
+// imageAuditor inventories every image on every page, HEAD-checking each image once for its size,
+// and flags images without alt text and images bigger than maxBytes
+type imageAuditor struct {
+	client   *http.Client
+	side     sideRequests
+	maxBytes int64
+
+	mutex sync.Mutex
+	seen  map[string]bool
+	sizes map[string]imageSize
+
+	references chan imageReference
+	dropped    droppedWork
+	report     *report.Report
+}
+
+func newImageAuditor(client *http.Client, side sideRequests, maxBytes int64, workers int, queueSize int) *imageAuditor {
+	auditor := &imageAuditor{
+		client:     client,
+		side:       side,
+		maxBytes:   maxBytes,
+		seen:       make(map[string]bool),
+		sizes:      make(map[string]imageSize),
+		references: make(chan imageReference, queueSize),
+		report:     report.New("images", "image", "page", "alt", "width", "height", "bytes", "type", "status", "problems"),
+	}
+
+	for i := 0; i < workers; i++ {
+		go auditor.run()
+	}
+	return auditor
+}
+
+func (auditor *imageAuditor) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	auditor.mutex.Lock()
+	seen := auditor.seen[crawled.String()]
+	auditor.seen[crawled.String()] = true
+	auditor.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	for _, image := range extract.Images(crawled.body) {
+		resolved, err := crawled.final.Parse(image.Src)
+		if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+			continue
+		}
+		select {
+		case auditor.references <- imageReference{crawled.String(), resolved.String(), image}:
+		default:
+			auditor.dropped.add()
+		}
+	}
+}
+
+func (auditor *imageAuditor) run() {
+	for reference := range auditor.references {
+		size := auditor.size(reference.src)
+
+		var problems []string
+		if !reference.image.HasAlt {
+			problems = append(problems, "missing-alt")
+		}
+		if size.err != nil || size.status > 399 {
+			problems = append(problems, "broken")
+		}
+		if auditor.maxBytes > 0 && size.bytes > auditor.maxBytes {
+			problems = append(problems, "oversized")
+		}
+
+		auditor.report.Add(reference.src, reference.page, reference.image.Alt,
+			dimension(reference.image.Width), dimension(reference.image.Height),
+			dimension64(size.bytes), size.contentType, imageStatus(size), strings.Join(problems, " "))
+	}
+}
+
+// size HEAD-checks an image the first time it's seen, and remembers the answer after that
+func (auditor *imageAuditor) size(src string) imageSize {
+	auditor.mutex.Lock()
+	size, ok := auditor.sizes[src]
+	auditor.mutex.Unlock()
+	if ok {
+		return size
+	}
+
+	if parsed, err := url.Parse(src); err != nil || !auditor.side.allows(*parsed) {
+		return imageSize{unchecked: true}
+	}
+
+	response, err := auditor.client.Head(src)
+	if err != nil {
+		size = imageSize{err: err}
+	} else {
+		response.Body.Close()
+		size = imageSize{
+			status:      response.StatusCode,
+			bytes:       response.ContentLength,
+			contentType: response.Header.Get("Content-Type"),
+		}
+	}
+
+	auditor.mutex.Lock()
+	auditor.sizes[src] = size
+	auditor.mutex.Unlock()
+	return size
+}
+
+func imageStatus(size imageSize) string {
+	if size.unchecked {
+		return "not checked, outside the crawl"
+	}
+	if size.err != nil {
+		return size.err.Error()
+	}
+	return strconv.Itoa(size.status)
+}
+
+// dimension leaves unknown sizes blank rather than reporting them as zero
+func dimension(value int) string {
+	return dimension64(int64(value))
+}
+
+func dimension64(value int64) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// inlinkIndex remembers every page linking to each URL, rather than just the one it was first found on,
+// so reports can say everywhere a broken page is linked from and how many pages link to it
+type inlinkIndex struct {
+	canonical canonical.Chain
+
+	// How many referrers are kept per URL, every one is counted
+	max int
+
+	mutex     sync.Mutex
+	referrers map[string][]string
+	counts    map[string]int
+
+	// Only summarized once the subscription has caught up
+	caughtUp caughtUp
+
+	report *report.Report
+}
+
+func newInlinkIndex(normalize canonical.Chain, max int) *inlinkIndex {
+	return &inlinkIndex{
+		canonical: normalize,
+		max:       max,
+		referrers: make(map[string][]string),
+		counts:    make(map[string]int),
+		caughtUp:  newCaughtUp(),
+		report:    report.New("inlinks", "url", "inlinks", "linked from"),
+	}
+}
+
+func (index *inlinkIndex) watch(events <-chan crawlEvent) {
+	for event := range events {
+		// A page crawled again already counts as linking to everything it links to
+		if completed, ok := event.(fetchCompleted); ok && !completed.crawled.revisit {
+			index.add(completed.crawled)
+		}
+		index.caughtUp.see(event)
+	}
+}
+
+// add records the page as a referrer of everything it links to, once per target however often it links there
+func (index *inlinkIndex) add(crawled page) {
+	referrer := crawled.String()
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	linked := make(map[string]bool, len(crawled.links))
+	for _, link := range crawled.links {
+		if !crawlable(link.URL) {
+			continue
+		}
+		normalized := index.canonical.Apply(link.URL)
+		target := normalized.String()
+
+		if target == referrer || linked[target] {
+			continue
+		}
+		linked[target] = true
+
+		index.counts[target]++
+		if len(index.referrers[target]) < index.max {
+			index.referrers[target] = append(index.referrers[target], referrer)
+		}
+	}
+}
+
+// linkedFrom lists the pages linking to a URL for a report, falling back to the referrer a report
+// already knows of when no crawled page is known to link there, as with the start URL
+func (index *inlinkIndex) linkedFrom(pageURL string, fallback string) string {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	referrers := index.referrers[pageURL]
+	if len(referrers) == 0 {
+		return fallback
+	}
+	return strings.Join(referrers, " ")
+}
+
+// summarize fills the report with every URL linked to, the most linked first
+func (index *inlinkIndex) summarize() {
+	index.caughtUp.wait()
+
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	urls := make([]string, 0, len(index.counts))
+	for target := range index.counts {
+		urls = append(urls, target)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if index.counts[urls[i]] != index.counts[urls[j]] {
+			return index.counts[urls[i]] > index.counts[urls[j]]
+		}
+		return urls[i] < urls[j]
+	})
+
+	for _, target := range urls {
+		index.report.Add(target, strconv.Itoa(index.counts[target]), strings.Join(index.referrers[target], " "))
+	}
+}
+
+// runInlinks implements `grawler inlinks <crawl> <url> [selector]`, listing every crawled page linking to a
+// URL, read from the output of -output-format bolt (.db), jsonl (.jsonl) or sqlite (.sqlite), see runExplore
+// Crawls stored without -recordLinks only know the page each URL was first found on
+func runInlinks(args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		fmt.Println("usage: grawler inlinks <crawl.db|crawl.jsonl|crawl.sqlite> <url> [name|tag=value+...]")
+		os.Exit(exitFatal)
+	}
+
+	selector := ""
+	if len(args) == 3 {
+		selector = args[2]
+	}
+	records, err := readStoredCrawl(args[0], selector)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	target := normalizedArg(args[1])
+
+	linking := backlinks(records, target)
+	if len(linking) == 0 {
+		fmt.Printf("No crawled page links to %s\n", target)
+		os.Exit(exitThreshold)
+	}
+	for _, record := range linking {
+		fmt.Printf("%d %s\n", record.Status, record.URL)
+	}
+}
+
+// backlinks finds the records of every page linking to target, sorted by URL
+// Pages recorded without their links count as linking to the page they led the crawl to
+func backlinks(records []sink.Record, target string) []sink.Record {
+	pages := make(map[string]sink.Record, len(records))
+	for _, record := range records {
+		pages[record.URL] = record
+	}
+
+	linking := make(map[string]bool)
+	for _, record := range records {
+		for _, link := range record.Links {
+			if link == target && record.URL != target {
+				linking[record.URL] = true
+			}
+		}
+		if record.URL == target && record.Referrer != "" {
+			if referrer, ok := pages[record.Referrer]; !ok || referrer.Links == nil {
+				linking[record.Referrer] = true
+			}
+		}
+	}
+
+	found := make([]sink.Record, 0, len(linking))
+	for pageURL := range linking {
+		record, ok := pages[pageURL]
+		if !ok {
+			record = sink.Record{URL: pageURL}
+		}
+		found = append(found, record)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].URL < found[j].URL
+	})
+	return found
+}
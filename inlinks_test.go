@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// crawledPage is a page crawled fine at link, linking to every one of links
+func crawledPage(t *testing.T, link string, links ...string) page {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawled := page{website: website{URL: *parsed}, status: 200}
+	for _, link := range links {
+		if !crawled.addLink(link, "") {
+			t.Fatalf("Couldn't add link %s", link)
+		}
+	}
+	return crawled
+}
+
+func TestInlinksSummarizeCatchesUp(t *testing.T) {
+	normalize, err := canonical.Parse(canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := newInlinkIndex(normalize, 3)
+
+	bus := newEventBus()
+	events, err := bus.subscribe(1000, overflowBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go index.watch(events)
+
+	const pages = 500
+	for i := 0; i < pages; i++ {
+		bus.publish(fetchCompleted{crawled: crawledPage(t, fmt.Sprintf("http://example.com/%d", i), "http://example.com/target")})
+	}
+	bus.publish(crawlFinished{observed: make(chan struct{})})
+	index.summarize()
+
+	rows := index.report.Rows()
+	if len(rows) != 1 || rows[0][0] != "http://example.com/target" || rows[0][1] != fmt.Sprint(pages) {
+		t.Fatalf("Expected every page published before the finish to be counted, got %v", rows)
+	}
+	if rows[0][2] != "http://example.com/0 http://example.com/1 http://example.com/2" {
+		t.Errorf("Expected the first 3 referrers to be kept, got %s", rows[0][2])
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	records := []sink.Record{
+		{URL: "http://example.com/", Links: []string{"http://example.com/about", "http://example.com/about"}},
+		{URL: "http://example.com/about", Referrer: "http://example.com/", Links: []string{"http://example.com/about", "http://example.com/team"}},
+		{URL: "http://example.com/team", Referrer: "http://example.com/about"},
+		// Recorded without its links, so only known to link to the page it led the crawl to
+		{URL: "http://example.com/careers", Referrer: "http://example.com/team"},
+		{URL: "http://example.com/jobs", Referrer: "http://example.com/careers"},
+	}
+
+	for target, expected := range map[string][]string{
+		"http://example.com/about": {"http://example.com/"},
+		"http://example.com/team":  {"http://example.com/about"},
+		"http://example.com/jobs":  {"http://example.com/careers"},
+		"http://example.com/":      {},
+	} {
+		linking := backlinks(records, target)
+		found := make([]string, 0, len(linking))
+		for _, record := range linking {
+			found = append(found, record.URL)
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("Expected %s to be linked from %v, got %v", target, expected, found)
+		}
+	}
+}
+
+func TestNormalizedArg(t *testing.T) {
+	if normalized := normalizedArg("HTTP://Example.com:80/about?b=2&a=1#team"); normalized != "http://example.com/about?a=1&b=2" {
+		t.Errorf("Expected the URL normalized like the crawl's, got %s", normalized)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/queue"
+)
+
+// How many entries /frontier lists when no limit is asked for
+const defaultInspectLimit int = 50
+
+// Crawl-delays up to this long are what every host gets, longer ones are the host slowing us down
+const defaultCrawlDelay time.Duration = 1 * time.Second
+
+// frontierSnapshot is what /frontier reports, for debugging a crawl that seems stuck
+type frontierSnapshot struct {
+	// The entries that will be crawled next, in the order they'll be handed out
+	Top []inspectedEntry `json:"top"`
+
+	Hosts       []hostDepth       `json:"hosts"`
+	RateLimited []rateLimitedHost `json:"rateLimited"`
+}
+
+type inspectedEntry struct {
+	frontierEntry
+	State     string     `json:"state"`
+	Attempts  int        `json:"attempts,omitempty"`
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+}
+
+// hostDepth is how many of a host's entries are in each state
+type hostDepth struct {
+	Host      string `json:"host"`
+	Ready     int    `json:"ready"`
+	Scheduled int    `json:"scheduled"`
+	InFlight  int    `json:"inFlight"`
+}
+
+// rateLimitedHost is a host that's being crawled slower than usual, because its robots.txt asks
+// for a long Crawl-delay or because failed crawls are waiting to be retried
+type rateLimitedHost struct {
+	Host       string     `json:"host"`
+	CrawlDelay string     `json:"crawlDelay,omitempty"`
+	RetryAt    *time.Time `json:"retryAt,omitempty"`
+}
+
+// Queue entry states as reported by /frontier
+const (
+	entryReady     string = "ready"
+	entryScheduled string = "scheduled"
+	entryInFlight  string = "inFlight"
+)
+
+// jobState tells whether a job is waiting to be handed out, waiting on its NotBefore, or being crawled
+func jobState(job queue.Job, now time.Time) string {
+	switch {
+	case !job.Deadline.IsZero():
+		return entryInFlight
+	case job.NotBefore.After(now):
+		return entryScheduled
+	default:
+		return entryReady
+	}
+}
+
+// inspectFrontier snapshots the queue, listing at most limit of the entries that are up next
+func inspectFrontier(jobs *queue.Queue, limit int, now time.Time) (frontierSnapshot, error) {
+	snapshot := frontierSnapshot{Top: []inspectedEntry{}, Hosts: []hostDepth{}, RateLimited: []rateLimitedHost{}}
+
+	all, err := jobs.Jobs()
+	if err != nil {
+		return snapshot, err
+	}
+
+	depths := make(map[string]*hostDepth)
+	limited := make(map[string]*rateLimitedHost)
+	for _, job := range all {
+		var entry frontierEntry
+		if err := json.Unmarshal(job.Payload, &entry); err != nil {
+			continue
+		}
+		parsedURL, err := url.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+		host := parsedURL.Hostname()
+		state := jobState(job, now)
+
+		if len(snapshot.Top) < limit {
+			inspected := inspectedEntry{frontierEntry: entry, State: state, Attempts: job.Attempts}
+			if state == entryScheduled {
+				notBefore := job.NotBefore
+				inspected.NotBefore = &notBefore
+			}
+			snapshot.Top = append(snapshot.Top, inspected)
+		}
+
+		depth, ok := depths[host]
+		if !ok {
+			depth = &hostDepth{Host: host}
+			depths[host] = depth
+		}
+		switch state {
+		case entryReady:
+			depth.Ready++
+		case entryScheduled:
+			depth.Scheduled++
+		case entryInFlight:
+			depth.InFlight++
+		}
+
+		if entry.Delay <= defaultCrawlDelay && state != entryScheduled {
+			continue
+		}
+		slow, ok := limited[host]
+		if !ok {
+			slow = &rateLimitedHost{Host: host}
+			limited[host] = slow
+		}
+		if entry.Delay > defaultCrawlDelay {
+			slow.CrawlDelay = entry.Delay.String()
+		}
+		if state == entryScheduled && (slow.RetryAt == nil || job.NotBefore.Before(*slow.RetryAt)) {
+			retryAt := job.NotBefore
+			slow.RetryAt = &retryAt
+		}
+	}
+
+	for _, depth := range depths {
+		snapshot.Hosts = append(snapshot.Hosts, *depth)
+	}
+	// Deepest queues first, as those are the hosts holding the crawl up
+	sort.Slice(snapshot.Hosts, func(i, j int) bool {
+		a, b := snapshot.Hosts[i], snapshot.Hosts[j]
+		if total := a.Ready + a.Scheduled + a.InFlight; total != b.Ready+b.Scheduled+b.InFlight {
+			return total > b.Ready+b.Scheduled+b.InFlight
+		}
+		return a.Host < b.Host
+	})
+
+	for _, slow := range limited {
+		snapshot.RateLimited = append(snapshot.RateLimited, *slow)
+	}
+	sort.Slice(snapshot.RateLimited, func(i, j int) bool {
+		return snapshot.RateLimited[i].Host < snapshot.RateLimited[j].Host
+	})
+
+	return snapshot, nil
+}
+
+// frontierHandler serves /frontier, the snapshot of the queue, with ?limit= entries listed
+func frontierHandler(h *health) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultInspectLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				http.Error(w, "limit must be a number of entries", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		h.mutex.RLock()
+		jobs := h.jobs
+		h.mutex.RUnlock()
+		if jobs == nil {
+			http.Error(w, "the crawl hasn't started yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		snapshot, err := inspectFrontier(jobs, limit, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+	}
+}
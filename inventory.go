@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// What the crawl made of a URL, as far as the inventory comparison is concerned
+const (
+	inventoryNotDiscovered string = "not discovered"
+	inventoryNotCrawled    string = "not crawled"
+	inventoryDenied        string = "robots denied"
+	inventoryError         string = "error"
+	inventoryUnexpected    string = "unexpected"
+)
+
+type inventoryResult struct {
+	finding string
+	status  int
+	detail  string
+}
+
+// inventoryComparison compares the crawl against a list of the URLs a site is expected to have, such
+// as a CMS export, reporting expected URLs the crawl never found or couldn't fetch, and in-scope URLs
+// it found that weren't expected
+type inventoryComparison struct {
+	scope *crawlScope
+
+	mutex    sync.Mutex
+	expected map[string]bool
+	// Every in-scope URL discovered, with what happened to it, empty while it's waiting to be crawled
+	results map[string]inventoryResult
+
+	// Only summarized once the subscription has caught up
+	caughtUp caughtUp
+
+	report *report.Report
+}
+
+// newInventoryComparison expects every URL listed, normalized with the crawl's canonicalization
+func newInventoryComparison(c *crawler, urls []string) *inventoryComparison {
+	comparison := &inventoryComparison{
+		scope:    c.scope,
+		expected: make(map[string]bool),
+		results:  make(map[string]inventoryResult),
+		caughtUp: newCaughtUp(),
+		report:   report.New("inventory", "url", "finding", "status", "detail"),
+	}
+
+	for _, link := range urls {
+		parsedURL, err := url.Parse(link)
+		if err != nil || !crawlable(*parsedURL) {
+			continue
+		}
+		normalized := c.canonical.Apply(*parsedURL)
+		comparison.expected[normalized.String()] = true
+	}
+	return comparison
+}
+
+func (comparison *inventoryComparison) watch(events <-chan crawlEvent) {
+	for event := range events {
+		comparison.mutex.Lock()
+		switch event := event.(type) {
+		case urlDiscovered:
+			if comparison.scope.contains(event.site.URL) || comparison.expected[event.site.String()] {
+				comparison.results[event.site.String()] = inventoryResult{finding: inventoryNotCrawled}
+			}
+		case robotsDenied:
+			comparison.record(event.site.String(), inventoryResult{finding: inventoryDenied})
+		case fetchFailed:
+			result := inventoryResult{finding: inventoryError, status: event.crawled.status}
+			if event.err != nil {
+				result.detail = event.err.Error()
+			}
+			comparison.record(event.crawled.String(), result)
+		case fetchCompleted:
+			comparison.record(event.crawled.String(), inventoryResult{status: event.crawled.status})
+		}
+		comparison.mutex.Unlock()
+		comparison.caughtUp.see(event)
+	}
+}
+
+// record keeps the latest result of URLs being tracked
+func (comparison *inventoryComparison) record(pageURL string, result inventoryResult) {
+	if _, ok := comparison.results[pageURL]; ok {
+		comparison.results[pageURL] = result
+	}
+}
+
+// summarize fills the report with every expected URL that wasn't crawled successfully, then every unexpected one
+func (comparison *inventoryComparison) summarize() {
+	comparison.caughtUp.wait()
+
+	comparison.mutex.Lock()
+	defer comparison.mutex.Unlock()
+
+	var expected, unexpected []string
+	for pageURL := range comparison.expected {
+		expected = append(expected, pageURL)
+	}
+	for pageURL := range comparison.results {
+		if !comparison.expected[pageURL] {
+			unexpected = append(unexpected, pageURL)
+		}
+	}
+	sort.Strings(expected)
+	sort.Strings(unexpected)
+
+	for _, pageURL := range expected {
+		result, ok := comparison.results[pageURL]
+		if !ok {
+			result = inventoryResult{finding: inventoryNotDiscovered}
+		}
+		if result.finding != "" {
+			comparison.add(pageURL, result)
+		}
+	}
+	for _, pageURL := range unexpected {
+		result := comparison.results[pageURL]
+		result.finding = inventoryUnexpected
+		comparison.add(pageURL, result)
+	}
+}
+
+func (comparison *inventoryComparison) add(pageURL string, result inventoryResult) {
+	status := ""
+	if result.status != 0 {
+		status = strconv.Itoa(result.status)
+	}
+	comparison.report.Add(pageURL, result.finding, status, result.detail)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/revisit"
+	"github.com/jrokun/crawler/pkg/score"
+	"github.com/jrokun/crawler/pkg/sitemap"
+)
+
+// sitemapLastmods reads the <lastmod> dates in each host's sitemaps so pages an earlier crawl already
+// fetched since they last changed can be skipped, see -sitemapLastmod
+// The pages the sitemaps list are crawled too, since skipping pages means missing the links on them
+type sitemapLastmods struct {
+	client    *http.Client
+	canonical canonical.Chain
+	history   *revisit.Scheduler
+
+	mutex    sync.Mutex
+	loaded   map[string]bool
+	lastmods map[string]time.Time
+	listed   map[string]bool
+
+	// Pages held back by host while the host's sitemaps load
+	waiting map[string][]website
+}
+
+func newSitemapLastmods(client *http.Client, normalize canonical.Chain, history *revisit.Scheduler) *sitemapLastmods {
+	return &sitemapLastmods{
+		client:    client,
+		canonical: normalize,
+		history:   history,
+		loaded:    make(map[string]bool),
+		lastmods:  make(map[string]time.Time),
+		listed:    make(map[string]bool),
+		waiting:   make(map[string][]website),
+	}
+}
+
+// wait is whether a host's sitemaps are loaded, so its pages can be checked against them
+// Until they are the page is held back, and the first page of a host starts loading them in the background,
+// returning a channel that delivers the pages they list along with every page held back in the meantime
+func (lastmods *sitemapLastmods) wait(site website, locations []string) (bool, <-chan []website) {
+	lastmods.mutex.Lock()
+	defer lastmods.mutex.Unlock()
+
+	if lastmods.loaded[site.Host] {
+		return true, nil
+	}
+	held, loading := lastmods.waiting[site.Host]
+	site.waited = true
+	lastmods.waiting[site.Host] = append(held, site)
+	if loading {
+		return false, nil
+	}
+
+	batch := make(chan []website, 1)
+	go func() { batch <- lastmods.load(site.URL, locations) }()
+	return false, batch
+}
+
+// load reads a host's sitemaps, returning the pages they list and those held back while they loaded
+// Without a Sitemap directive in robots.txt, the conventional location is tried
+func (lastmods *sitemapLastmods) load(site url.URL, locations []string) []website {
+	if len(locations) == 0 {
+		root := url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/sitemap.xml"}
+		locations = []string{root.String()}
+	}
+	entries, err := sitemap.Fetch(lastmods.client, locations)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	lastmods.mutex.Lock()
+	defer lastmods.mutex.Unlock()
+
+	listed := make([]website, 0, len(entries))
+	for _, entry := range entries {
+		parsedURL, err := url.Parse(entry.URL)
+		if err != nil || !crawlable(*parsedURL) {
+			continue
+		}
+		normalized := lastmods.canonical.Apply(*parsedURL)
+
+		lastmods.listed[normalized.String()] = true
+		if !entry.LastModified.IsZero() {
+			lastmods.lastmods[normalized.String()] = entry.LastModified
+		}
+		listed = append(listed, website{relation: relationSitemap, score: score.Neutral, URL: normalized})
+	}
+
+	lastmods.loaded[site.Host] = true
+	listed = append(listed, lastmods.waiting[site.Host]...)
+	delete(lastmods.waiting, site.Host)
+	return listed
+}
+
+// unchanged is whether an earlier crawl fetched a page after the lastmod its sitemap gives it, along
+// with the links the page had then
+func (lastmods *sitemapLastmods) unchanged(pageURL string) ([]revisit.Link, bool) {
+	lastmods.mutex.Lock()
+	lastmod, ok := lastmods.lastmods[pageURL]
+	lastmods.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	record, found, err := lastmods.history.Lookup(pageURL)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+	return record.Links, found && !record.LastCrawled.Before(lastmod)
+}
+
+// links are the links a page had when an earlier crawl last fetched it, for when it comes back unchanged
+func (lastmods *sitemapLastmods) links(pageURL string) []revisit.Link {
+	record, _, err := lastmods.history.Lookup(pageURL)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return record.Links
+}
+
+// ifModifiedSince is when an earlier crawl last fetched a page listed in a sitemap, so the server can
+// answer 304 Not Modified when a lastmod claimed a change that didn't happen, as sitemaps stamping
+// every page with the day they were generated do
+func (lastmods *sitemapLastmods) ifModifiedSince(pageURL string) (time.Time, bool) {
+	lastmods.mutex.Lock()
+	listed := lastmods.listed[pageURL]
+	lastmods.mutex.Unlock()
+	if !listed {
+		return time.Time{}, false
+	}
+
+	record, found, err := lastmods.history.Lookup(pageURL)
+	if err != nil || !found {
+		return time.Time{}, false
+	}
+	return record.LastCrawled, true
+}
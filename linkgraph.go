@@ -0,0 +1,165 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/graph"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// How many pages of a component the link-components report lists
+const componentListed = 20
+
+// linkGraph keeps the links between crawled pages to find where visitors get stuck, see -graphAnalysis:
+// pages with no links onward, pages that can't be reached from the start by clicking, and groups of pages
+// that all link round to each other
+type linkGraph struct {
+	canonical canonical.Chain
+	start     string
+
+	mutex  sync.Mutex
+	links  graph.Links
+	status map[string]int
+	html   map[string]bool
+	found  map[string]string
+
+	// Only summarized once the subscription has caught up
+	caughtUp caughtUp
+
+	deadEnds    *report.Report
+	unreachable *report.Report
+	components  *report.Report
+}
+
+func newLinkGraph(normalize canonical.Chain, start string) *linkGraph {
+	return &linkGraph{
+		canonical:   normalize,
+		start:       start,
+		links:       make(graph.Links),
+		status:      make(map[string]int),
+		html:        make(map[string]bool),
+		found:       make(map[string]string),
+		caughtUp:    newCaughtUp(),
+		deadEnds:    report.New("dead-ends", "url", "status"),
+		unreachable: report.New("unreachable", "url", "cluster", "cluster size", "found via"),
+		components:  report.New("link-components", "component", "pages", "urls"),
+	}
+}
+
+func (links *linkGraph) watch(events <-chan crawlEvent) {
+	for event := range events {
+		if completed, ok := event.(fetchCompleted); ok && !completed.crawled.revisit {
+			links.add(completed.crawled)
+		}
+		links.caughtUp.see(event)
+	}
+}
+
+func (links *linkGraph) add(crawled page) {
+	pageURL := crawled.String()
+	found := crawled.relation
+	switch {
+	case found != "":
+	case crawled.referrer.Host != "":
+		found = "link"
+	default:
+		found = "seed"
+	}
+
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	links.links[pageURL] = outlinks(crawled, links.canonical)
+	links.status[pageURL] = crawled.status
+	links.html[pageURL] = crawled.html
+	links.found[pageURL] = found
+}
+
+// summarize fills the reports in from the links between the pages crawled
+func (links *linkGraph) summarize() {
+	links.caughtUp.wait()
+
+	links.mutex.Lock()
+	defer links.mutex.Unlock()
+
+	pages := make([]string, 0, len(links.links))
+	for pageURL := range links.links {
+		pages = append(pages, pageURL)
+	}
+	sort.Strings(pages)
+
+	// Only links between crawled pages say anything about how the crawled site hangs together
+	crawled := make(graph.Links, len(links.links))
+	for _, pageURL := range pages {
+		crawled[pageURL] = []string{}
+		for _, target := range links.links[pageURL] {
+			if _, ok := links.links[target]; ok && target != pageURL {
+				crawled[pageURL] = append(crawled[pageURL], target)
+			}
+		}
+	}
+
+	// Files like images and PDFs have nowhere to link to, so only pages can be dead ends
+	for _, pageURL := range pages {
+		if links.html[pageURL] && links.status[pageURL]/100 == 2 && len(links.links[pageURL]) == 0 {
+			links.deadEnds.Add(pageURL, strconv.Itoa(links.status[pageURL]))
+		}
+	}
+
+	reached := graph.Reachable(crawled, links.start)
+	links.clusterUnreachable(pages, crawled, reached)
+
+	component := 0
+	for _, members := range graph.StronglyConnected(crawled) {
+		if len(members) < 2 {
+			break
+		}
+		component++
+		listed := members
+		if len(listed) > componentListed {
+			listed = append(listed[:componentListed:componentListed], "and "+strconv.Itoa(len(members)-componentListed)+" more")
+		}
+		links.components.Add(strconv.Itoa(component), strconv.Itoa(len(members)), strings.Join(listed, " "))
+	}
+}
+
+// clusterUnreachable reports the pages that can't be reached from the start, grouped by the links between them
+// whichever way they go, so a section only reached through a sitemap shows up as one cluster
+func (links *linkGraph) clusterUnreachable(pages []string, crawled graph.Links, reached map[string]bool) {
+	either := make(graph.Links)
+	for _, pageURL := range pages {
+		if reached[pageURL] {
+			continue
+		}
+		for _, target := range crawled[pageURL] {
+			if !reached[target] {
+				either[pageURL] = append(either[pageURL], target)
+				either[target] = append(either[target], pageURL)
+			}
+		}
+	}
+
+	cluster := 0
+	clustered := make(map[string]bool)
+	for _, pageURL := range pages {
+		if reached[pageURL] || clustered[pageURL] {
+			continue
+		}
+		cluster++
+
+		members := graph.Reachable(either, pageURL)
+		sorted := make([]string, 0, len(members))
+		for member := range members {
+			clustered[member] = true
+			sorted = append(sorted, member)
+		}
+		sort.Strings(sorted)
+		for _, member := range sorted {
+			links.unreachable.Add(member, strconv.Itoa(cluster), strconv.Itoa(len(members)), links.found[member])
+		}
+	}
+}
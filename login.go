@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// loginStep is a form submitted before the crawl starts, so the session cookies it sets are
+// sent along with every request to that site
+//
+// Values may refer to environment variables as $NAME or ${NAME}, which keeps passwords out of the file:
+//
+//	[{"url": "https://example.com/login", "form": {"user": "me", "password": "$SITE_PASSWORD"}, "cookie": "session"}]
+type loginStep struct {
+	URL  string            `json:"url"`
+	Form map[string]string `json:"form"`
+
+	// When set, the login only counts as successful if a cookie of this name was set for the site
+	Cookie string `json:"cookie,omitempty"`
+}
+
+func readLoginSteps(path string) ([]loginStep, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []loginStep
+	if err := json.Unmarshal(contents, &steps); err != nil {
+		return nil, fmt.Errorf("login steps %s: %v", path, err)
+	}
+	return steps, nil
+}
+
+// login submits every step's form in order, failing on the first that doesn't succeed
+// The client needs a cookie jar to hold on to the session afterwards
+func login(client *http.Client, steps []loginStep) error {
+	for _, step := range steps {
+		form := url.Values{}
+		for name, value := range step.Form {
+			form.Set(name, os.ExpandEnv(value))
+		}
+
+		response, err := client.PostForm(os.ExpandEnv(step.URL), form)
+		if err != nil {
+			return fmt.Errorf("login to %s: %v", step.URL, err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode > 399 {
+			return fmt.Errorf("login to %s: status code %d", step.URL, response.StatusCode)
+		}
+
+		if step.Cookie != "" && !hasCookie(client, response.Request.URL, step.Cookie) {
+			return fmt.Errorf("login to %s: no %s cookie was set", step.URL, step.Cookie)
+		}
+
+		fmt.Printf("Logged in to %s\n", response.Request.URL.Hostname())
+	}
+
+	return nil
+}
+
+func hasCookie(client *http.Client, site *url.URL, name string) bool {
+	for _, cookie := range client.Jar.Cookies(site) {
+		if strings.EqualFold(cookie.Name, name) {
+			return true
+		}
+	}
+	return false
+}
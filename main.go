@@ -3,250 +3,1427 @@ package main
 import (
 	"flag"
 	"fmt"
-	"hash/fnv"
-	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/signal"
-	"sync"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/awalterschulze/gographviz"
-	"github.com/jackdanger/collectlinks"
+	"github.com/jrokun/crawler/pkg/breaker"
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/cdx"
+	"github.com/jrokun/crawler/pkg/document"
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/fingerprint"
+	"github.com/jrokun/crawler/pkg/headless"
+	"github.com/jrokun/crawler/pkg/hoststats"
+	"github.com/jrokun/crawler/pkg/netguard"
+	"github.com/jrokun/crawler/pkg/notify"
+	"github.com/jrokun/crawler/pkg/queue"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/resolve"
+	"github.com/jrokun/crawler/pkg/revisit"
 	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/robotshistory"
+	"github.com/jrokun/crawler/pkg/score"
+	"github.com/jrokun/crawler/pkg/secrets"
+	"github.com/jrokun/crawler/pkg/sink"
+	"github.com/jrokun/crawler/pkg/soft404"
+	"github.com/jrokun/crawler/pkg/trend"
+	"github.com/jrokun/crawler/pkg/tunnel"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/net/publicsuffix"
 )
 
 const userAgent string = "Grawler"
 
-type headerTransport struct{}
+type headerTransport struct {
+	// Sent instead of userAgent when set
+	userAgent string
+
+	// Makes the actual requests, http.DefaultTransport when nil
+	base http.RoundTripper
+}
 
 func (transport *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("User-Agent", userAgent)
-	return http.DefaultTransport.RoundTrip(req)
+	agent := transport.userAgent
+	if agent == "" {
+		agent = userAgent
+	}
+	req.Header.Add("User-Agent", agent)
+
+	base := transport.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
 }
 
+// Relations between a referrer and the website it led to, other than a plain link
+const (
+	relationRefresh   string = "refresh"
+	relationFrame     string = "frame"
+	relationAMP       string = "amphtml"
+	relationAlternate string = "alternate"
+	relationNext      string = "next"
+	relationPrev      string = "prev"
+	relationSitemap   string = "sitemap"
+	relationDocument  string = "document"
+)
+
 type website struct {
 	referrer url.URL
 
+	// Set when this is a scheduled recrawl of a page we've already visited
+	revisit bool
+
+	// Set when the page was held back while its host's sitemaps loaded, see sitemapLastmods
+	waited bool
+
+	// How the referrer led here, empty for a plain link
+	relation string
+
+	// How many times, and from which sections, the referrer links here
+	placement extract.LinkPlacement
+
+	// Higher scoring websites are crawled first
+	score float64
+
 	url.URL
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "monitor":
+			runMonitor(os.Args[2:])
+			return
+		case "frontier":
+			runFrontier(os.Args[2:])
+			return
+		case "retry-failures":
+			os.Args = retryFailuresArgs(os.Args)
+		case "check":
+			os.Args = checkArgs(os.Args)
+		case "explore":
+			runExplore(os.Args[2:])
+			return
+		case "crawls":
+			runCrawls(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "inlinks":
+			runInlinks(os.Args[2:])
+			return
+		case "path":
+			runPath(os.Args[2:])
+			return
+		}
+	}
+
 	firstURL := flag.String("start", "https://crawler-test.com/", "First website to crawl")
 	queueSize := flag.Int("queueSize", 100, "Size of the backing queues")
+	configPath := flag.String("config", "", "JSON file to read options from")
+	asGooglebot := flag.Bool("googlebot", false, "Crawl as Googlebot, following its robots.txt rules and sending its User-Agent, and report URLs it's treated differently on. Only use this on sites you own")
+	loginPath := flag.String("login", "", "JSON file listing forms to submit before crawling, whose session cookies are then sent with every request")
+	listenAddr := flag.String("listen", "", "Address to serve /healthz, /readyz, /frontier and the /status page on: host:port, with a host like 127.0.0.1 binding just that interface, or unix:/path/to.sock for a unix socket; disabled when empty")
+	noListeners := flag.Bool("noListeners", false, "Never listen for connections, whatever -listen says, for locked-down environments; best set in the -config file")
+	dbPath := flag.String("db", "grawler.db", "BoltDB file holding the crawl frontier")
+	maxAttempts := flag.Int("retries", 3, "How many times to attempt a page before giving up on it")
+	robotsPrefetch := flag.Int("robotsPrefetch", 8, "How many robots.txt files of the hosts the crawl starts on, like those -discoverSeeds finds, to fetch at once before crawling begins; 0 fetches each as it's first reached")
+	maxCrawlDelay := flag.Duration("maxCrawlDelay", robots.DefaultMaxDelay, "Longest robots.txt Crawl-delay to honor")
+	overMaxCrawlDelay := flag.String("overMaxCrawlDelay", "clamp", "What to do with hosts asking for a Crawl-delay over -maxCrawlDelay: clamp (crawl them at -maxCrawlDelay) or skip (don't crawl them)")
+	retryDelay := flag.Duration("retryDelay", 10*time.Second, "Delay before retrying a failed page, doubled on each attempt")
+	visibilityTimeout := flag.Duration("visibilityTimeout", time.Minute, "How long a page can be in flight, from when its crawl starts after any Crawl-delay, before it is handed to another worker")
+	revisitPages := flag.Bool("revisit", false, "Keep recrawling pages as they come due instead of crawling each only once")
+	revisitMin := flag.Duration("revisitMin", 10*time.Minute, "Shortest interval between recrawls of a page")
+	sitemapLastmod := flag.Bool("sitemapLastmod", false, "Crawl the pages each host's sitemaps list, skipping those an earlier crawl with the same -db fetched since their <lastmod> and asking for the rest it fetched with If-Modified-Since")
+	skipStale := flag.Duration("skipStale", 0, "Skip pages last modified longer ago than this that were unchanged the last time an earlier crawl with the same -db fetched them, rechecking each once its last crawl is that old too; 0 fetches everything")
+	revisitMax := flag.Duration("revisitMax", 24*time.Hour, "Longest interval between recrawls of a page")
+	reportDir := flag.String("reportDir", ".", "Directory reports are written to on exit")
+	checkWayback := flag.Bool("wayback", false, "Check whether each crawled or broken page has a Wayback Machine snapshot")
+	saveWayback := flag.Bool("waybackSave", false, "Submit pages without a snapshot to the Wayback Machine, implies -wayback")
+	headlessPath := flag.String("headless", "", "Path to a Chrome/Chromium binary used to render pages before extracting links, disabled when empty")
+	headlessChallenges := flag.Bool("headlessChallenges", false, "Only use -headless for pages that come back as bot challenges, fetching them again in the browser, instead of rendering every page")
+	spaRoutes := flag.Bool("spaRoutes", false, "Crawl single page app routes like #/about and #!/about as pages of their own instead of stripping the fragment, requires -headless")
+	screenshotDir := flag.String("screenshots", "", "Directory to save a screenshot of every page to, requires -headless")
+	canonicalSteps := flag.String("canonicalize", canonical.Default, fmt.Sprintf("Comma separated steps normalizing every URL before it's crawled, from %v, with arguments after colons like drop-query:page", canonical.Names()))
+	querySignificance := flag.String("query", "keep", "Whether query strings make pages different, for crawling each once and for graph node IDs: keep, drop, or keep:id:page for only those parameters; either for every host or as a comma separated list of host=setting, where a bare setting applies to the other hosts")
+	hostAliases := flag.String("hostAliases", "", "Comma separated alias=host pairs like www.example.com=example.com, crawling and reporting the aliases as the host they stand for")
+	scopeMode := flag.String("scope", scopeAll, "Which links to crawl: all, host (the start URL's host) or domain (the start URL's domain)")
+	sshServer := flag.String("sshTunnel", "", "Crawl through an SSH tunnel to user@host[:port], for internal sites only reachable from a bastion host; hostnames are resolved by the bastion and -allowPrivateNetworks doesn't apply to the connections it makes")
+	sshKey := flag.String("sshKey", "", "Private key to log in to -sshTunnel with, the keys in ssh-agent when empty")
+	sshKnownHosts := flag.String("sshKnownHosts", "", "known_hosts file the -sshTunnel host key must be in, ~/.ssh/known_hosts when empty")
+	sshForward := flag.String("sshForward", "", "Send every connection through -sshTunnel to this host:port, like ssh -L, rather than to the address each URL is on")
+	resolveSpec := flag.String("resolve", "", "Connect to these addresses instead of what DNS says, like curl's --resolve, given as host:ip or host:port:ip separated by commas, e.g. www.example.com:10.0.0.5 to crawl staging under the production hostname. Connections to these addresses are allowed without -allowPrivateNetworks")
+	allowPrivateNetworks := flag.Bool("allowPrivateNetworks", false, "Crawl URLs resolving to loopback, private and link-local addresses, for intranet crawls; refused by default so untrusted links can't reach internal services")
+	allowDomains := flag.String("allow-domains", "", "File of the only domains to crawl, one per line, with *.example.com matching every subdomain of example.com; reloaded on SIGHUP")
+	blockDomains := flag.String("block-domains", "", "File of domains never to send a request to, one per line, with *.example.com matching every subdomain of example.com; reloaded on SIGHUP")
+	checkExternal := flag.Bool("checkExternal", false, "HEAD-check links outside of -scope and report their health, instead of ignoring them")
+	maxReferrers := flag.Int("maxReferrers", 10, "How many of the pages linking to a URL reports like broken-links list, rather than just the one it was first found on; 0 lists only that one")
+	reportInlinks := flag.Bool("inlinks", false, "Report every URL linked to, with how many crawled pages link to it and which, up to -maxReferrers")
+	reportDeadHosts := flag.Bool("deadHosts", true, "Report hosts that never resolve or accept a connection, along with the pages linking to them")
+	reportBlockedHosts := flag.Bool("blockedHosts", true, "Report the hosts that rate limited the crawl or served it bot challenges and firewall blocks, like Cloudflare's or Akamai's")
+	discoverSeeds := flag.Int("discoverSeeds", 0, "Also start from up to this many URLs -seedIndex knows of on the start URL's host, to reach pages nothing links to, none when 0")
+	seedIndex := flag.String("seedIndex", cdx.CommonCrawl, "CDX index -discoverSeeds asks for known URLs: commoncrawl (its latest collection), wayback, or the URL of any CDX API")
+	failedOnly := flag.Bool("failedOnly", false, "Only re-attempt the pages of -db that failed last time, without following their links and adding to the earlier output, then exit; see grawler retry-failures")
+	visitedPath := flag.String("visitedList", "", "File to write every URL the crawl attempted to on exit, one per line, disabled when empty")
+	visitedStatus := flag.Bool("visitedStatus", false, "Follow each URL in -visitedList with a tab and its status code, 0 when there was no response")
+	failOn := flag.String("fail-on", "", "Comma separated report thresholds like broken-links>0 that, when exceeded, make the crawl exit with status 1; a bare report name fails on any row")
+	expectedPath := flag.String("expectedURLs", "", "File of the URLs the site should have, one per line such as a CMS export, to report those never discovered or failing and the unexpected ones found")
+	seenPath := flag.String("seen", "", "A previous crawl's .jsonl output or a file of URLs, one per line like -visitedList, not to crawl again so only what's new since is explored; the start URL is always crawled")
+	maxPages := flag.Int("max-pages", 0, "Stop crawling once this many pages have been fetched, finishing those in flight, 0 for no limit")
+	exitWhenDone := flag.Bool("exitWhenDone", false, "Exit once every discovered page has been crawled instead of waiting for CTRL-C")
+	quiet := flag.Bool("quiet", false, "Log progress to stderr and print only the broken links to stdout, one per line and sorted, so runs can be diffed")
+	maxBytes := flag.Int64("max-bytes", 0, "Stop crawling once this many bytes of pages have been downloaded, finishing those in flight, 0 for no limit")
+	circuitFailures := flag.Int("circuitFailures", 5, "Consecutive failures after which a host's pages are requeued instead of crawled for -circuitCooldown, 0 disables")
+	circuitCooldown := flag.Duration("circuitCooldown", 5*time.Minute, "How long to hold back a host after -circuitFailures consecutive failures")
+	throttleHosts := flag.Bool("throttleHosts", true, "Deprioritize hosts that are consistently slow or erroring and crawl them one page at a time, and report them")
+	slowHostLatency := flag.Duration("slowHostLatency", 5*time.Second, "Hosts taking longer than this to respond on average are slow, 0 disables the check")
+	erroringHostRate := flag.Float64("erroringHostRate", 0.5, "Hosts failing more than this fraction of requests on average are erroring, 0 disables the check")
+	detectSoft404 := flag.Bool("soft404", true, "Flag pages served with a 200 that look like error pages in the broken link report")
+	soft404MinBytes := flag.Int("soft404MinBytes", 256, "Pages smaller than this many bytes are considered soft 404s, 0 disables the check")
+	soft404Phrases := flag.String("soft404Phrases", strings.Join(soft404.DefaultPhrases, ","), "Comma separated phrases that mark a soft 404 when found in a page's title or headings")
+	soft404RedirectHome := flag.Bool("soft404RedirectHome", true, "Consider pages redirected to the site's home page soft 404s")
+	maxRedirectHops := flag.Int("maxRedirectHops", 3, "Redirect chains with more hops than this are reported")
+	inventoryQueryParameters := flag.Bool("queryParameters", false, "Report every query parameter linked to per host, with how many URLs use it, its distinct values and whether -canonicalize drops it")
+	checkDuplicateURLs := flag.Bool("duplicateURLs", true, "Report pages served under URLs that only differ by a trailing slash, path case or index file")
+	checkRobotsConflicts := flag.Bool("robotsConflicts", true, "Report pages whose X-Robots-Tag header and meta robots tag contradict each other")
+	followFrames := flag.Bool("frames", false, "Crawl the sources of frames and iframes as children of the page framing them")
+	checkRobotsNoindex := flag.Bool("robotsNoindex", false, "Report indexable pages covered by a Noindex line in robots.txt, which search engines no longer honor")
+	checkSitemaps := flag.Bool("sitemaps", false, "Report sitemap URLs that robots.txt disallows or that crawled pages mark noindex")
+	documentLinks := flag.String("documentLinks", "", "Also crawl the links in documents that aren't HTML: pdf, docx, or media/type=command to have a program given each such document on stdin print its links one per line, separated by commas")
+	followAlternates := flag.Bool("alternates", false, "Crawl the AMP and media-specific alternate versions of pages, and report AMP pages whose canonical doesn't point back")
+	findOpenRedirects := flag.Bool("openRedirects", false, "Report links whose query parameters hold absolute URLs, like ?next=https://..., as potential open redirects")
+	probeOpenRedirects := flag.Bool("openRedirectProbe", false, "Request each -openRedirects candidate with the parameter pointing elsewhere and report whether it redirects there, implies -openRedirects")
+	blocklistPath := flag.String("blocklist", "", "File of known malicious or parked domains, one per line optionally followed by the threat, to check outbound links against")
+	safeBrowsingKey := flag.String("safeBrowsingKey", "", "Google Safe Browsing API key to check outbound links with")
+	inspectCORS := flag.Bool("cors", false, "Send a cross-origin request to every host and report its CORS policy, flagging reflected origins and wildcards with credentials")
+	probeWellKnown := flag.Bool("wellKnown", false, "Fetch /.well-known/security.txt, /humans.txt and /ads.txt from every host, recording which are there and what they say in the hosts report, and flagging a security.txt with no Contact or past its Expires date")
+	checkSiteIcons := flag.Bool("siteIcons", false, "Report the favicons, apple-touch-icons and web app manifest of every host, and whether they resolve")
+	auditImages := flag.Bool("images", false, "Inventory every image with its alt text, dimensions and HEAD-checked size, flagging missing alt text and oversized images")
+	imageMaxBytes := flag.Int64("imageMaxBytes", 200*1024, "Images bigger than this many bytes are reported as oversized, 0 disables the check")
+	thinContentWords := flag.Int("thinContent", 0, "Report every page's word count, flagging pages with fewer words than this as thin and grouping them by template, 0 disables")
+	scanSecrets := flag.Bool("secrets", false, "Report API keys, private keys, emails and SSNs leaked in page bodies, with the matches redacted")
+	secretRulesPath := flag.String("secretRules", "", "File of extra -secrets rules, one name=regexp per line")
+	secretDefaults := flag.Bool("secretDefaults", true, "Include the built-in -secrets rules alongside any from -secretRules")
+	extractContacts := flag.Bool("contacts", false, "Report email addresses and phone numbers found on each page, from mailto: and tel: links and page text")
+	validateHTML := flag.Bool("validate", false, "Report per page HTML well-formedness problems: unclosed tags, stray end tags, duplicate ids and multiple h1s")
+	checkAccessibility := flag.Bool("accessibility", false, "Report cheap accessibility problems on each page: images without alt, links without text, and missing lang or title")
+	auditCompression := flag.Bool("compressionAudit", false, "Report text responses served without compression")
+	compressionMinBytes := flag.Int64("compressionMinBytes", 1024, "Responses smaller than this many bytes aren't worth compressing and aren't reported")
+	auditCaching := flag.Bool("cacheAudit", false, "Report responses shared caches can't store, and hosts that cache the same kind of content inconsistently")
+	discoverEndpoints := flag.Bool("endpoints", false, "Report URLs referenced from inline scripts, such as fetch and XHR endpoints, without crawling them")
+	focusKeywords := flag.String("focus", "", "Comma separated keywords, links from pages mentioning more of them are crawled first")
+	focusThreshold := flag.Float64("focusThreshold", 0, "Drop links from pages mentioning less than this fraction of the -focus keywords")
+	scriptPath := flag.String("script", "", "Starlark script defining extract(page) and/or score(page, link) hooks")
+	outputFormats := flag.String("output-format", "dot", fmt.Sprintf("Comma separated output formats to write at once, from %v, where sitemap-changes lists the pages added or changed since the site's previous crawl stored by bolt", sink.Names()))
+	outputBase := flag.String("output", "grawled", "Output file name, each format adds its own extension")
+	crawlName := flag.String("crawlName", "", "Name to store the crawl under in -output-format bolt, which keeps every crawl written to it and replaces one of the same name, the start time when empty")
+	crawlTags := flag.String("tags", "", "Comma separated key=value tags to store the crawl with in -output-format bolt, on top of its site, date and config tags, so commands like grawler diff can find it by tag")
+	keepCrawls := flag.Int("keepCrawls", 0, "How many crawls -output-format bolt keeps, this one included, pruning older ones as the crawl starts so continuous crawling doesn't fill the disk, all of them when 0")
+	keepCrawlsFor := flag.Duration("keepCrawlsFor", 0, "How long -output-format bolt keeps crawls for, pruning those started longer ago as the crawl starts, forever when 0")
+	trendCSV := flag.String("trendCSV", "", "CSV file to append a row of this crawl's totals to, like pages, broken links and average latency, so site health can be graphed over time")
+	pushgateway := flag.String("pushgateway", "", "Prometheus Pushgateway URL to push this crawl's totals to as gauges, grouped by -pushgatewayJob and the site crawled")
+	pushgatewayJob := flag.String("pushgatewayJob", "grawler", "Job the totals are pushed to -pushgateway under")
+	collapsePaths := flag.String("collapse", "", "Comma separated path prefixes like /blog/* whose pages the graph draws as a single node per host, with a count of the pages it stands for")
+	formatTemplate := flag.String("format-template", "", "Go text/template executed on every page record, like {{.Status}} {{.URL}}, writing one line per page to -output plus .txt; adds the template output format")
+	nearDuplicates := flag.Bool("nearDuplicates", false, "Cluster pages with near-identical text, such as print views and session id variants, and report the clusters")
+	nearDuplicateDistance := flag.Int("nearDuplicateDistance", 3, "Pages whose SimHashes differ by at most this many bits are near-duplicates")
+	crawlRepresentatives := flag.Bool("crawlRepresentatives", false, "Don't follow links from near-duplicates of pages already crawled, implies -nearDuplicates")
+	extractText := flag.String("text", "", "Also record each page's text in the output: text (everything but navigation, headers, footers and scripts) or readability (just the main content), either for every format or as a comma separated list of format=mode")
+	auditRobots := flag.Bool("robotsAudit", false, "Report for every host the robots.txt directives applied, how many URLs each turned away, and the Crawl-delay asked for and used")
+	trackRobots := flag.Bool("robotsChanges", false, "Remember every host's robots.txt rules in -db, along with the paths crawled and turned away, and report and alert when the rules change between crawls to block paths crawled before or allow paths turned away")
+	robotsWebhook := flag.String("robotsAlertWebhook", "", "URL to POST -robotsChanges alerts to as JSON, in addition to printing them")
+	analyzeGraph := flag.Bool("graphAnalysis", false, "Report pages with no links onward (dead-ends), pages that can't be reached from -start by clicking grouped into clusters (unreachable), and groups of pages that all link round to each other (link-components)")
+	recordLinks := flag.Bool("recordLinks", true, "Record the pages each page links to in the output, for grawler inlinks to look up every page linking to a URL")
+	hashStructure := flag.Bool("structureHash", false, "Also record a hash of each page's element structure in the output, ignoring text, attributes, scripts and ads")
+	captureHeaders := flag.String("captureHeaders", "", "Comma separated response headers to record with each page in the output, such as Server,Cache-Control,Content-Security-Policy")
+	outputFilter := flag.String("output-filter", "", "What each output format is given: ok (pages crawled fine, the default), errors (failed attempts), all, in-scope, out-of-scope or statuses like 200|4xx, joined with + to narrow it down, either for every format or as a comma separated list of format=filter like dot=in-scope+200,jsonl=all")
+	outputPolicy := flag.String("output-policy", overflowSlow, "What to do when an output format falls behind: slow (the crawl), block or drop (events), either for every format or as a comma separated list of format=policy")
+	flag.CommandLine.Usage = configUsage(flag.CommandLine)
 	flag.Parse()
 
+	if err := applyConfig(flag.CommandLine, *configPath); err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	// Everything but the results goes to stderr
+	results := os.Stdout
+	if *quiet {
+		os.Stdout = os.Stderr
+	}
+
+	// Cookies are kept per host, so sites that set them see a consistent session
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	robotsAgent, agentString := robots.DefaultAgent, userAgent
+	if *asGooglebot {
+		robotsAgent, agentString = googlebotAgent, googlebotUserAgent
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if !*allowPrivateNetworks {
+		base = netguard.Transport()
+	}
+	// Run just before reports are saved, for anything that only summarizes or cleans up at the end
+	var finalizers []func()
+
+	dial := (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	if *sshServer != "" {
+		sshTunnel, err := tunnel.Open(tunnel.Config{Server: *sshServer, KeyPath: *sshKey, KnownHosts: knownHostsPath(*sshKnownHosts), Forward: *sshForward})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		// Not deferred, as the crawl ends with os.Exit
+		finalizers = append(finalizers, func() { sshTunnel.Close() })
+		dial = sshTunnel.DialContext
+		base.DialContext = dial
+	}
+	if *resolveSpec != "" {
+		overrides, err := resolve.Parse(*resolveSpec)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		overrides.Apply(base, dial)
+	}
+
 	client := &http.Client{
-		Transport: &headerTransport{},
-		Timeout:   5 * time.Second,
+		Transport:     &compressionTransport{&headerTransport{agentString, base}},
+		CheckRedirect: checkRedirect,
+		Jar:           jar,
+		Timeout:       5 * time.Second,
+	}
+
+	if *loginPath != "" {
+		steps, err := readLoginSteps(*loginPath)
+		if err == nil {
+			err = login(client, steps)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
 	}
 
 	parsedURL, err := url.Parse(*firstURL)
 	if err != nil {
 		fmt.Println(err)
-		return
+		os.Exit(exitFatal)
+	}
+
+	events := newEventBus()
+	var observers []crawlObserver
+	var scorers []score.Func
+	var reports []*report.Report
+
+	if *spaRoutes {
+		if *headlessPath == "" {
+			fmt.Println("-spaRoutes requires -headless")
+			os.Exit(exitFatal)
+		}
+		*canonicalSteps = keepRouteFragments(*canonicalSteps)
+	}
+
+	if *hostAliases != "" {
+		steps, err := hostAliasSteps(*hostAliases)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		*canonicalSteps += "," + steps
+	}
+
+	canonicalizer, err := canonical.Parse(*canonicalSteps)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
 	}
+	if *querySignificance != "keep" {
+		step, err := queryStep(*querySignificance)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		canonicalizer = append(canonicalizer, step)
+	}
+	*parsedURL = canonicalizer.Apply(*parsedURL)
 
-	visited, rulesIndex, finished := manager(client, *parsedURL, *queueSize)
-	graph, err := printer(finished)
+	if *overMaxCrawlDelay != "clamp" && *overMaxCrawlDelay != "skip" {
+		fmt.Printf("unknown -overMaxCrawlDelay %q, expected clamp or skip\n", *overMaxCrawlDelay)
+		os.Exit(exitFatal)
+	}
+
+	scope, err := newCrawlScope(*scopeMode, []url.URL{*parsedURL})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+
+	var filter *domainFilter
+	if *allowDomains != "" || *blockDomains != "" {
+		if filter, err = newDomainFilter(*allowDomains, *blockDomains); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		filter.reloadOnHangup()
+	}
+	// Where checkers making requests of their own may send them
+	side := sideRequests{scope, filter}
+
+	var external *externalChecker
+	if *checkExternal {
+		external = newExternalChecker(client, 4, *queueSize)
+		reports = append(reports, external.report)
+	}
+
+	db, err := bolt.Open(*dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	defer db.Close()
 
+	jobs, err := queue.New(db, *visibilityTimeout)
 	if err != nil {
 		fmt.Println(err)
-		return
+		os.Exit(exitFatal)
+	}
+	pending := &frontier{jobs, *maxAttempts, *retryDelay}
+
+	// Whatever else is left in the frontier is held back so only the failures are crawled, and comes back
+	// at the end, or when the frontier is next opened if this run doesn't get that far
+	if *failedOnly {
+		if _, err := jobs.Hold(); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		revived, err := jobs.Revive()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		fmt.Printf("Re-attempting %d urls that failed last time\n", revived)
+	}
+
+	// Page history is kept for -skipStale and -sitemapLastmod as well, but only -revisit schedules recrawls from it
+	var history, revisits *revisit.Scheduler
+	if *revisitPages || *skipStale > 0 || *sitemapLastmod {
+		if history, err = revisit.New(db, *revisitMin, *revisitMax); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+	}
+	if *revisitPages {
+		revisits = history
+	}
+
+	var detector *soft404.Detector
+	if *detectSoft404 {
+		detector = &soft404.Detector{
+			MinBytes:       *soft404MinBytes,
+			Phrases:        strings.Split(*soft404Phrases, ","),
+			RedirectToHome: *soft404RedirectHome,
+		}
+	}
+
+	var inlinks *inlinkIndex
+	if *maxReferrers > 0 || *reportInlinks {
+		inlinks = newInlinkIndex(canonicalizer, *maxReferrers)
+		go inlinks.watch(subscribe(events, *queueSize, overflowSlow))
+		if *reportInlinks {
+			reports = append(reports, inlinks.report)
+			finalizers = append(finalizers, inlinks.summarize)
+		}
+	}
+
+	var audit *robotsAudit
+	if *auditRobots {
+		audit = newRobotsAudit(robotsAgent)
+		go audit.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, audit.report)
+	}
+
+	var robotsChanges *robotsChangeTracker
+	if *trackRobots {
+		history, err := robotshistory.New(db)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		notifiers := notify.All{notify.Writer{Writer: os.Stdout}}
+		if *robotsWebhook != "" {
+			notifiers = append(notifiers, notify.Webhook{URL: *robotsWebhook, Client: &http.Client{Timeout: 10 * time.Second}})
+		}
+		robotsChanges = newRobotsChangeTracker(history, notifiers)
+		go robotsChanges.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, robotsChanges.report)
+	}
+
+	if *analyzeGraph {
+		links := newLinkGraph(canonicalizer, parsedURL.String())
+		go links.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, links.deadEnds, links.unreachable, links.components)
+		finalizers = append(finalizers, links.summarize)
+	}
+
+	brokenLinks := newBrokenLinkTracker(detector, inlinks)
+	observers = append(observers, brokenLinks.observe)
+	reports = append(reports, brokenLinks.report)
+	finalizers = append(finalizers, brokenLinks.summarize)
+
+	pagination := newPaginationTracker(canonicalizer)
+	observers = append(observers, pagination.observe)
+	reports = append(reports, pagination.report)
+	finalizers = append(finalizers, pagination.summarize)
+
+	redirectReport := newRedirectTracker(*maxRedirectHops)
+	observers = append(observers, redirectReport.observe)
+	reports = append(reports, redirectReport.report)
+
+	if *inventoryQueryParameters {
+		inventory := newQueryParameterInventory(canonicalizer)
+		observers = append(observers, inventory.observe)
+		reports = append(reports, inventory.report)
+		finalizers = append(finalizers, inventory.summarize)
+	}
+
+	if *checkDuplicateURLs {
+		tracker := newDuplicateURLTracker()
+		observers = append(observers, tracker.observe)
+		reports = append(reports, tracker.report)
+	}
+
+	if *checkRobotsConflicts {
+		checker := newRobotsConflictChecker()
+		observers = append(observers, checker.observe)
+		reports = append(reports, checker.report)
+	}
+
+	var documents document.Parsers
+	if *documentLinks != "" {
+		if documents, err = document.Parse(*documentLinks, 30*time.Second); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+	}
+
+	if *followAlternates {
+		amp := newAMPChecker(canonicalizer)
+		observers = append(observers, amp.observe)
+		reports = append(reports, amp.report)
+	}
+
+	if *extractContacts {
+		extractor := newContactExtractor()
+		observers = append(observers, extractor.observe)
+		reports = append(reports, extractor.report)
+	}
+
+	if *findOpenRedirects || *probeOpenRedirects {
+		detector := newOpenRedirectDetector(client, side, *probeOpenRedirects, 2, *queueSize)
+		observers = append(observers, detector.observe)
+		finalizers = append(finalizers, func() { detector.dropped.summarize("open redirect probes") })
+		reports = append(reports, detector.report)
+	}
+
+	if *blocklistPath != "" || *safeBrowsingKey != "" {
+		checker, err := newReputationChecker(client, scope, *blocklistPath, *safeBrowsingKey, *queueSize)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		observers = append(observers, checker.observe)
+		finalizers = append(finalizers, func() { checker.dropped.summarize("reputation lookups") })
+		reports = append(reports, checker.report)
+	}
+
+	if *inspectCORS {
+		inspector := newCORSInspector(client, side, 2, *queueSize)
+		observers = append(observers, inspector.observe)
+		finalizers = append(finalizers, func() { inspector.dropped.summarize("CORS probes") })
+		reports = append(reports, inspector.report)
+	}
+
+	if *checkSiteIcons || *probeWellKnown {
+		hosts := newHostsReport()
+		reports = append(reports, hosts)
+
+		if *checkSiteIcons {
+			checker := newSiteIconChecker(client, side, hosts, *queueSize)
+			observers = append(observers, checker.observe)
+			finalizers = append(finalizers, func() { checker.dropped.summarize("site icon checks") })
+		}
+		if *probeWellKnown {
+			prober := newWellKnownProber(client, side, hosts, *queueSize)
+			observers = append(observers, prober.observe)
+			finalizers = append(finalizers, func() { prober.dropped.summarize("well-known probes") })
+		}
+	}
+
+	if *auditImages {
+		auditor := newImageAuditor(client, side, *imageMaxBytes, 2, *queueSize)
+		observers = append(observers, auditor.observe)
+		finalizers = append(finalizers, func() { auditor.dropped.summarize("image checks") })
+		reports = append(reports, auditor.report)
+	}
+
+	if *thinContentWords > 0 {
+		tracker := newThinContentTracker(*thinContentWords)
+		observers = append(observers, tracker.observe)
+		reports = append(reports, tracker.report, tracker.templates)
+		finalizers = append(finalizers, tracker.summarize)
+	}
+
+	if *scanSecrets {
+		var rules []secrets.Rule
+		if *secretDefaults {
+			rules = append(rules, secrets.DefaultRules...)
+		}
+		if *secretRulesPath != "" {
+			extra, err := readSecretRules(*secretRulesPath)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitFatal)
+			}
+			rules = append(rules, extra...)
+		}
+
+		scanner := newSecretScanner(rules)
+		observers = append(observers, scanner.observe)
+		reports = append(reports, scanner.report)
+	}
+
+	if *validateHTML {
+		validator := newHTMLValidator()
+		observers = append(observers, validator.observe)
+		reports = append(reports, validator.report)
+	}
+
+	if *checkAccessibility {
+		checker := newAccessibilityChecker()
+		observers = append(observers, checker.observe)
+		reports = append(reports, checker.report)
+	}
+
+	if *auditCompression {
+		auditor := newCompressionAuditor(*compressionMinBytes)
+		observers = append(observers, auditor.observe)
+		reports = append(reports, auditor.report)
 	}
 
+	if *auditCaching {
+		auditor := newCacheAuditor()
+		observers = append(observers, auditor.observe)
+		reports = append(reports, auditor.uncacheable, auditor.summary)
+		finalizers = append(finalizers, auditor.summarize)
+	}
+
+	if *discoverEndpoints {
+		endpoints := newEndpointDiscoverer()
+		observers = append(observers, endpoints.observe)
+		reports = append(reports, endpoints.report)
+	}
+
+	if *visitedPath != "" {
+		visited := newVisitedList(*visitedPath, *visitedStatus)
+		observers = append(observers, visited.observe)
+		finalizers = append(finalizers, visited.save)
+	}
+
+	if *reportDeadHosts {
+		deadHosts := newDeadHostTracker()
+		observers = append(observers, deadHosts.observe)
+		reports = append(reports, deadHosts.report)
+		finalizers = append(finalizers, deadHosts.summarize)
+	}
+
+	if *reportBlockedHosts {
+		blockedHosts := newBlockedHostTracker()
+		observers = append(observers, blockedHosts.observe)
+		reports = append(reports, blockedHosts.report)
+		finalizers = append(finalizers, blockedHosts.summarize)
+	}
+
+	var circuits *breaker.Breaker
+	if *circuitFailures > 0 {
+		circuits = breaker.New(*circuitFailures, *circuitCooldown)
+	}
+
+	var throttle *hostThrottle
+	if *throttleHosts {
+		throttle = newHostThrottle(hoststats.Limits{Latency: *slowHostLatency, ErrorRate: *erroringHostRate, MinRequests: 5})
+		observers = append(observers, throttle.observe)
+		scorers = append(scorers, throttle.score)
+		reports = append(reports, throttle.report)
+		finalizers = append(finalizers, throttle.summarize)
+	}
+
+	if *focusKeywords != "" {
+		relevance := score.Keywords(strings.Split(*focusKeywords, ","))
+		scorers = append(scorers, score.Focused(relevance, *focusThreshold))
+	}
+
+	if *nearDuplicates || *crawlRepresentatives {
+		clusterer := newNearDuplicateClusterer(*nearDuplicateDistance)
+		observers = append(observers, clusterer.observe)
+		reports = append(reports, clusterer.report)
+		finalizers = append(finalizers, clusterer.summarize)
+
+		if *crawlRepresentatives {
+			scorers = append(scorers, clusterer.score)
+		}
+	}
+
+	if *scriptPath != "" {
+		hooks, err := newScriptHooks(*scriptPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		observers = append(observers, hooks.observe)
+		scorers = append(scorers, hooks.score)
+		reports = append(reports, hooks.report)
+	}
+
+	var browser *headless.Browser
+	if *headlessPath != "" {
+		browser = headless.New(*headlessPath, agentString, 30*time.Second)
+	}
+
+	renderer := browser
+	var challengeBrowser *headless.Browser
+	if *headlessChallenges {
+		if browser == nil {
+			fmt.Println("-headlessChallenges requires -headless")
+			os.Exit(exitFatal)
+		}
+		if *spaRoutes {
+			fmt.Println("-spaRoutes needs every page rendered, so can't be used with -headlessChallenges")
+			os.Exit(exitFatal)
+		}
+		renderer, challengeBrowser = nil, browser
+	}
+
+	if *screenshotDir != "" {
+		if browser == nil {
+			fmt.Println("-screenshots requires -headless")
+			os.Exit(exitFatal)
+		}
+
+		shots, err := newScreenshotter(browser, *screenshotDir, *reportDir)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		observers = append(observers, shots.observe)
+		reports = append(reports, shots.report)
+	}
+
+	if history != nil {
+		observers = append(observers, func(crawled page, crawlErr error) {
+			if crawlErr != nil {
+				return
+			}
+			lastModified, _ := http.ParseTime(crawled.header.Get("Last-Modified"))
+			links := make([]revisit.Link, len(crawled.links))
+			for i, link := range crawled.links {
+				links[i] = revisit.Link{URL: link.String(), Relation: link.relation}
+			}
+			if _, err := history.Record(crawled.String(), crawled.body, links, lastModified, time.Now()); err != nil {
+				fmt.Println(err)
+			}
+		})
+	}
+
+	if *checkWayback || *saveWayback {
+		archive := newArchiver(client, *saveWayback, *queueSize)
+		observers = append(observers, archive.observe)
+		reports = append(reports, archive.report)
+		finalizers = append(finalizers, func() { archive.dropped.summarize("Wayback Machine checks") })
+	}
+
+	status := newHealth(events)
+	go status.watch(subscribe(events, *queueSize, overflowSlow))
+	// The run's totals and trend sample count pages from these subscriptions, so they wait on them too
+	finalizers = append(finalizers, status.caughtUp.wait)
+	trends := newTrendMeter()
+	if *trendCSV != "" || *pushgateway != "" {
+		go trends.watch(subscribe(events, *queueSize, overflowSlow))
+		finalizers = append(finalizers, trends.caughtUp.wait)
+	}
+	go observeEvents(subscribe(events, *queueSize, overflowSlow), observers)
+	if *listenAddr != "" && !*noListeners {
+		listener, err := listen(*listenAddr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		// Closing removes a unix socket again
+		finalizers = append(finalizers, func() { listener.Close() })
+		go func() {
+			if err := http.Serve(listener, healthHandler(status)); err != nil && !isClosedListener(err) {
+				fmt.Println(err)
+			}
+		}()
+	}
+
+	robotsRules := robots.NewAgentRulesIndex(client, robotsAgent)
+	robotsRules.MaxDelay = *maxCrawlDelay
+
+	c := &crawler{
+		client:           client,
+		browser:          renderer,
+		challengeBrowser: challengeBrowser,
+		pending:          pending,
+		canonical:        canonicalizer,
+		routeFragments:   strings.Contains(*canonicalSteps, "route-fragment"),
+
+		rules:         robotsRules,
+		skipSlowHosts: *overMaxCrawlDelay == "skip",
+
+		scope:    scope,
+		external: external,
+		domains:  filter,
+		throttle: throttle,
+		breaker:  circuits,
+		revisits: revisits,
+		events:   events,
+		scorers:  scorers,
+		status:   status,
+
+		followFrames:     *followFrames,
+		followAlternates: *followAlternates,
+		documents:        documents,
+		robotsPrefetch:   *robotsPrefetch,
+
+		maxPages:     *maxPages,
+		failedOnly:   *failedOnly,
+		stopWhenDone: *exitWhenDone || *failedOnly,
+		stopped:      make(chan struct{}),
+	}
+	if *maxBytes > 0 {
+		c.budget = newByteBudget(*maxBytes)
+	}
+	if *sitemapLastmod {
+		c.lastmods = newSitemapLastmods(client, canonicalizer, history)
+	}
+	if *discoverSeeds > 0 && !*failedOnly {
+		c.seeds = indexedSeeds(client, *seedIndex, *parsedURL, *discoverSeeds)
+	}
+	if *seenPath != "" {
+		if c.seen, err = loadSeen(*seenPath, canonicalizer); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		fmt.Printf("Skipping %d urls seen by a previous crawl\n", len(c.seen))
+	}
+	if *skipStale > 0 {
+		stale, err := history.Stale(time.Now().Add(-*skipStale))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		c.stale = make(map[string][]revisit.Link, len(stale))
+		for _, record := range stale {
+			c.stale[record.URL] = record.Links
+		}
+		fmt.Printf("Skipping %d unchanged urls last modified over %v ago, following their links from last time\n", len(stale), *skipStale)
+	}
+
+	// A crawl of only failed pages starts from the frontier rather than -start
+	seeds := []string{parsedURL.String()}
+	if *failedOnly {
+		seeds = []string{}
+	}
+
+	if *formatTemplate != "" {
+		*outputFormats += ",template"
+	}
+	var collapse []string
+	for _, prefix := range strings.Split(*collapsePaths, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			collapse = append(collapse, prefix)
+		}
+	}
+	tags, err := crawlTagsFor(*crawlTags, *parsedURL, status.started, configHash(flag.CommandLine, "crawlName", "tags"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	stored := sink.Crawl{Name: *crawlName, Tags: tags, Started: status.started}
+
+	// The changes sitemap compares against the site's previous crawl in the bolt output, read before this crawl joins it
+	var previous map[string]string
+	if strings.Contains(*outputFormats, "sitemap-changes") {
+		if previous, err = previousCrawl(*outputBase+".db", tags["site"]); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+	}
+	output, formats, err := openSinks(*outputFormats, sink.Options{Base: *outputBase, Template: *formatTemplate, Seeds: seeds, Collapse: collapse, Crawl: stored, Previous: previous, Append: *failedOnly})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	if *keepCrawls > 0 || *keepCrawlsFor > 0 {
+		pruneCrawls(output, *keepCrawls, *keepCrawlsFor)
+	}
+	var earlier sink.Lister
+	for _, opened := range output {
+		if lister, ok := opened.(sink.Lister); ok {
+			earlier = lister
+			break
+		}
+	}
+	status.setHistory(revisits, earlier)
+
+	if *checkSitemaps {
+		conflicts := newSitemapConflicts(c)
+		go conflicts.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, conflicts.report)
+	}
+
+	if *checkRobotsNoindex {
+		noindex := newRobotsNoindexChecker(c)
+		go noindex.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, noindex.report)
+	}
+
+	if *expectedPath != "" {
+		expected, err := readLines(*expectedPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		inventory := newInventoryComparison(c, expected)
+		go inventory.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, inventory.report)
+		finalizers = append(finalizers, inventory.summarize)
+	}
+
+	if *asGooglebot {
+		comparison := newRobotsComparison(c)
+		go comparison.watch(subscribe(events, *queueSize, overflowSlow))
+		reports = append(reports, comparison.report)
+	}
+
+	var headerNames []string
+	for _, name := range strings.Split(*captureHeaders, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			headerNames = append(headerNames, http.CanonicalHeaderKey(name))
+		}
+	}
+
+	// Each format gets its own subscription, so one falling behind is handled by its own policy
+	var printers []caughtUp
+	for i, format := range formats {
+		recording := recordOptions{headers: headerNames, structureHash: *hashStructure, scope: scope, links: *recordLinks, canonical: canonicalizer}
+		if recording.filter, err = parseOutputFilter(formatSetting(*outputFilter, format, "")); err != nil {
+			fmt.Println(err)
+			os.Exit(exitFatal)
+		}
+		if mode := formatSetting(*extractText, format, ""); mode != "" {
+			var ok bool
+			if recording.text, ok = textExtractors[mode]; !ok {
+				fmt.Printf("unknown -text mode %q for %s, expected text or readability\n", mode, format)
+				os.Exit(exitFatal)
+			}
+		}
+
+		printers = append(printers, printer(subscribe(events, *queueSize, sinkPolicy(*outputPolicy, format)), output[i], events, recording))
+	}
+	thresholds, err := report.ParseThresholds(*failOn)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitFatal)
+	}
+	for _, threshold := range thresholds {
+		if !hasReport(reports, threshold.Report) {
+			fmt.Printf("-fail-on %s: no such report in this crawl\n", threshold.Report)
+			os.Exit(exitFatal)
+		}
+	}
+
+	visited, rulesIndex := c.manager(*parsedURL, *queueSize)
+	if robotsChanges != nil {
+		finalizers = append(finalizers, func() { robotsChanges.compare(rulesIndex.View()) })
+	}
+	if audit != nil {
+		finalizers = append(finalizers, func() { audit.summarize(rulesIndex.View()) })
+	}
+	status.setState(stateCrawling)
+
 	// Wait here until CTRL-C or other term signal is received.
 	fmt.Println("Crawler is now running.  Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
-	<-sc
+	aborted := false
+	select {
+	case <-sc:
+		aborted = true
+	case <-c.stopped:
+	}
+	status.setState(stateStopping)
+
+	// Give observers a moment to catch up on the last pages crawled
+	caughtUp := crawlFinished{observed: make(chan struct{})}
+	events.publish(caughtUp)
+	select {
+	case <-caughtUp.observed:
+	case <-time.NewTimer(catchUpTimeout).C:
+	}
+	// Nor can the sinks be closed while a printer is still writing to them
+	for _, written := range printers {
+		written.wait()
+	}
+
+	if err := output.Close(); err != nil {
+		fmt.Println(err)
+	}
 
-	writeGraph(graph)
+	for _, finalize := range finalizers {
+		finalize()
+	}
+	var reportFiles []string
+	for _, findings := range reports {
+		if err := findings.Save(*reportDir); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		reportFiles = append(reportFiles, filepath.Join(*reportDir, findings.Name+".csv"))
+	}
+	if len(reports) > 0 {
+		if err := report.SaveHTML(*reportDir, reports); err != nil {
+			fmt.Println(err)
+		} else {
+			reportFiles = append(reportFiles, filepath.Join(*reportDir, "report.html"))
+		}
+	}
 
 	fmt.Println(rulesIndex.String())
 	fmt.Printf("Crawled %d urls for %d unique sites\n", len(visited), rulesIndex.DomainCount())
+
+	if *failedOnly {
+		if _, err := jobs.Release(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	unexplored, _, err := jobs.Len()
+	if err != nil {
+		fmt.Println(err)
+	}
+	failed, err := jobs.Failed()
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	if *failedOnly {
+		fmt.Printf("%d urls are still failing\n", len(failed))
+	}
+
+	if c.budget != nil && c.budget.spent() {
+		fmt.Printf("Stopped after downloading %d bytes of the %d byte budget, leaving %d urls in the frontier unexplored\n", c.budget.downloaded(), c.budget.limit, unexplored)
+	}
+
+	if slow := rulesIndex.OverMaxDelay(); len(slow) > 0 {
+		action := fmt.Sprintf("crawled with a %v delay instead", *maxCrawlDelay)
+		if c.skipSlowHosts {
+			action = "skipped"
+		}
+		fmt.Printf("%d sites asked for a Crawl-delay over %v and were %s: %s\n", len(slow), *maxCrawlDelay, action, strings.Join(slow, ", "))
+	}
+
+	finished := time.Now()
+	run := manifest{
+		Version:    buildVersion(),
+		Seeds:      seeds,
+		Config:     flagConfig(flag.CommandLine),
+		StartedAt:  status.started,
+		FinishedAt: finished,
+		Duration:   finished.Sub(status.started).Round(time.Second).String(),
+		Totals: manifestTotals{
+			Crawled:    status.report().Crawled,
+			Discovered: len(visited),
+			Sites:      rulesIndex.DomainCount(),
+			Unexplored: unexplored,
+			Failed:     len(failed),
+		},
+		ExitCode: exitClean,
+		Outputs:  output.Files(),
+		Reports:  reportFiles,
+	}
+	if *visitedPath != "" {
+		run.Outputs = append(run.Outputs, *visitedPath)
+	}
+	if c.budget != nil {
+		run.Totals.Downloaded = c.budget.downloaded()
+	}
+
+	exceeded := report.Exceeded(reports, thresholds)
+	for _, problem := range exceeded {
+		fmt.Printf("Failing the crawl: %s\n", problem)
+	}
+	switch {
+	case len(exceeded) > 0:
+		run.ExitCode = exitThreshold
+	case aborted:
+		run.ExitCode = exitAborted
+	}
+
+	if err := run.save(*reportDir); err != nil {
+		fmt.Println(err)
+	}
+
+	summary := trend.Summary{
+		Finished:       finished,
+		Site:           parsedURL.Hostname(),
+		Crawl:          stored.Label(),
+		Pages:          run.Totals.Crawled,
+		Failed:         trends.failures(),
+		BrokenLinks:    brokenLinks.report.Len(),
+		AverageLatency: trends.average(),
+		Duration:       finished.Sub(status.started),
+		ExitCode:       run.ExitCode,
+	}
+	for _, err := range exportTrend(*trendCSV, *pushgateway, *pushgatewayJob, summary) {
+		fmt.Println(err)
+	}
+
+	if *quiet {
+		for _, row := range brokenLinks.report.Rows() {
+			printBrokenLink(results, row)
+		}
+	}
+
+	// os.Exit skips deferred calls
+	db.Close()
+	if temporaryDir != "" {
+		os.RemoveAll(temporaryDir)
+	}
+	os.Exit(run.ExitCode)
 }
 
-func manager(client *http.Client, initialURL url.URL, queueSize int) (visited robots.Set, rulesIndex robots.RulesIndex, finished chan website) {
-	visited = make(robots.Set)
-	rulesIndex = robots.NewRulesIndex(client)
+// hasReport is true when one of reports has the given name
+func hasReport(reports []*report.Report, name string) bool {
+	for _, findings := range reports {
+		if findings.Name == name {
+			return true
+		}
+	}
+	return false
+}
 
-	finished = make(chan website, queueSize)
+// keepRouteFragments swaps the fragment step of a -canonicalize description for one that keeps single page app routes
+func keepRouteFragments(description string) string {
+	steps := strings.Split(description, ",")
+	for i, step := range steps {
+		if strings.TrimSpace(step) == "fragment" {
+			steps[i] = "route-fragment"
+		}
+	}
+	return strings.Join(steps, ",")
+}
 
-	go func() {
-		vettingQueue := make(chan []website, queueSize)
-		vettingQueue <- []website{website{URL: initialURL}}
+// queryStep turns -query into a canonicalization step, per host where a host is given
+func queryStep(spec string) (canonical.Step, error) {
+	var fallback canonical.Step
+	byHost := make(map[string]canonical.Step)
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
 
-		for {
-			for _, toVet := range <-vettingQueue {
-				fullURL := toVet.String()
+		host, setting := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			host, setting = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
 
-				// We don't want to crawl sites we've already visited
-				if _, ok := visited[fullURL]; ok {
-					continue
-				}
-				visited[fullURL] = true
+		var step canonical.Step
+		switch parts := strings.Split(setting, ":"); {
+		case setting == "keep":
+			step = func(u *url.URL) {}
+		case setting == "drop":
+			step = canonical.StripQuery
+		case parts[0] == "keep" && len(parts) > 1:
+			step = canonical.KeepQuery(parts[1:]...)
+		default:
+			return nil, fmt.Errorf("-query: unknown setting %q, expected keep, drop or keep:param:...", setting)
+		}
 
-				// Load or fetch the robots.txt rules for this site
-				rules, err := rulesIndex.Get(toVet.Hostname())
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
+		if host == "" {
+			fallback = step
+		} else {
+			byHost[host] = step
+		}
+	}
+	return canonical.ByHost(byHost, fallback), nil
+}
 
-				if ok := rules.Test(toVet.Path); !ok {
-					fmt.Printf("Skipping %s\n", fullURL)
-					continue
-				}
+// hostAliasSteps turns -hostAliases into canonicalization steps, one alias step per canonical host
+func hostAliasSteps(spec string) (string, error) {
+	aliases := make(map[string][]string)
+	var hosts []string
+	for _, pair := range strings.Split(spec, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
 
-				// Start a crawling worker
-				go func(toCrawl website) {
-					<-time.NewTimer(rules.Delay).C
-					crawl(client, toCrawl, vettingQueue, finished)
-				}(toVet)
-			}
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return "", fmt.Errorf("-hostAliases: expected alias=host, got %q", pair)
 		}
-	}()
+		alias, host := strings.TrimSpace(pair[:i]), strings.ToLower(strings.TrimSpace(pair[i+1:]))
+		if _, ok := aliases[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		aliases[host] = append(aliases[host], alias)
+	}
 
-	return
+	steps := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		steps = append(steps, "alias:"+host+":"+strings.Join(aliases[host], ":"))
+	}
+	return strings.Join(steps, ","), nil
 }
 
-func crawl(client *http.Client, toCrawl website, vettingQueue chan<- []website, finished chan<- website) {
-	response, err := client.Get(toCrawl.String())
-	if err != nil {
-		fmt.Println(err)
-		return
+// openSinks opens every sink in a comma separated list of formats, returning them along with their format
+func openSinks(formats string, options sink.Options) (sink.Multi, []string, error) {
+	var sinks sink.Multi
+	var names []string
+	opened := make(map[string]bool)
+
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" || opened[format] {
+			continue
+		}
+		opened[format] = true
+
+		output, err := sink.Open(format, options)
+		if err != nil {
+			sinks.Close()
+			return nil, nil, err
+		}
+		sinks = append(sinks, output)
+		names = append(names, format)
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode > 399 || response.StatusCode < 200 {
-		fmt.Printf("Status code %d %s\n", response.StatusCode, toCrawl.String())
-		return
+	if len(sinks) == 0 {
+		return nil, nil, fmt.Errorf("no output formats given")
 	}
+	return sinks, names, nil
+}
 
-	allLinks := collectlinks.All(response.Body)
+// pruneCrawls drops old crawls from every sink keeping several, see -keepCrawls and -keepCrawlsFor
+func pruneCrawls(sinks sink.Multi, keep int, maxAge time.Duration) {
+	for _, output := range sinks {
+		pruner, ok := output.(sink.Pruner)
+		if !ok {
+			continue
+		}
 
-	urlsToVet := make([]website, len(allLinks))
-	for _, link := range allLinks {
-		parsedURL, err := url.Parse(link)
+		pruned, err := pruner.Prune(keep, maxAge, time.Now())
 		if err != nil {
 			fmt.Println(err)
 			continue
 		}
-
-		// ! Relative links need to use the crawling Hostname
-		if parsedURL.Hostname() == "" {
-			parsedURL.Host = toCrawl.Hostname()
+		for _, crawl := range pruned {
+			fmt.Printf("Pruned crawl %s started %s\n", crawl.Name, crawl.Started.Format(time.RFC3339))
 		}
+	}
+}
 
-		// Assume http for scheme-less urls
-		if parsedURL.Scheme == "" {
-			parsedURL.Scheme = "http"
-		}
+// sinkPolicy picks the overflow policy for a format out of -output-policy
+func sinkPolicy(policies string, format string) string {
+	return formatSetting(policies, format, overflowSlow)
+}
 
-		toVet := website{referrer: toCrawl.URL, URL: *parsedURL}
-		urlsToVet = append(urlsToVet, toVet)
+// formatSetting picks the value of a per-format flag for one format
+// The flag is either a single value for every format or a list of format=value, where a bare value is the default
+func formatSetting(setting string, format string, fallback string) string {
+	value := fallback
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.TrimSpace(entry)
+		if i := strings.Index(entry, "="); i < 0 {
+			if entry != "" {
+				value = entry
+			}
+		} else if strings.TrimSpace(entry[:i]) == format {
+			return strings.TrimSpace(entry[i+1:])
+		}
 	}
+	return value
+}
 
-	vettingQueue <- urlsToVet
-	finished <- toCrawl
+// textExtractors are the ways of picking the text out of a page for -text
+var textExtractors = map[string]func(body []byte) string{
+	"text":        extract.Text,
+	"readability": extract.Readable,
 }
 
-func printer(finished <-chan website) (*gographviz.Graph, error) {
-	graphAst, err := gographviz.ParseString(`digraph "Grawled Websites" {}`)
+// subscribe is eventBus.subscribe for policies given on the command line, exiting if they're invalid
+func subscribe(bus *eventBus, buffer int, policy string) <-chan crawlEvent {
+	events, err := bus.subscribe(buffer, policy)
 	if err != nil {
-		return nil, err
+		fmt.Println(err)
+		os.Exit(exitFatal)
 	}
+	return events
+}
 
-	graph := gographviz.NewGraph()
-	if err := gographviz.Analyse(graphAst, graph); err != nil {
-		return nil, err
-	}
+// recordOptions are what's recorded for each page beyond the basics
+type recordOptions struct {
+	// Of the page's response headers, only these are recorded
+	headers []string
 
-	graphName := `"Grawled Websites"`
+	// Every page is recorded with a hash of its content, and of its structure too when this is set
+	structureHash bool
 
-	graph.SetName(graphName)
-	graph.AddNode(graphName, "start", map[string]string{"label": "Start"})
+	// Picks the text out of the page to record, when set
+	text func(body []byte) string
 
-	mutex := sync.Mutex{}
+	// Which attempts are recorded at all, judged against scope
+	filter outputFilter
+	scope  *crawlScope
 
-	go func() {
-		for {
-			website := <-finished
+	// Records the pages each page links to, normalized the way the crawl does, when set
+	links     bool
+	canonical canonical.Chain
+}
+
+// outlinks lists the crawlable pages a page links to, once each and in the order it first links to them
+func outlinks(crawled page, normalize canonical.Chain) []string {
+	seen := make(map[string]bool, len(crawled.links))
+	var links []string
+	for _, link := range crawled.links {
+		if !crawlable(link.URL) {
+			continue
+		}
+		normalized := normalize.Apply(link.URL)
+		if target := normalized.String(); !seen[target] {
+			seen[target] = true
+			links = append(links, target)
+		}
+	}
+	return links
+}
 
-			websiteHostname := fmt.Sprintf("\"%s\"", website.Hostname())
-			websitePath := fmt.Sprintf("\"%s\"", website.EscapedPath())
-			websiteGraphName := fmt.Sprintf("cluster_%s", hash(website.Hostname()))
+// printer hands every crawled page to the output sink, flushing it periodically
+// printer writes the pages crawled to output, the returned caughtUp telling when it has written every page
+// published before the crawl finished
+func printer(events <-chan crawlEvent, output sink.Sink, bus *eventBus, options recordOptions) caughtUp {
+	written := newCaughtUp()
+	go func() {
+		for event := range events {
+			written.see(event)
 
-			if !graph.IsSubGraph(websiteGraphName) {
-				mutex.Lock()
-				graph.AddSubGraph(graphName, websiteGraphName, graphAttributes(websiteHostname))
-				mutex.Unlock()
+			var crawled page
+			var crawlErr error
+			unchanged := false
+			switch event := event.(type) {
+			case fetchCompleted:
+				crawled = event.crawled
+			case fetchUnchanged:
+				crawled, unchanged = event.crawled, true
+			case fetchFailed:
+				crawled, crawlErr = event.crawled, event.err
+			default:
+				continue
 			}
 
-			// Add the crawled site
-			websiteNodeName := hashURL(website.URL)
-
-			mutex.Lock()
-			graph.AddNode(websiteGraphName, websiteNodeName, nodeAttributes(websitePath))
+			// Revisited pages are already in the graph
+			if crawled.revisit || !options.filter.matches(crawled, crawlErr, options.scope) {
+				continue
+			}
 
-			// If there is no referrer, this must be the entrypoint into the system
-			if website.referrer.Hostname() == "" {
-				graph.AddEdge("start", websiteNodeName, true, map[string]string{})
+			record := sink.Record{
+				ID:        sink.ID(crawled.String()),
+				URL:       crawled.String(),
+				Status:    crawled.status,
+				Encoding:  crawled.transfer.encoding,
+				WireBytes: crawled.transfer.wire,
+				Bytes:     crawled.transfer.decoded,
+				CrawledAt: time.Now(),
+				Unchanged: unchanged,
+			}
+			if crawlErr != nil {
+				// There's no content to speak of
+				record.Error = crawlErr.Error()
+			} else if unchanged {
+				if options.links {
+					record.Links = outlinks(crawled, options.canonical)
+				}
 			} else {
-				reffererNodeName := hashURL(website.referrer)
-				graph.AddEdge(reffererNodeName, websiteNodeName, true, map[string]string{})
+				record.ContentHash = fingerprint.Content(crawled.body)
+				if options.structureHash {
+					record.StructureHash = fingerprint.Structure(crawled.body)
+				}
+				if options.text != nil {
+					record.Text = options.text(crawled.body)
+				}
+				if options.links {
+					record.Links = outlinks(crawled, options.canonical)
+				}
+			}
+			if crawled.referrer.Hostname() != "" {
+				record.Referrer = crawled.referrer.String()
+				record.Relation = crawled.relation
+				record.LinkCount = crawled.placement.Count
+				record.LinkSections = crawled.placement.Sections
+			}
+
+			for _, name := range options.headers {
+				if values, ok := crawled.header[name]; ok {
+					if record.Headers == nil {
+						record.Headers = make(map[string]string)
+					}
+					record.Headers[name] = strings.Join(values, ", ")
+				}
 			}
-			mutex.Unlock()
 
-			fmt.Printf("Crawled: %s%s\n", website.Hostname(), website.Path)
+			if err := output.Write(record); err != nil {
+				fmt.Println(err)
+			}
 		}
 	}()
 
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		for range ticker.C {
-			mutex.Lock()
-			defer mutex.Unlock()
-			writeGraph(graph)
+			err := output.Flush()
+			if err != nil {
+				fmt.Println(err)
+			}
+			bus.publish(outputFlushed{err})
 		}
 	}()
 
-	return graph, nil
+	return written
 }
 
-func writeGraph(graph *gographviz.Graph) {
-	output := graph.String()
-	if err := ioutil.WriteFile("grawled.gv", []byte(output), 0777); err != nil {
-		fmt.Println(err)
-	}
-}
-
-func graphAttributes(hostname string) map[string]string {
-	return map[string]string{
-		"label":   hostname,
-		"nodesep": "6",
-		"ranksep": "4",
-		"style":   "dotted",
+// knownHostsPath is the -sshKnownHosts file, or the user's own known_hosts when none was given
+func knownHostsPath(path string) string {
+	if path != "" {
+		return path
 	}
-}
-
-func nodeAttributes(path string) map[string]string {
-	return map[string]string{
-		"label": path,
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
 	}
-}
-
-func hashURL(url url.URL) string {
-	token := fmt.Sprintf("%s%s", url.Hostname(), url.Path)
-	return hash(token)
-}
-
-func hash(token string) string {
-	hash := fnv.New32a()
-	hash.Write([]byte(token))
-
-	return fmt.Sprintf("h%0x", hash.Sum32())
+	return filepath.Join(home, ".ssh", "known_hosts")
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// version is set when building releases, with -ldflags "-X main.version=v1.2.3"
+var version string
+
+// buildVersion is the version this binary was built as, falling back to the module version
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// manifest describes a finished crawl and where its artifacts are, written to manifest.json in -reportDir
+type manifest struct {
+	Version string            `json:"version"`
+	Seeds   []string          `json:"seeds"`
+	Config  map[string]string `json:"config"`
+
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Duration   string    `json:"duration"`
+
+	Totals manifestTotals `json:"totals"`
+
+	// What the process exits with, see exitClean and friends
+	ExitCode int `json:"exitCode"`
+
+	// Paths of everything the crawl wrote, sink output first and then reports
+	Outputs []string `json:"outputs"`
+	Reports []string `json:"reports"`
+}
+
+type manifestTotals struct {
+	Crawled    int `json:"crawled"`
+	Discovered int `json:"discovered"`
+	Sites      int `json:"sites"`
+
+	// Left in the frontier, and set aside after running out of attempts
+	Unexplored int `json:"unexplored"`
+	Failed     int `json:"failed"`
+
+	// Only counted with -max-bytes
+	Downloaded int64 `json:"downloaded,omitempty"`
+}
+
+// flagConfig is the value of every flag, with those holding credentials redacted
+func flagConfig(flags *flag.FlagSet) map[string]string {
+	config := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value != "" && isCredential(f.Name) {
+			value = "REDACTED"
+		}
+		config[f.Name] = value
+	})
+	return config
+}
+
+func isCredential(flagName string) bool {
+	name := strings.ToLower(flagName)
+	for _, suffix := range []string{"key", "token", "password", "secret"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the manifest to manifest.json in dir
+func (m manifest) save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
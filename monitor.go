@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/monitor"
+	"github.com/jrokun/crawler/pkg/notify"
+	bolt "go.etcd.io/bbolt"
+)
+
+// runMonitor implements `grawler monitor`, which re-checks a set of important URLs on an
+// interval and alerts when they start failing, redirect elsewhere, or recover
+func runMonitor(args []string) {
+	flags := flag.NewFlagSet("monitor", flag.ExitOnError)
+	urlsPath := flags.String("urls", "", "File listing the URLs to monitor, one per line")
+	interval := flags.Duration("interval", 10*time.Minute, "How often every URL is checked")
+	dbPath := flags.String("db", "grawler.db", "BoltDB file holding the results of previous checks")
+	webhookURL := flags.String("alertWebhook", "", "URL to POST alerts to as JSON, in addition to printing them")
+	configPath := flags.String("config", "", "JSON file to read options from")
+	flags.Usage = configUsage(flags)
+	flags.Parse(args)
+
+	if err := applyConfig(flags, *configPath); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	urls := flags.Args()
+	if *urlsPath != "" {
+		fromFile, err := readLines(*urlsPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		urls = append(urls, fromFile...)
+	}
+	if len(urls) == 0 {
+		fmt.Println("No URLs to monitor, pass them as arguments or with -urls")
+		os.Exit(2)
+	}
+
+	db, err := bolt.Open(*dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer db.Close()
+
+	client := &http.Client{
+		Transport: &headerTransport{},
+		Timeout:   10 * time.Second,
+	}
+
+	checker, err := monitor.New(db, client)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	notifiers := notify.All{notify.Writer{Writer: os.Stdout}}
+	if *webhookURL != "" {
+		notifiers = append(notifiers, notify.Webhook{URL: *webhookURL, Client: client})
+	}
+
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
+
+	fmt.Printf("Monitoring %d urls every %v.  Press CTRL-C to exit.\n", len(urls), *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		checkAll(checker, urls, notifiers)
+
+		select {
+		case <-ticker.C:
+		case <-sc:
+			return
+		}
+	}
+}
+
+func checkAll(checker *monitor.Monitor, urls []string, notifier notify.Notifier) {
+	for _, url := range urls {
+		check, alert, err := checker.Check(url)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("Checked %s: %s\n", url, check)
+
+		if alert == nil {
+			continue
+		}
+		if err := notifier.Notify(url, alert.Reason); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/fingerprint"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/score"
+)
+
+// nearDuplicateCluster is a group of pages whose SimHashes are all close to the first page crawled in it
+type nearDuplicateCluster struct {
+	representative string
+	hash           uint64
+
+	members   []string
+	distances []int
+}
+
+// nearDuplicateClusterer groups pages with near-identical text, such as print views and session id variants
+// Each page is compared against every cluster's representative, which is fine for site-sized crawls
+type nearDuplicateClusterer struct {
+	maxDistance int
+
+	mutex    sync.Mutex
+	clusters []*nearDuplicateCluster
+
+	// The cluster each page landed in, nil for pages without any text
+	assigned map[string]*nearDuplicateCluster
+
+	report *report.Report
+}
+
+func newNearDuplicateClusterer(maxDistance int) *nearDuplicateClusterer {
+	return &nearDuplicateClusterer{
+		maxDistance: maxDistance,
+		assigned:    make(map[string]*nearDuplicateCluster),
+		report:      report.New("near-duplicates", "cluster", "url", "representative", "distance"),
+	}
+}
+
+// assign puts a page in the closest cluster within reach, or starts a new one with the page as its representative
+func (clusterer *nearDuplicateClusterer) assign(page url.URL, body []byte) *nearDuplicateCluster {
+	clusterer.mutex.Lock()
+	defer clusterer.mutex.Unlock()
+
+	if cluster, ok := clusterer.assigned[page.String()]; ok {
+		return cluster
+	}
+
+	hash, ok := fingerprint.SimHash(body)
+	if !ok {
+		clusterer.assigned[page.String()] = nil
+		return nil
+	}
+
+	var closest *nearDuplicateCluster
+	closestDistance := clusterer.maxDistance + 1
+	for _, cluster := range clusterer.clusters {
+		if distance := fingerprint.Distance(hash, cluster.hash); distance < closestDistance {
+			closest, closestDistance = cluster, distance
+		}
+	}
+
+	if closest == nil {
+		closest, closestDistance = &nearDuplicateCluster{representative: page.String(), hash: hash}, 0
+		clusterer.clusters = append(clusterer.clusters, closest)
+	}
+
+	closest.members = append(closest.members, page.String())
+	closest.distances = append(closest.distances, closestDistance)
+	clusterer.assigned[page.String()] = closest
+	return closest
+}
+
+func (clusterer *nearDuplicateClusterer) observe(crawled page, crawlErr error) {
+	if crawlErr == nil {
+		clusterer.assign(crawled.URL, crawled.body)
+	}
+}
+
+// score drops the links of pages that are near-duplicates of a page already crawled, so only each
+// cluster's representative is crawled any further
+func (clusterer *nearDuplicateClusterer) score(from score.Page, link score.Link) float64 {
+	cluster := clusterer.assign(from.URL, from.Body)
+	if cluster != nil && cluster.representative != from.URL.String() {
+		return 0
+	}
+	return score.Neutral
+}
+
+// summarize reports every cluster with more than one page in it
+func (clusterer *nearDuplicateClusterer) summarize() {
+	clusterer.mutex.Lock()
+	defer clusterer.mutex.Unlock()
+
+	for i, cluster := range clusterer.clusters {
+		if len(cluster.members) < 2 {
+			continue
+		}
+
+		name := strconv.Itoa(i + 1)
+		for j, member := range cluster.members {
+			clusterer.report.Add(name, member, cluster.representative, strconv.Itoa(cluster.distances[j]))
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/redirects"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// Where probes try to send the browser, it's never actually requested
+const openRedirectCanary string = "https://grawler-canary.example/"
+
+// How much of a probed page's body is searched for meta refreshes and scripts
+const openRedirectBodyLimit int64 = 64 * 1024
+
+// openRedirectCandidate is a link with a query parameter holding an absolute URL
+type openRedirectCandidate struct {
+	link      website
+	parameter string
+}
+
+// openRedirectDetector reports links passing absolute URLs in their query parameters, the usual sign of an
+// open redirect, and optionally probes each one with a URL of our own to see if it's followed
+type openRedirectDetector struct {
+	// Nil unless probing, never follows redirects itself
+	client *http.Client
+
+	// Candidates elsewhere are reported but never probed
+	side sideRequests
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	candidates chan openRedirectCandidate
+	dropped    droppedWork
+	report     *report.Report
+}
+
+func newOpenRedirectDetector(client *http.Client, side sideRequests, probe bool, workers int, queueSize int) *openRedirectDetector {
+	detector := &openRedirectDetector{
+		side:   side,
+		seen:   make(map[string]bool),
+		report: report.New("open-redirects", "url", "parameter", "value", "linked from", "probe"),
+	}
+
+	if probe {
+		probeClient := *client
+		probeClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		detector.client = &probeClient
+
+		detector.candidates = make(chan openRedirectCandidate, queueSize)
+		for i := 0; i < workers; i++ {
+			go detector.run()
+		}
+	}
+
+	return detector
+}
+
+func (detector *openRedirectDetector) observe(crawled page, crawlErr error) {
+	detector.check(crawled.website)
+	if crawlErr != nil {
+		return
+	}
+
+	for _, link := range crawled.links {
+		detector.check(link)
+	}
+}
+
+func (detector *openRedirectDetector) check(link website) {
+	for _, parameter := range redirects.RedirectParameters(&link.URL) {
+		// The same endpoint is usually linked with many different values, only report it once
+		key := link.Host + link.Path + "?" + parameter
+
+		detector.mutex.Lock()
+		seen := detector.seen[key]
+		detector.seen[key] = true
+		detector.mutex.Unlock()
+		if seen {
+			continue
+		}
+
+		candidate := openRedirectCandidate{link, parameter}
+		switch {
+		case detector.client == nil:
+			detector.add(candidate, "")
+		case !detector.side.allows(link.URL):
+			detector.add(candidate, "not probed, outside the crawl")
+		default:
+			select {
+			case detector.candidates <- candidate:
+			default:
+				detector.dropped.add()
+				detector.add(candidate, "not probed, too many queued")
+			}
+		}
+	}
+}
+
+func (detector *openRedirectDetector) run() {
+	for candidate := range detector.candidates {
+		result := "no redirect"
+
+		redirected, err := detector.probe(candidate)
+		if err != nil {
+			result = err.Error()
+		} else if redirected {
+			result = "redirects to canary"
+		}
+
+		detector.add(candidate, result)
+	}
+}
+
+func (detector *openRedirectDetector) add(candidate openRedirectCandidate, result string) {
+	link := candidate.link
+	detector.report.Add(link.String(), candidate.parameter, link.Query().Get(candidate.parameter), link.referrer.String(), result)
+}
+
+// probe requests the link with the parameter pointing at the canary, and checks whether the response sends
+// the browser there, whether by HTTP redirect, Refresh header, meta refresh or script
+func (detector *openRedirectDetector) probe(candidate openRedirectCandidate) (bool, error) {
+	canary, _ := url.Parse(openRedirectCanary)
+	probeURL := redirects.WithParameter(&candidate.link.URL, candidate.parameter, openRedirectCanary)
+
+	response, err := detector.client.Get(probeURL.String())
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if location := response.Header.Get("Location"); location != "" && redirects.Leads(probeURL, location, canary.Hostname()) {
+		return true, nil
+	}
+
+	if target, _, ok := redirects.ParseRefresh(response.Header.Get("Refresh")); ok && redirects.Leads(probeURL, target, canary.Hostname()) {
+		return true, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, openRedirectBodyLimit))
+	if err != nil {
+		return false, err
+	}
+	for _, pseudo := range redirects.Find(body) {
+		if redirects.Leads(probeURL, pseudo.Target, canary.Hostname()) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// outputFilter decides which crawl attempts an output format is given, see -output-filter
+type outputFilter struct {
+	successes bool
+	failures  bool
+
+	// Only attempts ending up in scope, or out of it, when set
+	inScope  bool
+	outScope bool
+
+	// Statuses like 404, or classes like 4xx, any of which matches, every status when empty
+	statuses []string
+}
+
+// parseOutputFilter reads terms joined by +, all of which must match
+// ok is every page crawled fine and the default, errors every failed attempt and all both; in-scope
+// and out-of-scope go by where any redirects ended up, and statuses are listed like 301|4xx
+func parseOutputFilter(spec string) (outputFilter, error) {
+	filter := outputFilter{successes: true}
+	if spec == "" {
+		return filter, nil
+	}
+
+	for _, term := range strings.Split(spec, "+") {
+		switch term = strings.TrimSpace(term); term {
+		case "ok":
+			filter.successes, filter.failures = true, false
+		case "errors":
+			filter.successes, filter.failures = false, true
+		case "all":
+			filter.successes, filter.failures = true, true
+		case "in-scope":
+			filter.inScope = true
+		case "out-of-scope":
+			filter.outScope = true
+		default:
+			for _, status := range strings.Split(term, "|") {
+				if !validStatusPattern(status) {
+					return filter, fmt.Errorf("unknown -output-filter term %q, expected ok, errors, all, in-scope, out-of-scope or statuses like 200|4xx", term)
+				}
+				filter.statuses = append(filter.statuses, status)
+			}
+		}
+	}
+	return filter, nil
+}
+
+func validStatusPattern(status string) bool {
+	if len(status) != 3 {
+		return false
+	}
+	if strings.HasSuffix(status, "xx") {
+		return status[0] >= '1' && status[0] <= '5'
+	}
+	_, err := strconv.Atoi(status)
+	return err == nil
+}
+
+// matches is whether an attempt, failed when crawlErr isn't nil, passes the filter
+func (filter outputFilter) matches(crawled page, crawlErr error, scope *crawlScope) bool {
+	if crawlErr == nil && !filter.successes || crawlErr != nil && !filter.failures {
+		return false
+	}
+
+	if filter.inScope || filter.outScope {
+		landed := crawled.final
+		if landed.Host == "" {
+			landed = crawled.URL
+		}
+		if inScope := scope.contains(landed); filter.inScope && !inScope || filter.outScope && inScope {
+			return false
+		}
+	}
+
+	if len(filter.statuses) == 0 {
+		return true
+	}
+	status := strconv.Itoa(crawled.status)
+	for _, pattern := range filter.statuses {
+		if pattern == status || strings.HasSuffix(pattern, "xx") && crawled.status != 0 && pattern[0] == status[0] {
+			return true
+		}
+	}
+	return false
+}
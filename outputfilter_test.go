@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// memorySink keeps every record written to it
+type memorySink struct {
+	records []sink.Record
+}
+
+func (memory *memorySink) Write(record sink.Record) error {
+	memory.records = append(memory.records, record)
+	return nil
+}
+
+func (memory *memorySink) Flush() error { return nil }
+func (memory *memorySink) Close() error { return nil }
+
+func TestParseOutputFilter(t *testing.T) {
+	for _, spec := range []string{"", "ok", "errors", "all+in-scope", "out-of-scope+301|4xx"} {
+		if _, err := parseOutputFilter(spec); err != nil {
+			t.Errorf("Expected %q to parse, got %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"broken", "6xx", "20", "ok+4x"} {
+		if _, err := parseOutputFilter(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
+
+func TestOutputFilterMatches(t *testing.T) {
+	start, _ := url.Parse("http://example.com/")
+	scope, err := newCrawlScope(scopeHost, []url.URL{*start})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := crawledPage(t, "http://example.com/")
+	missing := crawledPage(t, "http://example.com/gone")
+	missing.status = 404
+	moved := crawledPage(t, "http://example.com/moved")
+	moved.status = 301
+	moved.final = url.URL{Scheme: "http", Host: "elsewhere.com", Path: "/"}
+	failed := errors.New("Status code 404")
+
+	for _, test := range []struct {
+		spec     string
+		crawled  page
+		crawlErr error
+		expected bool
+	}{
+		{"", ok, nil, true},
+		{"", missing, failed, false},
+		{"errors", ok, nil, false},
+		{"errors", missing, failed, true},
+		{"all+4xx", missing, failed, true},
+		{"all+5xx", missing, failed, false},
+		{"all+200|301", moved, nil, true},
+		{"in-scope", moved, nil, false},
+		{"out-of-scope", moved, nil, true},
+		{"out-of-scope", ok, nil, false},
+	} {
+		filter, err := parseOutputFilter(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matched := filter.matches(test.crawled, test.crawlErr, scope); matched != test.expected {
+			t.Errorf("Expected %q matching %s to be %t", test.spec, test.crawled.String(), test.expected)
+		}
+	}
+}
+
+func TestPrinterFilters(t *testing.T) {
+	filter, err := parseOutputFilter("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := newEventBus()
+	events, err := bus.subscribe(10, overflowBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := &memorySink{}
+	written := printer(events, memory, bus, recordOptions{filter: filter})
+
+	missing := crawledPage(t, "http://example.com/gone")
+	missing.status = 404
+	bus.publish(fetchCompleted{crawled: crawledPage(t, "http://example.com/")})
+	bus.publish(fetchFailed{crawled: missing, err: errors.New("Status code 404")})
+	bus.publish(crawlFinished{observed: make(chan struct{})})
+	written.wait()
+
+	if len(memory.records) != 1 || memory.records[0].URL != "http://example.com/gone" || memory.records[0].Error == "" {
+		t.Errorf("Expected only the failed attempt to be recorded, got %v", memory.records)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// paginationTracker follows rel="next" links into paginated series, reporting each series along with
+// any place its chain breaks: a next page that fails, a loop, or a prev link that doesn't lead back
+type paginationTracker struct {
+	canonical canonical.Chain
+
+	mutex sync.Mutex
+	next  map[string]string
+	prev  map[string]string
+
+	// Latest outcome of every crawled page, empty when it succeeded
+	outcomes map[string]string
+
+	report *report.Report
+}
+
+func newPaginationTracker(chain canonical.Chain) *paginationTracker {
+	return &paginationTracker{
+		canonical: chain,
+		next:      make(map[string]string),
+		prev:      make(map[string]string),
+		outcomes:  make(map[string]string),
+		report:    report.New("pagination", "series", "pages", "last page", "problem"),
+	}
+}
+
+func (tracker *paginationTracker) observe(crawled page, crawlErr error) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if crawlErr != nil {
+		outcome := crawlErr.Error()
+		if crawled.status != 0 {
+			outcome = "status " + strconv.Itoa(crawled.status)
+		}
+		tracker.outcomes[crawled.String()] = outcome
+		return
+	}
+	tracker.outcomes[crawled.String()] = ""
+
+	for _, link := range crawled.links {
+		target := tracker.canonical.Apply(link.URL)
+		switch link.relation {
+		case relationNext:
+			tracker.next[crawled.String()] = target.String()
+		case relationPrev:
+			tracker.prev[crawled.String()] = target.String()
+		}
+	}
+}
+
+// summarize walks every series from its first page, which is any page with a next that isn't
+// another page's next, then walks whatever is left over, which can only be loops
+func (tracker *paginationTracker) summarize() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	isNext := make(map[string]bool)
+	var starts []string
+	for from, to := range tracker.next {
+		isNext[to] = true
+		starts = append(starts, from)
+	}
+	sort.Strings(starts)
+
+	walked := make(map[string]bool)
+	for _, start := range starts {
+		if !isNext[start] {
+			tracker.walk(start, walked)
+		}
+	}
+	for _, start := range starts {
+		if !walked[start] {
+			tracker.walk(start, walked)
+		}
+	}
+}
+
+func (tracker *paginationTracker) walk(start string, walked map[string]bool) {
+	inSeries := map[string]bool{start: true}
+	walked[start] = true
+
+	pages, current, problem := 1, start, ""
+	for {
+		next, ok := tracker.next[current]
+		if !ok {
+			break
+		}
+
+		if inSeries[next] {
+			problem = fmt.Sprintf("%s loops back to %s", current, next)
+			break
+		}
+
+		outcome, crawled := tracker.outcomes[next]
+		if !crawled {
+			problem = fmt.Sprintf("next page %s wasn't crawled", next)
+			break
+		}
+		if outcome != "" {
+			problem = fmt.Sprintf("next page %s failed with %s", next, outcome)
+			break
+		}
+
+		if prev, ok := tracker.prev[next]; ok && prev != current {
+			problem = fmt.Sprintf("%s has prev %s instead of %s", next, prev, current)
+		}
+
+		inSeries[next], walked[next] = true, true
+		pages++
+		current = next
+
+		if problem != "" {
+			break
+		}
+	}
+
+	tracker.report.Add(start, strconv.Itoa(pages), current, problem)
+}
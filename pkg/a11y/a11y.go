@@ -0,0 +1,113 @@
+// Package a11y runs cheap accessibility checks over HTML documents
+// They're the kind of problem that can be spotted from markup alone, not a replacement for a real audit.
+package a11y
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of issue found
+const (
+	MissingAlt    string = "img missing alt"
+	MissingLang   string = "html missing lang"
+	EmptyLinkText string = "link without text"
+	MissingTitle  string = "missing title"
+)
+
+// Issue is a single problem found in a document
+type Issue struct {
+	Kind string
+
+	// Something to help find the problem, like the src of an image
+	Detail string
+}
+
+// Check lists every issue found in a document, in document order
+func Check(body []byte) []Issue {
+	var issues []Issue
+
+	foundHTML, foundTitle := false, false
+	inTitle := false
+	var title strings.Builder
+
+	// The link currently open, if any, and whether anything inside it gives it a name
+	var link *html.Token
+	linkNamed := false
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "html":
+				foundHTML = true
+				if strings.TrimSpace(attr(token, "lang")) == "" {
+					issues = append(issues, Issue{Kind: MissingLang})
+				}
+			case "title":
+				foundTitle, inTitle = true, tokenType == html.StartTagToken
+			case "img":
+				alt, hasAlt := attrOK(token, "alt")
+				if !hasAlt {
+					issues = append(issues, Issue{Kind: MissingAlt, Detail: attr(token, "src")})
+				}
+				if link != nil && strings.TrimSpace(alt) != "" {
+					linkNamed = true
+				}
+			case "a":
+				if _, hasHref := attrOK(token, "href"); hasHref && tokenType == html.StartTagToken {
+					opened := token
+					link = &opened
+					linkNamed = strings.TrimSpace(attr(token, "aria-label")) != "" || strings.TrimSpace(attr(token, "title")) != ""
+				}
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = false
+			case "a":
+				if link != nil && !linkNamed {
+					issues = append(issues, Issue{Kind: EmptyLinkText, Detail: attr(*link, "href")})
+				}
+				link = nil
+			}
+		case html.TextToken:
+			if inTitle {
+				title.WriteString(token.Data)
+			}
+			if link != nil && strings.TrimSpace(token.Data) != "" {
+				linkNamed = true
+			}
+		}
+	}
+
+	// A fragment without an <html> tag isn't a document, so it can't be expected to have a title
+	if foundHTML && (!foundTitle || strings.TrimSpace(title.String()) == "") {
+		issues = append(issues, Issue{Kind: MissingTitle})
+	}
+
+	return issues
+}
+
+func attr(token html.Token, name string) string {
+	value, _ := attrOK(token, name)
+	return value
+}
+
+func attrOK(token html.Token, name string) (string, bool) {
+	for _, attribute := range token.Attr {
+		if attribute.Key == name {
+			return attribute.Val, true
+		}
+	}
+	return "", false
+}
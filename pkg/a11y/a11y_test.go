@@ -0,0 +1,35 @@
+package a11y
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	body := []byte(`<html><head><title>  </title></head><body>
+<img src="/logo.png">
+<img src="/spacer.gif" alt="">
+<a href="/home"><img src="/home.png" alt="Home"></a>
+<a href="/search"><svg></svg></a>
+<a href="/next" aria-label="Next page"></a>
+<a name="anchor"></a>
+<a href="/about">About <b>us</b></a>
+</body></html>`)
+
+	expected := []Issue{
+		{Kind: MissingLang},
+		{Kind: MissingAlt, Detail: "/logo.png"},
+		{Kind: EmptyLinkText, Detail: "/search"},
+		{Kind: MissingTitle},
+	}
+	if issues := Check(body); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("Expected %v, got %v", expected, issues)
+	}
+}
+
+func TestCheckCleanDocument(t *testing.T) {
+	body := []byte(`<!DOCTYPE html><html lang="en"><head><title>Fine</title></head><body><a href="/">Home</a></body></html>`)
+	if issues := Check(body); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
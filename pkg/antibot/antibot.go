@@ -0,0 +1,168 @@
+// Package antibot recognizes responses where a server, or the bot protection in front of it, is
+// throttling or blocking a crawler rather than serving what was asked for
+package antibot
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// Kinds of pushback
+const (
+	// Told to slow down, usually with a 429
+	RateLimited string = "rate limited"
+	// Served a challenge, like a CAPTCHA or a JavaScript check, that a crawler can't pass
+	Challenged string = "challenged"
+	// Refused outright by a firewall
+	Blocked string = "blocked"
+)
+
+// Verdict is what a response says about how the crawler is being treated
+type Verdict struct {
+	Kind string
+	// Who's doing it, like cloudflare or akamai, empty when it's the site itself or unknown
+	Vendor string
+	// The Retry-After header, if any
+	RetryAfter string
+}
+
+// signature recognizes a vendor's pages by a header, by text in the body, or both
+type signature struct {
+	vendor string
+	kind   string
+
+	// Header that must be present, and when value isn't empty, contain value
+	header string
+	value  string
+	body   string
+}
+
+// Checked in order, challenges before blocks since vendors serve both under the same headers
+var signatures = []signature{
+	{vendor: "cloudflare", kind: Challenged, header: "Cf-Mitigated", value: "challenge"},
+	{vendor: "cloudflare", kind: Challenged, header: "Server", value: "cloudflare", body: "challenge-platform"},
+	{vendor: "cloudflare", kind: Challenged, header: "Server", value: "cloudflare", body: "cf-browser-verification"},
+	{vendor: "cloudflare", kind: Challenged, header: "Server", value: "cloudflare", body: "<title>Just a moment...</title>"},
+	{vendor: "cloudflare", kind: Blocked, header: "Server", value: "cloudflare", body: "cf-error-details"},
+	{vendor: "akamai", kind: Blocked, header: "Server", value: "AkamaiGHost"},
+	{vendor: "akamai", kind: Blocked, body: "errors.edgesuite.net"},
+	{vendor: "imperva", kind: Challenged, body: "_Incapsula_Resource"},
+	{vendor: "imperva", kind: Blocked, header: "X-Iinfo"},
+	{vendor: "datadome", kind: Challenged, body: "captcha-delivery.com"},
+	{vendor: "datadome", kind: Blocked, header: "X-Datadome"},
+	{vendor: "perimeterx", kind: Challenged, body: "px-captcha"},
+	{vendor: "sucuri", kind: Blocked, header: "X-Sucuri-Id"},
+	{vendor: "sucuri", kind: Blocked, body: "Sucuri WebSite Firewall"},
+}
+
+// Detect looks at an error response, with the start of its body, for signs of throttling or bot protection
+// Successful responses are never a verdict, nor are plain 403s that could just be a page that's off limits
+// A vendor's header alone only marks a block on a 403 or 429, as it's on every error page the vendor serves
+func Detect(status int, header http.Header, body []byte) (Verdict, bool) {
+	if status < 400 {
+		return Verdict{}, false
+	}
+	refused := status == http.StatusForbidden || status == http.StatusTooManyRequests
+
+	verdict := Verdict{RetryAfter: header.Get("Retry-After")}
+	for _, sig := range signatures {
+		if sig.kind == Blocked && sig.body == "" && !refused {
+			continue
+		}
+		if sig.matches(header, body) {
+			verdict.Vendor = sig.vendor
+			verdict.Kind = sig.kind
+			break
+		}
+	}
+
+	// Whoever is behind it, a 429 is a request to slow down
+	if status == http.StatusTooManyRequests {
+		verdict.Kind = RateLimited
+	}
+	return verdict, verdict.Kind != ""
+}
+
+func (sig signature) matches(header http.Header, body []byte) bool {
+	if sig.header != "" {
+		values, ok := header[http.CanonicalHeaderKey(sig.header)]
+		if !ok {
+			return false
+		}
+		if sig.value != "" && !strings.Contains(strings.ToLower(strings.Join(values, " ")), strings.ToLower(sig.value)) {
+			return false
+		}
+	}
+	return sig.body == "" || bytes.Contains(body, []byte(sig.body))
+}
+
+// Widgets that make a page a CAPTCHA wall when the page is about nothing but proving you're human
+var captchaWidgets = []string{"g-recaptcha", "h-captcha", "cf-turnstile", "frc-captcha"}
+
+var captchaTitles = []string{"verify", "human", "robot", "captcha", "security check", "attention required", "access denied"}
+
+// Titles of the interstitials vendors serve in place of the page, on top of captchaTitles
+var interstitialTitles = []string{"just a moment", "one more step", "please wait", "checking your browser"}
+
+// Elements only the vendors' interstitials are built from, lowercased
+var interstitialMarkers = []string{
+	`id="challenge-form"`, `id="challenge-body-text"`, `id="challenge-stage"`, `id="cf-challenge-running"`,
+	`class="cf-browser-verification`, `id="px-captcha"`, `var dd={`,
+}
+
+// Challenge looks at a page served as if it were content for signs that it's really a challenge
+// interstitial, a JavaScript check or a CAPTCHA wall, returning a Challenged verdict when it is
+// Plenty of real pages load a vendor's challenge scripts or have a CAPTCHA on them, like contact forms, so
+// either only counts when the page's title or structure is that of an interstitial too
+func Challenge(header http.Header, body []byte) (Verdict, bool) {
+	lower := bytes.ToLower(body)
+	if !interstitial(lower) {
+		return Verdict{}, false
+	}
+
+	for _, sig := range signatures {
+		if sig.kind == Challenged && sig.body != "" && sig.matches(header, body) {
+			return Verdict{Kind: Challenged, Vendor: sig.vendor}, true
+		}
+	}
+	for _, widget := range captchaWidgets {
+		if bytes.Contains(lower, []byte(widget)) {
+			return Verdict{Kind: Challenged}, true
+		}
+	}
+	return Verdict{}, false
+}
+
+// interstitial is whether a lowercased page is titled or built like a challenge interstitial
+func interstitial(lower []byte) bool {
+	if captchaTitle(lower) {
+		return true
+	}
+	for _, marker := range interstitialMarkers {
+		if bytes.Contains(lower, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// captchaTitle is whether a lowercased page's title is about telling humans and bots apart
+func captchaTitle(lower []byte) bool {
+	start := bytes.Index(lower, []byte("<title"))
+	if start < 0 {
+		return false
+	}
+	end := bytes.Index(lower[start:], []byte("</title>"))
+	if end < 0 {
+		return false
+	}
+
+	title := lower[start : start+end]
+	for _, word := range append(captchaTitles, interstitialTitles...) {
+		if bytes.Contains(title, []byte(word)) {
+			return true
+		}
+	}
+	return false
+}
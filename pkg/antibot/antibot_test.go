@@ -0,0 +1,66 @@
+package antibot
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		body    string
+		verdict Verdict
+		ok      bool
+	}{
+		{"ok", 200, http.Header{"Server": {"cloudflare"}}, "challenge-platform", Verdict{}, false},
+		{"plain 403", 403, http.Header{"Server": {"nginx"}}, "Forbidden", Verdict{}, false},
+		{"plain 404 behind cloudflare", 404, http.Header{"Server": {"cloudflare"}}, "Not found", Verdict{}, false},
+		{"429", 429, http.Header{"Retry-After": {"120"}}, "", Verdict{Kind: RateLimited, RetryAfter: "120"}, true},
+		{"429 from cloudflare", 429, http.Header{"Server": {"cloudflare"}}, "<div id=\"cf-error-details\">", Verdict{Kind: RateLimited, Vendor: "cloudflare"}, true},
+		{"cloudflare managed challenge", 403, http.Header{"Server": {"cloudflare"}, "Cf-Mitigated": {"challenge"}}, "", Verdict{Kind: Challenged, Vendor: "cloudflare"}, true},
+		{"cloudflare interstitial", 503, http.Header{"Server": {"cloudflare"}}, "<title>Just a moment...</title>", Verdict{Kind: Challenged, Vendor: "cloudflare"}, true},
+		{"cloudflare firewall", 403, http.Header{"Server": {"cloudflare"}}, "<div id=\"cf-error-details\">1020</div>", Verdict{Kind: Blocked, Vendor: "cloudflare"}, true},
+		{"akamai", 403, http.Header{"Server": {"AkamaiGHost"}}, "Access Denied", Verdict{Kind: Blocked, Vendor: "akamai"}, true},
+		{"404 behind akamai", 404, http.Header{"Server": {"AkamaiGHost"}}, "Not Found", Verdict{}, false},
+		{"500 behind imperva", 500, http.Header{"X-Iinfo": {"1-2-3"}}, "Internal Server Error", Verdict{}, false},
+		{"akamai error page", 503, http.Header{"Server": {"AkamaiGHost"}}, "Reference errors.edgesuite.net", Verdict{Kind: Blocked, Vendor: "akamai"}, true},
+		{"sucuri", 403, http.Header{"X-Sucuri-Id": {"1"}}, "", Verdict{Kind: Blocked, Vendor: "sucuri"}, true},
+		{"datadome", 403, http.Header{}, "<script src=\"https://ct.captcha-delivery.com/c.js\">", Verdict{Kind: Challenged, Vendor: "datadome"}, true},
+	}
+
+	for _, test := range tests {
+		verdict, ok := Detect(test.status, test.header, []byte(test.body))
+		if verdict != test.verdict || ok != test.ok {
+			t.Errorf("%s: expected %+v %v, got %+v %v", test.name, test.verdict, test.ok, verdict, ok)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    string
+		verdict Verdict
+		ok      bool
+	}{
+		{"article", http.Header{}, "<title>Our products</title><p>Buy things</p>", Verdict{}, false},
+		{"contact form", http.Header{}, `<title>Contact us</title><form><div class="g-recaptcha"></div></form>`, Verdict{}, false},
+		{"captcha wall", http.Header{}, `<title>Please verify you are a human</title><div class="h-captcha"></div>`, Verdict{Kind: Challenged}, true},
+		{"cloudflare js challenge", http.Header{"Server": {"cloudflare"}}, `<title>Just a moment...</title><script src="/cdn-cgi/challenge-platform/h/b/orchestrate/jsch/v1"></script>`, Verdict{Kind: Challenged, Vendor: "cloudflare"}, true},
+		{"cloudflare challenge form", http.Header{"Server": {"cloudflare"}}, `<title>example.com</title><form id="challenge-form"></form><script src="/cdn-cgi/challenge-platform/h/g/orchestrate/chl_page/v1"></script>`, Verdict{Kind: Challenged, Vendor: "cloudflare"}, true},
+		{"page loading cloudflare scripts", http.Header{"Server": {"cloudflare"}}, `<title>Our products</title><script src="/cdn-cgi/challenge-platform/scripts/jsd/main.js"></script>`, Verdict{}, false},
+		{"challenge scripts without cloudflare", http.Header{"Server": {"nginx"}}, `<title>Just a moment...</title><script src="/cdn-cgi/challenge-platform/h/b/orchestrate/jsch/v1"></script>`, Verdict{}, false},
+		{"datadome", http.Header{}, `<title>example.com</title><script>var dd={'rt':'c','cid':'x'}</script><script src="https://ct.captcha-delivery.com/c.js"></script>`, Verdict{Kind: Challenged, Vendor: "datadome"}, true},
+		{"page using datadome", http.Header{}, `<title>Shop</title><script src="https://ct.captcha-delivery.com/c.js"></script>`, Verdict{}, false},
+	}
+
+	for _, test := range tests {
+		verdict, ok := Challenge(test.header, []byte(test.body))
+		if verdict != test.verdict || ok != test.ok {
+			t.Errorf("%s: expected %+v %v, got %+v %v", test.name, test.verdict, test.ok, verdict, ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Package breaker stops requests to hosts that keep failing, giving them time to recover
+//
+// Each host has a circuit that opens after Threshold consecutive failures. While it's open requests
+// to the host should be held back, and once Cooldown has passed it closes again on probation:
+// a success closes it for good, a single failure opens it for another Cooldown.
+package breaker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Breaker keeps a circuit for every host, it's safe for concurrent use
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mutex sync.Mutex
+	hosts map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// New creates a Breaker opening circuits after threshold consecutive failures, for cooldown
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, hosts: make(map[string]*circuit)}
+}
+
+// Allow tells whether a request to host may be sent at now, and if not, when the circuit closes
+func (breaker *Breaker) Allow(host string, now time.Time) (bool, time.Time) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	state, ok := breaker.hosts[host]
+	if !ok || state.openUntil.IsZero() {
+		return true, time.Time{}
+	}
+	if now.Before(state.openUntil) {
+		return false, state.openUntil
+	}
+
+	// On probation, the next failure opens the circuit again
+	state.openUntil = time.Time{}
+	state.failures = breaker.Threshold - 1
+	return true, time.Time{}
+}
+
+// Record adds the outcome of a request to host, returning true if its failure opened the circuit
+func (breaker *Breaker) Record(host string, failed bool, now time.Time) bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	state, ok := breaker.hosts[host]
+	if !ok {
+		state = &circuit{}
+		breaker.hosts[host] = state
+	}
+
+	if !failed {
+		state.failures = 0
+		return false
+	}
+
+	state.failures++
+	// Requests sent before the circuit opened may still be failing, they don't open it any longer
+	if state.failures < breaker.Threshold || now.Before(state.openUntil) {
+		return false
+	}
+	state.openUntil = now.Add(breaker.Cooldown)
+	return true
+}
+
+// Open lists the hosts whose circuit is open at now, sorted
+func (breaker *Breaker) Open(now time.Time) []string {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	var hosts []string
+	for name, host := range breaker.hosts {
+		if now.Before(host.openUntil) {
+			hosts = append(hosts, name)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
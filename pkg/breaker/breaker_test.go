@@ -0,0 +1,54 @@
+package breaker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	breaker := New(3, time.Minute)
+	now := time.Now()
+
+	breaker.Record("down.example", true, now)
+	breaker.Record("down.example", true, now)
+	if ok, _ := breaker.Allow("down.example", now); !ok {
+		t.Errorf("Expected the circuit to stay closed below the threshold")
+	}
+	if !breaker.Record("down.example", true, now) {
+		t.Errorf("Expected the third failure to open the circuit")
+	}
+
+	// Failures of requests already sent don't reopen it
+	if breaker.Record("down.example", true, now) {
+		t.Errorf("Expected an already open circuit not to be opened again")
+	}
+
+	ok, until := breaker.Allow("down.example", now.Add(time.Second))
+	if ok || !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("Expected the circuit to be open until %v, got %v %v", now.Add(time.Minute), ok, until)
+	}
+	if open := breaker.Open(now); !reflect.DeepEqual(open, []string{"down.example"}) {
+		t.Errorf("Expected down.example to be open, got %v", open)
+	}
+
+	// After the cooldown a single failure is enough to open it again
+	later := now.Add(2 * time.Minute)
+	if ok, _ := breaker.Allow("down.example", later); !ok {
+		t.Errorf("Expected the circuit to close after the cooldown")
+	}
+	if !breaker.Record("down.example", true, later) {
+		t.Errorf("Expected a failure on probation to open the circuit again")
+	}
+}
+
+func TestBreakerSuccessResets(t *testing.T) {
+	breaker := New(2, time.Minute)
+	now := time.Now()
+
+	breaker.Record("flaky.example", true, now)
+	breaker.Record("flaky.example", false, now)
+	if breaker.Record("flaky.example", true, now) {
+		t.Errorf("Expected only consecutive failures to open the circuit")
+	}
+}
@@ -0,0 +1,158 @@
+// Package caching works out how a response may be cached from its headers
+package caching
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is what a response's headers say about caching it
+type Policy struct {
+	// Cache-Control as sent, normalized to lowercase with sorted directives, empty if missing
+	CacheControl string
+
+	// Whether a shared cache (a CDN or proxy) may store and reuse the response
+	Cacheable bool
+
+	// Why a response isn't cacheable, empty when it is
+	Reason string
+
+	// How long a shared cache may reuse the response, zero when unknown or uncacheable
+	MaxAge time.Duration
+
+	// The CDN that served the response and whether it was a hit there ("hit", "miss" or another
+	// status the CDN reports), both empty when no CDN was detected
+	CDN       string
+	CDNStatus string
+}
+
+// Reasons a response isn't cacheable
+const (
+	ReasonNoStore   string = "no-store"
+	ReasonPrivate   string = "private"
+	ReasonNoCache   string = "no-cache"
+	ReasonExpired   string = "expires immediately"
+	ReasonNoHeaders string = "no caching headers"
+)
+
+// Analyze reads the caching headers of a response received at now
+func Analyze(header http.Header, now time.Time) Policy {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	policy := Policy{CacheControl: formatCacheControl(directives)}
+	policy.CDN, policy.CDNStatus = detectCDN(header)
+
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	_, noCache := directives["no-cache"]
+
+	switch {
+	case noStore:
+		policy.Reason = ReasonNoStore
+	case private:
+		policy.Reason = ReasonPrivate
+	case noCache:
+		policy.Reason = ReasonNoCache
+	default:
+		maxAge, hasMaxAge := maxAge(directives)
+		if !hasMaxAge {
+			if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+				maxAge, hasMaxAge = expires.Sub(now), true
+			} else if header.Get("Expires") != "" {
+				// Invalid dates, usually "0" or "-1", mean already expired
+				maxAge, hasMaxAge = 0, true
+			}
+		}
+
+		switch {
+		case !hasMaxAge && header.Get("Last-Modified") == "" && header.Get("ETag") == "":
+			policy.Reason = ReasonNoHeaders
+		case hasMaxAge && maxAge <= 0:
+			policy.Reason = ReasonExpired
+		default:
+			policy.Cacheable = true
+			if maxAge > 0 {
+				policy.MaxAge = maxAge
+			}
+		}
+	}
+
+	return policy
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+
+		name, argument := directive, ""
+		if i := strings.Index(directive, "="); i >= 0 {
+			name, argument = strings.TrimSpace(directive[:i]), strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+		}
+		directives[name] = argument
+	}
+	return directives
+}
+
+func formatCacheControl(directives map[string]string) string {
+	formatted := make([]string, 0, len(directives))
+	for name, argument := range directives {
+		if argument != "" {
+			name += "=" + argument
+		}
+		formatted = append(formatted, name)
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ", ")
+}
+
+// s-maxage applies to shared caches and takes precedence over max-age
+func maxAge(directives map[string]string) (time.Duration, bool) {
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if argument, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(argument)
+			if err != nil {
+				return 0, true
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// Headers identifying a CDN, with the header holding its cache status if it has one
+var cdnHeaders = []struct {
+	cdn    string
+	header string
+	status string
+}{
+	{"cloudflare", "Cf-Ray", "Cf-Cache-Status"},
+	{"cloudfront", "X-Amz-Cf-Id", "X-Cache"},
+	{"fastly", "X-Served-By", "X-Cache"},
+	{"akamai", "X-Akamai-Transformed", "X-Cache"},
+	{"vercel", "X-Vercel-Id", "X-Vercel-Cache"},
+	{"netlify", "X-Nf-Request-Id", "Cache-Status"},
+}
+
+func detectCDN(header http.Header) (string, string) {
+	for _, known := range cdnHeaders {
+		if header.Get(known.header) == "" {
+			continue
+		}
+
+		status := strings.ToLower(header.Get(known.status))
+		switch {
+		case strings.Contains(status, "hit"):
+			status = "hit"
+		case strings.Contains(status, "miss"):
+			status = "miss"
+		}
+		return known.cdn, status
+	}
+	return "", ""
+}
@@ -0,0 +1,50 @@
+package caching
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		header    http.Header
+		cacheable bool
+		reason    string
+		maxAge    time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=60, s-maxage=3600"}}, true, "", time.Hour},
+		{http.Header{"Cache-Control": {"no-cache"}, "Expires": {"Thu, 01 Jan 2099 00:00:00 GMT"}}, false, ReasonNoCache, 0},
+		{http.Header{"Cache-Control": {"Private, max-age=600"}}, false, ReasonPrivate, 0},
+		{http.Header{"Cache-Control": {"max-age=0"}}, false, ReasonExpired, 0},
+		{http.Header{"Expires": {"0"}}, false, ReasonExpired, 0},
+		{http.Header{"Expires": {"Wed, 01 Jan 2020 01:00:00 GMT"}}, true, "", time.Hour},
+		{http.Header{"Etag": {`"abc"`}}, true, "", 0},
+		{http.Header{}, false, ReasonNoHeaders, 0},
+	}
+
+	for _, c := range cases {
+		policy := Analyze(c.header, now)
+		if policy.Cacheable != c.cacheable || policy.Reason != c.reason || policy.MaxAge != c.maxAge {
+			t.Errorf("Expected %v to be %v %q %v, got %+v", c.header, c.cacheable, c.reason, c.maxAge, policy)
+		}
+	}
+}
+
+func TestAnalyzeNormalizesAndDetectsCDN(t *testing.T) {
+	header := http.Header{
+		"Cache-Control":   {"Public,  MAX-AGE=300"},
+		"Cf-Ray":          {"123-AMS"},
+		"Cf-Cache-Status": {"HIT"},
+	}
+
+	policy := Analyze(header, time.Now())
+	if policy.CacheControl != "max-age=300, public" {
+		t.Errorf("Expected a normalized Cache-Control, got %q", policy.CacheControl)
+	}
+	if policy.CDN != "cloudflare" || policy.CDNStatus != "hit" {
+		t.Errorf("Expected a Cloudflare hit, got %q %q", policy.CDN, policy.CDNStatus)
+	}
+}
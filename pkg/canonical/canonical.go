@@ -0,0 +1,333 @@
+// Package canonical normalizes URLs so the same page isn't crawled under several names
+//
+// Normalization is a Chain of Steps applied in order. Steps are registered by name, so a chain
+// can be described on the command line, and packages can add domain-specific steps of their own:
+//
+//	func init() {
+//		canonical.Register("shop-pages", func(args []string) (canonical.Step, error) {
+//			return canonical.ForHost("shop.example.com", canonical.DropQuery("page", "sort")), nil
+//		})
+//	}
+//
+// A chain is described as a comma separated list of step names, each optionally followed by
+// colon separated arguments, e.g. "fragment,host,drop-query:utm_source:utm_medium".
+package canonical
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Step rewrites a URL in place
+type Step func(u *url.URL)
+
+// Chain is a list of steps applied in order
+type Chain []Step
+
+// Apply returns the canonical form of u, leaving u untouched
+func (chain Chain) Apply(u url.URL) url.URL {
+	// User is a pointer, everything else is copied with u
+	if u.User != nil {
+		user := *u.User
+		u.User = &user
+	}
+
+	for _, step := range chain {
+		step(&u)
+	}
+	return u
+}
+
+// Default is the chain used when nothing else is configured
+const Default = "fragment,host,port,query"
+
+// StripFragment drops the #fragment, which never changes what the server sends
+func StripFragment(u *url.URL) {
+	u.Fragment = ""
+}
+
+// StripNonRouteFragment drops the #fragment unless it's a single page app route, like #/about or #!/about,
+// which the app's scripts render as a page of its own
+func StripNonRouteFragment(u *url.URL) {
+	if !Route(u.Fragment) {
+		u.Fragment = ""
+	}
+}
+
+// Route is whether a fragment is a single page app route, like /about or !/about
+func Route(fragment string) bool {
+	return strings.HasPrefix(fragment, "/") || strings.HasPrefix(fragment, "!")
+}
+
+// LowercaseHost lowercases the scheme and host, which are case insensitive
+func LowercaseHost(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+}
+
+// StripDefaultPort drops :80 from http and :443 from https URLs
+func StripDefaultPort(u *url.URL) {
+	port := u.Port()
+	if (port == "80" && strings.EqualFold(u.Scheme, "http")) || (port == "443" && strings.EqualFold(u.Scheme, "https")) {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+}
+
+// SortQuery orders query parameters by name, keeping the order of repeated parameters
+func SortQuery(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	u.RawQuery = u.Query().Encode()
+}
+
+// DropQuery removes the named query parameters, such as tracking or pagination parameters
+func DropQuery(names ...string) Step {
+	return func(u *url.URL) {
+		if u.RawQuery == "" {
+			return
+		}
+
+		query := u.Query()
+		for _, name := range names {
+			query.Del(name)
+		}
+		u.RawQuery = query.Encode()
+	}
+}
+
+// StripQuery removes the whole query string, for sites where it never makes a page different
+func StripQuery(u *url.URL) {
+	u.RawQuery = ""
+	u.ForceQuery = false
+}
+
+// KeepQuery removes every query parameter other than those named, like the id of /item?id=1
+func KeepQuery(names ...string) Step {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	return func(u *url.URL) {
+		if u.RawQuery == "" {
+			return
+		}
+
+		query := u.Query()
+		for name := range query {
+			if !keep[name] {
+				query.Del(name)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+}
+
+// ByHost applies the step given for a URL's host, or fallback to URLs on any other host when it isn't nil
+// Hosts are matched case-insensitively
+func ByHost(steps map[string]Step, fallback Step) Step {
+	byHost := make(map[string]Step, len(steps))
+	for host, step := range steps {
+		byHost[strings.ToLower(host)] = step
+	}
+
+	return func(u *url.URL) {
+		if step, ok := byHost[strings.ToLower(u.Hostname())]; ok {
+			step(u)
+		} else if fallback != nil {
+			fallback(u)
+		}
+	}
+}
+
+// ForHost only applies step to URLs on the given host
+func ForHost(host string, step Step) Step {
+	return func(u *url.URL) {
+		if strings.EqualFold(u.Hostname(), host) {
+			step(u)
+		}
+	}
+}
+
+// AliasHost rewrites URLs on any of the aliases, such as a www. variant or a CDN hostname, onto host,
+// so they're treated as the same site. Ports are kept
+func AliasHost(host string, aliases ...string) Step {
+	return func(u *url.URL) {
+		for _, alias := range aliases {
+			if strings.EqualFold(u.Hostname(), alias) {
+				if port := u.Port(); port != "" {
+					u.Host = host + ":" + port
+				} else {
+					u.Host = host
+				}
+				return
+			}
+		}
+	}
+}
+
+// Directory index files servers usually answer for the directory itself
+var indexFiles = []string{"index.html", "index.htm", "index.php", "default.aspx", "default.asp"}
+
+// StripIndex drops a directory index file from the end of the path, so /docs/index.html becomes /docs/
+func StripIndex(u *url.URL) {
+	for _, index := range indexFiles {
+		if strings.HasSuffix(strings.ToLower(u.Path), "/"+index) {
+			u.Path = u.Path[:len(u.Path)-len(index)]
+			u.RawPath = ""
+			return
+		}
+	}
+}
+
+// StripTrailingSlash drops the slash from the end of any path but the root
+func StripTrailingSlash(u *url.URL) {
+	if len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+}
+
+// LowercasePath lowercases the path, which only case insensitive servers treat as the same page
+func LowercasePath(u *url.URL) {
+	u.Path = strings.ToLower(u.Path)
+	u.RawPath = ""
+}
+
+// Variants maps URLs that only differ by an index file, trailing slash or path case to the same URL
+// Such URLs usually serve the same page, but not always, so it's not part of Default; it's for spotting them
+var Variants = Chain{StripIndex, StripTrailingSlash, LowercasePath}
+
+// Differences names how two variants of the same URL differ: "index", "trailing-slash" and/or "case"
+func Differences(a, b url.URL) []string {
+	var differences []string
+
+	indexA, indexB := a, b
+	StripIndex(&indexA)
+	StripIndex(&indexB)
+	if (indexA.Path != a.Path) != (indexB.Path != b.Path) {
+		differences = append(differences, "index")
+	}
+
+	slashA, slashB := indexA, indexB
+	StripTrailingSlash(&slashA)
+	StripTrailingSlash(&slashB)
+	if (slashA.Path != indexA.Path) != (slashB.Path != indexB.Path) {
+		differences = append(differences, "trailing-slash")
+	}
+
+	if slashA.Path != slashB.Path && strings.EqualFold(slashA.Path, slashB.Path) {
+		differences = append(differences, "case")
+	}
+
+	return differences
+}
+
+// Factory builds a step from the arguments given after its name
+type Factory func(args []string) (Step, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a step available by name
+// Registering the same name twice panics, as that's almost certainly two packages fighting over it
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("canonical: %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Names lists every registered step
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse builds a chain from its description, see the package documentation
+func Parse(description string) (Chain, error) {
+	var chain Chain
+
+	for _, spec := range strings.Split(description, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		parts := strings.Split(spec, ":")
+		registryMutex.RLock()
+		factory, ok := registry[parts[0]]
+		registryMutex.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("canonical: unknown step %q, expected one of %v", parts[0], Names())
+		}
+
+		step, err := factory(parts[1:])
+		if err != nil {
+			return nil, fmt.Errorf("canonical: %s: %v", parts[0], err)
+		}
+		chain = append(chain, step)
+	}
+
+	return chain, nil
+}
+
+// simple registers a step that takes no arguments
+func simple(name string, step Step) {
+	Register(name, func(args []string) (Step, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("takes no arguments")
+		}
+		return step, nil
+	})
+}
+
+func init() {
+	simple("fragment", StripFragment)
+	simple("route-fragment", StripNonRouteFragment)
+	simple("host", LowercaseHost)
+	simple("port", StripDefaultPort)
+	simple("query", SortQuery)
+	simple("no-query", StripQuery)
+	simple("index", StripIndex)
+	simple("trailing-slash", StripTrailingSlash)
+	simple("path-case", LowercasePath)
+
+	Register("drop-query", func(args []string) (Step, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected the parameters to drop, like drop-query:page")
+		}
+		return DropQuery(args...), nil
+	})
+
+	Register("keep-query", func(args []string) (Step, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("expected the parameters to keep, like keep-query:id")
+		}
+		return KeepQuery(args...), nil
+	})
+
+	Register("alias", func(args []string) (Step, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("expected the canonical host then its aliases, like alias:example.com:www.example.com")
+		}
+		return AliasHost(strings.ToLower(args[0]), args[1:]...), nil
+	})
+}
@@ -0,0 +1,161 @@
+package canonical
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultChain(t *testing.T) {
+	chain, err := Parse(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"HTTP://Example.COM:80/Path?b=2&a=1#top": "http://example.com/Path?a=1&b=2",
+		"https://example.com:443/":               "https://example.com/",
+		"https://example.com:8443/":              "https://example.com:8443/",
+		"http://example.com/?b=2&a=1&b=1":        "http://example.com/?a=1&b=2&b=1",
+	}
+
+	for raw, expected := range cases {
+		parsedURL, _ := url.Parse(raw)
+		before := parsedURL.String()
+		canonical := chain.Apply(*parsedURL)
+		if canonical.String() != expected {
+			t.Errorf("Expected %s to become %s, got %s", raw, expected, canonical.String())
+		}
+		if parsedURL.String() != before {
+			t.Errorf("Expected %s to be left untouched, got %s", before, parsedURL.String())
+		}
+	}
+}
+
+func TestCustomStep(t *testing.T) {
+	Register("test-shop", func(args []string) (Step, error) {
+		return ForHost("shop.example.com", DropQuery(args...)), nil
+	})
+
+	chain, err := Parse("test-shop:page, fragment")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"http://shop.example.com/shoes?page=2&color=red": "http://shop.example.com/shoes?color=red",
+		"http://blog.example.com/posts?page=2#comments":  "http://blog.example.com/posts?page=2",
+	}
+
+	for raw, expected := range cases {
+		parsedURL, _ := url.Parse(raw)
+		if canonical := chain.Apply(*parsedURL); canonical.String() != expected {
+			t.Errorf("Expected %s to become %s, got %s", raw, expected, canonical.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, description := range []string{"nope", "fragment:x", "drop-query", "keep-query", "alias:example.com"} {
+		if _, err := Parse(description); err == nil {
+			t.Errorf("Expected %q to fail", description)
+		}
+	}
+}
+
+func TestAliasHost(t *testing.T) {
+	chain, err := Parse("host,alias:example.com:www.example.com:cdn.example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"http://WWW.example.com/about":       "http://example.com/about",
+		"https://cdn.example.net:8443/a.css": "https://example.com:8443/a.css",
+		"http://blog.example.com/":           "http://blog.example.com/",
+	}
+
+	for raw, expected := range cases {
+		parsedURL, _ := url.Parse(raw)
+		if canonical := chain.Apply(*parsedURL); canonical.String() != expected {
+			t.Errorf("Expected %s to become %s, got %s", raw, expected, canonical.String())
+		}
+	}
+}
+
+func TestQuerySignificance(t *testing.T) {
+	chain := Chain{ByHost(map[string]Step{
+		"Shop.example.com": KeepQuery("id"),
+		"news.example.com": func(u *url.URL) {},
+	}, StripQuery)}
+
+	cases := map[string]string{
+		"http://example.com/search?q=go&page=2":          "http://example.com/search",
+		"http://shop.example.com/item?id=1&utm_source=x": "http://shop.example.com/item?id=1",
+		"http://shop.example.com/item?id=2":              "http://shop.example.com/item?id=2",
+		"http://news.example.com/story?id=7&ref=home":    "http://news.example.com/story?id=7&ref=home",
+	}
+
+	for raw, expected := range cases {
+		parsedURL, _ := url.Parse(raw)
+		if canonical := chain.Apply(*parsedURL); canonical.String() != expected {
+			t.Errorf("Expected %s to become %s, got %s", raw, expected, canonical.String())
+		}
+	}
+
+	parsed, err := Parse("no-query,keep-query:id")
+	if err != nil || len(parsed) != 2 {
+		t.Errorf("Expected the query steps to be registered, got %v", err)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	cases := []struct {
+		a, b        string
+		differences []string
+	}{
+		{"http://example.com/docs/", "http://example.com/docs", []string{"trailing-slash"}},
+		{"http://example.com/docs/index.html", "http://example.com/docs/", []string{"index"}},
+		{"http://example.com/Docs/Index.HTML", "http://example.com/docs", []string{"index", "trailing-slash", "case"}},
+		{"http://example.com/About", "http://example.com/about/", []string{"trailing-slash", "case"}},
+	}
+
+	for _, c := range cases {
+		a, _ := url.Parse(c.a)
+		b, _ := url.Parse(c.b)
+
+		variantA, variantB := Variants.Apply(*a), Variants.Apply(*b)
+		if variantA.String() != variantB.String() {
+			t.Errorf("Expected %s and %s to be variants, got %s and %s", c.a, c.b, variantA.String(), variantB.String())
+		}
+		if differences := Differences(*a, *b); !reflect.DeepEqual(differences, c.differences) {
+			t.Errorf("%s and %s: expected %v, got %v", c.a, c.b, c.differences, differences)
+		}
+	}
+
+	root, _ := url.Parse("http://example.com/")
+	if variant := Variants.Apply(*root); variant.String() != "http://example.com/" {
+		t.Errorf("Expected the root to keep its slash, got %s", variant.String())
+	}
+}
+
+func TestRouteFragments(t *testing.T) {
+	chain, err := Parse("route-fragment")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"http://example.com/app#/about":     "http://example.com/app#/about",
+		"http://example.com/app#!/users/1":  "http://example.com/app#!/users/1",
+		"http://example.com/docs#section-2": "http://example.com/docs",
+		"http://example.com/docs":           "http://example.com/docs",
+	}
+
+	for raw, expected := range cases {
+		parsedURL, _ := url.Parse(raw)
+		if canonical := chain.Apply(*parsedURL); canonical.String() != expected {
+			t.Errorf("Expected %s to become %s, got %s", raw, expected, canonical.String())
+		}
+	}
+}
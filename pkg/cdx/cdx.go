@@ -0,0 +1,179 @@
+// Package cdx finds the URLs a web archive's CDX index knows of on a host, such as Common Crawl's or the
+// Wayback Machine's, so crawls of sites whose pages aren't all linked to can start from more of them
+package cdx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Indexes known by name, anything else is taken to be the URL of a CDX API
+const (
+	CommonCrawl string = "commoncrawl"
+	Wayback     string = "wayback"
+)
+
+// Index queries one CDX API
+type Index struct {
+	// Internal http Client
+	client *http.Client
+
+	// The CDX API, or for Common Crawl empty until the latest collection's is looked up
+	endpoint string
+
+	// Where Common Crawl lists its collections, newest first
+	collections string
+
+	// What the index calls the field holding a capture's URL
+	field string
+}
+
+// New will construct a new Index for a named index or the URL of a CDX API
+// If no http.Client is provided, we'll use the default one
+func New(client *http.Client, index string) *Index {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	switch index {
+	case CommonCrawl:
+		return &Index{client: client, collections: "https://index.commoncrawl.org/collinfo.json", field: "url"}
+	case Wayback:
+		return &Index{client: client, endpoint: "https://web.archive.org/cdx/search/cdx", field: "original"}
+	default:
+		return &Index{client: client, endpoint: index, field: "url"}
+	}
+}
+
+// URLs lists up to limit distinct URLs the index has captured on host, in the order the index gives them
+func (index *Index) URLs(host string, limit int) ([]string, error) {
+	if index.endpoint == "" {
+		endpoint, err := index.latestCollection()
+		if err != nil {
+			return nil, err
+		}
+		index.endpoint = endpoint
+	}
+
+	query := url.Values{}
+	query.Set("url", host+"/*")
+	query.Set("output", "json")
+	query.Set("fl", index.field)
+	query.Set("limit", strconv.Itoa(limit))
+
+	response, err := index.client.Get(index.endpoint + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// Common Crawl answers a host it has nothing on with a 404
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cdx: querying %s for %s returned %d", index.endpoint, host, response.StatusCode)
+	}
+
+	captured, err := readURLs(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cdx: reading %s: %v", index.endpoint, err)
+	}
+
+	// An index lists a URL once per capture
+	seen := make(map[string]bool, len(captured))
+	urls := make([]string, 0, len(captured))
+	for _, captured := range captured {
+		if !seen[captured] && len(urls) < limit {
+			seen[captured] = true
+			urls = append(urls, captured)
+		}
+	}
+	return urls, nil
+}
+
+// latestCollection finds the CDX API of Common Crawl's newest collection
+func (index *Index) latestCollection() (string, error) {
+	response, err := index.client.Get(index.collections)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cdx: listing collections at %s returned %d", index.collections, response.StatusCode)
+	}
+
+	var collections []struct {
+		API string `json:"cdx-api"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&collections); err != nil {
+		return "", err
+	}
+	if len(collections) == 0 || collections[0].API == "" {
+		return "", fmt.Errorf("cdx: no collections listed at %s", index.collections)
+	}
+	return collections[0].API, nil
+}
+
+// readURLs reads the url field out of either kind of JSON output CDX servers give: an object per line,
+// as Common Crawl's does, or an array of rows with the field names first, as the Wayback Machine's does
+func readURLs(body io.Reader) ([]string, error) {
+	reader := bufio.NewReader(body)
+	first, err := reader.Peek(1)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	if bytes.Equal(first, []byte("[")) {
+		var rows [][]string
+		if err := json.NewDecoder(reader).Decode(&rows); err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, nil
+		}
+
+		column := -1
+		for i, name := range rows[0] {
+			if name == "url" || name == "original" {
+				column = i
+			}
+		}
+		if column < 0 {
+			return nil, fmt.Errorf("no url field in %s", strings.Join(rows[0], ", "))
+		}
+		for _, row := range rows[1:] {
+			if column < len(row) {
+				urls = append(urls, row[column])
+			}
+		}
+		return urls, nil
+	}
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var capture struct {
+			URL string `json:"url"`
+		}
+		if err := decoder.Decode(&capture); err == io.EOF {
+			return urls, nil
+		} else if err != nil {
+			return urls, err
+		}
+		if capture.URL != "" {
+			urls = append(urls, capture.URL)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cdx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCommonCrawl(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/collinfo.json":
+			fmt.Fprintf(w, `[{"id": "CC-MAIN-2", "cdx-api": "%s/CC-MAIN-2-index"}, {"id": "CC-MAIN-1", "cdx-api": "%s/CC-MAIN-1-index"}]`, server.URL, server.URL)
+		case "/CC-MAIN-2-index":
+			if r.URL.Query().Get("url") != "example.com/*" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintln(w, `{"url": "http://example.com/"}`)
+			fmt.Fprintln(w, `{"url": "http://example.com/orphan"}`)
+			fmt.Fprintln(w, `{"url": "http://example.com/"}`)
+		default:
+			t.Errorf("Unexpected request for %s", r.URL)
+		}
+	}))
+	defer server.Close()
+
+	index := New(server.Client(), CommonCrawl)
+	index.collections = server.URL + "/collinfo.json"
+
+	urls, err := index.URLs("example.com", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"http://example.com/", "http://example.com/orphan"}; !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected %v from the latest collection, got %v", expected, urls)
+	}
+
+	if urls, err := index.URLs("example.org", 10); err != nil || len(urls) != 0 {
+		t.Errorf("Expected nothing for an unknown host, got %v (%v)", urls, err)
+	}
+}
+
+func TestWaybackRows(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[["original"], ["http://example.com/a"], ["http://example.com/b"], ["http://example.com/c"]]`)
+	}))
+	defer server.Close()
+
+	urls, err := New(server.Client(), server.URL).URLs("example.com", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"http://example.com/a", "http://example.com/b"}; !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected %v, got %v", expected, urls)
+	}
+}
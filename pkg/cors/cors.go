@@ -0,0 +1,61 @@
+// Package cors reads the CORS headers a server sends back for a cross-origin request
+package cors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Problems a CORS policy can have
+const (
+	// Any origin, with credentials; browsers refuse this, but it shows what the server meant to allow
+	WildcardWithCredentials string = "wildcard-with-credentials"
+
+	// Whatever origin asks is allowed
+	ReflectedOrigin string = "reflected-origin"
+
+	// Whatever origin asks is allowed to read responses made with the user's cookies
+	ReflectedWithCredentials string = "reflected-origin-with-credentials"
+
+	// Sandboxed frames and local files, which anyone can create, are allowed
+	NullOrigin string = "null-origin"
+)
+
+// Policy is how a server answered a request from Origin
+type Policy struct {
+	Origin           string
+	AllowOrigin      string
+	AllowCredentials bool
+}
+
+// Read picks the CORS headers out of the response to a request sent from origin
+func Read(origin string, header http.Header) Policy {
+	return Policy{
+		Origin:           origin,
+		AllowOrigin:      strings.TrimSpace(header.Get("Access-Control-Allow-Origin")),
+		AllowCredentials: strings.EqualFold(strings.TrimSpace(header.Get("Access-Control-Allow-Credentials")), "true"),
+	}
+}
+
+// Problems lists what's wrong with the policy, if anything
+// Origin should be one the server has no reason to trust, so allowing it means it allows anyone
+func (policy Policy) Problems() []string {
+	var problems []string
+
+	switch {
+	case policy.AllowOrigin == "*":
+		if policy.AllowCredentials {
+			problems = append(problems, WildcardWithCredentials)
+		}
+	case policy.AllowOrigin == "null" && policy.Origin == "null":
+		problems = append(problems, NullOrigin)
+	case policy.AllowOrigin != "" && strings.EqualFold(policy.AllowOrigin, policy.Origin):
+		if policy.AllowCredentials {
+			problems = append(problems, ReflectedWithCredentials)
+		} else {
+			problems = append(problems, ReflectedOrigin)
+		}
+	}
+
+	return problems
+}
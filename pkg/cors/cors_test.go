@@ -0,0 +1,40 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProblems(t *testing.T) {
+	const origin = "https://canary.example"
+
+	cases := []struct {
+		origin      string
+		allow       string
+		credentials string
+		problems    []string
+	}{
+		{origin, "", "", nil},
+		{origin, "*", "", nil},
+		{origin, "*", "true", []string{WildcardWithCredentials}},
+		{origin, "https://example.com", "true", nil},
+		{origin, origin, "", []string{ReflectedOrigin}},
+		{origin, origin, "TRUE", []string{ReflectedWithCredentials}},
+		{"null", "null", "true", []string{NullOrigin}},
+	}
+
+	for _, c := range cases {
+		header := http.Header{}
+		if c.allow != "" {
+			header.Set("Access-Control-Allow-Origin", c.allow)
+		}
+		if c.credentials != "" {
+			header.Set("Access-Control-Allow-Credentials", c.credentials)
+		}
+
+		if problems := Read(c.origin, header).Problems(); !reflect.DeepEqual(problems, c.problems) {
+			t.Errorf("%s allowing %q (credentials %q): expected %v, got %v", c.origin, c.allow, c.credentials, c.problems, problems)
+		}
+	}
+}
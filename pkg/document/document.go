@@ -0,0 +1,129 @@
+// Package document pulls the hyperlinks out of documents that aren't HTML, like PDFs and Word files,
+// so the pages they link to can be crawled too
+package document
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+// Parser finds the hyperlinks in one kind of document
+type Parser interface {
+	Links(body []byte) ([]string, error)
+}
+
+// ParserFunc lets a plain function be a Parser
+type ParserFunc func(body []byte) ([]string, error)
+
+// Links calls the function
+func (parse ParserFunc) Links(body []byte) ([]string, error) {
+	return parse(body)
+}
+
+// Media types of the documents with a built-in Parser
+const (
+	PDF  string = "application/pdf"
+	DOCX string = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// Builtin are the parsers that come with the package, by the short names they're chosen with
+var Builtin = map[string]struct {
+	MediaType string
+	Parser    Parser
+}{
+	"pdf":  {PDF, ParserFunc(PDFLinks)},
+	"docx": {DOCX, ParserFunc(DOCXLinks)},
+}
+
+// Extensions stand in for the media type when a server sends a generic one
+var extensions = map[string]string{
+	".pdf":  PDF,
+	".docx": DOCX,
+}
+
+// Parsers picks a Parser by media type
+type Parsers map[string]Parser
+
+// Links finds the links in a document with the given Content-Type, fetched from a URL with the given path
+// ok is false when no parser handles the document
+func (parsers Parsers) Links(contentType string, urlPath string, body []byte) (links []string, ok bool, err error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	parser, ok := parsers[mediaType]
+	if !ok {
+		if parser, ok = parsers[extensions[strings.ToLower(path.Ext(urlPath))]]; !ok {
+			return nil, false, nil
+		}
+	}
+
+	links, err = parser.Links(body)
+	return links, true, err
+}
+
+// Command is a Parser running an external program, which is given the document on stdin and prints
+// the links it finds one per line, for kinds of documents without a built-in parser or better tools
+type Command struct {
+	Path string
+	Args []string
+
+	// How long the program gets per document
+	Timeout time.Duration
+}
+
+// Links runs the program
+func (command Command) Links(body []byte) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), command.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command.Path, command.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("document parser %s: %v %s", command.Path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var links []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if link := strings.TrimSpace(scanner.Text()); link != "" {
+			links = append(links, link)
+		}
+	}
+	return links, scanner.Err()
+}
+
+// Parse reads which documents to harvest links from, separated by commas: the name of a built-in
+// parser, like pdf or docx, or media/type=command to have a program parse that media type
+func Parse(spec string, timeout time.Duration) (Parsers, error) {
+	parsers := make(Parsers)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if equals := strings.Index(entry, "="); equals >= 0 {
+			mediaType, commandLine := strings.TrimSpace(entry[:equals]), strings.Fields(entry[equals+1:])
+			if !strings.Contains(mediaType, "/") || len(commandLine) == 0 {
+				return nil, fmt.Errorf("document parser %q isn't media/type=command", entry)
+			}
+			parsers[strings.ToLower(mediaType)] = Command{Path: commandLine[0], Args: commandLine[1:], Timeout: timeout}
+			continue
+		}
+
+		builtin, ok := Builtin[strings.ToLower(entry)]
+		if !ok {
+			return nil, fmt.Errorf("unknown document type %q, expected pdf, docx or media/type=command", entry)
+		}
+		parsers[builtin.MediaType] = builtin.Parser
+	}
+	return parsers, nil
+}
@@ -0,0 +1,105 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func samplePDF() []byte {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write([]byte("<< /Type /Annot /Subtype /Link /A << /S /URI /URI <687474703a2f2f6578616d706c652e636f6d2f6f626a7374726d> >> >>"))
+	writer.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.5\n")
+	pdf.WriteString("4 0 obj\n<< /Type /Annot /Subtype /Link /A << /S /URI /URI (http://example.com/a\\(1\\).pdf) >> >>\nendobj\n")
+	pdf.WriteString("5 0 obj\n<< /A << /S /URI /URI(http://example.com/\\142) >> >>\nendobj\n")
+	pdf.WriteString("6 0 obj\n<< /Type /ObjStm /Filter /FlateDecode >>\nstream\n")
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("7 0 obj\n<< /A << /S /URI /URI (http://example.com/a\\(1\\).pdf) >> >>\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func sampleDOCX(t *testing.T) []byte {
+	var docx bytes.Buffer
+	archive := zip.NewWriter(&docx)
+	parts := map[string]string{
+		"word/document.xml": `<w:document/>`,
+		"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/report" TargetMode="External"/>
+</Relationships>`,
+		"word/_rels/footer1.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/contact" TargetMode="External"/>
+</Relationships>`,
+	}
+	for name, contents := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(contents))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return docx.Bytes()
+}
+
+func TestPDFLinks(t *testing.T) {
+	links, err := PDFLinks(samplePDF())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"http://example.com/a(1).pdf", "http://example.com/b", "http://example.com/objstrm"}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %v, got %v", expected, links)
+	}
+}
+
+func TestDOCXLinks(t *testing.T) {
+	links, err := DOCXLinks(sampleDOCX(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0] == links[1] {
+		t.Errorf("expected the body and footer hyperlinks, got %v", links)
+	}
+
+	if _, err := DOCXLinks([]byte("not a zip")); err == nil {
+		t.Error("expected an error for a document that isn't one")
+	}
+}
+
+func TestParsers(t *testing.T) {
+	parsers, err := Parse("pdf, text/uri-list=cat", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if links, ok, err := parsers.Links("application/octet-stream", "/files/Annual.PDF", samplePDF()); !ok || err != nil || len(links) != 3 {
+		t.Errorf("expected a PDF to be told by its extension, got %v %v %v", links, ok, err)
+	}
+	if _, ok, _ := parsers.Links(DOCX, "/letter.docx", sampleDOCX(t)); ok {
+		t.Error("expected DOCX to be left alone when not asked for")
+	}
+
+	links, ok, err := parsers.Links("text/uri-list; charset=utf-8", "/list", []byte("http://example.com/x\n\nhttp://example.com/y\n"))
+	if !ok || err != nil || !reflect.DeepEqual(links, []string{"http://example.com/x", "http://example.com/y"}) {
+		t.Errorf("expected the command's output, got %v %v %v", links, ok, err)
+	}
+
+	for _, spec := range []string{"odt", "application/pdf=", "pdf=cat"} {
+		if _, err := Parse(spec, time.Second); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// relationships is an Open Packaging Conventions .rels part
+type relationships struct {
+	Relationships []struct {
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+// DOCXLinks finds the hyperlinks in a Word document, those of its body, headers, footers and notes alike,
+// which all keep their targets in relationship parts next to them
+func DOCXLinks(body []byte) ([]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, file := range archive.File {
+		if path.Dir(file.Name) != "word/_rels" || !strings.HasSuffix(file.Name, ".rels") {
+			continue
+		}
+
+		part, err := file.Open()
+		if err != nil {
+			return links, err
+		}
+		// Relationship parts are small, anything bigger isn't one
+		contents, err := ioutil.ReadAll(io.LimitReader(part, 4*1024*1024))
+		part.Close()
+		if err != nil {
+			return links, err
+		}
+
+		var rels relationships
+		if err := xml.Unmarshal(contents, &rels); err != nil {
+			return links, err
+		}
+		for _, rel := range rels.Relationships {
+			if !strings.HasSuffix(rel.Type, "/hyperlink") || rel.TargetMode != "External" || seen[rel.Target] {
+				continue
+			}
+			seen[rel.Target] = true
+			links = append(links, rel.Target)
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,180 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// maxInflated caps how much a PDF's compressed streams are inflated to, all together, against zip bombs
+const maxInflated int64 = 64 * 1024 * 1024
+
+// PDFLinks finds the targets of the link annotations in a PDF, their /URI actions
+// Those in compressed object streams are found too, by inflating every stream that will inflate
+func PDFLinks(body []byte) ([]string, error) {
+	sources := [][]byte{body}
+	budget := maxInflated
+	for _, stream := range pdfStreams(body) {
+		if budget <= 0 {
+			break
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(stream))
+		if err != nil {
+			continue
+		}
+		// A stream cut short still has whatever inflated before the error
+		inflated, _ := ioutil.ReadAll(io.LimitReader(reader, budget))
+		reader.Close()
+		budget -= int64(len(inflated))
+		sources = append(sources, inflated)
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		for _, link := range uriActions(source) {
+			if !seen[link] {
+				seen[link] = true
+				links = append(links, link)
+			}
+		}
+	}
+	return links, nil
+}
+
+// pdfStreams finds the raw contents of every stream ... endstream
+func pdfStreams(body []byte) [][]byte {
+	var streams [][]byte
+	for offset := 0; ; {
+		start := bytes.Index(body[offset:], []byte("stream"))
+		if start < 0 {
+			return streams
+		}
+		start += offset + len("stream")
+
+		// The keyword is followed by CRLF or LF, and "endstream" contains "stream" too
+		switch {
+		case bytes.HasPrefix(body[start:], []byte("\r\n")):
+			start += 2
+		case bytes.HasPrefix(body[start:], []byte("\n")):
+			start++
+		default:
+			offset = start
+			continue
+		}
+
+		end := bytes.Index(body[start:], []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		streams = append(streams, body[start:start+end])
+		offset = start + end + len("endstream")
+	}
+}
+
+// uriActions finds every /URI key with a string value, either (literal) or <hex>
+func uriActions(source []byte) []string {
+	var links []string
+	for offset := 0; ; {
+		found := bytes.Index(source[offset:], []byte("/URI"))
+		if found < 0 {
+			return links
+		}
+		offset += found + len("/URI")
+
+		value := bytes.TrimLeft(source[offset:], " \t\r\n\f\x00")
+		var link string
+		switch {
+		case len(value) > 0 && value[0] == '(':
+			link = literalString(value[1:])
+		case len(value) > 1 && value[0] == '<' && value[1] != '<':
+			link = hexString(value[1:])
+		}
+		if link != "" {
+			links = append(links, link)
+		}
+	}
+}
+
+// literalString reads a PDF literal string up to its closing parenthesis, which nested pairs don't close
+func literalString(value []byte) string {
+	var decoded []byte
+	depth := 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '(':
+			depth++
+			decoded = append(decoded, c)
+		case ')':
+			if depth == 0 {
+				return string(decoded)
+			}
+			depth--
+			decoded = append(decoded, c)
+		case '\\':
+			i++
+			if i == len(value) {
+				return ""
+			}
+			switch escaped := value[i]; escaped {
+			case 'n':
+				decoded = append(decoded, '\n')
+			case 'r':
+				decoded = append(decoded, '\r')
+			case 't':
+				decoded = append(decoded, '\t')
+			case 'b':
+				decoded = append(decoded, '\b')
+			case 'f':
+				decoded = append(decoded, '\f')
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string on the next
+				if escaped == '\r' && i+1 < len(value) && value[i+1] == '\n' {
+					i++
+				}
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					digits := 1
+					for digits < 3 && i+digits < len(value) && value[i+digits] >= '0' && value[i+digits] <= '7' {
+						digits++
+					}
+					octal, _ := strconv.ParseUint(string(value[i:i+digits]), 8, 8)
+					decoded = append(decoded, byte(octal))
+					i += digits - 1
+				} else {
+					decoded = append(decoded, escaped)
+				}
+			}
+		default:
+			decoded = append(decoded, c)
+		}
+	}
+	// Never closed
+	return ""
+}
+
+// hexString reads a PDF hexadecimal string up to its closing angle bracket, ignoring whitespace
+func hexString(value []byte) string {
+	end := bytes.IndexByte(value, '>')
+	if end < 0 {
+		return ""
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range value[:end] {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != '\f' {
+			digits = append(digits, c)
+		}
+	}
+	// A missing final digit is taken to be 0
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return ""
+	}
+	return string(decoded)
+}
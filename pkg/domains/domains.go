@@ -0,0 +1,55 @@
+// Package domains matches hostnames against lists of domains, such as allowlists and blocklists
+package domains
+
+import (
+	"strings"
+)
+
+// List is a set of domains, where "example.com" matches just that host and "*.example.com" matches
+// every subdomain of it, however deep, but not example.com itself
+type List struct {
+	exact    map[string]bool
+	wildcard map[string]bool
+}
+
+// Parse builds a List from one domain per entry
+// Entries are case insensitive, and anything after whitespace is ignored so lists can be annotated
+func Parse(entries []string) *List {
+	list := &List{exact: make(map[string]bool), wildcard: make(map[string]bool)}
+	for _, entry := range entries {
+		fields := strings.Fields(strings.ToLower(entry))
+		if len(fields) == 0 {
+			continue
+		}
+
+		domain := strings.TrimSuffix(fields[0], ".")
+		if strings.HasPrefix(domain, "*.") {
+			list.wildcard[domain[2:]] = true
+		} else {
+			list.exact[domain] = true
+		}
+	}
+	return list
+}
+
+// Len is how many domains are listed
+func (list *List) Len() int {
+	return len(list.exact) + len(list.wildcard)
+}
+
+// Match is true when host is listed, either exactly or as a subdomain of a wildcard entry
+func (list *List) Match(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if list.exact[host] {
+		return true
+	}
+
+	for i := strings.Index(host, "."); i >= 0; {
+		host = host[i+1:]
+		if list.wildcard[host] {
+			return true
+		}
+		i = strings.Index(host, ".")
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package domains
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	list := Parse([]string{"Example.com", "*.ads.example.net  # every ad server", ""})
+
+	cases := map[string]bool{
+		"example.com":         true,
+		"EXAMPLE.COM.":        true,
+		"www.example.com":     false,
+		"cdn.ads.example.net": true,
+		"a.b.ads.example.net": true,
+		"ads.example.net":     false,
+		"badads.example.net":  false,
+		"example.net":         false,
+		"notexample.com":      false,
+	}
+
+	for host, expected := range cases {
+		if matched := list.Match(host); matched != expected {
+			t.Errorf("%s: expected %v, got %v", host, expected, matched)
+		}
+	}
+
+	if list.Len() != 2 {
+		t.Errorf("Expected 2 domains, got %d", list.Len())
+	}
+}
@@ -0,0 +1,135 @@
+package extract
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Kinds of contact
+const (
+	ContactEmail string = "email"
+	ContactPhone string = "phone"
+)
+
+// Contact is an email address or phone number found on a page
+type Contact struct {
+	Kind  string
+	Value string
+
+	// "mailto" or "tel" for links, "text" for something spotted in the page's text
+	Source string
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// Deliberately loose, the digit count below weeds out most dates and prices
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d\s().-]{6,}\d`)
+	datePattern  = regexp.MustCompile(`^\d{4}[-./]\d{1,2}[-./]\d{1,2}$|^\d{1,2}[-./]\d{1,2}[-./]\d{4}$`)
+)
+
+// Phone numbers have between 7 and 15 digits, E.164 allows no more
+const (
+	minPhoneDigits int = 7
+	maxPhoneDigits int = 15
+)
+
+// Contacts lists the email addresses and phone numbers on a page, from mailto: and tel: links
+// and from its visible text, each only once
+func Contacts(body []byte) []Contact {
+	var contacts []Contact
+	seen := make(map[string]bool)
+	add := func(contact Contact) {
+		key := contact.Kind + " " + strings.ToLower(contact.Value)
+		if !seen[key] {
+			seen[key] = true
+			contacts = append(contacts, contact)
+		}
+	}
+
+	skipping := 0
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return contacts
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if token.Data == "script" || token.Data == "style" {
+				if tokenType == html.StartTagToken {
+					skipping++
+				}
+				continue
+			}
+			if token.Data != "a" {
+				continue
+			}
+			for _, attribute := range token.Attr {
+				if attribute.Key == "href" {
+					if contact, ok := linkContact(attribute.Val); ok {
+						add(contact)
+					}
+				}
+			}
+		case html.EndTagToken:
+			if (token.Data == "script" || token.Data == "style") && skipping > 0 {
+				skipping--
+			}
+		case html.TextToken:
+			if skipping > 0 {
+				continue
+			}
+			for _, email := range emailPattern.FindAllString(token.Data, -1) {
+				add(Contact{Kind: ContactEmail, Value: email, Source: "text"})
+			}
+			for _, phone := range phonePattern.FindAllString(token.Data, -1) {
+				phone = strings.TrimSpace(phone)
+				if digits := countDigits(phone); digits >= minPhoneDigits && digits <= maxPhoneDigits && !datePattern.MatchString(phone) {
+					add(Contact{Kind: ContactPhone, Value: phone, Source: "text"})
+				}
+			}
+		}
+	}
+}
+
+func linkContact(href string) (Contact, bool) {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+
+	switch {
+	case strings.HasPrefix(lower, "mailto:"):
+		// Drop ?subject= and friends, and any percent encoding
+		address := href[len("mailto:"):]
+		if i := strings.Index(address, "?"); i >= 0 {
+			address = address[:i]
+		}
+		if unescaped, err := url.PathUnescape(address); err == nil {
+			address = unescaped
+		}
+		if address = strings.TrimSpace(address); address != "" {
+			return Contact{Kind: ContactEmail, Value: address, Source: "mailto"}, true
+		}
+	case strings.HasPrefix(lower, "tel:"):
+		if number := strings.TrimSpace(href[len("tel:"):]); number != "" {
+			return Contact{Kind: ContactPhone, Value: number, Source: "tel"}, true
+		}
+	}
+	return Contact{}, false
+}
+
+func countDigits(value string) int {
+	digits := 0
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits
+}
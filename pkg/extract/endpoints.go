@@ -0,0 +1,94 @@
+package extract
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Ways an endpoint can be referenced from a script
+const (
+	EndpointFetch   string = "fetch"
+	EndpointXHR     string = "xhr"
+	EndpointLiteral string = "literal"
+)
+
+// Endpoint is a URL referenced from an inline script
+type Endpoint struct {
+	URL  string
+	Kind string
+
+	// HTTP method, when the script makes it obvious
+	Method string
+}
+
+var (
+	fetchPattern   = regexp.MustCompile("fetch\\(\\s*[\"'`]([^\"'`]+)[\"'`]")
+	xhrPattern     = regexp.MustCompile("\\.open\\(\\s*[\"'](\\w+)[\"']\\s*,\\s*[\"'`]([^\"'`]+)[\"'`]")
+	literalPattern = regexp.MustCompile("[\"'`]((?:https?:)?//[^\"'`\\s<>]+|/[\\w\\-.~%!$&()*+,;=:@]+(?:/[\\w\\-.~%!$&()*+,;=:@]*)*(?:\\?[^\"'`\\s<>]*)?)[\"'`]")
+)
+
+// Endpoints lists URL-like string literals in inline scripts, such as the targets of fetch and XMLHttpRequest calls
+// Each URL is only listed once, by the most specific way it was referenced
+func Endpoints(body []byte) []Endpoint {
+	var endpoints []Endpoint
+	seen := make(map[string]bool)
+
+	add := func(endpoint Endpoint) {
+		if seen[endpoint.URL] {
+			return
+		}
+		seen[endpoint.URL] = true
+		endpoints = append(endpoints, endpoint)
+	}
+
+	for _, script := range inlineScripts(body) {
+		for _, match := range fetchPattern.FindAllStringSubmatch(script, -1) {
+			add(Endpoint{URL: match[1], Kind: EndpointFetch})
+		}
+		for _, match := range xhrPattern.FindAllStringSubmatch(script, -1) {
+			add(Endpoint{URL: match[2], Kind: EndpointXHR, Method: strings.ToUpper(match[1])})
+		}
+		for _, match := range literalPattern.FindAllStringSubmatch(script, -1) {
+			add(Endpoint{URL: match[1], Kind: EndpointLiteral})
+		}
+	}
+
+	return endpoints
+}
+
+// inlineScripts returns the contents of every script element without a src
+func inlineScripts(body []byte) []string {
+	var scripts []string
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inScript := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return scripts
+		case html.StartTagToken:
+			name, hasAttrs := tokenizer.TagName()
+			if string(name) != "script" {
+				continue
+			}
+
+			inScript = true
+			for hasAttrs {
+				var key []byte
+				key, _, hasAttrs = tokenizer.TagAttr()
+				if string(key) == "src" {
+					inScript = false
+				}
+			}
+		case html.TextToken:
+			if inScript {
+				scripts = append(scripts, string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			inScript = false
+		}
+	}
+}
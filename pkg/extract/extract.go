@@ -0,0 +1,312 @@
+// Package extract pulls references to other resources out of HTML documents
+package extract
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// eachTag calls visit for every start (or self-closing) tag with one of the given names
+// The tokenizer lowercases tag and attribute names, and only the first of any repeated attribute is kept
+func eachTag(body []byte, names []string, visit func(name string, attrs map[string]string)) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttrs := tokenizer.TagName()
+			if !wanted[string(name)] {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for hasAttrs {
+				var key, value []byte
+				key, value, hasAttrs = tokenizer.TagAttr()
+				if _, ok := attrs[string(key)]; !ok {
+					attrs[string(key)] = string(value)
+				}
+			}
+			visit(string(name), attrs)
+		}
+	}
+}
+
+// Links lists the href of every <a>, in order and without repeats, keeping any #fragment
+func Links(body []byte) []string {
+	var links []string
+	seen := make(map[string]bool)
+	eachTag(body, []string{"a"}, func(name string, attrs map[string]string) {
+		href, ok := attrs["href"]
+		if ok && !seen[href] {
+			seen[href] = true
+			links = append(links, href)
+		}
+	})
+	return links
+}
+
+// Frames lists the src of every frame and iframe
+func Frames(body []byte) []string {
+	var sources []string
+	eachTag(body, []string{"frame", "iframe"}, func(name string, attrs map[string]string) {
+		if src := strings.TrimSpace(attrs["src"]); src != "" {
+			sources = append(sources, src)
+		}
+	})
+	return sources
+}
+
+// MetaRobots lists the directives of every <meta name="robots"> tag, lowercased, such as "noindex" and "nofollow"
+func MetaRobots(body []byte) []string {
+	var directives []string
+	eachTag(body, []string{"meta"}, func(name string, attrs map[string]string) {
+		if !strings.EqualFold(strings.TrimSpace(attrs["name"]), "robots") {
+			return
+		}
+		directives = append(directives, RobotsDirectives(attrs["content"])...)
+	})
+	return directives
+}
+
+// RobotsDirectives splits a meta robots content or X-Robots-Tag value into lowercase directives
+func RobotsDirectives(value string) []string {
+	var directives []string
+	for _, directive := range strings.Split(value, ",") {
+		if directive = strings.ToLower(strings.TrimSpace(directive)); directive != "" {
+			directives = append(directives, directive)
+		}
+	}
+	return directives
+}
+
+// Robots directives that take a value after a colon, which mustn't be mistaken for a user-agent prefix
+var robotsValueDirectives = map[string]bool{
+	"unavailable_after": true, "max-snippet": true, "max-image-preview": true, "max-video-preview": true,
+}
+
+// HeaderRobots lists the directives of every X-Robots-Tag header value that applies to all crawlers
+// Values aimed at one crawler, like "googlebot: noindex", are skipped, as meta name="robots" can't say that
+func HeaderRobots(values []string) []string {
+	var directives []string
+	for _, value := range values {
+		if i := strings.Index(value, ":"); i >= 0 {
+			name := strings.ToLower(strings.TrimSpace(value[:i]))
+			if !robotsValueDirectives[name] && !strings.Contains(name, ",") {
+				continue
+			}
+		}
+		directives = append(directives, RobotsDirectives(value)...)
+	}
+	return directives
+}
+
+// RobotsConflict is a pair of directives from two sources that contradict each other
+type RobotsConflict struct {
+	Header string
+	Meta   string
+}
+
+// RobotsConflicts compares X-Robots-Tag and meta robots directives, reporting where one allows what the
+// other forbids, e.g. the header says noindex while the meta tag says index
+// "all" and "none" count as both index and follow, or noindex and nofollow
+func RobotsConflicts(header []string, meta []string) []RobotsConflict {
+	var conflicts []RobotsConflict
+	for _, pair := range [][2]string{{"index", "noindex"}, {"follow", "nofollow"}} {
+		headerSays, metaSays := robotsStance(header, pair), robotsStance(meta, pair)
+		if headerSays != "" && metaSays != "" && headerSays != metaSays {
+			conflicts = append(conflicts, RobotsConflict{Header: headerSays, Meta: metaSays})
+		}
+	}
+	return conflicts
+}
+
+// robotsStance is which of allow/forbid the directives say, the forbidding one if they say both, or
+// empty if they say neither
+func robotsStance(directives []string, pair [2]string) string {
+	stance := ""
+	for _, directive := range directives {
+		switch directive {
+		case pair[1], "none":
+			return pair[1]
+		case pair[0], "all":
+			stance = pair[0]
+		}
+	}
+	return stance
+}
+
+// Alternate is another version of a page, declared with a <link> tag
+type Alternate struct {
+	URL string
+
+	// "amphtml" for an AMP version, "alternate" for a version for other media such as a mobile site
+	Rel   string
+	Media string
+}
+
+// Alternates lists the AMP versions of a page and its alternates for other media
+// Alternates without a media attribute (feeds, translations) aren't versions of the same page for a device, so they're skipped
+func Alternates(body []byte) []Alternate {
+	var alternates []Alternate
+	eachTag(body, []string{"link"}, func(name string, attrs map[string]string) {
+		href := strings.TrimSpace(attrs["href"])
+		if href == "" {
+			return
+		}
+
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			media := strings.TrimSpace(attrs["media"])
+			if rel == "amphtml" || (rel == "alternate" && media != "") {
+				alternates = append(alternates, Alternate{URL: href, Rel: rel, Media: media})
+				return
+			}
+		}
+	})
+	return alternates
+}
+
+// Canonical is the href of the first <link rel="canonical">, empty if there isn't one
+func Canonical(body []byte) string {
+	canonical := ""
+	eachTag(body, []string{"link"}, func(name string, attrs map[string]string) {
+		if canonical != "" {
+			return
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			if rel == "canonical" {
+				canonical = strings.TrimSpace(attrs["href"])
+				return
+			}
+		}
+	})
+	return canonical
+}
+
+// IsAMP is true when the document declares itself an AMP page with <html amp> or <html ⚡>
+func IsAMP(body []byte) bool {
+	amp := false
+	eachTag(body, []string{"html"}, func(name string, attrs map[string]string) {
+		_, hasAMP := attrs["amp"]
+		_, hasBolt := attrs["⚡"]
+		amp = amp || hasAMP || hasBolt
+	})
+	return amp
+}
+
+// PageLink is a link to the next or previous page of a paginated series
+type PageLink struct {
+	URL string
+
+	// "next" or "prev"
+	Rel string
+}
+
+// Pagination lists every rel="next" and rel="prev" link, from both <link> and <a> tags
+// rel="previous" is a common synonym and is reported as "prev"
+func Pagination(body []byte) []PageLink {
+	var links []PageLink
+	eachTag(body, []string{"link", "a"}, func(name string, attrs map[string]string) {
+		href := strings.TrimSpace(attrs["href"])
+		if href == "" {
+			return
+		}
+
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			switch rel {
+			case "next":
+				links = append(links, PageLink{URL: href, Rel: "next"})
+				return
+			case "prev", "previous":
+				links = append(links, PageLink{URL: href, Rel: "prev"})
+				return
+			}
+		}
+	})
+	return links
+}
+
+// Image is an <img> on a page, with its dimensions as written in its attributes
+type Image struct {
+	Src string
+	Alt string
+
+	// Whether there's an alt attribute at all, an empty one marks a decorative image
+	HasAlt bool
+
+	// Zero when the attribute is missing or isn't a plain number of pixels
+	Width  int
+	Height int
+}
+
+// Images lists every <img> with a src
+func Images(body []byte) []Image {
+	var images []Image
+	eachTag(body, []string{"img"}, func(name string, attrs map[string]string) {
+		src := strings.TrimSpace(attrs["src"])
+		if src == "" {
+			return
+		}
+
+		alt, hasAlt := attrs["alt"]
+		width, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attrs["width"]), "px"))
+		height, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(attrs["height"]), "px"))
+
+		images = append(images, Image{
+			Src:    src,
+			Alt:    strings.TrimSpace(alt),
+			HasAlt: hasAlt,
+			Width:  width,
+			Height: height,
+		})
+	})
+	return images
+}
+
+// Kinds of site icon, as named in the rel attribute of the <link> declaring them
+const (
+	IconFavicon        string = "icon"
+	IconAppleTouchIcon string = "apple-touch-icon"
+	IconManifest       string = "manifest"
+)
+
+// Icon is a favicon, apple-touch-icon or web app manifest declared by a page
+type Icon struct {
+	Kind string
+	URL  string
+}
+
+// Icons lists the favicons, apple-touch-icons and web app manifests a page declares in <link> tags
+func Icons(body []byte) []Icon {
+	var icons []Icon
+	eachTag(body, []string{"link"}, func(name string, attrs map[string]string) {
+		href := strings.TrimSpace(attrs["href"])
+		if href == "" {
+			return
+		}
+
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			switch rel {
+			case "icon":
+				icons = append(icons, Icon{IconFavicon, href})
+			case "apple-touch-icon", "apple-touch-icon-precomposed":
+				icons = append(icons, Icon{IconAppleTouchIcon, href})
+			case "manifest":
+				icons = append(icons, Icon{IconManifest, href})
+			default:
+				continue
+			}
+			return
+		}
+	})
+	return icons
+}
@@ -0,0 +1,290 @@
+package extract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrames(t *testing.T) {
+	body := []byte(`<html>
+<frameset cols="25%,75%">
+  <frame src="menu.html">
+  <FRAME SRC="/content.html" />
+</frameset>
+<body><iframe src=" https://example.com/embed "></iframe><iframe srcdoc="<p>inline</p>"></iframe></body>
+</html>`)
+
+	expected := []string{"menu.html", "/content.html", "https://example.com/embed"}
+	if frames := Frames(body); !reflect.DeepEqual(frames, expected) {
+		t.Errorf("Expected %v, got %v", expected, frames)
+	}
+}
+
+func TestMetaRobots(t *testing.T) {
+	body := []byte(`<head>
+<meta name="description" content="noindex">
+<META NAME="Robots" CONTENT="NoIndex, follow">
+<meta name="robots" content="noarchive">
+</head>`)
+
+	expected := []string{"noindex", "follow", "noarchive"}
+	if directives := MetaRobots(body); !reflect.DeepEqual(directives, expected) {
+		t.Errorf("Expected %v, got %v", expected, directives)
+	}
+}
+
+func TestAlternates(t *testing.T) {
+	body := []byte(`<html ⚡><head>
+<link rel="canonical" href="https://example.com/article">
+<link rel="canonical" href="https://example.com/other">
+<link rel="amphtml" href="/article.amp">
+<link rel="alternate" media="only screen and (max-width: 640px)" href="https://m.example.com/article">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="alternate" hreflang="de" href="/de/article">
+</head></html>`)
+
+	expected := []Alternate{
+		{URL: "/article.amp", Rel: "amphtml"},
+		{URL: "https://m.example.com/article", Rel: "alternate", Media: "only screen and (max-width: 640px)"},
+	}
+	if alternates := Alternates(body); !reflect.DeepEqual(alternates, expected) {
+		t.Errorf("Expected %v, got %v", expected, alternates)
+	}
+
+	if canonical := Canonical(body); canonical != "https://example.com/article" {
+		t.Errorf("Expected the first canonical, got %q", canonical)
+	}
+	if !IsAMP(body) || IsAMP([]byte(`<html lang="en">`)) {
+		t.Errorf("Expected only the ⚡ document to be AMP")
+	}
+}
+
+func TestPagination(t *testing.T) {
+	body := []byte(`<head>
+<link rel="prev" href="/list?page=1">
+<link rel="next" href="/list?page=3">
+</head><body>
+<a rel="previous" href="/list?page=1">Back</a>
+<a rel="nofollow next" href="/list?page=3">More</a>
+<a href="/list?page=4">4</a>
+</body>`)
+
+	expected := []PageLink{
+		{URL: "/list?page=1", Rel: "prev"},
+		{URL: "/list?page=3", Rel: "next"},
+		{URL: "/list?page=1", Rel: "prev"},
+		{URL: "/list?page=3", Rel: "next"},
+	}
+	if links := Pagination(body); !reflect.DeepEqual(links, expected) {
+		t.Errorf("Expected %v, got %v", expected, links)
+	}
+}
+
+func TestContacts(t *testing.T) {
+	body := []byte(`<body>
+<a href="mailto:Sales%40example.com?subject=Hi">Email sales</a>
+<a href="tel:+1-555-010-9999">Call us</a>
+<p>Or write to sales@example.com or support@example.org, call (555) 010-2000.</p>
+<p>Posted 2020-01-01, costs 1,299.00, order 12345.</p>
+<script>var email = "hidden@example.com";</script>
+</body>`)
+
+	expected := []Contact{
+		{Kind: ContactEmail, Value: "Sales@example.com", Source: "mailto"},
+		{Kind: ContactPhone, Value: "+1-555-010-9999", Source: "tel"},
+		{Kind: ContactEmail, Value: "support@example.org", Source: "text"},
+		{Kind: ContactPhone, Value: "(555) 010-2000", Source: "text"},
+	}
+	if contacts := Contacts(body); !reflect.DeepEqual(contacts, expected) {
+		t.Errorf("Expected %v, got %v", expected, contacts)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	body := []byte(`<html>
+<script src="/static/app.js"></script>
+<script>
+  fetch('/api/v1/users?active=true').then(render);
+  var xhr = new XMLHttpRequest();
+  xhr.open("post", "https://api.example.com/orders");
+  const config = {search: "/api/search", cdn: "//cdn.example.com/lib.js", notAPath: "hello world"};
+</script>
+</html>`)
+
+	expected := []Endpoint{
+		{URL: "/api/v1/users?active=true", Kind: EndpointFetch},
+		{URL: "https://api.example.com/orders", Kind: EndpointXHR, Method: "POST"},
+		{URL: "/api/search", Kind: EndpointLiteral},
+		{URL: "//cdn.example.com/lib.js", Kind: EndpointLiteral},
+	}
+
+	if endpoints := Endpoints(body); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, endpoints)
+	}
+}
+
+func TestText(t *testing.T) {
+	body := []byte(`<html><head><title>Ignored</title><style>p { color: red }</style></head><body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div role="banner">Site banner</div>
+<h1>Grawler   release</h1>
+<p>It crawls <b>faster</b> now.<br>Really.</p>
+<script>track()</script>
+<ul><li>One</li><li>Two</li></ul>
+<footer>Copyright</footer>
+</body></html>`)
+
+	expected := "Grawler release\nIt crawls faster now.\nReally.\nOne\nTwo"
+	if text := Text(body); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	// Marked up content wins over everything around it
+	article := []byte(`<body><div>Related posts</div><article><h2>Title</h2><p>Body</p></article></body>`)
+	if text := Text(article); text != "Title\nBody" {
+		t.Errorf("Expected just the article, got %q", text)
+	}
+}
+
+func TestReadable(t *testing.T) {
+	body := []byte(`<html><body>
+<div class="menu"><p><a href="/">Home, the place where everything starts</a></p></div>
+<div class="sidebar"><p>Popular posts, hand picked, updated daily, for you</p></div>
+<div class="post-content">
+	<h2>Release notes</h2>
+	<p>This release makes the crawler faster, smaller, and a good deal more polite to servers.</p>
+	<p>It also fixes a long standing bug where redirects were followed forever.</p>
+	<div class="share">Share this</div>
+</div>
+<div class="comments"><p>Great post, thanks, really enjoyed reading it!</p></div>
+</body></html>`)
+
+	expected := "Release notes\nThis release makes the crawler faster, smaller, and a good deal more polite to servers.\nIt also fixes a long standing bug where redirects were followed forever."
+	if text := Readable(body); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	if text := Readable([]byte(`<body><h1>Short</h1></body>`)); text != "Short" {
+		t.Errorf("Expected pages without paragraphs to fall back to Text, got %q", text)
+	}
+}
+
+func TestImages(t *testing.T) {
+	body := []byte(`<body>
+<img src="/logo.png" alt=" Grawler logo " width="120" height="40px">
+<img src="spacer.gif" alt="">
+<IMG SRC="photo.jpg" width="100%">
+<img data-src="lazy.jpg">
+</body>`)
+
+	expected := []Image{
+		{Src: "/logo.png", Alt: "Grawler logo", HasAlt: true, Width: 120, Height: 40},
+		{Src: "spacer.gif", HasAlt: true},
+		{Src: "photo.jpg"},
+	}
+	if images := Images(body); !reflect.DeepEqual(images, expected) {
+		t.Errorf("Expected %v, got %v", expected, images)
+	}
+}
+
+func TestIcons(t *testing.T) {
+	body := []byte(`<head>
+<link rel="shortcut icon" href="/favicon.ico">
+<link rel="icon" type="image/png" sizes="32x32" href="/icon-32.png">
+<link rel="apple-touch-icon-precomposed" href="/touch.png">
+<link rel="manifest" href="/site.webmanifest">
+<link rel="stylesheet" href="/style.css">
+</head>`)
+
+	expected := []Icon{
+		{IconFavicon, "/favicon.ico"},
+		{IconFavicon, "/icon-32.png"},
+		{IconAppleTouchIcon, "/touch.png"},
+		{IconManifest, "/site.webmanifest"},
+	}
+	if icons := Icons(body); !reflect.DeepEqual(icons, expected) {
+		t.Errorf("Expected %v, got %v", expected, icons)
+	}
+}
+
+func TestRobotsConflicts(t *testing.T) {
+	header := HeaderRobots([]string{"noindex, follow", "googlebot: index", "unavailable_after: 25 Jun 2030 15:00:00 PST"})
+	expectedHeader := []string{"noindex", "follow", "unavailable_after: 25 jun 2030 15:00:00 pst"}
+	if !reflect.DeepEqual(header, expectedHeader) {
+		t.Errorf("Expected %v, got %v", expectedHeader, header)
+	}
+
+	expected := []RobotsConflict{{Header: "noindex", Meta: "index"}}
+	if conflicts := RobotsConflicts(header, []string{"index", "follow"}); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected %v, got %v", expected, conflicts)
+	}
+
+	expected = []RobotsConflict{{Header: "follow", Meta: "nofollow"}}
+	if conflicts := RobotsConflicts(header, []string{"none"}); !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected %v, got %v", expected, conflicts)
+	}
+
+	// Saying nothing either way isn't a conflict
+	if conflicts := RobotsConflicts(header, []string{"noarchive"}); len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestLinkPlacements(t *testing.T) {
+	placements := LinkPlacements([]byte(`<html><head><link rel="next" href="/page/2"></head><body>
+<header><a href="/">Home</a></header>
+<div role="navigation"><div><a href="/about">About</a></div><a href="/blog">Blog</a></div>
+<main><a href="/about#team">Our team</a><a href="/blog">Read the blog</a><a href="/blog">More</a></main>
+<footer><div><a href="/">Home</a></div><a href="/about">About</a></footer>
+<a href="/contact">Contact</a>
+</body></html>`))
+
+	expected := map[string]LinkPlacement{
+		"/page/2":  {Count: 1, Sections: []string{SectionHead}},
+		"/":        {Count: 2, Sections: []string{SectionNav, SectionFooter}},
+		"/about":   {Count: 3, Sections: []string{SectionNav, SectionBody, SectionFooter}},
+		"/blog":    {Count: 3, Sections: []string{SectionNav, SectionBody}},
+		"/contact": {Count: 1, Sections: []string{SectionBody}},
+	}
+	if !reflect.DeepEqual(placements, expected) {
+		t.Errorf("Expected %v, got %v", expected, placements)
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	page := []byte("<!DOCTYPE html><html><body><a href=\"/a\">a</a></body></html>")
+	xhtml := []byte("<?xml version=\"1.0\"?>\n<html xmlns=\"http://www.w3.org/1999/xhtml\"><body></body></html>")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	cases := []struct {
+		contentType string
+		body        []byte
+		expected    bool
+	}{
+		{"text/html; charset=utf-8", page, true},
+		{"application/xhtml+xml", xhtml, true},
+		{"", page, true},
+		{"application/octet-stream", page, true},
+		{"application/octet-stream", xhtml, true},
+		{"not a type", page, true},
+		{"", png, false},
+		{"application/octet-stream", []byte("%PDF-1.4 <html>"), false},
+		{"application/json", page, false},
+		{"text/plain", page, false},
+		{"image/png", png, false},
+	}
+
+	for _, c := range cases {
+		if isHTML := IsHTML(c.contentType, c.body); isHTML != c.expected {
+			t.Errorf("%q %.20q: expected %v, got %v", c.contentType, c.body, c.expected, isHTML)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	body := []byte(`<a href="/a">a</a><a href="#/about">about</a><a name="top"></a><a href="/a">again</a><A HREF="/b#part">b</A>`)
+	links := Links(body)
+	if expected := []string{"/a", "#/about", "/b#part"}; !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %v, got %v", expected, links)
+	}
+}
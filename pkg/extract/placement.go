@@ -0,0 +1,113 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Sections of a page a link can be placed in
+const (
+	SectionHead   string = "head"
+	SectionNav    string = "nav"
+	SectionBody   string = "body"
+	SectionFooter string = "footer"
+)
+
+// LinkPlacement is how often a page links to one URL, and from which sections of the page
+type LinkPlacement struct {
+	Count int
+	// Each section once, in the order they first link to the URL
+	Sections []string
+}
+
+// openSection is an element that puts everything inside it in a section, with how many elements of
+// the same name are open inside it, so the right end tag closes it
+type openSection struct {
+	name    string
+	section string
+	nested  int
+}
+
+// LinkPlacements counts the links of <a>, <area> and <link> tags, by href without any #fragment
+// <nav> and <header>, and elements with role="navigation", make a nav section; <footer>, and role="contentinfo", a footer
+func LinkPlacements(body []byte) map[string]LinkPlacement {
+	placements := make(map[string]LinkPlacement)
+	var open []openSection
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return placements
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if top := len(open) - 1; top >= 0 && open[top].name == string(name) {
+				if open[top].nested > 0 {
+					open[top].nested--
+				} else {
+					open = open[:top]
+				}
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			rawName, hasAttrs := tokenizer.TagName()
+			name := string(rawName)
+			var href, role string
+			for hasAttrs {
+				var key, value []byte
+				key, value, hasAttrs = tokenizer.TagAttr()
+				switch string(key) {
+				case "href":
+					href = string(value)
+				case "role":
+					role = strings.ToLower(string(value))
+				}
+			}
+
+			if name == "a" || name == "area" || name == "link" {
+				if i := strings.Index(href, "#"); i >= 0 {
+					href = href[:i]
+				}
+				section := SectionBody
+				if len(open) > 0 {
+					section = open[len(open)-1].section
+				}
+				placements[href] = placed(placements[href], section)
+			}
+
+			if tokenType == html.SelfClosingTagToken {
+				continue
+			}
+			if section := sectionOf(name, role); section != "" {
+				open = append(open, openSection{name: name, section: section})
+			} else if top := len(open) - 1; top >= 0 && open[top].name == name {
+				open[top].nested++
+			}
+		}
+	}
+}
+
+func sectionOf(name string, role string) string {
+	switch {
+	case name == "head":
+		return SectionHead
+	case name == "nav" || name == "header" || role == "navigation":
+		return SectionNav
+	case name == "footer" || role == "contentinfo":
+		return SectionFooter
+	}
+	return ""
+}
+
+func placed(placement LinkPlacement, section string) LinkPlacement {
+	placement.Count++
+	for _, existing := range placement.Sections {
+		if existing == section {
+			return placement
+		}
+	}
+	placement.Sections = append(placement.Sections, section)
+	return placement
+}
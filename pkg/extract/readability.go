@@ -0,0 +1,175 @@
+package extract
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Class and id hints about whether an element holds the content or the chrome around it
+var (
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeHints = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|widget|nav|menu|share|social|related|promo|sponsor|banner|cookie|\bads?\b`)
+)
+
+// Paragraph-like elements shorter than this are too short to say anything about where the content is
+const minParagraphLength int = 25
+
+// Readable is the text of a page's main content, picked readability style: paragraphs award points to
+// the elements containing them, and the best scoring element once link-heavy ones are marked down wins
+// Pages without anything paragraph-like fall back to Text
+func Readable(body []byte) string {
+	document, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+	award := func(node *html.Node, points float64) {
+		if node == nil || node.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[node]; !ok {
+			scores[node] = baseScore(node)
+			candidates = append(candidates, node)
+		}
+		scores[node] += points
+	}
+
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			if boilerplateElements[node.Data] || isBoilerplate(node) {
+				return
+			}
+
+			switch node.Data {
+			case "p", "pre", "td", "blockquote":
+				text := strings.TrimSpace(nodeText(node))
+				if len(text) >= minParagraphLength {
+					points := 1 + float64(strings.Count(text, ",")) + minFloat(float64(len(text))/100, 3)
+					award(node.Parent, points)
+					if node.Parent != nil {
+						award(node.Parent.Parent, points/2)
+					}
+				}
+				return
+			}
+		}
+
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(document)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(candidate))
+		if best == nil || score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+
+	if best == nil {
+		return Text(body)
+	}
+
+	removeChrome(best)
+	return blockText(best)
+}
+
+// removeChrome takes elements out of the content whose class or id says they're chrome, like share buttons
+func removeChrome(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && hinted(child, negativeHints) && !hinted(child, positiveHints) {
+			node.RemoveChild(child)
+		} else {
+			removeChrome(child)
+		}
+		child = next
+	}
+}
+
+// baseScore is how likely an element is to hold content, going by its name, class and id alone
+func baseScore(node *html.Node) float64 {
+	score := 0.0
+	switch node.Data {
+	case "article", "main":
+		score += 10
+	case "div":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	if hinted(node, negativeHints) {
+		score -= 25
+	}
+	if hinted(node, positiveHints) {
+		score += 25
+	}
+	return score
+}
+
+// hinted is true when the element's class or id matches hints
+func hinted(node *html.Node, hints *regexp.Regexp) bool {
+	for _, attr := range node.Attr {
+		if (attr.Key == "class" || attr.Key == "id") && hints.MatchString(attr.Val) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkDensity is the fraction of an element's text that's inside links
+func linkDensity(node *html.Node) float64 {
+	total := len(nodeText(node))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "a" {
+			linked += len(nodeText(node))
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(node)
+
+	return float64(linked) / float64(total)
+}
+
+// nodeText is all the text under a node, boilerplate and all
+func nodeText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+
+	var text strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(nodeText(child))
+	}
+	return text.String()
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,46 @@
+package extract
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+)
+
+// sniffLength is how much of a body is looked at to tell what it is, as much as http.DetectContentType uses
+const sniffLength int = 512
+
+// Content-Types that say nothing about what a body is, which servers send when they don't know
+var genericTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/unknown":      true,
+	"unknown/unknown":          true,
+	"*/*":                      true,
+}
+
+// IsHTML is whether a response with this Content-Type and body is an HTML document worth parsing
+// A Content-Type that's missing or generic is no help, so then the start of the body decides
+func IsHTML(contentType string, body []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+	if !genericTypes[mediaType] {
+		return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	}
+
+	start := body
+	if len(start) > sniffLength {
+		start = start[:sniffLength]
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(start))
+	switch sniffed {
+	case "text/html":
+		return true
+	case "text/xml":
+		// XHTML starting with an XML declaration sniffs as XML
+		return bytes.Contains(bytes.ToLower(start), []byte("<html"))
+	}
+	return false
+}
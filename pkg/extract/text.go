@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Elements that are boilerplate rather than content, left out of Text entirely
+var boilerplateElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "nav": true, "header": true, "footer": true, "aside": true, "form": true,
+	"button": true, "select": true,
+}
+
+// ARIA roles marking boilerplate, for sites that don't use the elements above
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+}
+
+// Elements that start a new line of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "br": true, "hr": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true, "table": true, "tr": true,
+	"figure": true, "figcaption": true, "address": true,
+}
+
+// Text is the readable text of a page, one block per line, with navigation, headers, footers, scripts
+// and the like stripped out
+// When the page marks its content with <main> or <article>, only that is kept
+func Text(body []byte) string {
+	document, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	root := findElement(document, "main")
+	if root == nil {
+		root = findElement(document, "article")
+	}
+	if root == nil {
+		root = document
+	}
+
+	return blockText(root)
+}
+
+// blockText is the text under a node, without boilerplate, one block per line
+func blockText(node *html.Node) string {
+	var text strings.Builder
+	writeText(node, &text)
+
+	var lines []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func findElement(node *html.Node, name string) *html.Node {
+	if node.Type == html.ElementNode && node.Data == name {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func writeText(node *html.Node, text *strings.Builder) {
+	switch node.Type {
+	case html.TextNode:
+		text.WriteString(node.Data)
+		return
+	case html.ElementNode:
+		if boilerplateElements[node.Data] || isBoilerplate(node) {
+			return
+		}
+	}
+
+	block := node.Type == html.ElementNode && blockElements[node.Data]
+	if block {
+		text.WriteString("\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeText(child, text)
+	}
+	if block {
+		text.WriteString("\n")
+	}
+}
+
+func isBoilerplate(node *html.Node) bool {
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "role":
+			if boilerplateRoles[strings.ToLower(strings.TrimSpace(attr.Val))] {
+				return true
+			}
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if strings.TrimSpace(attr.Val) == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
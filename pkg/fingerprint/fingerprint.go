@@ -0,0 +1,248 @@
+// Package fingerprint hashes pages so copies and changes can be spotted across crawls
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Content hashes a page's body exactly as it was served
+func Content(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Elements whose contents change from one request to the next without the page changing,
+// like scripts and embedded ads, and are left out of the structure entirely
+var volatileElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"iframe":   true,
+	"ins":      true,
+	"template": true,
+	"svg":      true,
+}
+
+// Elements that never have an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// How deep into the layout Template looks
+const templateDepth int = 8
+
+// Elements that lay a page out, rather than make up its content
+var layoutElements = map[string]bool{
+	"html": true, "body": true, "div": true, "section": true, "header": true, "footer": true,
+	"nav": true, "main": true, "aside": true, "article": true, "form": true,
+}
+
+// Template hashes the outline of a page's layout: the distinct paths of layout elements, like divs and
+// sections, with their classes and ids
+// Pages built from the same template hash the same however much content they have, unlike Structure
+func Template(body []byte) string {
+	paths := make(map[string]bool)
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+
+	var stack []string
+	skipping := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		name, hasAttrs := tokenizer.TagName()
+		tag := string(name)
+
+		switch tokenType {
+		case html.StartTagToken:
+			if volatileElements[tag] {
+				skipping++
+			}
+			if skipping > 0 || !layoutElements[tag] {
+				continue
+			}
+
+			element := tag
+			for hasAttrs {
+				var key, value []byte
+				key, value, hasAttrs = tokenizer.TagAttr()
+				switch string(key) {
+				case "id":
+					element += "#" + string(value)
+				case "class":
+					element += "." + strings.Join(strings.Fields(string(value)), ".")
+				}
+			}
+
+			if len(stack) < templateDepth {
+				paths[strings.Join(append(stack, element), ">")] = true
+			}
+			stack = append(stack, element)
+		case html.EndTagToken:
+			if skipping > 0 {
+				if volatileElements[tag] {
+					skipping--
+				}
+				continue
+			}
+			if !layoutElements[tag] {
+				continue
+			}
+
+			// Pop back to the matching element, tolerating unclosed ones in between
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == tag || strings.HasPrefix(stack[i], tag+"#") || strings.HasPrefix(stack[i], tag+".") {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	return Content([]byte(strings.Join(sorted, "\n")))
+}
+
+// Structure hashes just the nesting of a page's elements, ignoring text and attributes
+// Pages that only differ in things like timestamps, ads and session tokens hash the same
+func Structure(body []byte) string {
+	var structure bytes.Buffer
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+
+	// How deep inside a volatile element we are, if at all
+	skipping := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		name, _ := tokenizer.TagName()
+		tag := string(name)
+
+		if tokenType == html.StartTagToken && voidElements[tag] {
+			tokenType = html.SelfClosingTagToken
+		}
+
+		switch tokenType {
+		case html.StartTagToken:
+			if skipping > 0 || volatileElements[tag] {
+				skipping++
+				continue
+			}
+			structure.WriteString("<" + tag + ">")
+		case html.EndTagToken:
+			if skipping > 0 {
+				skipping--
+				continue
+			}
+			structure.WriteString("</" + tag + ">")
+		case html.SelfClosingTagToken:
+			if skipping == 0 && !volatileElements[tag] {
+				structure.WriteString("<" + tag + "/>")
+			}
+		}
+	}
+
+	return Content(structure.Bytes())
+}
+
+// How many words make up each feature of a SimHash
+const shingleSize int = 3
+
+// SimHash fingerprints the visible text of a page so that similar pages get similar fingerprints,
+// differing in only a few bits, whereas Content changes completely for any difference at all
+// ok is false for pages without any text, which would all look alike
+func SimHash(body []byte) (hash uint64, ok bool) {
+	words := visibleWords(body)
+	if len(words) == 0 {
+		return 0, false
+	}
+
+	size := shingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	var weights [64]int
+	for i := 0; i+size <= len(words); i++ {
+		feature := fnv.New64a()
+		feature.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := feature.Sum64()
+
+		for bit := uint(0); bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	for bit := uint(0); bit < 64; bit++ {
+		if weights[bit] > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash, true
+}
+
+// Distance is how many bits two SimHashes differ by, a handful or fewer means near-duplicates
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// visibleWords lowercases the words of a page's text, leaving out volatile elements
+func visibleWords(body []byte) []string {
+	var words []string
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	skipping := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		switch tokenType {
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if volatileElements[string(name)] {
+				skipping++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if volatileElements[string(name)] && skipping > 0 {
+				skipping--
+			}
+		case html.TextToken:
+			if skipping > 0 {
+				continue
+			}
+			words = append(words, strings.FieldsFunc(strings.ToLower(string(tokenizer.Text())), func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			})...)
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,64 @@
+package fingerprint
+
+import "testing"
+
+func TestContent(t *testing.T) {
+	if hash := Content([]byte("hello")); hash != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Unexpected hash %s", hash)
+	}
+}
+
+func TestStructure(t *testing.T) {
+	original := `<html><body><p class="date">Updated 2020-01-01</p><script>var now = 1;</script><ins class="ad"><a href="/ad1">Buy</a></ins><div><br/></div></body></html>`
+	changed := `<html><body><p class="time">Updated 2020-06-30</p><script>var now = 2; if (a < b) {}</script><ins class="ad"><a href="/ad2">Sell</a><img src="x"></ins><div><br/></div></body></html>`
+	restructured := `<html><body><p>Updated 2020-01-01</p><div><br/><br/></div></body></html>`
+
+	if Structure([]byte(original)) != Structure([]byte(changed)) {
+		t.Errorf("Expected text, attribute, script and ad changes to be ignored")
+	}
+	if Structure([]byte(original)) == Structure([]byte(restructured)) {
+		t.Errorf("Expected a new element to change the hash")
+	}
+	if Content([]byte(original)) == Content([]byte(changed)) {
+		t.Errorf("Expected the content hashes to differ")
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	article := `<html><body><h1>Grawler</h1><p>Grawler is a small web crawler written in Go. It follows links, respects robots.txt,
+and writes the graph of everything it finds to a file. Reports cover broken links, redirects and much more.</p>`
+
+	printView := article + `<p>Printed on 2020-01-01</p><script>print()</script></body></html>`
+	other := `<html><body><h1>Recipes</h1><p>Whisk the eggs with sugar until pale, fold in the flour and bake for twenty minutes.</p></body></html>`
+
+	original, ok := SimHash([]byte(article))
+	if !ok {
+		t.Fatal("Expected a SimHash for the article")
+	}
+	printed, _ := SimHash([]byte(printView))
+	different, _ := SimHash([]byte(other))
+
+	if distance := Distance(original, printed); distance > 10 {
+		t.Errorf("Expected the print view to be a near-duplicate, distance %d", distance)
+	}
+	if distance := Distance(original, different); distance <= 10 {
+		t.Errorf("Expected an unrelated page to be far away, distance %d", distance)
+	}
+
+	if _, ok := SimHash([]byte(`<html><body><script>var x = 1;</script></body></html>`)); ok {
+		t.Errorf("Expected no SimHash for a page without text")
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	short := `<html><body><div id="header"><a href="/">Home</a></div><div class="content"><p>One</p></div><div id="footer"></div></body></html>`
+	long := `<html><body><div id="header"><a href="/">Home</a></div><div class="content"><p>One</p><p>Two <b>bold</b></p><p>Three</p></div><div id="footer"></div></body></html>`
+	other := `<html><body><nav class="menu"><a href="/">Home</a></nav><main><p>One</p></main></body></html>`
+
+	if Template([]byte(short)) != Template([]byte(long)) {
+		t.Errorf("Expected pages from the same template to match however much content they have")
+	}
+	if Template([]byte(short)) == Template([]byte(other)) {
+		t.Errorf("Expected a different layout to hash differently")
+	}
+}
@@ -0,0 +1,105 @@
+package graph
+
+import "sort"
+
+// Links are a directed graph as each node's outgoing edges, nodes only linked to needn't be keys
+type Links map[string][]string
+
+// StronglyConnected splits the graph into strongly connected components, sets of nodes every one of which
+// can be reached from every other, using Tarjan's algorithm
+// Nodes in each component are sorted, and components are sorted largest first
+func StronglyConnected(links Links) [][]string {
+	tarjan := &tarjan{links: links, index: make(map[string]int), low: make(map[string]int), onStack: make(map[string]bool)}
+
+	nodes := make([]string, 0, len(links))
+	for node := range links {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if _, visited := tarjan.index[node]; !visited {
+			tarjan.connect(node)
+		}
+	}
+
+	for _, component := range tarjan.components {
+		sort.Strings(component)
+	}
+	sort.SliceStable(tarjan.components, func(i, j int) bool {
+		if len(tarjan.components[i]) != len(tarjan.components[j]) {
+			return len(tarjan.components[i]) > len(tarjan.components[j])
+		}
+		return tarjan.components[i][0] < tarjan.components[j][0]
+	})
+	return tarjan.components
+}
+
+type tarjan struct {
+	links Links
+
+	next    int
+	index   map[string]int
+	low     map[string]int
+	stack   []string
+	onStack map[string]bool
+
+	components [][]string
+}
+
+func (tarjan *tarjan) connect(node string) {
+	tarjan.index[node], tarjan.low[node] = tarjan.next, tarjan.next
+	tarjan.next++
+	tarjan.stack = append(tarjan.stack, node)
+	tarjan.onStack[node] = true
+
+	for _, next := range tarjan.links[node] {
+		if _, visited := tarjan.index[next]; !visited {
+			tarjan.connect(next)
+			if tarjan.low[next] < tarjan.low[node] {
+				tarjan.low[node] = tarjan.low[next]
+			}
+		} else if tarjan.onStack[next] && tarjan.index[next] < tarjan.low[node] {
+			tarjan.low[node] = tarjan.index[next]
+		}
+	}
+
+	// A node no earlier node can be reached from roots a component of everything above it on the stack
+	if tarjan.low[node] != tarjan.index[node] {
+		return
+	}
+	var component []string
+	for {
+		last := tarjan.stack[len(tarjan.stack)-1]
+		tarjan.stack = tarjan.stack[:len(tarjan.stack)-1]
+		tarjan.onStack[last] = false
+		component = append(component, last)
+		if last == node {
+			break
+		}
+	}
+	tarjan.components = append(tarjan.components, component)
+}
+
+// Reachable is every node that can be reached by following edges from any of the roots, roots included
+func Reachable(links Links, roots ...string) map[string]bool {
+	reached := make(map[string]bool, len(links))
+	queue := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if !reached[root] {
+			reached[root] = true
+			queue = append(queue, root)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range links[node] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
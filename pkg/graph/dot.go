@@ -0,0 +1,120 @@
+package graph
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes the graph as a Graphviz digraph, quoting names and values wherever DOT needs them
+// to be, with nodes inside the clusters they belong to
+func (graph *Graph) WriteDOT(w io.Writer) error {
+	attributes := graph.Attributes()
+	clusters := graph.Clusters()
+	nodes := graph.Nodes()
+	edges := graph.Edges()
+
+	clustered := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		clustered[cluster.ID] = true
+	}
+	members := make(map[string][]Node)
+	for _, node := range nodes {
+		members[node.Cluster] = append(members[node.Cluster], node)
+	}
+
+	out := bufio.NewWriter(w)
+	out.WriteString("digraph " + quote(graph.name) + " {\n")
+	writeStatements(out, "\t", attributes)
+
+	for _, cluster := range clusters {
+		out.WriteString("\tsubgraph " + quote(cluster.ID) + " {\n")
+		writeStatements(out, "\t\t", cluster.Attributes)
+		for _, node := range members[cluster.ID] {
+			out.WriteString("\t\t" + quote(node.ID) + writeList(node.Attributes) + ";\n")
+		}
+		out.WriteString("\t}\n")
+	}
+
+	// Nodes outside any cluster, or in one that was never added, are drawn at the top level
+	for _, node := range nodes {
+		if clustered[node.Cluster] {
+			continue
+		}
+		out.WriteString("\t" + quote(node.ID) + writeList(node.Attributes) + ";\n")
+	}
+
+	for _, edge := range edges {
+		out.WriteString("\t" + quote(edge.From) + "->" + quote(edge.To) + writeList(edge.Attributes) + ";\n")
+	}
+
+	out.WriteString("}\n")
+	return out.Flush()
+}
+
+// String is the graph as DOT
+func (graph *Graph) String() string {
+	var builder strings.Builder
+	graph.WriteDOT(&builder)
+	return builder.String()
+}
+
+func writeStatements(out *bufio.Writer, indent string, attributes Attributes) {
+	for _, key := range attributes.keys() {
+		out.WriteString(indent + quote(key) + "=" + quote(attributes[key]) + ";\n")
+	}
+}
+
+func writeList(attributes Attributes) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	list := make([]string, 0, len(attributes))
+	for _, key := range attributes.keys() {
+		list = append(list, quote(key)+"="+quote(attributes[key]))
+	}
+	return " [ " + strings.Join(list, ", ") + " ]"
+}
+
+// quote leaves plain identifiers and numbers as they are and quotes everything else, escaping quotes
+// and newlines, while backslash escapes like \l are passed through for Graphviz to interpret
+func quote(id string) string {
+	if isIdentifier(id) || isNumeral(id) {
+		return id
+	}
+	escaped := strings.NewReplacer(`"`, `\"`, "\n", `\n`).Replace(id)
+	return `"` + escaped + `"`
+}
+
+func isIdentifier(id string) bool {
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		return false
+	}
+	for _, r := range id {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	// Keywords have to be quoted to be used as names
+	switch strings.ToLower(id) {
+	case "node", "edge", "graph", "digraph", "subgraph", "strict":
+		return false
+	}
+	return true
+}
+
+func isNumeral(id string) bool {
+	dot, digits := false, false
+	for i, r := range id {
+		switch {
+		case r == '-' && i == 0:
+		case r == '.' && !dot:
+			dot = true
+		case r >= '0' && r <= '9':
+			digits = true
+		default:
+			return false
+		}
+	}
+	return digits
+}
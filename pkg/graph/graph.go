@@ -0,0 +1,183 @@
+// Package graph is a directed graph of nodes grouped into clusters, safe for concurrent use,
+// which can be written out as Graphviz DOT or read back node by node for other formats
+package graph
+
+import (
+	"sort"
+	"sync"
+)
+
+// Attributes are a node, edge, cluster or graph's Graphviz attributes, with unquoted values
+type Attributes map[string]string
+
+// Node is a node and the cluster it's drawn in, empty for the top level
+type Node struct {
+	ID         string
+	Cluster    string
+	Attributes Attributes
+}
+
+// Edge is a directed edge between two nodes
+type Edge struct {
+	From       string
+	To         string
+	Attributes Attributes
+}
+
+// Cluster is a group of nodes drawn together
+type Cluster struct {
+	ID         string
+	Attributes Attributes
+}
+
+// Graph keeps nodes, edges and clusters in the order they were first added, so output is stable
+// Every method takes the graph's own lock, callers needn't hold one of their own
+type Graph struct {
+	name string
+
+	mutex      sync.RWMutex
+	attributes Attributes
+	clusters   []*Cluster
+	clusterIDs map[string]*Cluster
+	nodes      []*Node
+	nodeIDs    map[string]*Node
+	edges      []Edge
+}
+
+// New creates an empty graph
+func New(name string) *Graph {
+	return &Graph{
+		name:       name,
+		attributes: make(Attributes),
+		clusterIDs: make(map[string]*Cluster),
+		nodeIDs:    make(map[string]*Node),
+	}
+}
+
+// Name is what the graph is called
+func (graph *Graph) Name() string {
+	return graph.name
+}
+
+// SetAttribute sets an attribute of the whole graph
+func (graph *Graph) SetAttribute(key string, value string) {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	graph.attributes[key] = value
+}
+
+// AddCluster adds a cluster, or sets more attributes on one already there
+func (graph *Graph) AddCluster(id string, attributes Attributes) {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	cluster, ok := graph.clusterIDs[id]
+	if !ok {
+		cluster = &Cluster{ID: id, Attributes: make(Attributes)}
+		graph.clusterIDs[id] = cluster
+		graph.clusters = append(graph.clusters, cluster)
+	}
+	merge(cluster.Attributes, attributes)
+}
+
+// HasCluster is whether a cluster was added
+func (graph *Graph) HasCluster(id string) bool {
+	graph.mutex.RLock()
+	defer graph.mutex.RUnlock()
+
+	_, ok := graph.clusterIDs[id]
+	return ok
+}
+
+// AddNode adds a node to a cluster, or sets more attributes on one already there, which stays in
+// the cluster it was first added to
+func (graph *Graph) AddNode(cluster string, id string, attributes Attributes) {
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	node, ok := graph.nodeIDs[id]
+	if !ok {
+		node = &Node{ID: id, Cluster: cluster, Attributes: make(Attributes)}
+		graph.nodeIDs[id] = node
+		graph.nodes = append(graph.nodes, node)
+	}
+	merge(node.Attributes, attributes)
+}
+
+// AddEdge adds an edge, nodes can be joined by more than one
+func (graph *Graph) AddEdge(from string, to string, attributes Attributes) {
+	edge := Edge{From: from, To: to, Attributes: make(Attributes)}
+	merge(edge.Attributes, attributes)
+
+	graph.mutex.Lock()
+	defer graph.mutex.Unlock()
+
+	graph.edges = append(graph.edges, edge)
+}
+
+// Attributes copies the attributes of the whole graph
+func (graph *Graph) Attributes() Attributes {
+	graph.mutex.RLock()
+	defer graph.mutex.RUnlock()
+
+	return copyAttributes(graph.attributes)
+}
+
+// Clusters copies every cluster, in the order they were added
+func (graph *Graph) Clusters() []Cluster {
+	graph.mutex.RLock()
+	defer graph.mutex.RUnlock()
+
+	clusters := make([]Cluster, 0, len(graph.clusters))
+	for _, cluster := range graph.clusters {
+		clusters = append(clusters, Cluster{ID: cluster.ID, Attributes: copyAttributes(cluster.Attributes)})
+	}
+	return clusters
+}
+
+// Nodes copies every node, in the order they were added
+func (graph *Graph) Nodes() []Node {
+	graph.mutex.RLock()
+	defer graph.mutex.RUnlock()
+
+	nodes := make([]Node, 0, len(graph.nodes))
+	for _, node := range graph.nodes {
+		nodes = append(nodes, Node{ID: node.ID, Cluster: node.Cluster, Attributes: copyAttributes(node.Attributes)})
+	}
+	return nodes
+}
+
+// Edges copies every edge, in the order they were added
+func (graph *Graph) Edges() []Edge {
+	graph.mutex.RLock()
+	defer graph.mutex.RUnlock()
+
+	edges := make([]Edge, 0, len(graph.edges))
+	for _, edge := range graph.edges {
+		edges = append(edges, Edge{From: edge.From, To: edge.To, Attributes: copyAttributes(edge.Attributes)})
+	}
+	return edges
+}
+
+func merge(into Attributes, from Attributes) {
+	for key, value := range from {
+		into[key] = value
+	}
+}
+
+func copyAttributes(attributes Attributes) Attributes {
+	copied := make(Attributes, len(attributes))
+	merge(copied, attributes)
+	return copied
+}
+
+// keys are the attribute names in order, so the same graph is always written the same way
+func (attributes Attributes) keys() []string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
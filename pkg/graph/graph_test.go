@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWriteDOT(t *testing.T) {
+	graph := New("Grawled Websites")
+	graph.SetAttribute("comment", "pages=2")
+	graph.AddCluster("cluster_a", Attributes{"label": "example.com"})
+	graph.AddNode("", "start", Attributes{"label": "Start"})
+	graph.AddNode("cluster_a", "a", Attributes{"label": "/"})
+	graph.AddNode("cluster_a", "b", Attributes{"label": `say "hi"`})
+	graph.AddNode("elsewhere", "b", Attributes{"color": "orange"})
+	graph.AddEdge("start", "a", nil)
+	graph.AddEdge("a", "b", Attributes{"weight": "2", "tooltip": "line\nbreak"})
+
+	expected := `digraph "Grawled Websites" {
+	comment="pages=2";
+	subgraph cluster_a {
+		label="example.com";
+		a [ label="/" ];
+		b [ color=orange, label="say \"hi\"" ];
+	}
+	start [ label=Start ];
+	start->a;
+	a->b [ tooltip="line\nbreak", weight=2 ];
+}
+`
+	if output := graph.String(); output != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, output)
+	}
+}
+
+func TestConcurrentMutation(t *testing.T) {
+	graph := New("g")
+
+	var wait sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wait.Add(1)
+		go func(worker int) {
+			defer wait.Done()
+			cluster := fmt.Sprintf("cluster_%d", worker%2)
+			for i := 0; i < 100; i++ {
+				node := fmt.Sprintf("n%d_%d", worker, i)
+				graph.AddCluster(cluster, Attributes{"label": cluster})
+				graph.AddNode(cluster, node, Attributes{"label": node})
+				graph.AddEdge("start", node, nil)
+				graph.WriteDOT(ioutil.Discard)
+			}
+		}(worker)
+	}
+	wait.Wait()
+
+	if nodes, edges := len(graph.Nodes()), len(graph.Edges()); nodes != 800 || edges != 800 {
+		t.Errorf("Expected 800 nodes and edges, got %d and %d", nodes, edges)
+	}
+	if clusters := len(graph.Clusters()); clusters != 2 {
+		t.Errorf("Expected 2 clusters, got %d", clusters)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for id, expected := range map[string]string{
+		"n1f":       "n1f",
+		"1.5":       "1.5",
+		"-2":        "-2",
+		"subgraph":  `"subgraph"`,
+		"":          `""`,
+		"a b":       `"a b"`,
+		`x\ly`:      `"x\ly"`,
+		"-":         `"-"`,
+		"1a":        `"1a"`,
+		"node_name": "node_name",
+	} {
+		if quoted := quote(id); quoted != expected {
+			t.Errorf("Expected %q quoted as %s, got %s", id, expected, quoted)
+		}
+	}
+}
+
+func TestStronglyConnected(t *testing.T) {
+	links := Links{
+		"home":  {"about", "blog"},
+		"about": {"home"},
+		"blog":  {"post"},
+		"post":  {"blog", "pdf"},
+		"pdf":   {},
+		"lone":  {"home"},
+	}
+
+	components := StronglyConnected(links)
+	expected := [][]string{{"about", "home"}, {"blog", "post"}, {"lone"}, {"pdf"}}
+	if !reflect.DeepEqual(components, expected) {
+		t.Errorf("Expected components %v, got %v", expected, components)
+	}
+
+	reached := Reachable(links, "home")
+	if len(reached) != 5 || reached["lone"] {
+		t.Errorf("Expected everything but lone reachable from home, got %v", reached)
+	}
+}
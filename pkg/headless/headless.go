@@ -0,0 +1,61 @@
+// Package headless renders pages with a headless Chrome (or Chromium) binary
+package headless
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// Browser shells out to a Chrome binary for every page, so there's no session shared between pages
+type Browser struct {
+	// Path to the chrome/chromium executable
+	path string
+
+	userAgent string
+
+	// How long a single render may take before it's killed
+	timeout time.Duration
+
+	// Viewport used for screenshots, as "width,height"
+	WindowSize string
+}
+
+// New will construct a new Browser for the given executable
+func New(path string, userAgent string, timeout time.Duration) *Browser {
+	return &Browser{
+		path:       path,
+		userAgent:  userAgent,
+		timeout:    timeout,
+		WindowSize: "1280,800",
+	}
+}
+
+// DOM renders pageURL, running its scripts, and returns the resulting document
+func (browser *Browser) DOM(pageURL string) ([]byte, error) {
+	return browser.run("--dump-dom", pageURL)
+}
+
+// Screenshot renders pageURL and saves a PNG of the viewport to file
+func (browser *Browser) Screenshot(pageURL string, file string) error {
+	_, err := browser.run("--screenshot="+file, "--window-size="+browser.WindowSize, "--hide-scrollbars", pageURL)
+	return err
+}
+
+func (browser *Browser) run(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), browser.timeout)
+	defer cancel()
+
+	args = append([]string{"--headless", "--disable-gpu", "--no-sandbox", "--user-agent=" + browser.userAgent}, args...)
+	command := exec.CommandContext(ctx, browser.path, args...)
+
+	var stdout, stderr bytes.Buffer
+	command.Stdout, command.Stderr = &stdout, &stderr
+
+	if err := command.Run(); err != nil {
+		return nil, fmt.Errorf("headless: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
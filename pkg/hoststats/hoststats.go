@@ -0,0 +1,143 @@
+// Package hoststats keeps running averages of how fast and how reliably each host responds,
+// so the hosts dragging a crawl down can be told apart from the rest
+package hoststats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// How much each new request moves the averages, higher values forget older requests faster
+const weight float64 = 0.2
+
+// The lowest Penalty ever given, so even the worst hosts are eventually crawled rather than starved
+const minPenalty float64 = 0.05
+
+// Stats is how a host has been responding
+type Stats struct {
+	Requests int
+	Failures int
+
+	// Exponentially weighted moving averages, so a host that recovers stops looking bad
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+// Limits is when a host counts as bad
+type Limits struct {
+	// Average latency above which a host is slow, 0 to never call a host slow
+	Latency time.Duration
+
+	// Average error rate above which a host is erroring, 0 to never call a host erroring
+	ErrorRate float64
+
+	// Requests needed before a host is judged at all, so one unlucky request doesn't condemn it
+	MinRequests int
+}
+
+// Slow is true when the host is consistently slower than the limits allow
+func (stats Stats) Slow(limits Limits) bool {
+	return stats.Requests >= limits.MinRequests && limits.Latency > 0 && stats.Latency > limits.Latency
+}
+
+// Erroring is true when the host fails more often than the limits allow
+func (stats Stats) Erroring(limits Limits) bool {
+	return stats.Requests >= limits.MinRequests && limits.ErrorRate > 0 && stats.ErrorRate > limits.ErrorRate
+}
+
+// Bad is true when the host is slow, erroring or both
+func (stats Stats) Bad(limits Limits) bool {
+	return stats.Slow(limits) || stats.Erroring(limits)
+}
+
+// Tracker collects Stats for every host, it's safe for concurrent use
+type Tracker struct {
+	Limits Limits
+
+	mutex sync.Mutex
+	hosts map[string]*Stats
+}
+
+// New creates a Tracker judging hosts by limits
+func New(limits Limits) *Tracker {
+	return &Tracker{Limits: limits, hosts: make(map[string]*Stats)}
+}
+
+// Record adds a request to host that took latency and either succeeded or failed
+func (tracker *Tracker) Record(host string, latency time.Duration, failed bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	failure := 0.0
+	if failed {
+		failure = 1
+	}
+
+	stats, ok := tracker.hosts[host]
+	if !ok {
+		stats = &Stats{Latency: latency, ErrorRate: failure}
+		tracker.hosts[host] = stats
+	} else {
+		stats.Latency += time.Duration(weight * float64(latency-stats.Latency))
+		stats.ErrorRate += weight * (failure - stats.ErrorRate)
+	}
+
+	stats.Requests++
+	if failed {
+		stats.Failures++
+	}
+}
+
+// Stats is how host has been responding, false if no request to it was recorded
+func (tracker *Tracker) Stats(host string) (Stats, bool) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	stats, ok := tracker.hosts[host]
+	if !ok {
+		return Stats{}, false
+	}
+	return *stats, true
+}
+
+// Bad is true when host is slow or erroring
+func (tracker *Tracker) Bad(host string) bool {
+	stats, ok := tracker.Stats(host)
+	return ok && stats.Bad(tracker.Limits)
+}
+
+// Penalty is what to multiply the priority of a request to host by: 1 for hosts that aren't bad,
+// less the slower and more erroring they are
+func (tracker *Tracker) Penalty(host string) float64 {
+	stats, ok := tracker.Stats(host)
+	if !ok || !stats.Bad(tracker.Limits) {
+		return 1
+	}
+
+	penalty := 1.0
+	if stats.Slow(tracker.Limits) {
+		penalty *= float64(tracker.Limits.Latency) / float64(stats.Latency)
+	}
+	if stats.Erroring(tracker.Limits) {
+		penalty *= 1 - stats.ErrorRate
+	}
+
+	if penalty < minPenalty {
+		return minPenalty
+	}
+	return penalty
+}
+
+// Hosts lists every host with recorded requests, sorted
+func (tracker *Tracker) Hosts() []string {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	hosts := make([]string, 0, len(tracker.hosts))
+	for host := range tracker.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
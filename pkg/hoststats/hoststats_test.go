@@ -0,0 +1,43 @@
+package hoststats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := New(Limits{Latency: time.Second, ErrorRate: 0.5, MinRequests: 3})
+
+	for i := 0; i < 5; i++ {
+		tracker.Record("fast.example", 100*time.Millisecond, false)
+		tracker.Record("slow.example", 4*time.Second, false)
+		tracker.Record("broken.example", 100*time.Millisecond, true)
+	}
+
+	if tracker.Bad("fast.example") || tracker.Penalty("fast.example") != 1 {
+		t.Errorf("Expected fast.example to be left alone")
+	}
+	if penalty := tracker.Penalty("slow.example"); penalty != 0.25 {
+		t.Errorf("Expected slow.example to be penalized by how much slower it is, got %v", penalty)
+	}
+	if !tracker.Bad("broken.example") || tracker.Penalty("broken.example") != minPenalty {
+		t.Errorf("Expected broken.example to get the lowest penalty, got %v", tracker.Penalty("broken.example"))
+	}
+
+	// Recovering hosts stop being bad
+	for i := 0; i < 20; i++ {
+		tracker.Record("broken.example", 100*time.Millisecond, false)
+	}
+	if stats, _ := tracker.Stats("broken.example"); stats.Bad(tracker.Limits) || stats.Failures != 5 {
+		t.Errorf("Expected broken.example to have recovered, got %+v", stats)
+	}
+}
+
+func TestTrackerMinRequests(t *testing.T) {
+	tracker := New(Limits{Latency: time.Second, MinRequests: 3})
+	tracker.Record("unlucky.example", time.Minute, true)
+
+	if tracker.Bad("unlucky.example") || tracker.Bad("unknown.example") {
+		t.Errorf("Expected hosts not to be judged on a single request")
+	}
+}
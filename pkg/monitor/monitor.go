@@ -0,0 +1,149 @@
+// Package monitor re-checks important URLs and notices when they rot
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var checksBucket = []byte("monitor")
+
+// Check is the outcome of requesting a URL once
+type Check struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+
+	// Where the URL redirected to, if it did
+	Location string `json:"location,omitempty"`
+
+	// Set when no response was received at all
+	Error string `json:"error,omitempty"`
+
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Healthy is true for a successful response that didn't redirect
+func (check Check) Healthy() bool {
+	return check.Error == "" && check.Status >= 200 && check.Status < 300
+}
+
+// Redirected is true when the URL now points somewhere else
+func (check Check) Redirected() bool {
+	return check.Error == "" && check.Status >= 300 && check.Status < 400
+}
+
+func (check Check) String() string {
+	switch {
+	case check.Error != "":
+		return check.Error
+	case check.Redirected():
+		return fmt.Sprintf("%d redirect to %s", check.Status, check.Location)
+	default:
+		return fmt.Sprintf("%d", check.Status)
+	}
+}
+
+// Alert describes a change in a URL's health worth telling someone about
+type Alert struct {
+	Previous Check
+	Current  Check
+	Reason   string
+}
+
+// Monitor checks URLs and compares each result with the one stored from the previous check
+type Monitor struct {
+	db *bolt.DB
+
+	// Internal http Client, which must not follow redirects
+	client *http.Client
+}
+
+// New prepares a Monitor inside an already opened database
+// The client is copied so redirects can be observed rather than followed
+func New(db *bolt.DB, client *http.Client) (*Monitor, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(checksBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	noRedirects := *client
+	noRedirects.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Monitor{db, &noRedirects}, nil
+}
+
+// Check requests url and returns an Alert if its health changed since the last check
+func (monitor *Monitor) Check(url string) (Check, *Alert, error) {
+	current := monitor.request(url)
+
+	var previous *Check
+	err := monitor.db.Update(func(tx *bolt.Tx) error {
+		checks := tx.Bucket(checksBucket)
+
+		if value := checks.Get([]byte(url)); value != nil {
+			previous = &Check{}
+			if err := json.Unmarshal(value, previous); err != nil {
+				return err
+			}
+		}
+
+		value, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		return checks.Put([]byte(url), value)
+	})
+	if err != nil {
+		return current, nil, err
+	}
+
+	return current, compare(previous, current), nil
+}
+
+func (monitor *Monitor) request(url string) Check {
+	check := Check{URL: url, CheckedAt: time.Now()}
+
+	response, err := monitor.client.Get(url)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer response.Body.Close()
+
+	check.Status = response.StatusCode
+	check.Location = response.Header.Get("Location")
+	return check
+}
+
+// A URL seen for the first time is only worth an alert if it's already broken
+func compare(previous *Check, current Check) *Alert {
+	if previous == nil {
+		previous = &Check{URL: current.URL, Status: http.StatusOK}
+	}
+	alert := &Alert{Previous: *previous, Current: current}
+
+	switch {
+	case !current.Healthy() && !current.Redirected() && (previous.Healthy() || previous.Redirected()):
+		alert.Reason = fmt.Sprintf("started failing with %s", current)
+	case current.Redirected() && current.Location != previous.Location:
+		alert.Reason = fmt.Sprintf("now redirects to %s", current.Location)
+	case current.Healthy() && !previous.Healthy():
+		alert.Reason = fmt.Sprintf("recovered, was %s", previous)
+	default:
+		return nil
+	}
+
+	return alert
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMonitorAlerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "monitor.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusMovedPermanently {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	monitor, err := New(db, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectations := []struct {
+		status int
+		alert  bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusOK, false},
+		{http.StatusNotFound, true},
+		{http.StatusNotFound, false},
+		{http.StatusOK, true},
+		{http.StatusMovedPermanently, true},
+		{http.StatusMovedPermanently, false},
+	}
+
+	for i, expected := range expectations {
+		status = expected.status
+
+		check, alert, err := monitor.Check(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (alert != nil) != expected.alert {
+			t.Errorf("Check %d (%s): expected alert %v, got %+v", i, check, expected.alert, alert)
+		}
+	}
+}
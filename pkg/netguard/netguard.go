@@ -0,0 +1,83 @@
+// Package netguard keeps connections away from private networks, so crawling untrusted links can't be
+// used to reach services that are only meant to be reachable from inside
+package netguard
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Ranges that aren't reachable from the public internet, on top of the loopback, link-local and
+// unspecified ranges net.IP already knows about
+var privateRanges = mustParseCIDRs(
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10", // Carrier-grade NAT
+	"fc00::/7",      // Unique local addresses
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var ranges []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ranges = append(ranges, network)
+	}
+	return ranges
+}
+
+// Private is true for loopback, private, link-local and unspecified addresses
+func Private(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range privateRanges {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivateAddressError is returned when a connection to a private address is refused
+type PrivateAddressError struct {
+	Address string
+}
+
+func (err *PrivateAddressError) Error() string {
+	return fmt.Sprintf("refusing to connect to %s, a private network address", err.Address)
+}
+
+// Control is a net.Dialer Control function refusing connections to private addresses
+// It runs once the hostname has been resolved, so a public name resolving to a private address is caught too
+func Control(network string, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || Private(ip) {
+		return &PrivateAddressError{address}
+	}
+	return nil
+}
+
+// Transport is like http.DefaultTransport, but refuses to connect to private addresses
+func Transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   Control,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,48 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrivate(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1":       true,
+		"10.1.2.3":        true,
+		"172.31.255.255":  true,
+		"172.32.0.1":      false,
+		"192.168.1.1":     true,
+		"169.254.169.254": true,
+		"100.64.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fd00::1":         true,
+		"fe80::1":         true,
+		"8.8.8.8":         false,
+		"2001:4860::8888": false,
+		"::ffff:10.0.0.1": true,
+	}
+
+	for address, expected := range cases {
+		if private := Private(net.ParseIP(address)); private != expected {
+			t.Errorf("%s: expected %v, got %v", address, expected, private)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(), Timeout: time.Second}
+
+	_, err := client.Get(server.URL)
+	var private *PrivateAddressError
+	if !errors.As(err, &private) {
+		t.Errorf("Expected the connection to loopback to be refused, got %v", err)
+	}
+}
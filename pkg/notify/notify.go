@@ -0,0 +1,77 @@
+// Package notify delivers alerts to people
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Notifier delivers a single alert
+type Notifier interface {
+	Notify(subject string, message string) error
+}
+
+// Writer prints alerts, typically to stdout
+type Writer struct {
+	io.Writer
+}
+
+// Notify writes the alert as a single line
+func (writer Writer) Notify(subject string, message string) error {
+	_, err := fmt.Fprintf(writer, "ALERT %s: %s\n", subject, message)
+	return err
+}
+
+// Webhook POSTs alerts as JSON
+// The payload's "text" field makes it usable directly with Slack and Mattermost incoming webhooks
+type Webhook struct {
+	URL string
+
+	// If no http.Client is provided, we'll use the default one
+	Client *http.Client
+}
+
+// Notify sends the alert to the webhook
+func (webhook Webhook) Notify(subject string, message string) error {
+	client := webhook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"subject": subject,
+		"message": message,
+		"text":    fmt.Sprintf("%s: %s", subject, message),
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Post(webhook.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 399 || response.StatusCode < 200 {
+		return fmt.Errorf("notify: webhook returned %d", response.StatusCode)
+	}
+	return nil
+}
+
+// All fans an alert out to every notifier, returning the first error encountered
+type All []Notifier
+
+// Notify delivers the alert to every notifier, even if some of them fail
+func (notifiers All) Notify(subject string, message string) error {
+	var firstErr error
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(subject, message); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,31 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotifyAll(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	var printed bytes.Buffer
+	notifiers := All{Writer{&printed}, Webhook{URL: server.URL}}
+
+	if err := notifiers.Notify("http://example.com/", "started returning 404"); err != nil {
+		t.Fatal(err)
+	}
+
+	if printed.String() != "ALERT http://example.com/: started returning 404\n" {
+		t.Errorf("Unexpected output %q", printed.String())
+	}
+	if received["text"] != "http://example.com/: started returning 404" {
+		t.Errorf("Unexpected webhook payload %v", received)
+	}
+}
@@ -0,0 +1,511 @@
+// Package queue provides a persistent job queue backed by BoltDB
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	availableBucket = []byte("available")
+	scheduledBucket = []byte("scheduled")
+	inflightBucket  = []byte("inflight")
+	failedBucket    = []byte("failed")
+	heldBucket      = []byte("held")
+
+	// In-flight job IDs by deadline, so expired jobs are found without reading every job in flight
+	deadlinesBucket = []byte("deadlines")
+)
+
+// ErrUnknownJob is returned when acknowledging or retrying a job that isn't in flight
+var ErrUnknownJob = errors.New("queue: unknown job")
+
+// Job is a single unit of work in the queue
+type Job struct {
+	ID      uint64 `json:"id"`
+	Payload []byte `json:"payload"`
+
+	// Available jobs with a higher priority are handed out first
+	Priority float64 `json:"priority"`
+
+	// How many times this job has been handed out without being acknowledged
+	Attempts int `json:"attempts"`
+
+	// The job won't be handed out before this time
+	NotBefore time.Time `json:"notBefore"`
+
+	// While in flight, when the job becomes visible again if it isn't acknowledged
+	Deadline time.Time `json:"deadline,omitempty"`
+}
+
+// Queue is a durable priority queue of jobs
+//
+// Jobs become available once their NotBefore time has passed, and available jobs are handed out
+// highest priority first, oldest first among equals.
+// Popped jobs are held "in flight" until they are acknowledged, retried or failed.
+// If none of those happens within the visibility timeout (say, because the process crashed)
+// the job is made available again automatically. The timeout runs from the pop, or from the last
+// Touch for work that waits a while before it begins.
+// Failed jobs are set aside, out of the way, until they're revived.
+// The rest can be held back the same way, so only the revived ones are handed out.
+type Queue struct {
+	db *bolt.DB
+
+	// How long a popped job may go unacknowledged before it is handed out again
+	visibility time.Duration
+}
+
+// New prepares a queue inside an already opened database
+// The database may be shared with other users as long as they stay out of the queue's buckets
+// Jobs held back by a process that never released them are released
+func New(db *bolt.DB, visibility time.Duration) (*Queue, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{availableBucket, scheduledBucket, inflightBucket, failedBucket, heldBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(deadlinesBucket) == nil {
+			if err := indexDeadlines(tx); err != nil {
+				return err
+			}
+		}
+		_, err := release(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Queue{db, visibility}, nil
+}
+
+// Push adds a payload to the queue, available immediately
+func (queue *Queue) Push(payload []byte, priority float64) error {
+	return queue.Schedule(payload, priority, time.Now())
+}
+
+// Schedule adds a payload that won't be handed out before the given time
+func (queue *Queue) Schedule(payload []byte, priority float64, at time.Time) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(availableBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return putWaiting(tx, Job{ID: id, Payload: payload, Priority: priority, NotBefore: at}, time.Now())
+	})
+}
+
+// Pop hands out the next available job, if there is one
+// The job stays in flight until it is passed to Ack or Retry
+func (queue *Queue) Pop() (Job, bool, error) {
+	var job Job
+	found := false
+
+	err := queue.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		available := tx.Bucket(availableBucket)
+
+		if err := requeueExpired(tx, now); err != nil {
+			return err
+		}
+		if err := promoteScheduled(tx, now); err != nil {
+			return err
+		}
+
+		key, value := available.Cursor().First()
+		if key == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(value, &job); err != nil {
+			return err
+		}
+		if err := available.Delete(key); err != nil {
+			return err
+		}
+
+		job.Attempts++
+		job.Deadline = now.Add(queue.visibility)
+		found = true
+
+		return putInflight(tx, job)
+	})
+
+	return job, found, err
+}
+
+// Touch restarts the visibility timeout of an in-flight job, for when it waited a while before work on it began
+func (queue *Queue) Touch(id uint64) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		job, err := takeInflight(tx, id)
+		if err != nil {
+			return err
+		}
+
+		job.Deadline = time.Now().Add(queue.visibility)
+		return putInflight(tx, job)
+	})
+}
+
+// Ack marks an in-flight job as done, removing it from the queue
+func (queue *Queue) Ack(id uint64) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		_, err := takeInflight(tx, id)
+		return err
+	})
+}
+
+// Retry puts an in-flight job back in the queue to be handed out again after delay
+func (queue *Queue) Retry(id uint64, delay time.Duration) error {
+	return queue.requeue(id, delay, true)
+}
+
+// Postpone is Retry for a job that was never attempted, so handing it out doesn't count as an attempt
+func (queue *Queue) Postpone(id uint64, delay time.Duration) error {
+	return queue.requeue(id, delay, false)
+}
+
+func (queue *Queue) requeue(id uint64, delay time.Duration, attempted bool) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		job, err := takeInflight(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if !attempted {
+			job.Attempts--
+		}
+		job.NotBefore = time.Now().Add(delay)
+		job.Deadline = time.Time{}
+		return putWaiting(tx, job, time.Now())
+	})
+}
+
+// Fail sets an in-flight job aside as failed, it isn't handed out again unless it's revived
+func (queue *Queue) Fail(id uint64) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		job, err := takeInflight(tx, id)
+		if err != nil {
+			return err
+		}
+
+		job.Deadline = time.Time{}
+		return putJob(tx.Bucket(failedBucket), idKey(id), job)
+	})
+}
+
+// Failed lists the failed jobs, oldest first
+func (queue *Queue) Failed() ([]Job, error) {
+	var jobs []Job
+
+	err := queue.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(failedBucket).ForEach(func(key, value []byte) error {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+
+	return jobs, err
+}
+
+// Revive makes every failed job available again with its attempts reset, returning how many there were
+func (queue *Queue) Revive() (int, error) {
+	revived := 0
+
+	err := queue.db.Update(func(tx *bolt.Tx) error {
+		failed := tx.Bucket(failedBucket)
+		now := time.Now()
+
+		cursor := failed.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.First() {
+			var job Job
+			if err := json.Unmarshal(value, &job); err != nil {
+				return err
+			}
+			if err := failed.Delete(key); err != nil {
+				return err
+			}
+
+			job.Attempts = 0
+			job.NotBefore = now
+			if err := putWaiting(tx, job, now); err != nil {
+				return err
+			}
+			revived++
+		}
+		return nil
+	})
+
+	return revived, err
+}
+
+// Hold sets every waiting or in-flight job aside until Release, returning how many there were
+// Only jobs added or revived afterwards are handed out in the meantime
+func (queue *Queue) Hold() (int, error) {
+	held := 0
+
+	err := queue.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{availableBucket, scheduledBucket, inflightBucket} {
+			bucket := tx.Bucket(name)
+
+			cursor := bucket.Cursor()
+			for key, value := cursor.First(); key != nil; key, value = cursor.First() {
+				var job Job
+				if err := json.Unmarshal(value, &job); err != nil {
+					return err
+				}
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+
+				job.Deadline = time.Time{}
+				if err := putJob(tx.Bucket(heldBucket), idKey(job.ID), job); err != nil {
+					return err
+				}
+				held++
+			}
+		}
+		// Nothing is in flight any more
+		if err := tx.DeleteBucket(deadlinesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(deadlinesBucket)
+		return err
+	})
+
+	return held, err
+}
+
+// Release puts every held job back in the queue, returning how many there were
+func (queue *Queue) Release() (int, error) {
+	released := 0
+
+	err := queue.db.Update(func(tx *bolt.Tx) (err error) {
+		released, err = release(tx)
+		return err
+	})
+
+	return released, err
+}
+
+// Len reports how many jobs are waiting (available or scheduled) and how many are in flight
+func (queue *Queue) Len() (ready int, inflight int, err error) {
+	err = queue.db.View(func(tx *bolt.Tx) error {
+		ready = tx.Bucket(availableBucket).Stats().KeyN + tx.Bucket(scheduledBucket).Stats().KeyN
+		inflight = tx.Bucket(inflightBucket).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+// Jobs lists every job in the queue, available ones in the order they'd be handed out,
+// then scheduled ones by time, then those in flight, then those held back
+func (queue *Queue) Jobs() ([]Job, error) {
+	var jobs []Job
+
+	err := queue.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{availableBucket, scheduledBucket, inflightBucket, heldBucket} {
+			err := tx.Bucket(name).ForEach(func(key, value []byte) error {
+				var job Job
+				if err := json.Unmarshal(value, &job); err != nil {
+					return err
+				}
+				jobs = append(jobs, job)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return jobs, err
+}
+
+// Restore adds a job taken from another queue, keeping its priority, attempts and NotBefore
+// The job is given a new ID, and if it was in flight it's made available again
+func (queue *Queue) Restore(job Job) error {
+	return queue.db.Update(func(tx *bolt.Tx) error {
+		id, err := tx.Bucket(availableBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+
+		job.ID = id
+		job.Deadline = time.Time{}
+		return putWaiting(tx, job, time.Now())
+	})
+}
+
+func release(tx *bolt.Tx) (int, error) {
+	held := tx.Bucket(heldBucket)
+	now := time.Now()
+	released := 0
+
+	cursor := held.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.First() {
+		var job Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return released, err
+		}
+		if err := held.Delete(key); err != nil {
+			return released, err
+		}
+		if err := putWaiting(tx, job, now); err != nil {
+			return released, err
+		}
+		released++
+	}
+
+	return released, nil
+}
+
+// Any in-flight job past its deadline is assumed lost and goes back in the queue
+// Deadlines are walked in order, so only the expired ones are read
+func requeueExpired(tx *bolt.Tx, now time.Time) error {
+	cursor := tx.Bucket(deadlinesBucket).Cursor()
+
+	for key, _ := cursor.First(); key != nil && decodeTime(key).Before(now); key, _ = cursor.First() {
+		job, err := takeInflight(tx, binary.BigEndian.Uint64(key[8:]))
+		if err != nil {
+			return err
+		}
+
+		job.Deadline = time.Time{}
+		if err := putWaiting(tx, job, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putInflight stores a popped job along with its deadline
+func putInflight(tx *bolt.Tx, job Job) error {
+	if err := tx.Bucket(deadlinesBucket).Put(timeKey(job.Deadline, job.ID), nil); err != nil {
+		return err
+	}
+	return putJob(tx.Bucket(inflightBucket), idKey(job.ID), job)
+}
+
+// takeInflight removes an in-flight job and its deadline, returning the job
+func takeInflight(tx *bolt.Tx, id uint64) (Job, error) {
+	var job Job
+	inflight := tx.Bucket(inflightBucket)
+
+	value := inflight.Get(idKey(id))
+	if value == nil {
+		return job, ErrUnknownJob
+	}
+	if err := json.Unmarshal(value, &job); err != nil {
+		return job, err
+	}
+	if err := inflight.Delete(idKey(id)); err != nil {
+		return job, err
+	}
+	return job, tx.Bucket(deadlinesBucket).Delete(timeKey(job.Deadline, id))
+}
+
+// indexDeadlines creates the deadlines bucket for the jobs in flight, as queues written before it
+// existed have jobs in flight without it
+func indexDeadlines(tx *bolt.Tx) error {
+	deadlines, err := tx.CreateBucket(deadlinesBucket)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(inflightBucket).ForEach(func(key, value []byte) error {
+		var job Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return err
+		}
+		return deadlines.Put(timeKey(job.Deadline, job.ID), nil)
+	})
+}
+
+// Scheduled jobs whose time has come become available
+func promoteScheduled(tx *bolt.Tx, now time.Time) error {
+	scheduled := tx.Bucket(scheduledBucket)
+	cursor := scheduled.Cursor()
+
+	for key, value := cursor.First(); key != nil && !decodeTime(key).After(now); key, value = cursor.First() {
+		var job Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			return err
+		}
+		if err := scheduled.Delete(key); err != nil {
+			return err
+		}
+		if err := putJob(tx.Bucket(availableBucket), availableKey(job), job); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putWaiting stores a job that isn't in flight, as available or scheduled depending on its NotBefore
+func putWaiting(tx *bolt.Tx, job Job, now time.Time) error {
+	if job.NotBefore.After(now) {
+		return putJob(tx.Bucket(scheduledBucket), scheduledKey(job), job)
+	}
+	return putJob(tx.Bucket(availableBucket), availableKey(job), job)
+}
+
+// Available jobs are keyed by descending priority then ID, so a cursor walks them in the order they're handed out
+func availableKey(job Job) []byte {
+	// Flip the float's bits so they sort the same way as the numbers, then invert for descending order
+	bits := math.Float64bits(job.Priority)
+	if job.Priority >= 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], ^bits)
+	binary.BigEndian.PutUint64(key[8:], job.ID)
+	return key
+}
+
+// Scheduled jobs are keyed by availability time then ID, so a cursor walks them in order
+func scheduledKey(job Job) []byte {
+	return timeKey(job.NotBefore, job.ID)
+}
+
+func timeKey(at time.Time, id uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(at.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], id)
+	return key
+}
+
+func putJob(bucket *bolt.Bucket, key []byte, job Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, value)
+}
+
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+func decodeTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+}
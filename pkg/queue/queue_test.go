@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestQueue(t *testing.T, visibility time.Duration) (*Queue, func()) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "queue.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err := New(db, visibility)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return queue, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+
+	for _, payload := range []string{"first", "second"} {
+		if err := queue.Push([]byte(payload), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []string{"first", "second"} {
+		job, ok, err := queue.Pop()
+		if err != nil || !ok {
+			t.Fatalf("Expected a job, got %v %v", ok, err)
+		}
+		if string(job.Payload) != expected {
+			t.Errorf("Expected %s, got %s", expected, job.Payload)
+		}
+		if err := queue.Ack(job.ID); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, ok, _ := queue.Pop(); ok {
+		t.Errorf("Queue should be empty")
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+	queue.Push([]byte("flaky"), 0)
+
+	job, _, _ := queue.Pop()
+	if err := queue.Retry(job.ID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := queue.Pop(); ok {
+		t.Errorf("Retried job shouldn't be available before its delay")
+	}
+
+	ready, inflight, _ := queue.Len()
+	if ready != 1 || inflight != 0 {
+		t.Errorf("Expected 1 ready and 0 in flight, got %d and %d", ready, inflight)
+	}
+}
+
+func TestQueuePostpone(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+	queue.Push([]byte("skipped"), 0)
+
+	job, _, _ := queue.Pop()
+	if err := queue.Postpone(job.ID, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, _ := queue.Pop()
+	if !ok || job.Attempts != 1 {
+		t.Errorf("Expected the postponed job back without the skipped attempt counted, got %+v", job)
+	}
+}
+
+func TestQueueFailAndRevive(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+	queue.Push([]byte("down"), 1)
+
+	job, _, _ := queue.Pop()
+	if err := queue.Fail(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := queue.Pop(); ok {
+		t.Errorf("Failed job shouldn't be handed out again")
+	}
+	if failed, _ := queue.Failed(); len(failed) != 1 || string(failed[0].Payload) != "down" {
+		t.Errorf("Expected the job to be listed as failed, got %v", failed)
+	}
+
+	if revived, err := queue.Revive(); revived != 1 || err != nil {
+		t.Fatalf("Expected 1 job revived, got %d %v", revived, err)
+	}
+	job, ok, _ := queue.Pop()
+	if !ok || string(job.Payload) != "down" || job.Attempts != 1 {
+		t.Errorf("Expected the revived job with fresh attempts, got %+v", job)
+	}
+	if failed, _ := queue.Failed(); len(failed) != 0 {
+		t.Errorf("Expected no failed jobs left, got %v", failed)
+	}
+}
+
+func TestQueueHold(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+	queue.Push([]byte("down"), 1)
+	queue.Push([]byte("leftover"), 0)
+
+	job, _, _ := queue.Pop()
+	queue.Fail(job.ID)
+	if held, err := queue.Hold(); held != 1 || err != nil {
+		t.Fatalf("Expected 1 job held, got %d %v", held, err)
+	}
+	queue.Revive()
+
+	if job, ok, _ := queue.Pop(); !ok || string(job.Payload) != "down" {
+		t.Fatalf("Expected the revived job, got %+v", job)
+	}
+	if job, ok, _ := queue.Pop(); ok {
+		t.Fatalf("Expected the held job to stay out of the way, got %+v", job)
+	}
+
+	if released, err := queue.Release(); released != 1 || err != nil {
+		t.Fatalf("Expected 1 job released, got %d %v", released, err)
+	}
+	if job, ok, _ := queue.Pop(); !ok || string(job.Payload) != "leftover" {
+		t.Errorf("Expected the released job, got %+v", job)
+	}
+}
+
+func TestQueueVisibilityTimeout(t *testing.T) {
+	queue, cleanup := openTestQueue(t, -time.Second)
+	defer cleanup()
+	queue.Push([]byte("lost"), 0)
+
+	first, _, _ := queue.Pop()
+
+	// The first worker never acknowledged the job, so it should be handed out again
+	second, ok, _ := queue.Pop()
+	if !ok || second.ID != first.ID {
+		t.Fatalf("Expected job %d to be handed out again", first.ID)
+	}
+	if second.Attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", second.Attempts)
+	}
+
+	if err := queue.Ack(first.ID); err != nil {
+		t.Error(err)
+	}
+	if err := queue.Ack(first.ID); err != ErrUnknownJob {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestQueueTouch(t *testing.T) {
+	queue, cleanup := openTestQueue(t, 200*time.Millisecond)
+	defer cleanup()
+	queue.Push([]byte("slow"), 0)
+
+	job, _, _ := queue.Pop()
+	time.Sleep(150 * time.Millisecond)
+	if err := queue.Touch(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The timeout restarted from the touch, so the job is still in flight past its first deadline
+	time.Sleep(150 * time.Millisecond)
+	if _, ok, _ := queue.Pop(); ok {
+		t.Fatalf("Expected job %d to still be in flight", job.ID)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if again, ok, _ := queue.Pop(); !ok || again.ID != job.ID {
+		t.Errorf("Expected job %d to be handed out again once the touched timeout passed", job.ID)
+	}
+
+	if err := queue.Touch(job.ID + 1); err != ErrUnknownJob {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	queue, cleanup := openTestQueue(t, time.Minute)
+	defer cleanup()
+
+	queue.Push([]byte("low"), -1)
+	queue.Push([]byte("normal"), 1)
+	queue.Push([]byte("high"), 2.5)
+	queue.Push([]byte("also normal"), 1)
+	queue.Schedule([]byte("later"), 10, time.Now().Add(time.Hour))
+
+	for _, expected := range []string{"high", "normal", "also normal", "low"} {
+		job, ok, err := queue.Pop()
+		if err != nil || !ok {
+			t.Fatalf("Expected a job, got %v %v", ok, err)
+		}
+		if string(job.Payload) != expected {
+			t.Errorf("Expected %s, got %s", expected, job.Payload)
+		}
+	}
+
+	if _, ok, _ := queue.Pop(); ok {
+		t.Errorf("Scheduled job shouldn't be available before its time")
+	}
+}
+
+func TestQueueRestore(t *testing.T) {
+	from, cleanupFrom := openTestQueue(t, time.Minute)
+	defer cleanupFrom()
+	to, cleanupTo := openTestQueue(t, time.Minute)
+	defer cleanupTo()
+
+	from.Push([]byte("waiting"), 1)
+	from.Push([]byte("popped"), 2)
+	from.Schedule([]byte("later"), 3, time.Now().Add(time.Hour))
+	from.Pop()
+
+	jobs, err := from.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 3 || string(jobs[0].Payload) != "waiting" || string(jobs[2].Payload) != "popped" {
+		t.Fatalf("Expected available, scheduled then in-flight jobs, got %v", jobs)
+	}
+
+	for _, job := range jobs {
+		if err := to.Restore(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The in-flight job comes back available, and keeps its priority and attempts
+	job, ok, _ := to.Pop()
+	if !ok || string(job.Payload) != "popped" || job.Attempts != 2 {
+		t.Errorf("Expected the restored in-flight job first on its second attempt, got %v", job)
+	}
+	if ready, _, _ := to.Len(); ready != 2 {
+		t.Errorf("Expected 2 jobs left, got %d", ready)
+	}
+}
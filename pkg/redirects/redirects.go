@@ -0,0 +1,130 @@
+// Package redirects finds redirects that aren't HTTP redirects, such as meta refreshes and scripts changing location
+package redirects
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of pseudo-redirect
+const (
+	MetaRefresh string = "meta-refresh"
+	JavaScript  string = "javascript"
+)
+
+// Pseudo is a redirect found in a page's body
+type Pseudo struct {
+	Kind string
+
+	// Where the page sends the browser, as written in the page (so possibly relative)
+	Target string
+
+	// How long the page waits before redirecting, only known for meta refreshes
+	Delay int
+}
+
+var (
+	metaTagPattern     = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	httpEquivPattern   = regexp.MustCompile(`(?is)http-equiv\s*=\s*["']?refresh["'\s>/]`)
+	contentAttrPattern = regexp.MustCompile(`(?is)content\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	scriptPattern      = regexp.MustCompile(`(?is)<script[^>]*>(.*?)</script>`)
+	locationPattern    = regexp.MustCompile(`(?is)(?:window\.|document\.|top\.|self\.)?location(?:\.href)?\s*=\s*["']([^"']+)["']|location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`)
+)
+
+// ParseRefresh reads the value of a Refresh header or meta refresh, e.g. "5; url=/elsewhere"
+// ok is false when there's no target, as that only reloads the same page
+func ParseRefresh(value string) (target string, delay int, ok bool) {
+	parts := strings.SplitN(value, ";", 2)
+	delay, _ = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if len(parts) < 2 {
+		return "", delay, false
+	}
+
+	target = strings.TrimSpace(parts[1])
+	if len(target) >= 4 && strings.EqualFold(target[:4], "url=") {
+		target = strings.TrimSpace(target[4:])
+	}
+	target = strings.Trim(target, `"'`)
+
+	return target, delay, target != ""
+}
+
+// Find lists every meta refresh and script location change in body
+func Find(body []byte) []Pseudo {
+	var found []Pseudo
+
+	for _, tag := range metaTagPattern.FindAll(body, -1) {
+		if !httpEquivPattern.Match(tag) {
+			continue
+		}
+
+		content := contentAttrPattern.FindSubmatch(tag)
+		if content == nil {
+			continue
+		}
+		value := string(content[1]) + string(content[2])
+
+		if target, delay, ok := ParseRefresh(value); ok {
+			found = append(found, Pseudo{Kind: MetaRefresh, Target: target, Delay: delay})
+		}
+	}
+
+	for _, script := range scriptPattern.FindAllSubmatch(body, -1) {
+		for _, match := range locationPattern.FindAllSubmatch(script[1], -1) {
+			found = append(found, Pseudo{Kind: JavaScript, Target: string(match[1]) + string(match[2])})
+		}
+	}
+
+	return found
+}
+
+// RedirectParameters lists the query parameters of link whose values are absolute URLs, like ?next=https://...
+// These are where open redirects usually hide
+func RedirectParameters(link *url.URL) []string {
+	var names []string
+	for name, values := range link.Query() {
+		for _, value := range values {
+			if isAbsolute(value) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isAbsolute(value string) bool {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "//") {
+		return len(value) > 2
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// WithParameter copies link with a query parameter replaced, e.g. to point a redirect parameter somewhere else
+func WithParameter(link *url.URL, name string, value string) *url.URL {
+	probe := *link
+	query := probe.Query()
+	query.Set(name, value)
+	probe.RawQuery = query.Encode()
+	return &probe
+}
+
+// Leads reports whether a redirect target, as written in a Location header or page, resolved against base,
+// sends the browser to host
+func Leads(base *url.URL, target string, host string) bool {
+	resolved, err := base.Parse(strings.TrimSpace(target))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(resolved.Hostname(), host)
+}
@@ -0,0 +1,82 @@
+package redirects
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseRefresh(t *testing.T) {
+	cases := []struct {
+		value  string
+		target string
+		delay  int
+		ok     bool
+	}{
+		{"0; url=http://example.com/", "http://example.com/", 0, true},
+		{"5;URL='/elsewhere'", "/elsewhere", 5, true},
+		{"3; /no-prefix", "/no-prefix", 3, true},
+		{"30", "", 30, false},
+	}
+
+	for _, c := range cases {
+		target, delay, ok := ParseRefresh(c.value)
+		if target != c.target || delay != c.delay || ok != c.ok {
+			t.Errorf("%q: expected %q %d %v, got %q %d %v", c.value, c.target, c.delay, c.ok, target, delay, ok)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	body := []byte(`<html><head>
+<meta charset="utf-8">
+<meta http-equiv="Refresh" content="0; url=/new-home">
+<script>if (legacy) { window.location.href = "/legacy"; }</script>
+<script>location.replace('https://example.com/moved')</script>
+</head></html>`)
+
+	expected := []Pseudo{
+		{Kind: MetaRefresh, Target: "/new-home"},
+		{Kind: JavaScript, Target: "/legacy"},
+		{Kind: JavaScript, Target: "https://example.com/moved"},
+	}
+
+	if found := Find(body); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, found)
+	}
+}
+
+func TestRedirectParameters(t *testing.T) {
+	link, _ := url.Parse("http://example.com/login?next=https%3A%2F%2Fevil.example%2F&page=2&back=//evil.example/x&path=/home&q=http%3A")
+	expected := []string{"back", "next"}
+	if names := RedirectParameters(link); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	probe := WithParameter(link, "next", "https://canary.example/")
+	if probe.Query().Get("next") != "https://canary.example/" || probe.Query().Get("page") != "2" {
+		t.Errorf("Expected only next to change, got %s", probe.String())
+	}
+	if link.Query().Get("next") != "https://evil.example/" {
+		t.Errorf("Expected the original link to be left alone, got %s", link.String())
+	}
+}
+
+func TestLeads(t *testing.T) {
+	base, _ := url.Parse("http://example.com/login")
+	cases := []struct {
+		target string
+		leads  bool
+	}{
+		{"https://canary.example/", true},
+		{"//CANARY.example/path", true},
+		{"/home", false},
+		{"https://example.com/?next=https://canary.example/", false},
+	}
+
+	for _, c := range cases {
+		if leads := Leads(base, c.target, "canary.example"); leads != c.leads {
+			t.Errorf("%q: expected %v, got %v", c.target, c.leads, leads)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"isImage": func(report *Report, column int) bool {
+		for _, image := range report.Images {
+			if column < len(report.Columns) && report.Columns[column] == image {
+				return true
+			}
+		}
+		return false
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Grawler Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+img { max-width: 320px; }
+</style>
+</head>
+<body>
+<h1>Grawler Report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+{{range .Reports}}{{$report := .}}
+<h2 id="{{.Name}}">{{.Name}} ({{.Len}})</h2>
+<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range $column, $value := .}}<td>{{if and $value (isImage $report $column)}}<a href="{{$value}}"><img src="{{$value}}" alt=""></a>{{else}}{{$value}}{{end}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders every report as a table in a single page
+func WriteHTML(w io.Writer, reports []*Report) error {
+	return htmlTemplate.Execute(w, struct {
+		Generated time.Time
+		Reports   []*Report
+	}{time.Now(), reports})
+}
+
+// SaveHTML writes every report to <dir>/report.html
+func SaveHTML(dir string, reports []*Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dir, "report.html"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return WriteHTML(file, reports)
+}
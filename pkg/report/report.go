@@ -0,0 +1,77 @@
+// Package report collects tabular findings during a crawl and writes them out as CSV
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Report is a named table of findings, safe to add to from many goroutines
+type Report struct {
+	// Used as the file name when the report is saved
+	Name string
+
+	Columns []string
+
+	// Columns holding image paths, which the HTML report shows inline
+	Images []string
+
+	mutex sync.Mutex
+	rows  [][]string
+}
+
+// New creates an empty report with the given column headers
+func New(name string, columns ...string) *Report {
+	return &Report{Name: name, Columns: columns}
+}
+
+// Add appends a row, values should line up with the report's columns
+func (report *Report) Add(values ...string) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.rows = append(report.rows, values)
+}
+
+// Len is the number of rows added so far
+func (report *Report) Len() int {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return len(report.rows)
+}
+
+// Rows returns a copy of every row added so far
+func (report *Report) Rows() [][]string {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return append([][]string{}, report.rows...)
+}
+
+// WriteCSV writes the header and every row
+func (report *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(report.Columns); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(report.Rows()); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// Save writes the report to <dir>/<name>.csv
+func (report *Report) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filepath.Join(dir, report.Name+".csv"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return report.WriteCSV(file)
+}
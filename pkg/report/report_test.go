@@ -0,0 +1,67 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReportWriteCSV(t *testing.T) {
+	report := New("pages", "url", "status")
+	report.Add("http://example.com/", "200")
+	report.Add("http://example.com/a,b", "404")
+
+	if report.Len() != 2 {
+		t.Errorf("Expected 2 rows, got %d", report.Len())
+	}
+
+	var output bytes.Buffer
+	if err := report.WriteCSV(&output); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "url,status\nhttp://example.com/,200\n\"http://example.com/a,b\",404\n"
+	if output.String() != expected {
+		t.Errorf("Unexpected CSV:\n%s", output.String())
+	}
+}
+
+func TestReportWriteHTML(t *testing.T) {
+	report := New("screenshots", "url", "screenshot")
+	report.Images = []string{"screenshot"}
+	report.Add("http://example.com/<script>", "shots/home.png")
+
+	var output bytes.Buffer
+	if err := WriteHTML(&output, []*Report{report}); err != nil {
+		t.Fatal(err)
+	}
+
+	html := output.String()
+	if !strings.Contains(html, `<img src="shots/home.png"`) {
+		t.Errorf("Expected the screenshot to be shown inline:\n%s", html)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("Expected values to be escaped:\n%s", html)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("broken-links, dead-hosts>2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken, dead := New("broken-links", "url"), New("dead-hosts", "host")
+	broken.Add("http://example.com/gone")
+	dead.Add("a.example")
+	dead.Add("b.example")
+
+	exceeded := Exceeded([]*Report{broken, dead}, thresholds)
+	if len(exceeded) != 1 || !strings.HasPrefix(exceeded[0], "broken-links") {
+		t.Errorf("Expected only broken-links over its threshold, got %v", exceeded)
+	}
+
+	if _, err := ParseThresholds("broken-links>some"); err == nil {
+		t.Errorf("Expected a threshold that isn't a number to be refused")
+	}
+}
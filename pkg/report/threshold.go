@@ -0,0 +1,49 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Threshold is the most rows a report may have before a crawl counts as failed
+type Threshold struct {
+	Report string
+	Max    int
+}
+
+// ParseThresholds reads a comma separated list of thresholds like "broken-links>0,dead-hosts>5"
+// A bare report name is short for name>0, failing on any row at all
+func ParseThresholds(spec string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		threshold := Threshold{Report: entry}
+		if i := strings.Index(entry, ">"); i >= 0 {
+			max, err := strconv.Atoi(strings.TrimSpace(entry[i+1:]))
+			if err != nil || max < 0 {
+				return nil, fmt.Errorf("report: bad threshold %q, expected report>count", entry)
+			}
+			threshold = Threshold{Report: strings.TrimSpace(entry[:i]), Max: max}
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// Exceeded describes every threshold that one of reports has gone over
+func Exceeded(reports []*Report, thresholds []Threshold) []string {
+	var exceeded []string
+	for _, threshold := range thresholds {
+		for _, report := range reports {
+			if report.Name == threshold.Report && report.Len() > threshold.Max {
+				exceeded = append(exceeded, fmt.Sprintf("%s has %d rows, more than %d", report.Name, report.Len(), threshold.Max))
+			}
+		}
+	}
+	return exceeded
+}
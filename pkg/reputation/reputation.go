@@ -0,0 +1,169 @@
+// Package reputation looks up whether links lead somewhere known to be malicious or parked
+package reputation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Source is somewhere to look links up
+type Source interface {
+	// Lookup returns the threat for each of the links that has one, such as "malware" or "parked"
+	Lookup(links []string) (map[string]string, error)
+}
+
+// Blocklist is a local list of domains and what's wrong with them
+// A domain covers all of its subdomains too
+type Blocklist map[string]string
+
+// The threat listed for a blocklist domain that doesn't name one
+const DefaultThreat string = "blocklisted"
+
+// ParseBlocklist reads a blocklist from lines of a domain, optionally followed by its threat, e.g. "parked.example parked"
+func ParseBlocklist(lines []string) Blocklist {
+	blocklist := make(Blocklist)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		threat := DefaultThreat
+		if len(fields) > 1 {
+			threat = fields[1]
+		}
+		blocklist[strings.ToLower(strings.TrimSuffix(fields[0], "."))] = threat
+	}
+	return blocklist
+}
+
+// Lookup matches every link's host, and each domain it's under, against the list
+func (blocklist Blocklist) Lookup(links []string) (map[string]string, error) {
+	threats := make(map[string]string)
+	for _, link := range links {
+		parsed, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+
+		host := strings.ToLower(parsed.Hostname())
+		for host != "" {
+			if threat, ok := blocklist[host]; ok {
+				threats[link] = threat
+				break
+			}
+
+			i := strings.Index(host, ".")
+			if i < 0 {
+				break
+			}
+			host = host[i+1:]
+		}
+	}
+	return threats, nil
+}
+
+// The most URLs the Safe Browsing API takes in a single lookup
+const safeBrowsingBatch int = 500
+
+// SafeBrowsing looks links up with the Google Safe Browsing v4 Lookup API
+type SafeBrowsing struct {
+	// Internal http Client
+	client *http.Client
+
+	key      string
+	endpoint string
+}
+
+// NewSafeBrowsing will construct a new SafeBrowsing instance using an API key
+// If no http.Client is provided, we'll use the default one
+func NewSafeBrowsing(client *http.Client, key string) *SafeBrowsing {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &SafeBrowsing{
+		client,
+		key,
+		"https://safebrowsing.googleapis.com/v4/threatMatches:find",
+	}
+}
+
+// Lookup asks Safe Browsing about the links, in batches as large as it allows
+func (safeBrowsing *SafeBrowsing) Lookup(links []string) (map[string]string, error) {
+	threats := make(map[string]string)
+	for start := 0; start < len(links); start += safeBrowsingBatch {
+		end := start + safeBrowsingBatch
+		if end > len(links) {
+			end = len(links)
+		}
+
+		if err := safeBrowsing.lookup(links[start:end], threats); err != nil {
+			return threats, err
+		}
+	}
+	return threats, nil
+}
+
+type threatEntry struct {
+	URL string `json:"url"`
+}
+
+func (safeBrowsing *SafeBrowsing) lookup(links []string, threats map[string]string) error {
+	var request struct {
+		Client struct {
+			ClientID      string `json:"clientId"`
+			ClientVersion string `json:"clientVersion"`
+		} `json:"client"`
+		ThreatInfo struct {
+			ThreatTypes      []string      `json:"threatTypes"`
+			PlatformTypes    []string      `json:"platformTypes"`
+			ThreatEntryTypes []string      `json:"threatEntryTypes"`
+			ThreatEntries    []threatEntry `json:"threatEntries"`
+		} `json:"threatInfo"`
+	}
+	request.Client.ClientID = "grawler"
+	request.Client.ClientVersion = "1.0"
+	request.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	request.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	request.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	for _, link := range links {
+		request.ThreatInfo.ThreatEntries = append(request.ThreatInfo.ThreatEntries, threatEntry{link})
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	response, err := safeBrowsing.client.Post(safeBrowsing.endpoint+"?key="+url.QueryEscape(safeBrowsing.key), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("reputation: safe browsing returned %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var matches struct {
+		Matches []struct {
+			ThreatType string      `json:"threatType"`
+			Threat     threatEntry `json:"threat"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&matches); err != nil {
+		return err
+	}
+
+	for _, match := range matches.Matches {
+		threats[match.Threat.URL] = strings.ToLower(match.ThreatType)
+	}
+	return nil
+}
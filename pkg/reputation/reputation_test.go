@@ -0,0 +1,69 @@
+package reputation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	blocklist := ParseBlocklist([]string{"malware.example", "parked.example. parked"})
+
+	threats, _ := blocklist.Lookup([]string{
+		"http://malware.example/download",
+		"https://www.PARKED.example/",
+		"https://notmalware.example/",
+		"https://example.com/?next=malware.example",
+	})
+
+	expected := map[string]string{
+		"http://malware.example/download": DefaultThreat,
+		"https://www.PARKED.example/":     "parked",
+	}
+	if !reflect.DeepEqual(threats, expected) {
+		t.Errorf("Expected %v, got %v", expected, threats)
+	}
+}
+
+func TestSafeBrowsing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var request struct {
+			ThreatInfo struct {
+				ThreatEntries []threatEntry `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		for _, entry := range request.ThreatInfo.ThreatEntries {
+			if entry.URL == "http://bad.example/" {
+				w.Write([]byte(`{"matches": [{"threatType": "SOCIAL_ENGINEERING", "threat": {"url": "http://bad.example/"}}]}`))
+				return
+			}
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	safeBrowsing := NewSafeBrowsing(server.Client(), "secret")
+	safeBrowsing.endpoint = server.URL
+
+	threats, err := safeBrowsing.Lookup([]string{"http://good.example/", "http://bad.example/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]string{"http://bad.example/": "social_engineering"}; !reflect.DeepEqual(threats, expected) {
+		t.Errorf("Expected %v, got %v", expected, threats)
+	}
+
+	safeBrowsing.key = "wrong"
+	if _, err := safeBrowsing.Lookup([]string{"http://good.example/"}); err == nil {
+		t.Errorf("Expected a bad key to fail")
+	}
+}
@@ -0,0 +1,86 @@
+// Package resolve sends connections for chosen hosts to addresses of our choosing instead of whatever
+// DNS says, like curl's --resolve, so a staging server can be crawled under its production hostname
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Overrides maps "host:port", or just "host" for every port, to the IP connections should go to
+type Overrides map[string]string
+
+// Parse reads overrides separated by commas, each host:ip or host:port:ip, IPv6 addresses in brackets
+func Parse(spec string) (Overrides, error) {
+	overrides := make(Overrides)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		split := strings.LastIndex(entry, ":")
+		if strings.HasSuffix(entry, "]") {
+			split = strings.LastIndex(entry, ":[")
+		}
+		if split <= 0 {
+			return nil, fmt.Errorf("resolve override %q isn't host:ip or host:port:ip", entry)
+		}
+
+		target, address := strings.ToLower(entry[:split]), strings.Trim(entry[split+1:], "[]")
+		if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("resolve override %q doesn't end in an IP address", entry)
+		}
+		if host, port, err := net.SplitHostPort(target); err == nil && host != "" && port != "" {
+			target = net.JoinHostPort(host, port)
+		} else if strings.Contains(target, ":") {
+			return nil, fmt.Errorf("resolve override %q isn't host:ip or host:port:ip", entry)
+		}
+
+		overrides[target] = address
+	}
+	return overrides, nil
+}
+
+// Lookup gives the address to connect to in place of address, a host:port, and whether it's overridden
+func (overrides Overrides) Lookup(address string) (string, bool) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, false
+	}
+	host = strings.ToLower(host)
+
+	ip, ok := overrides[net.JoinHostPort(host, port)]
+	if !ok {
+		if ip, ok = overrides[host]; !ok {
+			return address, false
+		}
+	}
+	return net.JoinHostPort(ip, port), true
+}
+
+// DialFunc connects to an address, like net.Dialer's DialContext
+type DialFunc func(ctx context.Context, network string, address string) (net.Conn, error)
+
+// Apply makes transport connect to the overridden addresses with dial, leaving every other connection
+// to the transport's own dialing
+// The addresses were picked by whoever runs the crawl, so dial needn't be as careful as the transport's
+func (overrides Overrides) Apply(transport *http.Transport, dial DialFunc) {
+	if len(overrides) == 0 {
+		return
+	}
+
+	transportDial := transport.DialContext
+	if transportDial == nil {
+		transportDial = (&net.Dialer{}).DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		if overridden, ok := overrides.Lookup(address); ok {
+			return dial(ctx, network, overridden)
+		}
+		return transportDial(ctx, network, address)
+	}
+}
@@ -0,0 +1,65 @@
+package resolve
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	overrides, err := Parse("Example.com:10.0.0.1, example.com:8443:10.0.0.2,v6.example.com:[::1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"example.com:80":    "10.0.0.1:80",
+		"EXAMPLE.COM:443":   "10.0.0.1:443",
+		"example.com:8443":  "10.0.0.2:8443",
+		"v6.example.com:80": "[::1]:80",
+		"other.com:80":      "other.com:80",
+	}
+	for address, expected := range cases {
+		if overridden, _ := overrides.Lookup(address); overridden != expected {
+			t.Errorf("%s: expected %s, got %s", address, expected, overridden)
+		}
+	}
+
+	for _, spec := range []string{"example.com", "example.com:staging", ":10.0.0.1", "a:b:c:10.0.0.1"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	overrides, err := Parse("www.example.com:" + serverURL.Hostname())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &http.Transport{}
+	overrides.Apply(transport, (&net.Dialer{Timeout: time.Second}).DialContext)
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	response, err := client.Get("http://www.example.com:" + serverURL.Port() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	if expected := "www.example.com:" + serverURL.Port(); string(body) != expected {
+		t.Errorf("expected the request to keep its Host %s, got %s", expected, body)
+	}
+}
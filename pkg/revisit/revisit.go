@@ -0,0 +1,266 @@
+// Package revisit schedules recrawls of pages based on how often they change
+package revisit
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var recordsBucket = []byte("revisit")
+
+// Record is everything we remember about a page between crawls
+type Record struct {
+	URL string `json:"url"`
+
+	// Fingerprint of the body from the most recent crawl
+	Hash uint64 `json:"hash"`
+
+	LastCrawled time.Time `json:"lastCrawled"`
+	LastChanged time.Time `json:"lastChanged"`
+
+	// The Last-Modified header of the most recent crawl, zero when the server didn't send one
+	LastModified time.Time `json:"lastModified,omitempty"`
+
+	// How many times the page has been crawled, and how many of those found it changed
+	Crawls  int `json:"crawls"`
+	Changes int `json:"changes"`
+
+	// How long to wait after LastCrawled before the page is due again
+	Interval time.Duration `json:"interval"`
+
+	// Set while a revisit is already queued, so the page isn't handed out twice
+	NotBefore time.Time `json:"notBefore,omitempty"`
+
+	// The links found on the page by the most recent crawl
+	Links []Link `json:"links,omitempty"`
+}
+
+// Link is a link found on a page, kept so a page skipped as unchanged can still lead on to the others
+type Link struct {
+	URL      string `json:"url"`
+	Relation string `json:"relation,omitempty"`
+}
+
+// Due is when this page should next be crawled
+func (record Record) Due() time.Time {
+	due := record.LastCrawled.Add(record.Interval)
+	if record.NotBefore.After(due) {
+		return record.NotBefore
+	}
+	return due
+}
+
+// Scheduler tracks crawled pages and decides when each should be revisited
+//
+// Every page starts at the minimum interval. Each time a revisit finds the page unchanged
+// its interval doubles (up to the maximum), and each time it has changed the interval halves,
+// so frequently changing pages settle near the minimum and static ones near the maximum.
+type Scheduler struct {
+	db *bolt.DB
+
+	min time.Duration
+	max time.Duration
+}
+
+// New prepares a Scheduler inside an already opened database
+func New(db *bolt.DB, min, max time.Duration) (*Scheduler, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(recordsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{db, min, max}, nil
+}
+
+// Record notes that url was crawled at the given time and adjusts its revisit interval
+// lastModified is what the server said about when the page last changed, zero when it didn't say
+func (scheduler *Scheduler) Record(url string, body []byte, links []Link, lastModified, crawledAt time.Time) (Record, error) {
+	hash := fnv.New64a()
+	hash.Write(body)
+
+	var record Record
+	err := scheduler.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+
+		if value := records.Get([]byte(url)); value != nil {
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+		}
+		record = scheduler.update(record, url, hash.Sum64(), crawledAt)
+		record.LastModified = lastModified
+		record.Links = links
+
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return records.Put([]byte(url), value)
+	})
+
+	return record, err
+}
+
+func (scheduler *Scheduler) update(record Record, url string, hash uint64, crawledAt time.Time) Record {
+	switch {
+	case record.Crawls == 0:
+		record.URL = url
+		record.LastChanged = crawledAt
+		record.Interval = scheduler.min
+	case record.Hash != hash:
+		record.Changes++
+		record.LastChanged = crawledAt
+		record.Interval /= 2
+	default:
+		record.Interval *= 2
+	}
+
+	if record.Interval < scheduler.min {
+		record.Interval = scheduler.min
+	} else if record.Interval > scheduler.max {
+		record.Interval = scheduler.max
+	}
+
+	record.Hash = hash
+	record.Crawls++
+	record.LastCrawled = crawledAt
+
+	return record
+}
+
+// Lookup is what's remembered about a page, false when it's never been recorded
+func (scheduler *Scheduler) Lookup(url string) (Record, bool, error) {
+	var record Record
+	var found bool
+	err := scheduler.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(recordsBucket).Get([]byte(url))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &record)
+	})
+	return record, found, err
+}
+
+// Modified is when the page last changed, going by the server's Last-Modified when it sent one
+// and otherwise by when a crawl last found the page different
+func (record Record) Modified() time.Time {
+	if !record.LastModified.IsZero() {
+		return record.LastModified
+	}
+	return record.LastChanged
+}
+
+// Unchanged is whether the most recent crawl found the page the same as the one before it
+func (record Record) Unchanged() bool {
+	return record.Crawls > 1 && record.LastChanged.Before(record.LastCrawled)
+}
+
+// Stale lists the pages not worth fetching again yet: those the most recent crawl found unchanged,
+// that were last modified before cutoff, and that were crawled since cutoff, so even they get checked
+// on again once their last crawl is older than cutoff too
+func (scheduler *Scheduler) Stale(cutoff time.Time) ([]Record, error) {
+	stale := []Record{}
+
+	err := scheduler.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if record.Unchanged() && record.Modified().Before(cutoff) && record.LastCrawled.After(cutoff) {
+				stale = append(stale, record)
+			}
+			return nil
+		})
+	})
+
+	return stale, err
+}
+
+// Due lists every page whose revisit time has passed
+// Pages that change most often come first, then those that have been waiting longest
+func (scheduler *Scheduler) Due(now time.Time) ([]Record, error) {
+	due := []Record{}
+
+	err := scheduler.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if !record.Due().After(now) {
+				due = append(due, record)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Interval != due[j].Interval {
+			return due[i].Interval < due[j].Interval
+		}
+		return due[i].Due().Before(due[j].Due())
+	})
+
+	return due, err
+}
+
+// Postpone pushes a page's due time back without counting it as a crawl
+func (scheduler *Scheduler) Postpone(url string, until time.Time) error {
+	return scheduler.db.Update(func(tx *bolt.Tx) error {
+		records := tx.Bucket(recordsBucket)
+
+		value := records.Get([]byte(url))
+		if value == nil {
+			return nil
+		}
+
+		var record Record
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		record.NotBefore = until
+
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return records.Put([]byte(url), value)
+	})
+}
+
+// Upcoming lists up to limit pages that aren't due yet, those due soonest first
+func (scheduler *Scheduler) Upcoming(now time.Time, limit int) ([]Record, error) {
+	upcoming := []Record{}
+
+	err := scheduler.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if record.Due().After(now) {
+				upcoming = append(upcoming, record)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(upcoming, func(i, j int) bool {
+		return upcoming[i].Due().Before(upcoming[j].Due())
+	})
+	if len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+
+	return upcoming, err
+}
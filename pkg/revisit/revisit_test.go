@@ -0,0 +1,126 @@
+package revisit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSchedulerIntervals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "revisit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "revisit.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	scheduler, err := New(db, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	scheduler.Record("/static", []byte("same"), nil, time.Time{}, start)
+	scheduler.Record("/news", []byte("monday"), nil, time.Time{}, start)
+
+	// An unchanged page backs off, a changed one stays at the minimum
+	static, _ := scheduler.Record("/static", []byte("same"), nil, time.Time{}, start.Add(time.Minute))
+	news, _ := scheduler.Record("/news", []byte("tuesday"), nil, time.Time{}, start.Add(time.Minute))
+
+	if static.Interval != 2*time.Minute {
+		t.Errorf("Expected /static to back off to 2m, got %v", static.Interval)
+	}
+	if news.Interval != time.Minute || news.Changes != 1 {
+		t.Errorf("Expected /news to stay at 1m with 1 change, got %v with %d", news.Interval, news.Changes)
+	}
+
+	due, err := scheduler.Due(start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].URL != "/news" {
+		t.Errorf("Expected /news to be revisited first, got %v", due)
+	}
+
+	if record, found, err := scheduler.Lookup("/news"); err != nil || !found || record.Crawls != 2 {
+		t.Errorf("Expected /news to have been crawled twice, got %v %v %v", record, found, err)
+	}
+	if _, found, _ := scheduler.Lookup("/never"); found {
+		t.Errorf("Expected /never to not be found")
+	}
+
+	scheduler.Postpone("/news", start.Add(2*time.Hour))
+	if due, _ := scheduler.Due(start.Add(time.Hour)); len(due) != 1 {
+		t.Errorf("Expected the postponed page to not be due, got %v", due)
+	}
+
+	// /static is due 2m after its second crawl, well before the postponed /news
+	upcoming, err := scheduler.Upcoming(start, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 2 || upcoming[0].URL != "/static" {
+		t.Errorf("Expected /static to come up first, got %v", upcoming)
+	}
+	if upcoming, _ := scheduler.Upcoming(start, 1); len(upcoming) != 1 {
+		t.Errorf("Expected upcoming pages to be limited, got %v", upcoming)
+	}
+}
+
+func TestSchedulerStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "revisit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "revisit.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	scheduler, err := New(db, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	lastYear := now.AddDate(-1, 0, 0)
+	yesterday := now.AddDate(0, 0, -1)
+	lastMonth := now.AddDate(0, -1, 0)
+
+	// Old and unchanged across the last two crawls
+	scheduler.Record("/archive", []byte("same"), nil, lastYear, now.Add(-2*time.Hour))
+	scheduler.Record("/archive", []byte("same"), []Link{{URL: "/archive/2019"}}, lastYear, now.Add(-time.Hour))
+	// Old, but only crawled once so there's no telling whether it changes
+	scheduler.Record("/once", []byte("same"), nil, lastYear, now.Add(-time.Hour))
+	// Unchanged, but modified recently
+	scheduler.Record("/fresh", []byte("same"), nil, yesterday, now.Add(-2*time.Hour))
+	scheduler.Record("/fresh", []byte("same"), nil, yesterday, now.Add(-time.Hour))
+	// Old and unchanged, but not crawled in a long time so it's due a check
+	scheduler.Record("/forgotten", []byte("same"), nil, lastYear, lastMonth.Add(-time.Hour))
+	scheduler.Record("/forgotten", []byte("same"), nil, lastYear, lastMonth)
+	// Changed by the last crawl
+	scheduler.Record("/edited", []byte("before"), nil, lastYear, now.Add(-2*time.Hour))
+	scheduler.Record("/edited", []byte("after"), nil, lastYear, now.Add(-time.Hour))
+
+	stale, err := scheduler.Stale(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 1 || stale[0].URL != "/archive" {
+		t.Errorf("Expected only /archive to be stale, got %v", stale)
+	} else if len(stale[0].Links) != 1 || stale[0].Links[0].URL != "/archive/2019" {
+		t.Errorf("Expected the links of /archive to be kept, got %v", stale[0].Links)
+	}
+}
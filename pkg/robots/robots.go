@@ -4,10 +4,11 @@ package robots
 import (
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -29,20 +30,44 @@ type RulesIndex struct {
 	// Internal http Client
 	client *http.Client
 
+	// The user-agent token whose rules we follow, lowercase
+	agent string
+
 	// A mapping of domain to robots.txt rules
 	rules map[string]CrawlRules
+
+	// Held while rules is written, so views can read it from other goroutines
+	mutex *sync.RWMutex
+
+	// Crawl-delays longer than this are cut down to it
+	MaxDelay time.Duration
 }
 
-// NewRulesIndex will construct a new RulesIndex instance
+// DefaultMaxDelay is the longest Crawl-delay a new RulesIndex honors
+const DefaultMaxDelay time.Duration = 30 * time.Second
+
+// DefaultAgent is the user-agent token NewRulesIndex follows the rules for
+const DefaultAgent string = "grawler"
+
+// NewRulesIndex will construct a new RulesIndex instance following the rules for DefaultAgent
 // If no http.Client is provided, we'll use the default one
 func NewRulesIndex(client *http.Client) RulesIndex {
+	return NewAgentRulesIndex(client, DefaultAgent)
+}
+
+// NewAgentRulesIndex will construct a new RulesIndex instance following the rules for another
+// user-agent token, such as "googlebot"
+func NewAgentRulesIndex(client *http.Client, agent string) RulesIndex {
 	if client == nil {
 		client = http.DefaultClient
 	}
 
 	return RulesIndex{
 		client,
+		strings.ToLower(agent),
 		make(map[string]CrawlRules),
+		&sync.RWMutex{},
+		DefaultMaxDelay,
 	}
 }
 
@@ -55,23 +80,144 @@ func NewRulesIndex(client *http.Client) RulesIndex {
 // Be aware that there is no expiration on the cached rules for the lifetime of the index.
 func (index *RulesIndex) Get(hostname string) (CrawlRules, error) {
 	if _, ok := index.rules[hostname]; !ok {
-		crawlRules, err := fetchCrawlRules(index.client, hostname)
+		crawlRules, err := fetchCrawlRules(index.client, hostname, index.agent)
 		if err != nil {
 			return CrawlRules{}, err
 		}
-		index.rules[hostname] = crawlRules
+		index.store(hostname, crawlRules)
 	}
 
 	rules := index.rules[hostname]
+	if rules.Delay > index.MaxDelay {
+		rules.Delay = index.MaxDelay
+	}
 	return rules, nil
 }
 
+// Prefetch fetches the robots.txt of every hostname not cached yet, up to workers at a time, so a crawl
+// starting on many hosts doesn't wait on them one by one
+// The hostnames that couldn't be fetched are returned with their errors and left for Get to try again
+func (index *RulesIndex) Prefetch(hostnames []string, workers int) map[string]error {
+	type fetched struct {
+		hostname string
+		rules    CrawlRules
+		err      error
+	}
+
+	wanted := make(Set)
+	for _, hostname := range hostnames {
+		if _, ok := index.rules[hostname]; !ok {
+			wanted[hostname] = true
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	pending, results := make(chan string), make(chan fetched)
+	for i := 0; i < workers && i < len(wanted); i++ {
+		go func() {
+			for hostname := range pending {
+				rules, err := fetchCrawlRules(index.client, hostname, index.agent)
+				results <- fetched{hostname, rules, err}
+			}
+		}()
+	}
+	go func() {
+		for hostname := range wanted {
+			pending <- hostname
+		}
+		close(pending)
+	}()
+
+	// Only this goroutine touches the cache, as Get isn't safe to call concurrently
+	failed := make(map[string]error)
+	for range wanted {
+		result := <-results
+		if result.err != nil {
+			failed[result.hostname] = result.err
+			continue
+		}
+		index.store(result.hostname, result.rules)
+	}
+	return failed
+}
+
+func (index *RulesIndex) store(hostname string, rules CrawlRules) {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+	index.rules[hostname] = rules
+}
+
+// View gives read-only access to the rules as they're fetched, for use from other goroutines
+func (index *RulesIndex) View() RulesView {
+	return RulesView{index.rules, index.mutex, index.MaxDelay}
+}
+
+// RulesView reads the rules a RulesIndex has fetched so far, without ever fetching any itself
+// Unlike the index, it's safe to use while the index is being filled from another goroutine
+type RulesView struct {
+	rules    map[string]CrawlRules
+	mutex    *sync.RWMutex
+	maxDelay time.Duration
+}
+
+// Get returns the rules for a domain, if the index has fetched them
+func (view RulesView) Get(hostname string) (CrawlRules, bool) {
+	view.mutex.RLock()
+	rules, ok := view.rules[hostname]
+	view.mutex.RUnlock()
+
+	if rules.Delay > view.maxDelay {
+		rules.Delay = view.maxDelay
+	}
+	return rules, ok
+}
+
+// Domains lists every domain whose rules have been fetched so far, sorted
+func (view RulesView) Domains() []string {
+	view.mutex.RLock()
+	defer view.mutex.RUnlock()
+
+	domains := make([]string, 0, len(view.rules))
+	for domain := range view.rules {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// OverMaxDelay lists the domains whose robots.txt asks for a longer Crawl-delay than MaxDelay, sorted
+func (index *RulesIndex) OverMaxDelay() []string {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	var domains []string
+	for domain, rules := range index.rules {
+		if rules.RequestedDelay > index.MaxDelay {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
 // DomainCount simply provides a count of all the domains indexed
 func (index *RulesIndex) DomainCount() int {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
 	return len(index.rules)
 }
 
+// Domains lists every domain whose rules have been fetched, sorted
+func (index *RulesIndex) Domains() []string {
+	return index.View().Domains()
+}
+
 func (index *RulesIndex) String() string {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
 	ret := ""
 	for domain, rules := range index.rules {
 		ret += fmt.Sprintf("Domain: %s\n%s", domain, rules.String())
@@ -89,6 +235,19 @@ type CrawlRules struct {
 
 	// How long a crawler should wait before hitting a domain again
 	Delay time.Duration
+
+	// The Crawl-delay as given, which RulesIndex.Get may have cut Delay down from
+	RequestedDelay time.Duration
+
+	// Sitemaps listed in the robots.txt, which apply to every user-agent
+	Sitemaps []string
+
+	// Paths given by the unofficial Noindex directive, asking for them to be kept out of search results
+	// rather than uncrawled, so Test ignores them
+	NoindexPaths Set
+
+	// The robots.txt the rules were read from, for ForAgent
+	body string
 }
 
 // Test Given a path, test if the rules for this domain grant access
@@ -101,6 +260,45 @@ func (rules *CrawlRules) Test(path string) bool {
 	return !ok
 }
 
+// Noindex finds the Noindex rule covering a path, which may have a query, the longest when several do
+// Unlike Allow and Disallow these are read the way search engines read them: as path prefixes, with *
+// matching anything and a $ at the end anchoring it
+func (rules *CrawlRules) Noindex(path string) (string, bool) {
+	matched := ""
+	for pattern := range rules.NoindexPaths {
+		if matchesPattern(pattern, path) && (len(pattern) > len(matched) || len(pattern) == len(matched) && pattern < matched) {
+			matched = pattern
+		}
+	}
+	return matched, matched != ""
+}
+
+// ForAgent reads the same robots.txt again for another user-agent token, such as "googlebot"
+func (rules *CrawlRules) ForAgent(agent string) CrawlRules {
+	return parseCrawlRules(rules.body, strings.ToLower(agent))
+}
+
+func matchesPattern(pattern string, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		found := strings.Index(rest, part)
+		if found < 0 {
+			return false
+		}
+		rest = rest[found+len(part):]
+	}
+	return !anchored || rest == ""
+}
+
 func (rules *CrawlRules) String() string {
 	allowedPaths := ""
 	for path := range rules.AllowedPaths {
@@ -119,11 +317,12 @@ func newCrawlRules() CrawlRules {
 	return CrawlRules{
 		DisallowedPaths: make(Set),
 		AllowedPaths:    make(Set),
+		NoindexPaths:    make(Set),
 		Delay:           1 * time.Second,
 	}
 }
 
-func fetchCrawlRules(client *http.Client, domain string) (CrawlRules, error) {
+func fetchCrawlRules(client *http.Client, domain string, agent string) (CrawlRules, error) {
 	url := fmt.Sprintf("http://%s/robots.txt", domain)
 	response, err := client.Get(url)
 	if err != nil {
@@ -140,10 +339,17 @@ func fetchCrawlRules(client *http.Client, domain string) (CrawlRules, error) {
 		return newCrawlRules(), err
 	}
 
-	crawlRules := newCrawlRules()
+	return parseCrawlRules(string(body), agent), nil
+}
+
+// parseCrawlRules picks the rules in a robots.txt that apply to agent
+// Like the major search engines, a group naming the agent replaces the * group rather than adding to it
+func parseCrawlRules(body string, agent string) CrawlRules {
+	agentRules, anyRules := newCrawlRules(), newCrawlRules()
+	foundAgent := false
 
-	respectRules := false
-	for _, line := range strings.Split(string(body), "\n") {
+	var crawlRules *CrawlRules
+	for _, line := range strings.Split(body, "\n") {
 		// Ignore Comments
 		if len(line) == 0 || line[0] == '#' {
 			continue
@@ -155,15 +361,27 @@ func fetchCrawlRules(client *http.Client, domain string) (CrawlRules, error) {
 		}
 		directive, value := strings.ToLower(components[0]), components[1]
 
+		if directive == "sitemap" {
+			sitemap := strings.TrimSpace(value)
+			agentRules.Sitemaps = append(agentRules.Sitemaps, sitemap)
+			anyRules.Sitemaps = append(anyRules.Sitemaps, sitemap)
+			continue
+		}
+
 		// We only care about the robots.txt rules if they're talking about us
-		if directive == "user-agent" && (value == "*" || strings.ToLower(value) == "grawler") {
-			respectRules = true
+		if directive == "user-agent" {
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case agent:
+				crawlRules, foundAgent = &agentRules, true
+			case "*":
+				crawlRules = &anyRules
+			default:
+				crawlRules = nil
+			}
 			continue
-		} else if directive == "user-agent" {
-			respectRules = false
 		}
 
-		if !respectRules {
+		if crawlRules == nil {
 			continue
 		}
 
@@ -174,14 +392,23 @@ func fetchCrawlRules(client *http.Client, domain string) (CrawlRules, error) {
 		case "disallow":
 			path := strings.TrimSpace(value)
 			crawlRules.DisallowedPaths[path] = true
+		case "noindex":
+			if path := strings.TrimSpace(value); path != "" {
+				crawlRules.NoindexPaths[path] = true
+			}
 		case "crawl-delay":
 			count, err := strconv.Atoi(value)
 			if err != nil {
 				continue
 			}
-			crawlRules.Delay = time.Duration(int64(math.Min(30.0, float64(count)))) * time.Second
+			crawlRules.Delay = time.Duration(count) * time.Second
+			crawlRules.RequestedDelay = crawlRules.Delay
 		}
 	}
 
-	return crawlRules, nil
+	agentRules.body, anyRules.body = body, body
+	if foundAgent {
+		return agentRules
+	}
+	return anyRules
 }
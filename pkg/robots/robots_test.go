@@ -1,6 +1,16 @@
 package robots
 
-import "testing"
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestCrawlRulesTest(t *testing.T) {
 	rules := newCrawlRules()
@@ -23,3 +33,150 @@ func TestCrawlRulesTest(t *testing.T) {
 		t.Errorf("Should be able to access /this-should-work")
 	}
 }
+
+func TestParseCrawlRulesAgents(t *testing.T) {
+	body := "User-agent: *\nDisallow: /private\n\nUser-agent: Googlebot\nDisallow: /no-google\nCrawl-delay: 5\n\nSitemap: https://example.com/sitemap.xml\n"
+
+	grawler := parseCrawlRules(body, DefaultAgent)
+	if grawler.Test("/private") || !grawler.Test("/no-google") {
+		t.Errorf("Expected grawler to follow the * group, got %s", grawler.String())
+	}
+
+	// A group naming the agent replaces the * group entirely
+	googlebot := parseCrawlRules(body, "googlebot")
+	if !googlebot.Test("/private") || googlebot.Test("/no-google") {
+		t.Errorf("Expected googlebot to follow only its own group, got %s", googlebot.String())
+	}
+	if googlebot.Delay.Seconds() != 5 {
+		t.Errorf("Expected googlebot's crawl delay, got %v", googlebot.Delay)
+	}
+
+	// Sitemaps aren't part of any group
+	for _, rules := range []CrawlRules{grawler, googlebot} {
+		if len(rules.Sitemaps) != 1 || rules.Sitemaps[0] != "https://example.com/sitemap.xml" {
+			t.Errorf("Expected the sitemap to be listed, got %v", rules.Sitemaps)
+		}
+	}
+}
+
+func TestRulesIndexMaxDelay(t *testing.T) {
+	index := NewRulesIndex(nil)
+	index.MaxDelay = 10 * time.Second
+	index.rules["slow.example"] = parseCrawlRules("User-agent: *\nCrawl-delay: 120\n", DefaultAgent)
+	index.rules["fast.example"] = parseCrawlRules("User-agent: *\nCrawl-delay: 2\n", DefaultAgent)
+
+	slow, _ := index.Get("slow.example")
+	if slow.Delay != 10*time.Second || slow.RequestedDelay != 120*time.Second {
+		t.Errorf("Expected the delay to be cut down to 10s from 120s, got %v from %v", slow.Delay, slow.RequestedDelay)
+	}
+
+	fast, _ := index.Get("fast.example")
+	if fast.Delay != 2*time.Second {
+		t.Errorf("Expected a short delay to be left alone, got %v", fast.Delay)
+	}
+
+	if domains := index.OverMaxDelay(); len(domains) != 1 || domains[0] != "slow.example" {
+		t.Errorf("Expected only slow.example over the maximum, got %v", domains)
+	}
+}
+
+func TestRulesView(t *testing.T) {
+	index := NewAgentRulesIndex(nil, "googlebot")
+	index.MaxDelay = 10 * time.Second
+	view := index.View()
+
+	if _, ok := view.Get("example.com"); ok {
+		t.Errorf("Expected no rules before the index has any")
+	}
+
+	index.store("example.com", parseCrawlRules("User-agent: googlebot\nDisallow: /search\nCrawl-delay: 120\n\nUser-agent: *\nDisallow: /\nCrawl-delay: 60\n", "googlebot"))
+	rules, ok := view.Get("example.com")
+	if !ok || rules.Test("/search") || !rules.Test("/") || rules.Delay != 10*time.Second {
+		t.Errorf("Expected googlebot's rules through the view with the delay cut down, got %+v %v", rules, ok)
+	}
+
+	others := rules.ForAgent("Grawler")
+	if others.Test("/") || others.Delay != 60*time.Second {
+		t.Errorf("Expected the * group for another agent, got %+v", others)
+	}
+}
+
+func TestNoindex(t *testing.T) {
+	rules := parseCrawlRules("User-agent: *\nDisallow: /private\nNoindex: /drafts/\nNoindex: /drafts/old/\nNoindex: /*.pdf$\nNoindex: /search*q=\n", DefaultAgent)
+	if !rules.Test("/drafts/") {
+		t.Errorf("Noindex shouldn't keep /drafts/ from being crawled")
+	}
+
+	cases := map[string]string{
+		"/drafts/a.html":     "/drafts/",
+		"/drafts/old/a.html": "/drafts/old/",
+		"/files/report.pdf":  "/*.pdf$",
+		"/files/report.pdfx": "",
+		"/search?q=crawler":  "/search*q=",
+		"/search":            "",
+		"/":                  "",
+	}
+	for path, expected := range cases {
+		if rule, _ := rules.Noindex(path); rule != expected {
+			t.Errorf("%s: expected %q, got %q", path, expected, rule)
+		}
+	}
+}
+
+func TestRulesIndexPrefetch(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, most, requests := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		requests++
+		if inFlight > most {
+			most = inFlight
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	// Every hostname is the server, told apart by the Host header robots.txt is asked for with
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(network string, address string) (net.Conn, error) {
+			if strings.HasPrefix(address, "unreachable.") {
+				return nil, errors.New("unreachable")
+			}
+			return net.Dial(network, strings.TrimPrefix(server.URL, "http://"))
+		},
+	}}
+
+	index := NewRulesIndex(client)
+	index.rules["cached.example"] = newCrawlRules()
+	hostnames := []string{"cached.example", "unreachable.example"}
+	for i := 0; i < 6; i++ {
+		hostnames = append(hostnames, "site"+strconv.Itoa(i)+".example")
+	}
+	failed := index.Prefetch(append(hostnames, "site0.example"), 3)
+
+	if _, ok := failed["unreachable.example"]; !ok || len(failed) != 1 {
+		t.Errorf("Expected only the unreachable host to fail, got %v", failed)
+	}
+	if _, ok := index.rules["unreachable.example"]; ok {
+		t.Errorf("Expected the unreachable host to be left for Get to try again")
+	}
+	if requests != 6 {
+		t.Errorf("Expected every uncached host to be fetched once, got %d requests", requests)
+	}
+	if most < 2 || most > 3 {
+		t.Errorf("Expected up to 3 fetches at once, got %d", most)
+	}
+
+	rules := index.rules["site5.example"]
+	if rules.Test("/private") {
+		t.Errorf("Expected the prefetched rules to be cached, got %s", rules.String())
+	}
+}
@@ -0,0 +1,169 @@
+// Package robotshistory remembers each host's robots.txt rules between crawls, along with the paths
+// crawled and turned away on it, to tell when a change to the rules blocks paths crawled before or
+// unblocks paths that were turned away
+package robotshistory
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/robots"
+	bolt "go.etcd.io/bbolt"
+)
+
+var snapshotsBucket = []byte("robots-history")
+
+// MaxPaths caps how many paths of each kind are remembered per host
+const MaxPaths int = 10000
+
+// Snapshot is a host's rules as of a crawl, and what that crawl and earlier ones did on the host
+type Snapshot struct {
+	Host string `json:"host"`
+
+	// The rules, one "Allow: path" or "Disallow: path" line each, sorted
+	Rules []string `json:"rules"`
+
+	// Paths crawled, and paths robots.txt turned away, sorted
+	Crawled []string `json:"crawled,omitempty"`
+	Denied  []string `json:"denied,omitempty"`
+
+	Recorded time.Time `json:"recorded"`
+}
+
+// Lines writes rules out the way a Snapshot keeps them
+func Lines(rules robots.CrawlRules) []string {
+	lines := make([]string, 0, len(rules.AllowedPaths)+len(rules.DisallowedPaths))
+	for path := range rules.AllowedPaths {
+		lines = append(lines, "Allow: "+path)
+	}
+	for path := range rules.DisallowedPaths {
+		lines = append(lines, "Disallow: "+path)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Change is how the rules changed between two snapshots of a host
+type Change struct {
+	Added   []string
+	Removed []string
+
+	// Paths crawled before that the rules now turn away, and paths turned away before they now allow
+	Blocked   []string
+	Unblocked []string
+}
+
+// Changed is whether the rules changed at all
+func (change Change) Changed() bool {
+	return len(change.Added) > 0 || len(change.Removed) > 0
+}
+
+// Compare tells how a host's rules changed since a snapshot of it
+func Compare(previous Snapshot, current robots.CrawlRules) Change {
+	var change Change
+
+	now := robots.NewSet(Lines(current))
+	before := robots.NewSet(previous.Rules)
+	for line := range now {
+		if !before[line] {
+			change.Added = append(change.Added, line)
+		}
+	}
+	for line := range before {
+		if !now[line] {
+			change.Removed = append(change.Removed, line)
+		}
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+
+	if !change.Changed() {
+		return change
+	}
+	for _, path := range previous.Crawled {
+		if !current.Test(path) {
+			change.Blocked = append(change.Blocked, path)
+		}
+	}
+	for _, path := range previous.Denied {
+		if current.Test(path) {
+			change.Unblocked = append(change.Unblocked, path)
+		}
+	}
+	return change
+}
+
+// Merge carries what earlier crawls did on a host over into a snapshot of this one, a path counting as
+// crawled or denied by whatever happened to it most recently
+func Merge(previous Snapshot, current Snapshot) Snapshot {
+	crawled, denied := robots.NewSet(current.Crawled), robots.NewSet(current.Denied)
+	for _, path := range previous.Crawled {
+		if !denied[path] {
+			crawled[path] = true
+		}
+	}
+	for _, path := range previous.Denied {
+		if !crawled[path] {
+			denied[path] = true
+		}
+	}
+
+	current.Crawled, current.Denied = sorted(crawled), sorted(denied)
+	return current
+}
+
+func sorted(set robots.Set) []string {
+	items := make([]string, 0, len(set))
+	for item := range set {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	if len(items) > MaxPaths {
+		items = items[:MaxPaths]
+	}
+	return items
+}
+
+// Store keeps the latest snapshot of every host
+type Store struct {
+	db *bolt.DB
+}
+
+// New prepares a Store inside an already opened database
+func New(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(snapshotsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db}, nil
+}
+
+// Previous is the snapshot a host was last saved with, if it ever was
+func (store *Store) Previous(host string) (Snapshot, bool, error) {
+	var snapshot Snapshot
+	found := false
+	err := store.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(snapshotsBucket).Get([]byte(host))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &snapshot)
+	})
+	return snapshot, found, err
+}
+
+// Save replaces a host's snapshot
+func (store *Store) Save(snapshot Snapshot) error {
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).Put([]byte(snapshot.Host), value)
+	})
+}
@@ -0,0 +1,76 @@
+package robotshistory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/robots"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCompare(t *testing.T) {
+	before := robots.CrawlRules{DisallowedPaths: robots.NewSet([]string{"/admin"}), AllowedPaths: robots.Set{}}
+	previous := Snapshot{
+		Host:    "example.com",
+		Rules:   Lines(before),
+		Crawled: []string{"/", "/blog"},
+		Denied:  []string{"/admin"},
+	}
+
+	if change := Compare(previous, before); change.Changed() {
+		t.Errorf("Expected unchanged rules, got %+v", change)
+	}
+
+	after := robots.CrawlRules{DisallowedPaths: robots.NewSet([]string{"/blog"}), AllowedPaths: robots.Set{}}
+	change := Compare(previous, after)
+	expected := Change{
+		Added:     []string{"Disallow: /blog"},
+		Removed:   []string{"Disallow: /admin"},
+		Blocked:   []string{"/blog"},
+		Unblocked: []string{"/admin"},
+	}
+	if !reflect.DeepEqual(change, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, change)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "robotshistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "history.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := store.Previous("example.com"); found || err != nil {
+		t.Errorf("Expected no snapshot yet, got %v %v", found, err)
+	}
+
+	previous := Snapshot{Host: "example.com", Crawled: []string{"/", "/blog"}, Denied: []string{"/admin"}}
+	current := Snapshot{Host: "example.com", Rules: []string{"Disallow: /blog"}, Crawled: []string{"/admin"}, Denied: []string{"/blog"}, Recorded: time.Now().UTC()}
+	merged := Merge(previous, current)
+	if !reflect.DeepEqual(merged.Crawled, []string{"/", "/admin"}) || !reflect.DeepEqual(merged.Denied, []string{"/blog"}) {
+		t.Errorf("Expected paths classified by what happened to them last, got %+v", merged)
+	}
+
+	if err := store.Save(merged); err != nil {
+		t.Fatal(err)
+	}
+	saved, found, err := store.Previous("example.com")
+	if err != nil || !found || !reflect.DeepEqual(saved.Rules, merged.Rules) || !saved.Recorded.Equal(merged.Recorded) {
+		t.Errorf("Expected the saved snapshot back, got %+v %v %v", saved, found, err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package score ranks the links found during a crawl so the most promising are crawled first
+//
+// A Func looks at the page a link was found on and the link itself and returns a score.
+// Links are crawled highest score first, and links scoring 0 or less aren't crawled at all,
+// so a focused crawl is a matter of scoring the links you care about above Neutral:
+//
+//	docs := score.PathPrefix("/docs/", 10)
+//
+// Focused crawls can also follow content, scoring links by how relevant the page they were found on is:
+//
+//	topic := score.Focused(score.Keywords([]string{"crawler", "robots.txt"}), 0.5)
+package score
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Neutral is the score of a link nobody has an opinion about
+const Neutral float64 = 1
+
+// Page is the page a link was found on
+type Page struct {
+	URL      url.URL
+	Referrer url.URL
+	Status   int
+	Header   http.Header
+	Body     []byte
+
+	// Every link found on the page, including the one being scored
+	Links []Link
+}
+
+// Link is a link found on a page
+type Link struct {
+	URL url.URL
+
+	// How the page led to the link, empty for a plain link
+	Relation string
+}
+
+// Func scores a link found on a page
+type Func func(from Page, link Link) float64
+
+// Combine multiplies the scores of every func together
+// Any func scoring a link at 0 or below therefore drops it, whatever the others think
+func Combine(funcs ...Func) Func {
+	return func(from Page, link Link) float64 {
+		score := Neutral
+		for _, f := range funcs {
+			s := f(from, link)
+			if s <= 0 {
+				return s
+			}
+			score *= s
+		}
+		return score
+	}
+}
+
+// PathPrefix scores links whose path starts with prefix at weight, and everything else as Neutral
+func PathPrefix(prefix string, weight float64) Func {
+	return func(from Page, link Link) float64 {
+		if strings.HasPrefix(link.URL.Path, prefix) {
+			return weight
+		}
+		return Neutral
+	}
+}
+
+// Relevance rates how on-topic a page is, from 0 (not at all) to 1
+// Anything can back it, from a keyword list to comparing embeddings of the page and a topic
+type Relevance func(Page) float64
+
+// Keywords rates a page by the fraction of keywords that appear in its body, ignoring case
+func Keywords(keywords []string) Relevance {
+	lowered := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			lowered = append(lowered, keyword)
+		}
+	}
+
+	return func(page Page) float64 {
+		if len(lowered) == 0 {
+			return 0
+		}
+
+		body := strings.ToLower(string(page.Body))
+		found := 0
+		for _, keyword := range lowered {
+			if strings.Contains(body, keyword) {
+				found++
+			}
+		}
+		return float64(found) / float64(len(lowered))
+	}
+}
+
+// Scores given to links from pages that aren't relevant at all, so they still come last instead of being dropped
+const irrelevant float64 = 0.01
+
+// Focused scores links by the relevance of the page they were found on, so the crawl follows on-topic pages first
+// Links from pages rated below threshold are dropped, a threshold of 0 only deprioritizes them.
+func Focused(relevance Relevance, threshold float64) Func {
+	// Every link on a page shares its relevance, so it's only rated once
+	cache := &relevanceCache{ratings: make(map[string]float64)}
+
+	return func(from Page, link Link) float64 {
+		rating := cache.rate(from, relevance)
+		if rating < threshold {
+			return 0
+		}
+		if rating < irrelevant {
+			return irrelevant
+		}
+		return rating
+	}
+}
+
+// Pages are forgotten in bulk once this many have been rated
+const relevanceCacheSize = 1024
+
+type relevanceCache struct {
+	mutex   sync.Mutex
+	ratings map[string]float64
+}
+
+func (cache *relevanceCache) rate(page Page, relevance Relevance) float64 {
+	key := page.URL.String()
+
+	cache.mutex.Lock()
+	rating, ok := cache.ratings[key]
+	cache.mutex.Unlock()
+	if ok {
+		return rating
+	}
+
+	rating = relevance(page)
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if len(cache.ratings) >= relevanceCacheSize {
+		cache.ratings = make(map[string]float64)
+	}
+	cache.ratings[key] = rating
+	return rating
+}
@@ -0,0 +1,64 @@
+package score
+
+import (
+	"net/url"
+	"testing"
+)
+
+func link(t *testing.T, raw string) Link {
+	parsedURL, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Link{URL: *parsedURL}
+}
+
+func TestCombine(t *testing.T) {
+	docs := PathPrefix("/docs/", 10)
+	noBlog := PathPrefix("/blog/", 0)
+	scorer := Combine(docs, PathPrefix("/docs/api/", 2), noBlog)
+
+	cases := map[string]float64{
+		"http://example.com/":             Neutral,
+		"http://example.com/docs/intro":   10,
+		"http://example.com/docs/api/get": 20,
+		"http://example.com/blog/post":    0,
+	}
+
+	for raw, expected := range cases {
+		if got := scorer(Page{}, link(t, raw)); got != expected {
+			t.Errorf("Expected %s to score %v, got %v", raw, expected, got)
+		}
+	}
+}
+
+func TestCombineNothing(t *testing.T) {
+	if got := Combine()(Page{}, link(t, "http://example.com/")); got != Neutral {
+		t.Errorf("Expected no funcs to score Neutral, got %v", got)
+	}
+}
+
+func TestFocused(t *testing.T) {
+	scorer := Focused(Keywords([]string{"Crawler", "robots.txt", " "}), 0.5)
+
+	cases := map[string]float64{
+		"<p>A crawler that respects ROBOTS.TXT</p>": 1,
+		"<p>Our crawler</p>":                        0.5,
+		"<p>Cooking recipes</p>":                    0,
+	}
+
+	for body, expected := range cases {
+		// Ratings are cached by URL, so every page needs its own
+		from := Page{URL: link(t, "http://example.com/"+url.PathEscape(body)).URL, Body: []byte(body)}
+		if got := scorer(from, link(t, "http://example.com/next")); got != expected {
+			t.Errorf("Expected links from %q to score %v, got %v", body, expected, got)
+		}
+	}
+
+	// Without a threshold, irrelevant pages are only deprioritized
+	lenient := Focused(Keywords([]string{"crawler"}), 0)
+	from := Page{Body: []byte("<p>Cooking recipes</p>")}
+	if got := lenient(from, link(t, "http://example.com/next")); got <= 0 || got >= Neutral {
+		t.Errorf("Expected links from an irrelevant page to score just above 0, got %v", got)
+	}
+}
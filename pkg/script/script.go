@@ -0,0 +1,213 @@
+// Package script runs user-supplied Starlark hooks against crawled pages
+//
+// A script may define either or both of these functions:
+//
+//	def extract(page):
+//	    # return a dict of values to record for this page
+//	    return {"title": find_all("<title>(.*?)</title>", page.body)}
+//
+//	def score(page, link):
+//	    # return a number, higher scoring links are crawled first and those scoring 0 or less not at all
+//	    return 2.0 if "/docs/" in link else 1.0
+//
+// page has the fields url, referrer, status, headers (a dict with lowercase keys), body and links, and is
+// frozen, as score is handed the same page for every link on it.
+// find_all(pattern, text) returns every match of a regular expression, or of its first group if it has one.
+package script
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// Page is the view of a crawled page handed to scripts
+type Page struct {
+	URL      string
+	Referrer string
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Links    []string
+}
+
+// Hooks are the functions defined by a loaded script
+//
+// Starlark globals are frozen once the script is loaded, so hooks can be called from many goroutines.
+type Hooks struct {
+	name string
+
+	extract *starlark.Function
+	score   *starlark.Function
+}
+
+// Load reads and runs the script at path, collecting the hooks it defines
+func Load(path string) (*Hooks, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, source)
+}
+
+// Parse runs the script source, collecting the hooks it defines
+func Parse(name string, source []byte) (*Hooks, error) {
+	thread := &starlark.Thread{Name: name}
+	globals, err := starlark.ExecFile(thread, name, source, builtins)
+	if err != nil {
+		return nil, err
+	}
+	globals.Freeze()
+
+	hooks := &Hooks{name: name}
+	if hooks.extract, err = lookupFunction(globals, "extract", 1); err != nil {
+		return nil, err
+	}
+	if hooks.score, err = lookupFunction(globals, "score", 2); err != nil {
+		return nil, err
+	}
+	if hooks.extract == nil && hooks.score == nil {
+		return nil, fmt.Errorf("script: %s defines neither extract nor score", name)
+	}
+
+	return hooks, nil
+}
+
+// CanExtract is true when the script defines extract
+func (hooks *Hooks) CanExtract() bool {
+	return hooks.extract != nil
+}
+
+// CanScore is true when the script defines score
+func (hooks *Hooks) CanScore() bool {
+	return hooks.score != nil
+}
+
+// Extract calls the script's extract hook, returning each value as a string
+func (hooks *Hooks) Extract(page Page) (map[string]string, error) {
+	result, err := hooks.call(hooks.extract, Prepare(page).value)
+	if err != nil {
+		return nil, err
+	}
+
+	if result == starlark.None {
+		return nil, nil
+	}
+	dict, ok := result.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("script: extract returned %s, not a dict", result.Type())
+	}
+
+	values := make(map[string]string, dict.Len())
+	for _, item := range dict.Items() {
+		values[asString(item[0])] = asString(item[1])
+	}
+	return values, nil
+}
+
+// Prepared is a page converted for the script once, to be handed to the score hook for each of its links
+// It's frozen, so calls from many goroutines can share it.
+type Prepared struct {
+	value starlark.Value
+}
+
+// Prepare converts a page for the script
+func Prepare(page Page) Prepared {
+	value := toStarlark(page)
+	value.Freeze()
+	return Prepared{value}
+}
+
+// Score calls the script's score hook for a link found on page
+func (hooks *Hooks) Score(page Prepared, link string) (float64, error) {
+	result, err := hooks.call(hooks.score, page.value, starlark.String(link))
+	if err != nil {
+		return 0, err
+	}
+
+	score, ok := starlark.AsFloat(result)
+	if !ok {
+		return 0, fmt.Errorf("script: score returned %s, not a number", result.Type())
+	}
+	return score, nil
+}
+
+func (hooks *Hooks) call(function *starlark.Function, args ...starlark.Value) (starlark.Value, error) {
+	thread := &starlark.Thread{Name: hooks.name}
+	return starlark.Call(thread, function, args, nil)
+}
+
+func lookupFunction(globals starlark.StringDict, name string, params int) (*starlark.Function, error) {
+	value, ok := globals[name]
+	if !ok {
+		return nil, nil
+	}
+
+	function, ok := value.(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("script: %s is a %s, not a function", name, value.Type())
+	}
+	if function.NumParams() != params {
+		return nil, fmt.Errorf("script: %s should take %d parameters, not %d", name, params, function.NumParams())
+	}
+	return function, nil
+}
+
+func toStarlark(page Page) starlark.Value {
+	headers := starlark.NewDict(len(page.Header))
+	for name, values := range page.Header {
+		headers.SetKey(starlark.String(strings.ToLower(name)), starlark.String(strings.Join(values, ", ")))
+	}
+
+	links := make([]starlark.Value, len(page.Links))
+	for i, link := range page.Links {
+		links[i] = starlark.String(link)
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("page"), starlark.StringDict{
+		"url":      starlark.String(page.URL),
+		"referrer": starlark.String(page.Referrer),
+		"status":   starlark.MakeInt(page.Status),
+		"headers":  headers,
+		"body":     starlark.String(page.Body),
+		"links":    starlark.NewList(links),
+	})
+}
+
+func asString(value starlark.Value) string {
+	if str, ok := starlark.AsString(value); ok {
+		return str
+	}
+	return value.String()
+}
+
+var builtins = starlark.StringDict{
+	"find_all": starlark.NewBuiltin("find_all", findAll),
+}
+
+func findAll(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, text string
+	if err := starlark.UnpackArgs(builtin.Name(), args, kwargs, "pattern", &pattern, "text", &text); err != nil {
+		return nil, err
+	}
+
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", builtin.Name(), err)
+	}
+
+	var matches []starlark.Value
+	for _, match := range expression.FindAllStringSubmatch(text, -1) {
+		if len(match) > 1 {
+			matches = append(matches, starlark.String(match[1]))
+		} else {
+			matches = append(matches, starlark.String(match[0]))
+		}
+	}
+	return starlark.NewList(matches), nil
+}
@@ -0,0 +1,55 @@
+package script
+
+import "testing"
+
+const testScript = `
+def extract(page):
+    return {"title": find_all("<title>(.*?)</title>", page.body)[0], "status": page.status}
+
+def score(page, link):
+    if "/private/" in link:
+        return 0
+    return 2.0 if link.startswith(page.url + "docs/") else 1
+`
+
+func TestHooks(t *testing.T) {
+	hooks, err := Parse("test.star", []byte(testScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := Page{
+		URL:    "http://example.com/",
+		Status: 200,
+		Body:   []byte("<html><title>Example</title></html>"),
+	}
+
+	values, err := hooks.Extract(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["title"] != "Example" || values["status"] != "200" {
+		t.Errorf("Unexpected extracted values %v", values)
+	}
+
+	prepared := Prepare(page)
+	for link, expected := range map[string]float64{
+		"http://example.com/docs/intro": 2,
+		"http://example.com/blog":       1,
+		"http://example.com/private/x":  0,
+	} {
+		score, err := hooks.Score(prepared, link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != expected {
+			t.Errorf("Expected %s to score %v, got %v", link, expected, score)
+		}
+	}
+}
+
+func TestParseRejectsEmptyScripts(t *testing.T) {
+	if _, err := Parse("empty.star", []byte("x = 1")); err == nil {
+		t.Errorf("Expected an error for a script without hooks")
+	}
+}
@@ -0,0 +1,115 @@
+// Package secrets scans documents for leaked credentials and personal data
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule is a named pattern to look for
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules covers common credential formats and personal data
+var DefaultRules = []Rule{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"github-token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}\b`)},
+	{"stripe-secret-key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP )?PRIVATE KEY( BLOCK)?-----`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+	{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{"us-ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+}
+
+// ParseRule reads a rule written as name=pattern
+func ParseRule(line string) (Rule, error) {
+	i := strings.Index(line, "=")
+	if i <= 0 {
+		return Rule{}, fmt.Errorf("secrets: expected name=pattern, got %q", line)
+	}
+
+	name := strings.TrimSpace(line[:i])
+	pattern, err := regexp.Compile(strings.TrimSpace(line[i+1:]))
+	if err != nil {
+		return Rule{}, fmt.Errorf("secrets: %s: %v", name, err)
+	}
+	return Rule{Name: name, Pattern: pattern}, nil
+}
+
+// Match is something a rule found
+type Match struct {
+	Rule string
+
+	// The match with all but its first few characters masked, so reports don't spread what they found
+	Redacted string
+
+	// The text around the match, with the match itself redacted
+	Snippet string
+}
+
+// How much text either side of a match goes into its snippet
+const snippetContext int = 30
+
+// Scan runs every rule over body, reporting each distinct match once per rule
+func Scan(body []byte, rules []Rule) []Match {
+	text := string(body)
+	var matches []Match
+
+	for _, rule := range rules {
+		seen := make(map[string]bool)
+		for _, location := range rule.Pattern.FindAllStringIndex(text, -1) {
+			value := text[location[0]:location[1]]
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+
+			redacted := Redact(value)
+			matches = append(matches, Match{
+				Rule:     rule.Name,
+				Redacted: redacted,
+				Snippet:  snippet(text, location[0], location[1], redacted),
+			})
+		}
+	}
+
+	return matches
+}
+
+// Redact keeps the first four characters of a value, enough to recognize it, and masks the rest
+func Redact(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", len(runes)-4)
+}
+
+func snippet(text string, start, end int, redacted string) string {
+	from, to := start-snippetContext, end+snippetContext
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+
+	// Don't cut a multi-byte character in half
+	for from > 0 && !isRuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !isRuneStart(text[to]) {
+		to++
+	}
+
+	return strings.Join(strings.Fields(text[from:start]+redacted+text[end:to]), " ")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
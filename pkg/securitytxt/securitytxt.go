@@ -0,0 +1,65 @@
+// Package securitytxt parses security.txt files (RFC 9116) and checks them for the mistakes that
+// make them useless to whoever wants to report a vulnerability
+package securitytxt
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// File is the fields of a security.txt file, by lowercased field name in the order they appear
+type File map[string][]string
+
+// Parse reads the "Field: value" lines of a security.txt file, skipping comments, blank lines and
+// anything else, such as the lines of a PGP signature
+func Parse(contents string) File {
+	file := make(File)
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+			continue
+		}
+		name := strings.ToLower(line[:i])
+		file[name] = append(file[name], strings.TrimSpace(line[i+1:]))
+	}
+	return file
+}
+
+// Get is the first value of a field, empty when it's not there
+func (file File) Get(name string) string {
+	if values := file[strings.ToLower(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Problems lists what's wrong with the file as of now, nothing when it's fine
+func (file File) Problems(now time.Time) []string {
+	var problems []string
+	if len(file["contact"]) == 0 {
+		problems = append(problems, "no Contact")
+	}
+
+	switch expires := file["expires"]; {
+	case len(expires) == 0:
+		problems = append(problems, "no Expires")
+	case len(expires) > 1:
+		problems = append(problems, "more than one Expires")
+	default:
+		expiry, err := time.Parse(time.RFC3339, expires[0])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unreadable Expires %q", expires[0]))
+		} else if expiry.Before(now) {
+			problems = append(problems, "expired on "+expiry.Format("2006-01-02"))
+		}
+	}
+	return problems
+}
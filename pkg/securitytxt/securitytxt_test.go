@@ -0,0 +1,50 @@
+package securitytxt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	file := Parse(`# Our security policy
+Contact: mailto:security@example.com
+contact: https://example.com/report
+Expires: 2030-01-01T00:00:00Z
+
+-----BEGIN PGP SIGNATURE-----
+Not a field: really
+`)
+
+	if contacts := file["contact"]; !reflect.DeepEqual(contacts, []string{"mailto:security@example.com", "https://example.com/report"}) {
+		t.Errorf("Expected both contacts, got %v", contacts)
+	}
+	if expires := file.Get("Expires"); expires != "2030-01-01T00:00:00Z" {
+		t.Errorf("Expected the expiry, got %q", expires)
+	}
+	if len(file) != 2 {
+		t.Errorf("Expected only Contact and Expires, got %v", file)
+	}
+}
+
+func TestProblems(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		contents string
+		problems []string
+	}{
+		{"Contact: mailto:a@example.com\nExpires: 2026-01-01T00:00:00Z", nil},
+		{"Expires: 2026-01-01T00:00:00Z", []string{"no Contact"}},
+		{"Contact: mailto:a@example.com", []string{"no Expires"}},
+		{"Contact: mailto:a@example.com\nExpires: 2024-12-31T23:00:00Z", []string{"expired on 2024-12-31"}},
+		{"Contact: mailto:a@example.com\nExpires: next year", []string{`unreadable Expires "next year"`}},
+		{"", []string{"no Contact", "no Expires"}},
+	}
+
+	for _, test := range tests {
+		if problems := Parse(test.contents).Problems(now); !reflect.DeepEqual(problems, test.problems) {
+			t.Errorf("%q: expected %v, got %v", test.contents, test.problems, problems)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func init() {
+	Register("bolt", func(options Options) (Sink, error) {
+		return NewBolt(options.path(".db"), options.Crawl, options.Append)
+	})
+}
+
+// Each crawl is a bucket of its own under crawls, holding its description and a bucket of its pages
+// Databases written before crawls were named have one unnamed crawl, in a pages bucket at the top
+var (
+	crawlsBucket = []byte("crawls")
+	pagesBucket  = []byte("pages")
+	crawlKey     = []byte("crawl")
+	countsKey    = []byte("counts")
+)
+
+// crawlCounts is how many pages a crawl has stored and how many of those failed, kept up to date as
+// pages are written so listing crawls doesn't have to read every page
+type crawlCounts struct {
+	Pages  int `json:"pages"`
+	Failed int `json:"failed"`
+}
+
+// failed is whether a stored page failed or had an error status
+func failed(record Record) bool {
+	return record.Error != "" || record.Status >= 400
+}
+
+// Crawl names one of the crawls stored together in a database, with tags to find it by
+type Crawl struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Started time.Time         `json:"started"`
+
+	// How many pages were stored, and how many of those failed or had an error status, only filled in
+	// when reading crawls back
+	Pages  int `json:"-"`
+	Failed int `json:"-"`
+}
+
+// Label is the crawl's name, or when it has none the time it started, which is what it's stored under
+func (crawl Crawl) Label() string {
+	if crawl.Name != "" {
+		return crawl.Name
+	}
+	return crawl.Started.UTC().Format("20060102T150405Z")
+}
+
+// Matches is whether the crawl is picked out by a selector, either its name or tags written like
+// site=example.com+date=2021-03-01, all of which must match
+// An empty selector matches every crawl
+func (crawl Crawl) Matches(selector string) bool {
+	if selector == "" || selector == crawl.Name {
+		return true
+	}
+	if !strings.Contains(selector, "=") {
+		return false
+	}
+	for _, term := range strings.Split(selector, "+") {
+		pair := strings.SplitN(term, "=", 2)
+		if len(pair) != 2 || crawl.Tags[strings.TrimSpace(pair[0])] != strings.TrimSpace(pair[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bolt stores every crawled page in a BoltDB file, keyed by URL, so a crawl can be browsed afterwards
+// A page crawled more than once keeps only its latest record
+// Any number of named crawls can share a file, a crawl given the name of one already there replaces it
+// unless it's being appended to
+type Bolt struct {
+	path string
+	name []byte
+	db   *bolt.DB
+}
+
+// NewBolt opens the database at path, creating it if need be, to store the crawl in
+// When appending, the records go in the crawl of the same name already there, or without a name the latest
+// crawl, keeping its pages
+func NewBolt(path string, crawl Crawl, appending bool) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if appending && crawl.Name == "" {
+			crawls, err := listCrawls(tx)
+			if err != nil {
+				return err
+			}
+			// A database written before crawls were named can't be added to, so that gets a new crawl
+			if len(crawls) > 0 {
+				crawl.Name = crawls[len(crawls)-1].Name
+			}
+		}
+		crawl.Name = crawl.Label()
+
+		crawls, err := tx.CreateBucketIfNotExists(crawlsBucket)
+		if err != nil {
+			return err
+		}
+		if existing := crawls.Bucket([]byte(crawl.Name)); existing != nil && appending {
+			return keepCounts(existing)
+		}
+		if crawls.Bucket([]byte(crawl.Name)) != nil {
+			if err := crawls.DeleteBucket([]byte(crawl.Name)); err != nil {
+				return err
+			}
+		}
+
+		stored, err := crawls.CreateBucket([]byte(crawl.Name))
+		if err != nil {
+			return err
+		}
+		if _, err := stored.CreateBucket(pagesBucket); err != nil {
+			return err
+		}
+		if err := stored.Put(countsKey, []byte("{}")); err != nil {
+			return err
+		}
+		description, err := json.Marshal(crawl)
+		if err != nil {
+			return err
+		}
+		return stored.Put(crawlKey, description)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Bolt{path: path, name: []byte(crawl.Name), db: db}, nil
+}
+
+// Files is the database file
+func (b *Bolt) Files() []string {
+	return []string{b.path}
+}
+
+// Write stores the record, batching concurrent writes into one transaction
+func (b *Bolt) Write(record Record) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return b.db.Batch(func(tx *bolt.Tx) error {
+		stored := tx.Bucket(crawlsBucket).Bucket(b.name)
+		var counts crawlCounts
+		if err := json.Unmarshal(stored.Get(countsKey), &counts); err != nil {
+			return err
+		}
+
+		pages := stored.Bucket(pagesBucket)
+		counts.Pages++
+		if previous := pages.Get([]byte(record.URL)); previous != nil {
+			// A page crawled again replaces its earlier record, rather than being counted twice
+			var replaced Record
+			if err := json.Unmarshal(previous, &replaced); err != nil {
+				return err
+			}
+			counts.Pages--
+			if failed(replaced) {
+				counts.Failed--
+			}
+		}
+		if failed(record) {
+			counts.Failed++
+		}
+
+		updated, err := json.Marshal(counts)
+		if err != nil {
+			return err
+		}
+		if err := stored.Put(countsKey, updated); err != nil {
+			return err
+		}
+		return pages.Put([]byte(record.URL), value)
+	})
+}
+
+// Flush does nothing, every write is committed before it returns
+func (b *Bolt) Flush() error {
+	return nil
+}
+
+// Close closes the database
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// ListCrawls describes every crawl stored at path, oldest first
+func ListCrawls(path string) ([]Crawl, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var crawls []Crawl
+	err = db.View(func(tx *bolt.Tx) error {
+		crawls, err = listCrawls(tx)
+		return err
+	})
+	return crawls, err
+}
+
+func listCrawls(tx *bolt.Tx) ([]Crawl, error) {
+	var crawls []Crawl
+	if pages := tx.Bucket(pagesBucket); pages != nil {
+		crawl := Crawl{}
+		if err := crawl.count(pages); err != nil {
+			return nil, err
+		}
+		crawls = append(crawls, crawl)
+	}
+
+	if stored := tx.Bucket(crawlsBucket); stored != nil {
+		err := stored.ForEach(func(name, _ []byte) error {
+			bucket := stored.Bucket(name)
+			if bucket == nil {
+				return nil
+			}
+
+			var crawl Crawl
+			if err := json.Unmarshal(bucket.Get(crawlKey), &crawl); err != nil {
+				return fmt.Errorf("sink: reading crawl %s: %v", name, err)
+			}
+			crawl.Name = string(name)
+			if counts := bucket.Get(countsKey); counts != nil {
+				var stored crawlCounts
+				if err := json.Unmarshal(counts, &stored); err != nil {
+					return fmt.Errorf("sink: reading crawl %s: %v", name, err)
+				}
+				crawl.Pages, crawl.Failed = stored.Pages, stored.Failed
+			} else if err := crawl.count(bucket.Bucket(pagesBucket)); err != nil {
+				return err
+			}
+			crawls = append(crawls, crawl)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(crawls, func(i, j int) bool {
+		return crawls[i].Started.Before(crawls[j].Started)
+	})
+	return crawls, nil
+}
+
+// keepCounts starts the running counts of a crawl stored before they were kept, so it can be added to
+func keepCounts(stored *bolt.Bucket) error {
+	if stored.Get(countsKey) != nil {
+		return nil
+	}
+
+	var crawl Crawl
+	if err := crawl.count(stored.Bucket(pagesBucket)); err != nil {
+		return err
+	}
+	counts, err := json.Marshal(crawlCounts{Pages: crawl.Pages, Failed: crawl.Failed})
+	if err != nil {
+		return err
+	}
+	return stored.Put(countsKey, counts)
+}
+
+// count fills in how many pages a crawl stored, and how many failed, by reading every one of them
+// Only needed for crawls stored before their counts were kept
+func (crawl *Crawl) count(pages *bolt.Bucket) error {
+	return pages.ForEach(func(key, value []byte) error {
+		var record Record
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		crawl.Pages++
+		if failed(record) {
+			crawl.Failed++
+		}
+		return nil
+	})
+}
+
+// Crawls describes every crawl in the database, see ListCrawls
+func (b *Bolt) Crawls() ([]Crawl, error) {
+	var crawls []Crawl
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		crawls, err = listCrawls(tx)
+		return err
+	})
+	return crawls, err
+}
+
+// ReadCrawl reads back every record of the latest crawl stored at path the selector matches, sorted
+// by URL, see Crawl.Matches
+func ReadCrawl(path string, selector string) ([]Record, Crawl, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, Crawl{}, err
+	}
+	defer db.Close()
+
+	var records []Record
+	var picked Crawl
+	err = db.View(func(tx *bolt.Tx) error {
+		crawls, err := listCrawls(tx)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, crawl := range crawls {
+			if crawl.Matches(selector) {
+				picked, found = crawl, true
+			}
+		}
+		if !found && selector != "" {
+			return fmt.Errorf("sink: no crawl in %s matches %q", path, selector)
+		}
+		if !found {
+			return nil
+		}
+
+		pages := tx.Bucket(pagesBucket)
+		if picked.Name != "" {
+			pages = tx.Bucket(crawlsBucket).Bucket([]byte(picked.Name)).Bucket(pagesBucket)
+		}
+		return pages.ForEach(func(key, value []byte) error {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, picked, err
+}
+
+// ReadBolt reads back every record of the latest crawl stored at path, sorted by URL
+func ReadBolt(path string) ([]Record, error) {
+	records, _, err := ReadCrawl(path, "")
+	return records, err
+}
+
+// Prune drops old crawls, see Pruner
+// The space they took up is reused by later crawls rather than given back, so the file stops growing
+func (b *Bolt) Prune(keep int, maxAge time.Duration, now time.Time) ([]Crawl, error) {
+	var pruned []Crawl
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		crawls, err := listCrawls(tx)
+		if err != nil {
+			return err
+		}
+
+		kept := 0
+		for i := len(crawls) - 1; i >= 0; i-- {
+			crawl := crawls[i]
+			if crawl.Name == string(b.name) {
+				continue
+			}
+			kept++
+			if (keep <= 0 || kept < keep) && (maxAge <= 0 || !crawl.Started.Before(now.Add(-maxAge))) {
+				continue
+			}
+
+			if crawl.Name == "" {
+				err = tx.DeleteBucket(pagesBucket)
+			} else {
+				err = tx.Bucket(crawlsBucket).DeleteBucket([]byte(crawl.Name))
+			}
+			if err != nil {
+				return err
+			}
+			pruned = append(pruned, crawl)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
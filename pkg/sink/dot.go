@@ -0,0 +1,298 @@
+package sink
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/graph"
+)
+
+func init() {
+	Register("dot", func(options Options) (Sink, error) {
+		dot, err := NewDot(options.documentPath(".gv"), options.Seeds)
+		if err != nil {
+			return nil, err
+		}
+		dot.Collapse(options.Collapse...)
+		return dot, nil
+	})
+}
+
+const graphName string = "Grawled Websites"
+
+const legendGraphName string = "cluster_legend"
+
+// Node colors by status, explained in the graph's legend
+var statusColors = []struct {
+	color   string
+	meaning string
+}{
+	{"black", "2xx"},
+	{"blue", "3xx"},
+	{"orange", "4xx"},
+	{"red", "5xx"},
+	{"gray", "no response"},
+}
+
+// Dot renders crawled pages as a Graphviz digraph, clustering pages by host, along with a legend
+// saying what the colors mean and what was crawled, so a rendered image explains itself
+type Dot struct {
+	path    string
+	seeds   []string
+	started time.Time
+
+	graph *graph.Graph
+
+	// The graph guards itself, this guards the totals and collapsed nodes
+	mutex  sync.Mutex
+	pages  int
+	failed int
+	hosts  map[string]bool
+
+	// Path prefixes whose pages are drawn as one node per host, with how many pages each node stands for
+	// and the edges already drawn, so a collapsed node isn't joined to the same page over and over
+	collapse  []string
+	collapsed map[string]int
+	edges     map[string]bool
+}
+
+// NewDot creates an empty graph, crawled from seeds, which is written to path on every flush
+func NewDot(path string, seeds []string) (*Dot, error) {
+	crawled := graph.New(graphName)
+	crawled.AddNode("", "start", graph.Attributes{"label": "Start"})
+
+	dot := &Dot{path: path, seeds: seeds, started: time.Now(), graph: crawled, hosts: make(map[string]bool)}
+	dot.addLegend()
+	return dot, nil
+}
+
+// addLegend draws a node of every color and a dashed edge, labelled with what they mean
+func (dot *Dot) addLegend() {
+	dot.graph.AddCluster(legendGraphName, graph.Attributes{"label": "Legend", "style": "solid"})
+
+	previous := ""
+	for _, status := range statusColors {
+		name := "legend_" + status.color
+		dot.graph.AddNode(legendGraphName, name, graph.Attributes{"label": status.meaning, "color": status.color, "fontcolor": status.color})
+		if previous != "" {
+			dot.graph.AddEdge(previous, name, graph.Attributes{"style": "invis"})
+		}
+		previous = name
+	}
+
+	dot.graph.AddNode(legendGraphName, "legend_from", graph.Attributes{"label": "page"})
+	dot.graph.AddNode(legendGraphName, "legend_to", graph.Attributes{"label": "page"})
+	dot.graph.AddEdge("legend_from", "legend_to", edgeAttributes("not a plain link, e.g. refresh"))
+	dot.describe()
+}
+
+// describe updates the legend's summary of the crawl, and the same as graph attributes for tools reading the file
+func (dot *Dot) describe() {
+	seeds := strings.Join(dot.seeds, " ")
+	crawled := dot.started.UTC().Format(time.RFC3339)
+	totals := fmt.Sprintf("%d pages on %d hosts, %d failed", dot.pages, len(dot.hosts), dot.failed)
+
+	summary := fmt.Sprintf("Crawled %s\nfrom %s\n%s", crawled, seeds, totals)
+	dot.graph.AddNode(legendGraphName, "legend_summary", graph.Attributes{"label": summary, "shape": "note"})
+	dot.graph.SetAttribute("comment", fmt.Sprintf("crawled=%s seeds=%s pages=%d hosts=%d failed=%d", crawled, seeds, dot.pages, len(dot.hosts), dot.failed))
+}
+
+// Collapse draws every page under any of the path prefixes, written like /blog/*, as one node per host
+// The page at the prefix itself, like /blog/, keeps a node of its own
+func (dot *Dot) Collapse(prefixes ...string) {
+	dot.mutex.Lock()
+	defer dot.mutex.Unlock()
+
+	for _, prefix := range prefixes {
+		if prefix = strings.TrimSuffix(prefix, "*"); prefix != "" {
+			dot.collapse = append(dot.collapse, prefix)
+		}
+	}
+	if len(dot.collapse) > 0 && dot.collapsed == nil {
+		dot.collapsed = make(map[string]int)
+		dot.edges = make(map[string]bool)
+	}
+}
+
+// collapsedPrefix is the prefix a path is collapsed into, empty when it isn't
+func (dot *Dot) collapsedPrefix(path string) string {
+	for _, prefix := range dot.collapse {
+		if strings.HasPrefix(path, prefix) && path != prefix {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// nodeName is the node a page is drawn as, which is its collapsed node if it has one
+func (dot *Dot) nodeName(pageURL string, website url.URL) string {
+	if prefix := dot.collapsedPrefix(website.Path); prefix != "" {
+		return hash(website.Hostname() + prefix + "*")
+	}
+	return ID(pageURL)
+}
+
+// Files is the graph file
+func (dot *Dot) Files() []string {
+	return []string{dot.path}
+}
+
+// Write adds the page to its host's cluster, with an edge from its referrer
+func (dot *Dot) Write(record Record) error {
+	website, err := url.Parse(record.URL)
+	if err != nil {
+		return err
+	}
+
+	websiteGraphName := fmt.Sprintf("cluster_%s", hash(website.Hostname()))
+	websiteNodeName := ID(record.URL)
+
+	dot.mutex.Lock()
+	defer dot.mutex.Unlock()
+
+	if !dot.graph.HasCluster(websiteGraphName) {
+		dot.graph.AddCluster(websiteGraphName, clusterAttributes(website.Hostname()))
+	}
+
+	dot.pages++
+	dot.hosts[website.Host] = true
+	if record.Error != "" {
+		dot.failed++
+	}
+
+	// Add the crawled site, or count it towards the node it's collapsed into
+	if prefix := dot.collapsedPrefix(website.Path); prefix != "" {
+		websiteNodeName = dot.nodeName(record.URL, *website)
+		dot.collapsed[websiteNodeName]++
+		pages := fmt.Sprintf("%d pages", dot.collapsed[websiteNodeName])
+		if dot.collapsed[websiteNodeName] == 1 {
+			pages = "1 page"
+		}
+		label := fmt.Sprintf("%s* (%s)", prefix, pages)
+		dot.graph.AddNode(websiteGraphName, websiteNodeName, graph.Attributes{"label": label, "shape": "folder"})
+	} else {
+		dot.graph.AddNode(websiteGraphName, websiteNodeName, nodeAttributes(pageLabel(*website), record.Status))
+	}
+
+	// If there is no referrer, this must be the entrypoint into the system
+	if record.Referrer == "" {
+		dot.addEdge("start", websiteNodeName, graph.Attributes{})
+		return nil
+	}
+
+	referrer, err := url.Parse(record.Referrer)
+	if err != nil {
+		return err
+	}
+	attributes := edgeAttributes(record.Relation)
+	if record.LinkCount > 0 {
+		// Pages linked to more often pull closer together
+		attributes["weight"] = strconv.Itoa(record.LinkCount)
+		attributes["tooltip"] = fmt.Sprintf("%d links from %s", record.LinkCount, strings.Join(record.LinkSections, ", "))
+	}
+	dot.addEdge(dot.nodeName(record.Referrer, *referrer), websiteNodeName, attributes)
+
+	return nil
+}
+
+// addEdge joins two nodes, only once and never to themselves when pages are collapsed
+func (dot *Dot) addEdge(from string, to string, attributes graph.Attributes) {
+	if dot.edges != nil {
+		key := from + "->" + to
+		if from == to || dot.edges[key] {
+			return
+		}
+		dot.edges[key] = true
+	}
+	dot.graph.AddEdge(from, to, attributes)
+}
+
+// Flush writes the whole graph out
+func (dot *Dot) Flush() error {
+	dot.mutex.Lock()
+	dot.describe()
+	dot.mutex.Unlock()
+
+	return ioutil.WriteFile(dot.path, []byte(dot.graph.String()), 0777)
+}
+
+// Close writes the graph out one last time
+func (dot *Dot) Close() error {
+	return dot.Flush()
+}
+
+// ID is the stable identifier of a page, the same in every output format and every crawl
+// It's "n" followed by the first 16 hex digits of the SHA-256 of the page's full URL as normalized by
+// the crawl, query string included, so pages differing only by query are told apart and graphs of
+// different crawls can be diffed node by node
+func ID(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return "n" + hex.EncodeToString(sum[:8])
+}
+
+// NodeID is the graph node name for a page, its ID
+func NodeID(link url.URL) string {
+	return ID(link.String())
+}
+
+func clusterAttributes(hostname string) graph.Attributes {
+	return graph.Attributes{
+		"label":   hostname,
+		"nodesep": "6",
+		"ranksep": "4",
+		"style":   "dotted",
+	}
+}
+
+// Anything other than a plain link is drawn dashed and labelled with its relation
+func edgeAttributes(relation string) graph.Attributes {
+	if relation == "" {
+		return graph.Attributes{}
+	}
+	return graph.Attributes{
+		"label": relation,
+		"style": "dashed",
+	}
+}
+
+// pageLabel is the path of the page along with whatever query was kept in its URL, as pages told apart
+// by their query would otherwise look the same
+func pageLabel(website url.URL) string {
+	if website.RawQuery == "" {
+		return website.EscapedPath()
+	}
+	return website.EscapedPath() + "?" + website.RawQuery
+}
+
+func nodeAttributes(path string, status int) graph.Attributes {
+	attributes := graph.Attributes{
+		"label": path,
+	}
+	if color := statusColor(status); color != "black" {
+		attributes["color"] = color
+		attributes["fontcolor"] = color
+	}
+	return attributes
+}
+
+func statusColor(status int) string {
+	if status < 200 || status > 599 {
+		return statusColors[len(statusColors)-1].color
+	}
+	return statusColors[status/100-2].color
+}
+
+func hash(token string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(token))
+
+	return fmt.Sprintf("h%0x", hash.Sum32())
+}
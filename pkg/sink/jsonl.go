@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+func init() {
+	Register("jsonl", func(options Options) (Sink, error) {
+		return NewJSONL(options.path(".jsonl"), options.Append)
+	})
+}
+
+// JSONL writes one JSON object per crawled page, per line
+type JSONL struct {
+	path string
+
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewJSONL creates (or truncates) the file at path, or appends to it
+func NewJSONL(path string, appending bool) (*JSONL, error) {
+	file, err := createFile(path, appending)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{path: path, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Files is the file the lines are written to
+func (jsonl *JSONL) Files() []string {
+	return []string{jsonl.path}
+}
+
+// Write appends the record as a line of JSON
+func (jsonl *JSONL) Write(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	jsonl.mutex.Lock()
+	defer jsonl.mutex.Unlock()
+
+	if _, err := jsonl.writer.Write(line); err != nil {
+		return err
+	}
+	return jsonl.writer.WriteByte('\n')
+}
+
+// Flush pushes buffered lines out to the file
+func (jsonl *JSONL) Flush() error {
+	jsonl.mutex.Lock()
+	defer jsonl.mutex.Unlock()
+	return jsonl.writer.Flush()
+}
+
+// Close flushes and closes the file
+func (jsonl *JSONL) Close() error {
+	if err := jsonl.Flush(); err != nil {
+		jsonl.file.Close()
+		return err
+	}
+	return jsonl.file.Close()
+}
+
+// ReadJSONL reads back the records a JSONL sink wrote
+func ReadJSONL(reader io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(reader)
+	for {
+		var record Record
+		if err := decoder.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,239 @@
+// Package sink defines where crawl results are written, along with a registry of the kinds of sink available
+//
+// Sinks register themselves by name, usually from an init function, so third-party
+// packages can add their own just by being imported:
+//
+//	func init() {
+//		sink.Register("kafka", func(options sink.Options) (sink.Sink, error) {
+//			return newKafkaSink(options.Path)
+//		})
+//	}
+package sink
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a single crawled page as handed to every sink
+type Record struct {
+	// The page's stable identifier, see ID
+	ID string `json:"id,omitempty"`
+
+	URL      string `json:"url"`
+	Referrer string `json:"referrer,omitempty"`
+	Status   int    `json:"status,omitempty"`
+
+	// Why the page couldn't be crawled, only ever set when a sink is given failed attempts too
+	Error string `json:"error,omitempty"`
+
+	// How the referrer led to this page, empty for a plain link and otherwise something like "refresh"
+	Relation string `json:"relation,omitempty"`
+
+	// How many times the referrer links to this page, and from which of its sections, like nav or footer
+	LinkCount    int      `json:"linkCount,omitempty"`
+	LinkSections []string `json:"linkSections,omitempty"`
+
+	// Every page this one links to, when the crawl was asked to record them
+	Links []string `json:"links,omitempty"`
+
+	// Response headers captured for the page, by canonical name
+	// Only the headers the crawl was asked to capture are present, with repeated headers joined by ", "
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// How the body was compressed on the wire, if at all, and its size there and once decoded
+	Encoding  string `json:"encoding,omitempty"`
+	WireBytes int64  `json:"wireBytes,omitempty"`
+	Bytes     int64  `json:"bytes,omitempty"`
+
+	// Set when the page was found unchanged since an earlier crawl, by a 304 Not Modified or without
+	// fetching it at all, so it has no content and its links are those it had then
+	Unchanged bool `json:"unchanged,omitempty"`
+
+	// SHA-256 of the body as served, and optionally of just its element structure, for diffing and finding duplicates
+	ContentHash   string `json:"contentHash,omitempty"`
+	StructureHash string `json:"structureHash,omitempty"`
+
+	// The page's text, one block per line, when the crawl was asked to record it
+	Text string `json:"text,omitempty"`
+
+	CrawledAt time.Time `json:"crawledAt"`
+}
+
+// Sink receives every crawled page
+//
+// Write may be called from many goroutines at once. Flush is called periodically during the crawl
+// and Close once at the end, and both should leave everything written so far in durable storage.
+type Sink interface {
+	Write(record Record) error
+	Flush() error
+	Close() error
+}
+
+// Options configure a sink as it's opened
+type Options struct {
+	// Where the sink writes to, a file path for file based sinks
+	Path string
+
+	// When Path is empty, file based sinks write to Base plus their own extension
+	// This lets several sinks share one -output name without clobbering each other
+	Base string
+
+	// The text/template the template sink writes each record with
+	Template string
+
+	// Where the crawl started, for sinks that describe the crawl as well as its pages
+	Seeds []string
+	// Path prefixes like /blog/* whose pages graph sinks draw as one node per host
+	Collapse []string
+
+	// Which crawl this is, for sinks keeping several crawls in one place
+	Crawl Crawl
+
+	// Content hashes of the pages of the previous crawl of the same site by URL, for sinks telling what
+	// changed since, nil when there wasn't one
+	Previous map[string]string
+
+	// Keep what an earlier run wrote, as when re-attempting its failures
+	// Sinks writing a line per page append to their files, those writing a whole document put it alongside
+	Append bool
+}
+
+func (options Options) path(extension string) string {
+	if options.Path != "" {
+		return options.Path
+	}
+
+	base := options.Base
+	if base == "" {
+		base = "grawled"
+	}
+	return base + extension
+}
+
+// documentPath is path, with .retry added before the extension when appending so the earlier document survives
+func (options Options) documentPath(extension string) string {
+	path := options.path(extension)
+	if !options.Append {
+		return path
+	}
+
+	extension = filepath.Ext(path)
+	return strings.TrimSuffix(path, extension) + ".retry" + extension
+}
+
+// createFile creates (or truncates) the file at path, or opens it for appending
+func createFile(path string, appending bool) (*os.File, error) {
+	if !appending {
+		return os.Create(path)
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+}
+
+// Factory opens a new sink
+type Factory func(options Options) (Sink, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes a kind of sink available by name
+// Registering the same name twice panics, as that's almost certainly two packages fighting over it
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("sink: %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Open creates a sink of the registered kind
+func Open(name string, options Options) (Sink, error) {
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("sink: unknown sink %q, expected one of %v", name, Names())
+	}
+	return factory(options)
+}
+
+// Names lists every registered kind of sink
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Filer is implemented by sinks that write to files, to tell where their output went
+type Filer interface {
+	Files() []string
+}
+
+// Pruner is implemented by sinks keeping several crawls, to drop the old ones so continuous crawling
+// doesn't fill the disk
+// The crawl being written is always kept, of the others only the latest keep are, and only those started
+// within maxAge of now, either limit being off when zero. The crawls dropped are returned
+type Pruner interface {
+	Prune(keep int, maxAge time.Duration, now time.Time) ([]Crawl, error)
+}
+
+// Lister is implemented by sinks keeping several crawls, to describe them while still writing one
+type Lister interface {
+	Crawls() ([]Crawl, error)
+}
+
+// Multi fans every call out to several sinks at once
+// Every sink is always called, the first error encountered is returned
+type Multi []Sink
+
+// Write hands the record to every sink
+func (sinks Multi) Write(record Record) error {
+	return sinks.each(func(s Sink) error { return s.Write(record) })
+}
+
+// Flush flushes every sink
+func (sinks Multi) Flush() error {
+	return sinks.each(Sink.Flush)
+}
+
+// Close closes every sink
+func (sinks Multi) Close() error {
+	return sinks.each(Sink.Close)
+}
+
+// Files lists the files of every sink that writes to files
+func (sinks Multi) Files() []string {
+	var files []string
+	for _, s := range sinks {
+		if filer, ok := s.(Filer); ok {
+			files = append(files, filer.Files()...)
+		}
+	}
+	return files
+}
+
+func (sinks Multi) each(call func(Sink) error) error {
+	var firstErr error
+	for _, s := range sinks {
+		if err := call(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
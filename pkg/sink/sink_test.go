@@ -0,0 +1,569 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	records []Record
+	closed  bool
+	err     error
+}
+
+func (memory *memorySink) Write(record Record) error {
+	memory.records = append(memory.records, record)
+	return memory.err
+}
+
+func (memory *memorySink) Flush() error { return memory.err }
+
+func (memory *memorySink) Close() error {
+	memory.closed = true
+	return memory.err
+}
+
+func TestRegistry(t *testing.T) {
+	memory := &memorySink{}
+	Register("memory", func(options Options) (Sink, error) {
+		return memory, nil
+	})
+
+	opened, err := Open("memory", Options{})
+	if err != nil || opened != memory {
+		t.Fatalf("Expected to open the registered sink, got %v %v", opened, err)
+	}
+
+	if _, err := Open("carrier-pigeon", Options{}); err == nil {
+		t.Errorf("Expected an error opening an unknown sink")
+	}
+}
+
+func TestMultiCallsEverySink(t *testing.T) {
+	failing := &memorySink{err: errors.New("disk full")}
+	working := &memorySink{}
+	sinks := Multi{failing, working}
+
+	if err := sinks.Write(Record{URL: "http://example.com/"}); err == nil {
+		t.Errorf("Expected the failing sink's error")
+	}
+	sinks.Close()
+
+	if len(working.records) != 1 || !working.closed {
+		t.Errorf("Expected the working sink to still be written and closed")
+	}
+}
+
+func TestDot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "graph.gv")
+	dot, err := Open("dot", Options{Path: path, Seeds: []string{"http://example.com/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot.Write(Record{URL: "http://example.com/"})
+	dot.Write(Record{URL: "http://example.com/about", Referrer: "http://example.com/", LinkCount: 2, LinkSections: []string{"nav", "footer"}})
+	dot.Write(Record{URL: "http://example.com/gone", Referrer: "http://example.com/", Status: 404, Error: "Status code 404"})
+	if err := dot.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := string(contents)
+	for _, expected := range []string{`label="/about"`, `label="example.com"`, "start->", "color=orange", "label=Legend", "3 pages on 1 hosts, 1 failed", `tooltip="2 links from nav, footer"`, "weight=2", "from http://example.com/"} {
+		if !strings.Contains(graph, expected) {
+			t.Errorf("Expected graph to contain %s:\n%s", expected, graph)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	first, second := ID("http://example.com/list?page=1"), ID("http://example.com/list?page=2")
+	if first == second {
+		t.Errorf("Expected pages differing by query to have different IDs")
+	}
+	if first != ID("http://example.com/list?page=1") || len(first) != 17 || first[0] != 'n' {
+		t.Errorf("Expected a stable n-prefixed ID, got %s", first)
+	}
+
+	link, _ := url.Parse("http://example.com/list?page=1")
+	if NodeID(*link) != first {
+		t.Errorf("Expected the node ID to be the page ID")
+	}
+}
+
+func TestDotQueryLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "graph.gv")
+	dot, err := Open("dot", Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot.Write(Record{URL: "http://example.com/list?page=1"})
+	dot.Write(Record{URL: "http://example.com/list?page=2", Referrer: "http://example.com/list?page=1"})
+	if err := dot.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := string(contents)
+	for _, expected := range []string{`label="/list?page=1"`, `label="/list?page=2"`} {
+		if !strings.Contains(graph, expected) {
+			t.Errorf("Expected graph to contain %s:\n%s", expected, graph)
+		}
+	}
+}
+
+func TestDotCollapse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "graph.gv")
+	dot, err := Open("dot", Options{Path: path, Collapse: []string{"/blog/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot.Write(Record{URL: "http://example.com/"})
+	dot.Write(Record{URL: "http://example.com/blog/", Referrer: "http://example.com/"})
+	dot.Write(Record{URL: "http://example.com/blog/first", Referrer: "http://example.com/blog/"})
+	dot.Write(Record{URL: "http://example.com/blog/second", Referrer: "http://example.com/blog/"})
+	dot.Write(Record{URL: "http://example.com/blog/third", Referrer: "http://example.com/blog/first"})
+	dot.Write(Record{URL: "http://example.com/about", Referrer: "http://example.com/blog/third"})
+	if err := dot.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := string(contents)
+	collapsed := hash("example.com/blog/*")
+	for _, expected := range []string{`label="/blog/* (3 pages)"`, `label="/blog/"`, ID("http://example.com/blog/") + "->" + collapsed, collapsed + "->" + ID("http://example.com/about")} {
+		if !strings.Contains(graph, expected) {
+			t.Errorf("Expected graph to contain %s:\n%s", expected, graph)
+		}
+	}
+	if strings.Contains(graph, "/blog/first") || strings.Count(graph, "->"+collapsed) != 1 || strings.Contains(graph, collapsed+"->"+collapsed) {
+		t.Errorf("Expected the blog posts to be a single node with a single edge to it:\n%s", graph)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	jsonl, err := Open("jsonl", Options{Base: filepath.Join(dir, "crawl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonl.Write(Record{URL: "http://example.com/", Status: 200})
+	jsonl.Write(Record{URL: "http://example.com/about", Referrer: "http://example.com/", Status: 200, Headers: map[string]string{"Server": "nginx"}})
+	if err := jsonl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "crawl.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"referrer":"http://example.com/"`) || !strings.Contains(lines[1], `"headers":{"Server":"nginx"}`) {
+		t.Errorf("Unexpected JSONL output:\n%s", contents)
+	}
+
+	if files := (Multi{jsonl, &memorySink{}}).Files(); len(files) != 1 || files[0] != filepath.Join(dir, "crawl.jsonl") {
+		t.Errorf("Expected just the JSONL file, got %v", files)
+	}
+
+	records, err := ReadJSONL(bytes.NewReader(contents))
+	if err != nil || len(records) != 2 || records[1].URL != "http://example.com/about" || records[1].Headers["Server"] != "nginx" {
+		t.Errorf("Expected to read back both records, got %+v, %v", records, err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, appending := range []bool{false, true} {
+		options := Options{Base: filepath.Join(dir, "crawl"), Append: appending}
+		output, err := openAll(options, "jsonl", "sqlite", "sitemap-changes")
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.Write(Record{URL: fmt.Sprintf("http://example.com/%d", i), Status: 200})
+		if err := output.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "crawl.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(contents)), "\n"); len(lines) != 2 {
+		t.Errorf("Expected the second run to append to the first, got:\n%s", contents)
+	}
+	if records, err := ReadSQLite(filepath.Join(dir, "crawl.sqlite")); err != nil || len(records) != 2 {
+		t.Errorf("Expected the second run to add to the first's rows, got %+v, %v", records, err)
+	}
+
+	for path, expected := range map[string]string{"crawl.changes.xml": "/0<", "crawl.changes.retry.xml": "/1<"} {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil || !strings.Contains(string(contents), expected) {
+			t.Errorf("Expected %s to list %s, got %s, %v", path, expected, contents, err)
+		}
+	}
+}
+
+func openAll(options Options, names ...string) (Multi, error) {
+	var sinks Multi
+	for _, name := range names {
+		opened, err := Open(name, options)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, opened)
+	}
+	return sinks, nil
+}
+
+func TestTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := Open("template", Options{Base: filepath.Join(dir, "crawl")}); err == nil {
+		t.Errorf("Expected the template sink to need a template")
+	}
+
+	output, err := Open("template", Options{Base: filepath.Join(dir, "crawl"), Template: `{{.Status}} {{.URL}}{{with .Referrer}} <- {{.}}{{end}} {{index .Headers "Server"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output.Write(Record{URL: "http://example.com/", Status: 200})
+	output.Write(Record{URL: "http://example.com/about", Referrer: "http://example.com/", Status: 404, Headers: map[string]string{"Server": "nginx"}})
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "crawl.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "200 http://example.com/ \n404 http://example.com/about <- http://example.com/ nginx\n"
+	if string(contents) != expected {
+		t.Errorf("Expected %q, got %q", expected, contents)
+	}
+}
+
+func TestBolt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open("bolt", Options{Base: filepath.Join(dir, "crawl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Write(Record{URL: "http://example.com/b", Status: 404})
+	db.Write(Record{URL: "http://example.com/a", Status: 500})
+	db.Write(Record{URL: "http://example.com/a", Status: 200})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadBolt(filepath.Join(dir, "crawl.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].URL != "http://example.com/a" || records[0].Status != 200 {
+		t.Errorf("Expected the latest record of each page sorted by URL, got %+v", records)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open("sqlite", Options{Base: filepath.Join(dir, "crawl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crawledAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	db.Write(Record{URL: "http://example.com/b", Status: 404, CrawledAt: crawledAt})
+	db.Write(Record{URL: "http://example.com/a", Status: 500, CrawledAt: crawledAt})
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	db.Write(Record{URL: "http://example.com/a", Status: 200, Links: []string{"http://example.com/b"}, Headers: map[string]string{"Server": "nginx"}, CrawledAt: crawledAt})
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadSQLite(filepath.Join(dir, "crawl.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].URL != "http://example.com/a" || records[0].Status != 200 || !records[0].CrawledAt.Equal(crawledAt) {
+		t.Errorf("Expected the latest record of each page sorted by URL, got %+v", records)
+	} else if len(records[0].Links) != 1 || records[0].Headers["Server"] != "nginx" || records[1].Links != nil {
+		t.Errorf("Expected the links and headers to be read back, got %+v", records)
+	}
+
+	// A new crawl empties the table
+	db, err = Open("sqlite", Options{Base: filepath.Join(dir, "crawl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if records, err := ReadSQLite(filepath.Join(dir, "crawl.sqlite")); err != nil || len(records) != 0 {
+		t.Errorf("Expected no records after a new crawl, got %+v, %v", records, err)
+	}
+}
+
+func TestBoltCrawls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crawls.db")
+
+	started := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, crawl := range []Crawl{
+		{Name: "first", Tags: map[string]string{"site": "example.com", "date": "2021-03-01"}, Started: started},
+		{Name: "other", Tags: map[string]string{"site": "example.org", "date": "2021-03-02"}, Started: started.Add(24 * time.Hour)},
+		{Name: "second", Tags: map[string]string{"site": "example.com", "date": "2021-03-03"}, Started: started.Add(48 * time.Hour)},
+	} {
+		db, err := NewBolt(path, crawl, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for page := 0; page <= i; page++ {
+			db.Write(Record{URL: fmt.Sprintf("http://%s/%d", crawl.Tags["site"], page), Status: 200})
+		}
+		// Crawled again, and failing this time
+		db.Write(Record{URL: fmt.Sprintf("http://%s/0", crawl.Tags["site"]), Status: 500})
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	crawls, err := ListCrawls(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crawls) != 3 || crawls[0].Name != "first" || crawls[2].Name != "second" || crawls[2].Pages != 3 || crawls[2].Failed != 1 {
+		t.Errorf("Expected 3 crawls oldest first with their page counts, got %+v", crawls)
+	}
+
+	for selector, expected := range map[string]string{
+		"":                                 "second",
+		"first":                            "first",
+		"site=example.com":                 "second",
+		"site=example.com+date=2021-03-01": "first",
+		"site=example.org":                 "other",
+	} {
+		if _, crawl, err := ReadCrawl(path, selector); err != nil || crawl.Name != expected {
+			t.Errorf("Expected %q to pick %s, got %s (%v)", selector, expected, crawl.Name, err)
+		}
+	}
+	if _, _, err := ReadCrawl(path, "site=example.net"); err == nil {
+		t.Errorf("Expected an error when no crawl matches")
+	}
+
+	// Crawling again under a name replaces the crawl
+	db, err := NewBolt(path, Crawl{Name: "first", Started: started.Add(72 * time.Hour)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if records, crawl, err := ReadCrawl(path, ""); err != nil || crawl.Name != "first" || len(records) != 0 {
+		t.Errorf("Expected the replaced crawl to be the latest and empty, got %s with %d pages (%v)", crawl.Name, len(records), err)
+	}
+}
+
+func TestBoltAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crawls.db")
+
+	started := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"", "named"} {
+		db, err := NewBolt(path, Crawl{Name: name, Started: started}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Write(Record{URL: "http://example.com/", Status: 200})
+		db.Write(Record{URL: "http://example.com/flaky", Status: 503})
+		db.Close()
+		started = started.Add(time.Hour)
+
+		// The retry of the failures, with the crawl's name or none at all
+		retry, err := Open("bolt", Options{Path: path, Crawl: Crawl{Name: name, Started: started}, Append: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		retry.Write(Record{URL: "http://example.com/flaky", Status: 200})
+		retry.Close()
+		started = started.Add(time.Hour)
+
+		records, crawl, err := ReadCrawl(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 || records[0].Status != 200 || records[1].Status != 200 {
+			t.Errorf("Expected %q to keep its pages with the retried one replaced, got %+v", name, records)
+		}
+		if crawl.Pages != 2 || crawl.Failed != 0 {
+			t.Errorf("Expected %q to count 2 pages and no failures, got %d and %d", name, crawl.Pages, crawl.Failed)
+		}
+	}
+
+	crawls, err := ListCrawls(path)
+	if err != nil || len(crawls) != 2 {
+		t.Errorf("Expected the retries to add to the crawls rather than start new ones, got %+v, %v", crawls, err)
+	}
+}
+
+func TestBoltPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crawls.db")
+
+	now := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+	for day := 1; day <= 4; day++ {
+		db, err := NewBolt(path, Crawl{Name: fmt.Sprintf("day%d", day), Started: now.AddDate(0, 0, day-10)}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+	}
+
+	current, err := NewBolt(path, Crawl{Name: "today", Started: now}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := current.Prune(3, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 2 || pruned[0].Name != "day2" || pruned[1].Name != "day1" {
+		t.Errorf("Expected all but the 3 latest crawls pruned, got %+v", pruned)
+	}
+
+	pruned, err = current.Prune(0, 6*24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 1 || pruned[0].Name != "day3" {
+		t.Errorf("Expected the crawl over 6 days old pruned, got %+v", pruned)
+	}
+	current.Close()
+
+	crawls, err := ListCrawls(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crawls) != 2 || crawls[0].Name != "day4" || crawls[1].Name != "today" {
+		t.Errorf("Expected day4 and today left, got %+v", crawls)
+	}
+}
+
+func TestSitemapChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous := map[string]string{"http://example.com/same": "a", "http://example.com/edited": "b"}
+	changes, err := Open("sitemap-changes", Options{Base: filepath.Join(dir, "crawl"), Previous: previous})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crawledAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	changes.Write(Record{URL: "http://example.com/same", Status: 200, ContentHash: "a", CrawledAt: crawledAt})
+	changes.Write(Record{URL: "http://example.com/edited", Status: 200, ContentHash: "c", CrawledAt: crawledAt})
+	changes.Write(Record{URL: "http://example.com/new?a=1&b=2", Status: 200, ContentHash: "d", CrawledAt: crawledAt})
+	changes.Write(Record{URL: "http://example.com/gone", Status: 404, CrawledAt: crawledAt})
+	if err := changes.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "crawl.changes.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemap := string(contents)
+	for _, expected := range []string{"<loc>http://example.com/edited</loc><lastmod>2021-03-01T12:00:00Z</lastmod>", "<loc>http://example.com/new?a=1&amp;b=2</loc>", `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`} {
+		if !strings.Contains(sitemap, expected) {
+			t.Errorf("Expected the sitemap to contain %s:\n%s", expected, sitemap)
+		}
+	}
+	for _, unexpected := range []string{"/same", "/gone"} {
+		if strings.Contains(sitemap, unexpected) {
+			t.Errorf("Expected the sitemap to leave out %s:\n%s", unexpected, sitemap)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("sitemap-changes", func(options Options) (Sink, error) {
+		return NewSitemapChanges(options.documentPath(".changes.xml"), options.Previous), nil
+	})
+}
+
+// The most URLs one sitemap may list, more are split across several files with an index listing them
+const sitemapMaxURLs = 50000
+
+// SitemapChanges writes a sitemap of the pages added or changed since the previous crawl, for feeding
+// incremental indexing systems
+// Only pages crawled fine with a 2xx status are listed, and every one of them when there's no previous crawl
+type SitemapChanges struct {
+	path string
+
+	// Content hashes of the previous crawl's pages by URL
+	previous map[string]string
+
+	mutex   sync.Mutex
+	changed map[string]time.Time
+	files   []string
+}
+
+// NewSitemapChanges creates a sitemap at path of the pages whose content hash differs from previous
+func NewSitemapChanges(path string, previous map[string]string) *SitemapChanges {
+	return &SitemapChanges{path: path, previous: previous, changed: make(map[string]time.Time)}
+}
+
+// Files is the sitemap, or the index and every sitemap it lists
+func (changes *SitemapChanges) Files() []string {
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+
+	if len(changes.files) == 0 {
+		return []string{changes.path}
+	}
+	return changes.files
+}
+
+// Write lists the page if it's new or changed
+func (changes *SitemapChanges) Write(record Record) error {
+	if record.Error != "" || record.Status < 200 || record.Status > 299 {
+		return nil
+	}
+	if hash, ok := changes.previous[record.URL]; ok && hash == record.ContentHash {
+		return nil
+	}
+
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+	changes.changed[record.URL] = record.CrawledAt
+	return nil
+}
+
+// Flush writes the sitemap out, sorted by URL
+func (changes *SitemapChanges) Flush() error {
+	changes.mutex.Lock()
+	defer changes.mutex.Unlock()
+
+	urls := make([]string, 0, len(changes.changed))
+	for pageURL := range changes.changed {
+		urls = append(urls, pageURL)
+	}
+	sort.Strings(urls)
+
+	if len(urls) <= sitemapMaxURLs {
+		changes.files = []string{changes.path}
+		return changes.writeURLs(changes.path, urls)
+	}
+
+	// Too many for one sitemap, so the index goes where the sitemap would have
+	base := strings.TrimSuffix(changes.path, ".xml")
+	changes.files = []string{changes.path}
+	for part := 0; part*sitemapMaxURLs < len(urls); part++ {
+		end := (part + 1) * sitemapMaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		path := fmt.Sprintf("%s-%d.xml", base, part+1)
+		if err := changes.writeURLs(path, urls[part*sitemapMaxURLs:end]); err != nil {
+			return err
+		}
+		changes.files = append(changes.files, path)
+	}
+	// Where the sitemaps end up being served from isn't known, so the index lists them by file name
+	names := make([]string, 0, len(changes.files)-1)
+	for _, path := range changes.files[1:] {
+		names = append(names, filepath.Base(path))
+	}
+	return writeSitemap(changes.path, "sitemapindex", "sitemap", names, nil)
+}
+
+func (changes *SitemapChanges) writeURLs(path string, urls []string) error {
+	return writeSitemap(path, "urlset", "url", urls, func(pageURL string) time.Time {
+		return changes.changed[pageURL]
+	})
+}
+
+// writeSitemap writes locations as a sitemap or sitemap index, with the dates lastmod gives them
+func writeSitemap(path string, root string, element string, locations []string, lastmod func(string) time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+
+	writer.WriteString(xml.Header)
+	writer.WriteString(`<` + root + ` xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	for _, location := range locations {
+		writer.WriteString("  <" + element + "><loc>")
+		xml.EscapeText(writer, []byte(location))
+		writer.WriteString("</loc>")
+		if lastmod != nil && !lastmod(location).IsZero() {
+			writer.WriteString("<lastmod>" + lastmod(location).UTC().Format(time.RFC3339) + "</lastmod>")
+		}
+		writer.WriteString("</" + element + ">\n")
+	}
+	writer.WriteString("</" + root + ">\n")
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close writes the sitemap out one last time
+func (changes *SitemapChanges) Close() error {
+	return changes.Flush()
+}
@@ -0,0 +1,210 @@
+package sink
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	// Registers the pure Go "sqlite" driver, so the crawler still builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	Register("sqlite", func(options Options) (Sink, error) {
+		return NewSQLite(options.path(".sqlite"), options.Append)
+	})
+}
+
+// The table every page goes in, one row per URL
+// Lists and headers are stored as JSON, which SQLite's json functions can query
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS pages (
+	url            TEXT PRIMARY KEY,
+	id             TEXT,
+	referrer       TEXT,
+	status         INTEGER,
+	error          TEXT,
+	relation       TEXT,
+	link_count     INTEGER,
+	link_sections  TEXT,
+	links          TEXT,
+	headers        TEXT,
+	encoding       TEXT,
+	wire_bytes     INTEGER,
+	bytes          INTEGER,
+	content_hash   TEXT,
+	structure_hash TEXT,
+	unchanged      INTEGER,
+	text           TEXT,
+	crawled_at     TEXT
+)`
+
+const sqliteInsert = `INSERT OR REPLACE INTO pages (url, id, referrer, status, error, relation, link_count, link_sections,
+	links, headers, encoding, wire_bytes, bytes, content_hash, structure_hash, unchanged, text, crawled_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// SQLite stores every crawled page as a row of a pages table in an SQLite database, so a crawl can be
+// queried with SQL afterwards
+// A page crawled more than once keeps only its latest row. Rows are written in one transaction per
+// flush, as committing every page on its own would make the disk the bottleneck of the crawl
+type SQLite struct {
+	path string
+	db   *sql.DB
+
+	mutex  sync.Mutex
+	tx     *sql.Tx
+	insert *sql.Stmt
+}
+
+// NewSQLite opens the database at path, creating it if need be, emptying the pages table unless appending
+func NewSQLite(path string, appending bool) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only has the one writer anyway
+	db.SetMaxOpenConns(1)
+
+	statements := []string{sqliteSchema}
+	if !appending {
+		statements = append([]string{"DROP TABLE IF EXISTS pages"}, statements...)
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &SQLite{path: path, db: db}, nil
+}
+
+// Files is the database file
+func (s *SQLite) Files() []string {
+	return []string{s.path}
+}
+
+// Write adds the record to the transaction of the current flush
+func (s *SQLite) Write(record Record) error {
+	linkSections, err := jsonColumn(record.LinkSections)
+	if err != nil {
+		return err
+	}
+	links, err := jsonColumn(record.Links)
+	if err != nil {
+		return err
+	}
+	headers, err := jsonColumn(record.Headers)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.tx == nil {
+		if s.tx, err = s.db.Begin(); err != nil {
+			return err
+		}
+		if s.insert, err = s.tx.Prepare(sqliteInsert); err != nil {
+			s.tx.Rollback()
+			s.tx = nil
+			return err
+		}
+	}
+
+	_, err = s.insert.Exec(record.URL, record.ID, record.Referrer, record.Status, record.Error, record.Relation,
+		record.LinkCount, linkSections, links, headers, record.Encoding, record.WireBytes, record.Bytes,
+		record.ContentHash, record.StructureHash, record.Unchanged, record.Text, record.CrawledAt.UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+// Flush commits the rows written since the last flush
+func (s *SQLite) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.tx == nil {
+		return nil
+	}
+	s.insert.Close()
+	err := s.tx.Commit()
+	s.tx, s.insert = nil, nil
+	return err
+}
+
+// Close commits what's left and closes the database
+func (s *SQLite) Close() error {
+	if err := s.Flush(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+// jsonColumn is the JSON stored for a list or map column, NULL when it's empty
+func jsonColumn(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case []string:
+		if len(value) == 0 {
+			return nil, nil
+		}
+	case map[string]string:
+		if len(value) == 0 {
+			return nil, nil
+		}
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// ReadSQLite reads back the records an SQLite sink wrote, sorted by URL
+func ReadSQLite(path string) ([]Record, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT url, id, referrer, status, error, relation, link_count, link_sections, links,
+		headers, encoding, wire_bytes, bytes, content_hash, structure_hash, unchanged, text, crawled_at FROM pages ORDER BY url`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var linkSections, links, headers sql.NullString
+		var crawledAt string
+		err := rows.Scan(&record.URL, &record.ID, &record.Referrer, &record.Status, &record.Error, &record.Relation,
+			&record.LinkCount, &linkSections, &links, &headers, &record.Encoding, &record.WireBytes, &record.Bytes,
+			&record.ContentHash, &record.StructureHash, &record.Unchanged, &record.Text, &crawledAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, column := range []struct {
+			stored sql.NullString
+			into   interface{}
+		}{{linkSections, &record.LinkSections}, {links, &record.Links}, {headers, &record.Headers}} {
+			if !column.stored.Valid {
+				continue
+			}
+			if err := json.Unmarshal([]byte(column.stored.String), column.into); err != nil {
+				return nil, err
+			}
+		}
+		if record.CrawledAt, err = time.Parse(time.RFC3339Nano, crawledAt); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
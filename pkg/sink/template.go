@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+func init() {
+	Register("template", func(options Options) (Sink, error) {
+		if options.Template == "" {
+			return nil, fmt.Errorf("sink: the template sink needs a template")
+		}
+		return NewTemplate(options.path(".txt"), options.Template, options.Append)
+	})
+}
+
+// Template writes each crawled page as the output of a text/template executed on its Record, one
+// line per page unless the template says otherwise, for output shaped without writing a sink
+type Template struct {
+	path     string
+	template *template.Template
+
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewTemplate parses text and creates (or truncates) the file at path, or appends to it
+// A newline is added to the end of text when it doesn't already have one
+func NewTemplate(path string, text string, appending bool) (*Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	parsed, err := template.New("record").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := createFile(path, appending)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{path: path, template: parsed, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Files is the file the output is written to
+func (output *Template) Files() []string {
+	return []string{output.path}
+}
+
+// Write executes the template on the record and appends the result
+// The template is executed before anything is written, so a failing one doesn't leave half a line behind
+func (output *Template) Write(record Record) error {
+	var buffer bytes.Buffer
+	if err := output.template.Execute(&buffer, record); err != nil {
+		return err
+	}
+
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+
+	_, err := output.writer.Write(buffer.Bytes())
+	return err
+}
+
+// Flush pushes buffered output out to the file
+func (output *Template) Flush() error {
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	return output.writer.Flush()
+}
+
+// Close flushes and closes the file
+func (output *Template) Close() error {
+	if err := output.Flush(); err != nil {
+		output.file.Close()
+		return err
+	}
+	return output.file.Close()
+}
@@ -0,0 +1,147 @@
+// Package sitemap reads XML sitemaps and sitemap indexes, gzipped or not
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MaxSitemaps caps how many sitemaps Fetch reads, since indexes can list other indexes
+const MaxSitemaps int = 50
+
+// Entry is a URL listed in a sitemap
+type Entry struct {
+	URL string
+
+	// The sitemap that listed it
+	Sitemap string
+
+	// When the sitemap says the page last changed, zero when it doesn't say or can't be read
+	LastModified time.Time
+}
+
+type document struct {
+	XMLName xml.Name
+	URLs    []location `xml:"url"`
+	Maps    []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// The W3C datetime formats <lastmod> may be written in, most precise first
+var lastmodFormats = []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00", "2006-01-02", "2006-01", "2006"}
+
+// ParseLastmod reads a <lastmod> date, which may be anything from just a year to a full timestamp
+func ParseLastmod(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, format := range lastmodFormats {
+		if parsed, err := time.Parse(format, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Parse reads a sitemap, returning the pages it lists along with any sitemaps it lists if it's an index
+// The entries returned don't have their Sitemap set
+func Parse(body []byte) (pages []Entry, sitemaps []string, err error) {
+	// Sitemaps are often served gzipped without a Content-Encoding
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var doc document
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return nil, nil, fmt.Errorf("sitemap: unexpected root element <%s>", doc.XMLName.Local)
+	}
+
+	for _, url := range doc.URLs {
+		if loc := strings.TrimSpace(url.Loc); loc != "" {
+			lastModified, _ := ParseLastmod(url.LastMod)
+			pages = append(pages, Entry{URL: loc, LastModified: lastModified})
+		}
+	}
+	for _, sitemap := range doc.Maps {
+		if loc := strings.TrimSpace(sitemap.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return pages, sitemaps, nil
+}
+
+// Fetch reads every URL listed in the given sitemaps, following sitemap indexes
+// Sitemaps that can't be read are skipped, and the first error is returned along with everything that could be
+func Fetch(client *http.Client, sitemaps []string) ([]Entry, error) {
+	var entries []Entry
+	var firstErr error
+
+	seen := make(map[string]bool)
+	for len(sitemaps) > 0 && len(seen) < MaxSitemaps {
+		sitemap := sitemaps[0]
+		sitemaps = sitemaps[1:]
+		if seen[sitemap] {
+			continue
+		}
+		seen[sitemap] = true
+
+		pages, children, err := fetchOne(client, sitemap)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		for _, page := range pages {
+			page.Sitemap = sitemap
+			entries = append(entries, page)
+		}
+		sitemaps = append(sitemaps, children...)
+	}
+
+	return entries, firstErr
+}
+
+func fetchOne(client *http.Client, sitemap string) ([]Entry, []string, error) {
+	response, err := client.Get(sitemap)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 399 || response.StatusCode < 200 {
+		return nil, nil, fmt.Errorf("sitemap: status code %d %s", response.StatusCode, sitemap)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pages, children, err := Parse(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", sitemap, err)
+	}
+	return pages, children, nil
+}
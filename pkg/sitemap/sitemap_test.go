@@ -0,0 +1,77 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchIndex(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/pages.xml.gz</loc></sitemap>
+  <sitemap><loc>%[1]s/sitemap.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/missing.xml</loc></sitemap>
+</sitemapindex>`, server.URL)
+		case "/pages.xml.gz":
+			var buffer bytes.Buffer
+			writer := gzip.NewWriter(&buffer)
+			writer.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/ </loc></url>
+  <url><loc>https://example.com/about</loc><lastmod>2020-01-01</lastmod></url>
+</urlset>`))
+			writer.Close()
+			w.Write(buffer.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	entries, err := Fetch(server.Client(), []string{server.URL + "/sitemap.xml"})
+	if err == nil {
+		t.Errorf("Expected the missing sitemap to be reported")
+	}
+
+	expected := []Entry{
+		{URL: "https://example.com/", Sitemap: server.URL + "/pages.xml.gz"},
+		{URL: "https://example.com/about", Sitemap: server.URL + "/pages.xml.gz", LastModified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+func TestParseRejectsOtherXML(t *testing.T) {
+	if _, _, err := Parse([]byte(`<rss><channel></channel></rss>`)); err == nil {
+		t.Errorf("Expected an RSS feed to be rejected")
+	}
+}
+
+func TestParseLastmod(t *testing.T) {
+	tests := map[string]time.Time{
+		"2021":                      time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		"2021-03":                   time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		" 2021-03-04 ":              time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		"2021-03-04T05:06Z":         time.Date(2021, 3, 4, 5, 6, 0, 0, time.UTC),
+		"2021-03-04T05:06:07+00:00": time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+	}
+	for value, expected := range tests {
+		if parsed, ok := ParseLastmod(value); !ok || !parsed.Equal(expected) {
+			t.Errorf("%q: expected %v, got %v %v", value, expected, parsed, ok)
+		}
+	}
+
+	if _, ok := ParseLastmod("yesterday"); ok {
+		t.Errorf("Expected yesterday to be unreadable")
+	}
+}
@@ -0,0 +1,61 @@
+// Package soft404 spots pages that are served with a 200 but are really error pages
+package soft404
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultPhrases are matched against a page's title and headings
+var DefaultPhrases = []string{
+	"not found",
+	"404",
+	"does not exist",
+	"doesn't exist",
+	"no longer available",
+	"page unavailable",
+}
+
+var headingPattern = regexp.MustCompile(`(?is)<(title|h1|h2)[^>]*>(.*?)</(?:title|h1|h2)>`)
+var tagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// Detector holds the heuristics used to decide whether a page is a soft 404
+type Detector struct {
+	// Bodies smaller than this are too thin to be a real page, zero disables the check
+	MinBytes int
+
+	// Phrases that give away an error page when found in the title or a top-level heading
+	Phrases []string
+
+	// Flag pages that were redirected to the site's home page
+	RedirectToHome bool
+}
+
+// Detect returns why the page looks like a soft 404, if it does
+// requested is the URL that was asked for and final is where redirects ended up
+func (detector Detector) Detect(requested url.URL, final url.URL, body []byte) (string, bool) {
+	if detector.RedirectToHome && isHome(final) && !isHome(requested) {
+		return fmt.Sprintf("redirected to home page %s", final.String()), true
+	}
+
+	if detector.MinBytes > 0 && len(body) < detector.MinBytes {
+		return fmt.Sprintf("body is only %d bytes", len(body)), true
+	}
+
+	for _, match := range headingPattern.FindAllSubmatch(body, -1) {
+		heading := strings.ToLower(string(tagPattern.ReplaceAll(match[2], nil)))
+		for _, phrase := range detector.Phrases {
+			if phrase != "" && strings.Contains(heading, strings.ToLower(phrase)) {
+				return fmt.Sprintf("%s contains %q", strings.ToLower(string(match[1])), phrase), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func isHome(page url.URL) bool {
+	return page.Path == "" || page.Path == "/"
+}
@@ -0,0 +1,42 @@
+package soft404
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	detector := Detector{MinBytes: 32, Phrases: DefaultPhrases, RedirectToHome: true}
+
+	parse := func(raw string) url.URL {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *parsed
+	}
+
+	realPage := []byte("<html><title>Our Products</title><h1>Products</h1><p>Lots of lovely things</p></html>")
+
+	cases := []struct {
+		name      string
+		requested string
+		final     string
+		body      []byte
+		soft404   bool
+	}{
+		{"real page", "http://example.com/products", "http://example.com/products", realPage, false},
+		{"home page", "http://example.com/", "http://example.com/", realPage, false},
+		{"tiny body", "http://example.com/gone", "http://example.com/gone", []byte("oops"), true},
+		{"phrase in title", "http://example.com/gone", "http://example.com/gone", []byte("<html><title>Page Not Found</title><p>Sorry about that, have a look around</p></html>"), true},
+		{"phrase in nested heading", "http://example.com/gone", "http://example.com/gone", []byte("<html><h1 class=\"error\"><span>Error 404</span></h1><p>Sorry about that, have a look around</p></html>"), true},
+		{"redirect home", "http://example.com/old-page", "http://example.com/", realPage, true},
+	}
+
+	for _, c := range cases {
+		reason, soft404 := detector.Detect(parse(c.requested), parse(c.final), c.body)
+		if soft404 != c.soft404 {
+			t.Errorf("%s: expected %v, got %v (%s)", c.name, c.soft404, soft404, reason)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package trend exports a summary of each crawl as one point of a time series, appended to a CSV file
+// or pushed to a Prometheus Pushgateway, so a site's health can be graphed over months
+package trend
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Summary is how one crawl went
+type Summary struct {
+	Finished time.Time
+	Site     string
+	Crawl    string
+
+	Pages       int
+	Failed      int
+	BrokenLinks int
+
+	// Mean time from sending a request to having its response, over every request that got that far
+	AverageLatency time.Duration
+	Duration       time.Duration
+
+	ExitCode int
+}
+
+var csvHeader = []string{"finished", "site", "crawl", "pages", "failed", "broken_links", "avg_latency_ms", "duration_s", "exit_code"}
+
+// AppendCSV adds the summary to the end of the CSV file at path as a row, creating the file with a header
+// row when it doesn't exist yet
+func AppendCSV(path string, summary Summary) error {
+	info, err := os.Stat(path)
+	fresh := os.IsNotExist(err) || err == nil && info.Size() == 0
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if fresh {
+		writer.Write(csvHeader)
+	}
+	writer.Write([]string{
+		summary.Finished.UTC().Format(time.RFC3339),
+		summary.Site,
+		summary.Crawl,
+		strconv.Itoa(summary.Pages),
+		strconv.Itoa(summary.Failed),
+		strconv.Itoa(summary.BrokenLinks),
+		strconv.FormatInt(summary.AverageLatency.Milliseconds(), 10),
+		strconv.FormatFloat(summary.Duration.Seconds(), 'f', 0, 64),
+		strconv.Itoa(summary.ExitCode),
+	})
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteMetrics writes the summary as gauges in the Prometheus text format
+func WriteMetrics(w io.Writer, summary Summary) error {
+	var buffer bytes.Buffer
+	gauge := func(name string, help string, value float64) {
+		fmt.Fprintf(&buffer, "# HELP grawler_%s %s\n# TYPE grawler_%s gauge\ngrawler_%s %s\n",
+			name, help, name, name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	gauge("last_crawl_timestamp_seconds", "When the crawl finished.", float64(summary.Finished.Unix()))
+	gauge("pages", "Pages crawled.", float64(summary.Pages))
+	gauge("failed_pages", "Pages that couldn't be crawled.", float64(summary.Failed))
+	gauge("broken_links", "Broken links found.", float64(summary.BrokenLinks))
+	gauge("average_latency_seconds", "Mean response time.", summary.AverageLatency.Seconds())
+	gauge("duration_seconds", "How long the crawl took.", summary.Duration.Seconds())
+	gauge("exit_code", "What the crawl exited with.", float64(summary.ExitCode))
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// Push replaces the metrics a Pushgateway at gateway holds for the job, and the site when there is one,
+// with the summary's
+func Push(client *http.Client, gateway string, job string, summary Summary) error {
+	target := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	if summary.Site != "" {
+		target += "/site/" + url.PathEscape(summary.Site)
+	}
+
+	var body bytes.Buffer
+	if err := WriteMetrics(&body, summary); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 != 2 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("trend: pushing to %s: %s %s", target, response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
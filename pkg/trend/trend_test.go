@@ -0,0 +1,78 @@
+package trend
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var summary = Summary{
+	Finished:       time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	Site:           "example.com",
+	Crawl:          "nightly",
+	Pages:          120,
+	Failed:         3,
+	BrokenLinks:    5,
+	AverageLatency: 250 * time.Millisecond,
+	Duration:       90 * time.Second,
+	ExitCode:       1,
+}
+
+func TestAppendCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trend")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trend.csv")
+
+	for i := 0; i < 2; i++ {
+		if err := AppendCSV(path, summary); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := "2021-03-01T12:00:00Z,example.com,nightly,120,3,5,250,90,1\n"
+	expected := strings.Join(csvHeader, ",") + "\n" + row + row
+	if string(contents) != expected {
+		t.Errorf("Expected one header and a row per crawl, got:\n%s", contents)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contents, _ := ioutil.ReadAll(r.Body)
+		path, body = r.Method+" "+r.URL.Path, string(contents)
+	}))
+	defer server.Close()
+
+	if err := Push(server.Client(), server.URL+"/", "grawler", summary); err != nil {
+		t.Fatal(err)
+	}
+	if path != "PUT /metrics/job/grawler/site/example.com" {
+		t.Errorf("Expected the metrics grouped by job and site, got %s", path)
+	}
+	for _, expected := range []string{"grawler_pages 120\n", "grawler_broken_links 5\n", "grawler_average_latency_seconds 0.25\n", "# TYPE grawler_failed_pages gauge\n"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	if err := Push(failing.Client(), failing.URL, "grawler", summary); err == nil || !strings.Contains(err.Error(), "bad metrics") {
+		t.Errorf("Expected the gateway's complaint as an error, got %v", err)
+	}
+}
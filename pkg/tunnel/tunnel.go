@@ -0,0 +1,188 @@
+// Package tunnel routes connections through an SSH server, like ssh -D or ssh -L, so sites only reachable
+// from behind a bastion host can be crawled from outside
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Config is where a Tunnel goes and how it authenticates
+type Config struct {
+	// The SSH server, as user@host or user@host:port, port 22 when left out
+	Server string
+
+	// Private key to authenticate with, the keys in ssh-agent when empty
+	KeyPath string
+
+	// known_hosts file the server's host key must be in
+	KnownHosts string
+
+	// Every connection goes to this host:port from the server, like ssh -L, when set; otherwise each goes
+	// to the address it was dialed for, like ssh -D
+	Forward string
+}
+
+// Tunnel dials connections from the SSH server, reconnecting to it if the connection is lost
+type Tunnel struct {
+	config  Config
+	address string
+	client  *ssh.ClientConfig
+
+	mutex     sync.Mutex
+	conn      *ssh.Client
+	agentConn net.Conn
+}
+
+// Open connects to the SSH server, failing early if it can't be reached or won't let us in
+func Open(config Config) (*Tunnel, error) {
+	user, address := "", config.Server
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		user, address = address[:at], address[at+1:]
+	}
+	if user == "" {
+		return nil, fmt.Errorf("ssh tunnel %q has no user, expected user@host", config.Server)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "22")
+	}
+
+	tunnel := &Tunnel{config: config, address: address}
+
+	hostKeys, err := knownhosts.New(config.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := tunnel.auth()
+	if err != nil {
+		return nil, err
+	}
+	tunnel.client = &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeys,
+		Timeout:         30 * time.Second,
+	}
+
+	if _, err := tunnel.connect(); err != nil {
+		tunnel.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+func (tunnel *Tunnel) auth() (ssh.AuthMethod, error) {
+	if tunnel.config.KeyPath != "" {
+		key, err := ioutil.ReadFile(tunnel.config.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading ssh key %s: %v", tunnel.config.KeyPath, err)
+		}
+		return ssh.PublicKeys(signer), nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("no ssh key given and no ssh-agent running to take keys from")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	tunnel.agentConn = conn
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), nil
+}
+
+// connect returns the connection to the SSH server, making one if there's none or the last one was lost
+func (tunnel *Tunnel) connect() (*ssh.Client, error) {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	if tunnel.conn != nil {
+		return tunnel.conn, nil
+	}
+
+	conn, err := ssh.Dial("tcp", tunnel.address, tunnel.client)
+	if err != nil {
+		return nil, err
+	}
+	tunnel.conn = conn
+
+	go func() {
+		conn.Wait()
+		tunnel.mutex.Lock()
+		if tunnel.conn == conn {
+			tunnel.conn = nil
+		}
+		tunnel.mutex.Unlock()
+	}()
+	return conn, nil
+}
+
+// DialContext connects to address from the SSH server, or to Config.Forward when set
+// It fits http.Transport's DialContext
+func (tunnel *Tunnel) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if tunnel.config.Forward != "" {
+		address = tunnel.config.Forward
+	}
+
+	conn, err := tunnel.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	type dialed struct {
+		conn net.Conn
+		err  error
+	}
+	// The SSH client can't be told to give up on a dial, so the result is waited for on the side
+	result := make(chan dialed, 1)
+	go func() {
+		remote, err := conn.Dial(network, address)
+		result <- dialed{remote, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		go func() {
+			if late := <-result; late.conn != nil {
+				late.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	case done := <-result:
+		if done.err != nil {
+			return nil, fmt.Errorf("dialing %s through ssh tunnel %s: %v", address, tunnel.address, done.err)
+		}
+		return done.conn, nil
+	}
+}
+
+// Close closes the connection to the SSH server
+func (tunnel *Tunnel) Close() error {
+	tunnel.mutex.Lock()
+	defer tunnel.mutex.Unlock()
+
+	if tunnel.agentConn != nil {
+		tunnel.agentConn.Close()
+	}
+	if tunnel.conn == nil {
+		return nil
+	}
+	err := tunnel.conn.Close()
+	tunnel.conn = nil
+	return err
+}
@@ -0,0 +1,208 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sshServer accepts clientKey and opens the direct-tcpip channels asked for, like sshd does
+func sshServer(t *testing.T, clientKey ssh.PublicKey) (net.Listener, ssh.PublicKey) {
+	hostKey, err := ssh.NewSignerFromKey(newKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config)
+		}
+	}()
+	return listener, hostKey.PublicKey()
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		// RFC 4254 7.2, the extra data leads with the host and port to connect to
+		data := newChannel.ExtraData()
+		hostLength := binary.BigEndian.Uint32(data)
+		host := string(data[4 : 4+hostLength])
+		port := binary.BigEndian.Uint32(data[4+hostLength:])
+
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			io.Copy(channel, target)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(target, channel)
+			target.Close()
+		}()
+	}
+}
+
+// fixture starts an SSH server and writes a key it accepts and a known_hosts file naming it
+func fixture(t *testing.T, dir string) (Config, net.Listener) {
+	clientKey := newKey(t)
+	clientPublic, err := ssh.NewPublicKey(&clientKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, hostKey := sshServer(t, clientPublic)
+
+	der, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ecdsa")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.Addr().String())}, hostKey)
+	if err := ioutil.WriteFile(knownHostsPath, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return Config{Server: "crawler@" + server.Addr().String(), KeyPath: keyPath, KnownHosts: knownHostsPath}, server
+}
+
+func get(t *testing.T, tunnel *Tunnel, target string) string {
+	client := &http.Client{Transport: &http.Transport{DialContext: tunnel.DialContext}, Timeout: 5 * time.Second}
+	response, err := client.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	return string(body)
+}
+
+func TestTunnel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tunnel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal " + r.Host))
+	}))
+	defer site.Close()
+
+	config, server := fixture(t, dir)
+	defer server.Close()
+
+	tunnel, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+
+	if body := get(t, tunnel, site.URL); body != "internal "+site.Listener.Addr().String() {
+		t.Errorf("expected the site through the tunnel, got %q", body)
+	}
+
+	// Forwarding sends every connection to the one address, whatever the request was for
+	config.Forward = site.Listener.Addr().String()
+	forwarding, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forwarding.Close()
+
+	if body := get(t, forwarding, "http://intranet.example/"); body != "internal intranet.example" {
+		t.Errorf("expected the forwarded site, got %q", body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tunnel.DialContext(ctx, "tcp", site.Listener.Addr().String()); err == nil {
+		t.Error("expected a cancelled dial to fail")
+	}
+}
+
+func TestUnknownHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tunnel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config, server := fixture(t, dir)
+	defer server.Close()
+
+	other, err := ssh.NewPublicKey(&newKey(t).PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.Addr().String())}, other)
+	if err := ioutil.WriteFile(config.KnownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(config); err == nil {
+		t.Error("expected a server with an unknown host key to be refused")
+	}
+}
@@ -0,0 +1,120 @@
+// Package validate runs a lightweight well-formedness check over HTML documents
+// It's nowhere near a full validator, just the mistakes that commonly break pages or their styling.
+package validate
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Result counts the problems found in a document
+type Result struct {
+	// Elements never closed, and end tags with nothing to close, by tag name in document order
+	Unclosed []string
+	Stray    []string
+
+	// IDs used more than once, in the order their second use appears
+	DuplicateIDs []string
+
+	// How many <h1> elements the document has, more than one is reported as a problem
+	H1s int
+}
+
+// Issues is the total number of problems found
+func (result Result) Issues() int {
+	issues := len(result.Unclosed) + len(result.Stray) + len(result.DuplicateIDs)
+	if result.H1s > 1 {
+		issues++
+	}
+	return issues
+}
+
+// Elements that never have content, so never need closing
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// Elements whose end tag may be left out
+var optionalEnd = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+	"option": true, "optgroup": true, "colgroup": true, "thead": true, "tbody": true, "tfoot": true,
+	"tr": true, "td": true, "th": true, "rb": true, "rt": true, "rtc": true, "rp": true,
+}
+
+// Check looks a document over
+func Check(body []byte) Result {
+	var result Result
+	var open []string
+	ids := make(map[string]int)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttrs := tokenizer.TagName()
+			tag := string(name)
+
+			for hasAttrs {
+				var key, value []byte
+				key, value, hasAttrs = tokenizer.TagAttr()
+				if string(key) != "id" {
+					continue
+				}
+				id := strings.TrimSpace(string(value))
+				if ids[id]++; ids[id] == 2 && id != "" {
+					result.DuplicateIDs = append(result.DuplicateIDs, id)
+				}
+			}
+
+			if tag == "h1" {
+				result.H1s++
+			}
+			if tokenType == html.StartTagToken && !voidElements[tag] {
+				open = append(open, tag)
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if voidElements[tag] {
+				continue
+			}
+
+			// Close the nearest matching element, anything opened after it was left unclosed
+			match := -1
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tag {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				result.Stray = append(result.Stray, tag)
+				continue
+			}
+			result.Unclosed = append(result.Unclosed, required(open[match+1:])...)
+			open = open[:match]
+		}
+	}
+
+	result.Unclosed = append(result.Unclosed, required(open)...)
+	return result
+}
+
+// required filters out elements that may be left unclosed
+func required(tags []string) []string {
+	var unclosed []string
+	for _, tag := range tags {
+		if !optionalEnd[tag] {
+			unclosed = append(unclosed, tag)
+		}
+	}
+	return unclosed
+}
@@ -0,0 +1,42 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	body := []byte(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Test</title></head>
+<body>
+<h1 id="top">One</h1>
+<div id="main"><p>Paragraph<p>Another <span>unclosed</div>
+<ul><li>Item<li>Item</ul>
+<h1 id="top">Two</h1>
+<br/><img src="x.png"></img>
+</section>
+<div id="main"><div id="main"></div>
+</body></html>`)
+
+	expected := Result{
+		Unclosed:     []string{"span", "div"},
+		Stray:        []string{"section"},
+		DuplicateIDs: []string{"top", "main"},
+		H1s:          2,
+	}
+
+	result := Check(body)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+	if result.Issues() != 6 {
+		t.Errorf("Expected 6 issues, got %d", result.Issues())
+	}
+}
+
+func TestCheckWellFormed(t *testing.T) {
+	body := []byte(`<html><body><h1>Only</h1><table><tr><td>Cell<td>Cell</table></body></html>`)
+	if result := Check(body); result.Issues() != 0 {
+		t.Errorf("Expected no issues, got %+v", result)
+	}
+}
@@ -0,0 +1,81 @@
+// Package wayback looks up and requests Internet Archive snapshots of pages
+package wayback
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Snapshot describes the closest archived copy of a page
+type Snapshot struct {
+	Available bool   `json:"available"`
+	URL       string `json:"url"`
+	Timestamp string `json:"timestamp"`
+	Status    string `json:"status"`
+}
+
+// Client talks to the Wayback Machine's availability and Save Page Now APIs
+type Client struct {
+	// Internal http Client
+	client *http.Client
+
+	availabilityURL string
+	saveURL         string
+}
+
+// New will construct a new Client
+// If no http.Client is provided, we'll use the default one
+func New(client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Client{
+		client,
+		"https://archive.org/wayback/available",
+		"https://web.archive.org/save/",
+	}
+}
+
+// Lookup finds the closest snapshot of pageURL, if one exists
+func (wayback *Client) Lookup(pageURL string) (Snapshot, error) {
+	response, err := wayback.client.Get(wayback.availabilityURL + "?url=" + url.QueryEscape(pageURL))
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Snapshot{}, fmt.Errorf("wayback: lookup of %s returned %d", pageURL, response.StatusCode)
+	}
+
+	var availability struct {
+		ArchivedSnapshots struct {
+			Closest *Snapshot `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&availability); err != nil {
+		return Snapshot{}, err
+	}
+
+	if availability.ArchivedSnapshots.Closest == nil {
+		return Snapshot{}, nil
+	}
+	return *availability.ArchivedSnapshots.Closest, nil
+}
+
+// Save asks the Wayback Machine to archive pageURL now
+func (wayback *Client) Save(pageURL string) error {
+	response, err := wayback.client.Get(wayback.saveURL + pageURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode > 399 || response.StatusCode < 200 {
+		return fmt.Errorf("wayback: saving %s returned %d", pageURL, response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package wayback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") == "http://example.com/" {
+			w.Write([]byte(`{"archived_snapshots": {"closest": {"available": true, "url": "http://web.archive.org/web/20200101000000/http://example.com/", "timestamp": "20200101000000", "status": "200"}}}`))
+			return
+		}
+		w.Write([]byte(`{"archived_snapshots": {}}`))
+	}))
+	defer server.Close()
+
+	wayback := New(server.Client())
+	wayback.availabilityURL = server.URL
+
+	snapshot, err := wayback.Lookup("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.Available || snapshot.Timestamp != "20200101000000" {
+		t.Errorf("Expected an available snapshot, got %+v", snapshot)
+	}
+
+	snapshot, err = wayback.Lookup("http://example.com/never-archived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Available {
+		t.Errorf("Expected no snapshot, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// queryParameterUsage is how one query parameter is used across a host
+type queryParameterUsage struct {
+	host string
+	name string
+
+	urls    int
+	values  map[string]bool
+	example string
+}
+
+// queryParameterInventory counts the query parameters in every URL linked to, per host, so they can
+// be added to -canonicalize drop-query steps or a search console's parameter handling
+type queryParameterInventory struct {
+	canonical canonical.Chain
+
+	mutex  sync.Mutex
+	seen   map[string]bool
+	usages map[string]*queryParameterUsage
+
+	report *report.Report
+}
+
+func newQueryParameterInventory(chain canonical.Chain) *queryParameterInventory {
+	return &queryParameterInventory{
+		canonical: chain,
+		seen:      make(map[string]bool),
+		usages:    make(map[string]*queryParameterUsage),
+		report:    report.New("query-parameters", "host", "parameter", "urls", "distinct values", "example", "dropped"),
+	}
+}
+
+func (inventory *queryParameterInventory) observe(crawled page, crawlErr error) {
+	inventory.mutex.Lock()
+	defer inventory.mutex.Unlock()
+
+	inventory.add(crawled.URL)
+	if crawlErr != nil {
+		return
+	}
+	for _, link := range crawled.links {
+		inventory.add(link.URL)
+	}
+}
+
+func (inventory *queryParameterInventory) add(link url.URL) {
+	if link.RawQuery == "" || !crawlable(link) {
+		return
+	}
+
+	// Links are seen both as written and canonicalized, which may encode the query differently
+	query := link.Query()
+	key := link.Host + link.Path + "?" + query.Encode()
+	if inventory.seen[key] {
+		return
+	}
+	inventory.seen[key] = true
+
+	for name, values := range query {
+		usageKey := link.Hostname() + "?" + name
+		usage, ok := inventory.usages[usageKey]
+		if !ok {
+			usage = &queryParameterUsage{host: link.Hostname(), name: name, values: make(map[string]bool), example: link.String()}
+			inventory.usages[usageKey] = usage
+		}
+
+		usage.urls++
+		for _, value := range values {
+			usage.values[value] = true
+		}
+	}
+}
+
+// summarize reports every host's parameters, the most used first
+// Parameters the canonicalization already drops are marked, so the rest are the ones left to decide on
+func (inventory *queryParameterInventory) summarize() {
+	inventory.mutex.Lock()
+	defer inventory.mutex.Unlock()
+
+	usages := make([]*queryParameterUsage, 0, len(inventory.usages))
+	for _, usage := range inventory.usages {
+		usages = append(usages, usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].host != usages[j].host {
+			return usages[i].host < usages[j].host
+		}
+		if usages[i].urls != usages[j].urls {
+			return usages[i].urls > usages[j].urls
+		}
+		return usages[i].name < usages[j].name
+	})
+
+	for _, usage := range usages {
+		example, _ := url.Parse(usage.example)
+		canonicalized := inventory.canonical.Apply(*example)
+		_, kept := canonicalized.Query()[usage.name]
+
+		inventory.report.Add(usage.host, usage.name, strconv.Itoa(usage.urls), strconv.Itoa(len(usage.values)),
+			usage.example, strconv.FormatBool(!kept))
+	}
+}
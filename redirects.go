@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/redirects"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// The most redirects followed for a single request, same as net/http's default
+const maxRedirects int = 10
+
+// redirectLoopError is returned when a redirect leads back to a URL already visited in the same chain
+type redirectLoopError struct {
+	chain []string
+}
+
+func (err *redirectLoopError) Error() string {
+	return fmt.Sprintf("redirect loop %s", strings.Join(err.chain, " -> "))
+}
+
+// checkRedirect is the http.Client CheckRedirect policy, stopping at loops rather than going around them
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	chain := make([]string, 0, len(via)+1)
+	for _, previous := range via {
+		chain = append(chain, previous.URL.String())
+	}
+	chain = append(chain, req.URL.String())
+
+	for _, previous := range via {
+		if previous.URL.String() == req.URL.String() {
+			return &redirectLoopError{chain}
+		}
+	}
+
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
+
+// redirectChain lists every URL a response passed through, from the one requested to the final one
+func redirectChain(response *http.Response) []string {
+	var chain []string
+	for req := response.Request; req != nil; {
+		chain = append([]string{req.URL.String()}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return chain
+}
+
+// redirectTracker reports redirect loops, chains with too many hops, and redirects hidden in page bodies
+type redirectTracker struct {
+	// Chains with more hops than this are reported
+	maxHops int
+
+	// Loops are retried like any other failure, so only report each once
+	mutex sync.Mutex
+	loops map[string]bool
+
+	report *report.Report
+}
+
+func newRedirectTracker(maxHops int) *redirectTracker {
+	return &redirectTracker{
+		maxHops: maxHops,
+		loops:   make(map[string]bool),
+		report:  report.New("redirects", "url", "kind", "hops", "chain", "linked from"),
+	}
+}
+
+func (tracker *redirectTracker) observe(crawled page, crawlErr error) {
+	referrer := ""
+	if crawled.referrer.Hostname() != "" {
+		referrer = crawled.referrer.String()
+	}
+
+	var loop *redirectLoopError
+	if errors.As(crawlErr, &loop) {
+		tracker.mutex.Lock()
+		seen := tracker.loops[crawled.String()]
+		tracker.loops[crawled.String()] = true
+		tracker.mutex.Unlock()
+
+		if !seen {
+			tracker.report.Add(crawled.String(), "loop", strconv.Itoa(len(loop.chain)-1), strings.Join(loop.chain, " -> "), referrer)
+		}
+		return
+	}
+	if crawlErr != nil {
+		return
+	}
+
+	if hops := len(crawled.redirects) - 1; hops > tracker.maxHops {
+		tracker.report.Add(crawled.String(), "long-chain", strconv.Itoa(hops), strings.Join(crawled.redirects, " -> "), referrer)
+	}
+
+	for _, pseudo := range redirects.Find(crawled.body) {
+		target := pseudo.Target
+		if resolved, err := crawled.final.Parse(target); err == nil {
+			target = resolved.String()
+		}
+		tracker.report.Add(crawled.String(), pseudo.Kind, "1", crawled.final.String()+" -> "+target, referrer)
+	}
+}
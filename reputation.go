@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/reputation"
+)
+
+// reputationChecker looks up the targets of outbound links and reports pages linking somewhere known to be
+// malicious or parked
+type reputationChecker struct {
+	sources []reputation.Source
+	scope   *crawlScope
+
+	// Threat of every link looked up so far, empty when it's clean
+	mutex   sync.Mutex
+	threats map[string]string
+
+	links   chan []website
+	dropped droppedWork
+	report  *report.Report
+}
+
+// newReputationChecker checks links against a local blocklist file and/or Safe Browsing, whichever are given
+func newReputationChecker(client *http.Client, scope *crawlScope, blocklistPath string, safeBrowsingKey string, queueSize int) (*reputationChecker, error) {
+	var sources []reputation.Source
+	if blocklistPath != "" {
+		lines, err := readLines(blocklistPath)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, reputation.ParseBlocklist(lines))
+	}
+	if safeBrowsingKey != "" {
+		sources = append(sources, reputation.NewSafeBrowsing(client, safeBrowsingKey))
+	}
+
+	checker := &reputationChecker{
+		sources: sources,
+		scope:   scope,
+		threats: make(map[string]string),
+		links:   make(chan []website, queueSize),
+		report:  report.New("link-reputation", "url", "threat", "linked from"),
+	}
+
+	go checker.run()
+	return checker, nil
+}
+
+func (checker *reputationChecker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	var outbound []website
+	for _, link := range crawled.links {
+		if checker.outbound(crawled.URL, link.URL) {
+			outbound = append(outbound, link)
+		}
+	}
+
+	if len(outbound) == 0 {
+		return
+	}
+	select {
+	case checker.links <- outbound:
+	default:
+		checker.dropped.add()
+	}
+}
+
+// outbound is true for links leaving the crawl, or just leaving the page's host when everything is in scope
+func (checker *reputationChecker) outbound(from url.URL, link url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+	if checker.scope.mode == scopeAll {
+		return !strings.EqualFold(from.Hostname(), link.Hostname())
+	}
+	return !checker.scope.contains(link)
+}
+
+func (checker *reputationChecker) run() {
+	for links := range checker.links {
+		checker.lookup(links)
+
+		checker.mutex.Lock()
+		for _, link := range links {
+			if threat := checker.threats[link.String()]; threat != "" {
+				checker.report.Add(link.String(), threat, link.referrer.String())
+			}
+		}
+		checker.mutex.Unlock()
+	}
+}
+
+// lookup asks every source about the links that haven't been looked up yet
+func (checker *reputationChecker) lookup(links []website) {
+	checker.mutex.Lock()
+	var unknown []string
+	for _, link := range links {
+		if _, ok := checker.threats[link.String()]; !ok {
+			checker.threats[link.String()] = ""
+			unknown = append(unknown, link.String())
+		}
+	}
+	checker.mutex.Unlock()
+
+	if len(unknown) == 0 {
+		return
+	}
+
+	for _, source := range checker.sources {
+		threats, err := source.Lookup(unknown)
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		checker.mutex.Lock()
+		for link, threat := range threats {
+			if checker.threats[link] == "" {
+				checker.threats[link] = threat
+			}
+		}
+		checker.mutex.Unlock()
+	}
+}
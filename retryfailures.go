@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// retryFailuresArgs turns `grawler retry-failures <crawl.db> [flags]` into the equivalent crawl,
+// `grawler -db <crawl.db> -failedOnly [flags]`
+// Pages that succeed are done with, those that fail again are set aside in the database once more
+func retryFailuresArgs(args []string) []string {
+	if len(args) < 3 || strings.HasPrefix(args[2], "-") {
+		fmt.Println("usage: grawler retry-failures <crawl.db> [flags]")
+		os.Exit(exitFatal)
+	}
+
+	retryArgs := []string{args[0], "-db", args[2], "-failedOnly"}
+	return append(retryArgs, args[3:]...)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/robotshistory"
+)
+
+// robotsAudit keeps the evidence of how robots.txt was obeyed on every host, see -robotsAudit
+// Once the crawl is done each host gets a row with the rules applied to it, how many URLs each rule
+// turned away, and the Crawl-delay it asked for next to the one actually used
+type robotsAudit struct {
+	agent string
+
+	mutex   sync.Mutex
+	crawled map[string]int
+	denied  map[string]map[string]int
+	slow    map[string]int
+
+	// Only summarized once the subscription has caught up
+	caughtUp caughtUp
+
+	report *report.Report
+}
+
+func newRobotsAudit(agent string) *robotsAudit {
+	return &robotsAudit{
+		agent:    agent,
+		crawled:  make(map[string]int),
+		denied:   make(map[string]map[string]int),
+		slow:     make(map[string]int),
+		caughtUp: newCaughtUp(),
+		report: report.New("robots-audit", "host", "user-agent", "directives", "crawled", "skipped by rules",
+			"skipped by directive", "skipped for crawl-delay", "requested delay", "delay used", "sitemaps"),
+	}
+}
+
+func (audit *robotsAudit) watch(events <-chan crawlEvent) {
+	for event := range events {
+		audit.mutex.Lock()
+		switch event := event.(type) {
+		case fetchCompleted:
+			audit.crawled[event.crawled.Hostname()]++
+		case robotsDenied:
+			host := event.site.Hostname()
+			if event.slow {
+				audit.slow[host]++
+				break
+			}
+			if audit.denied[host] == nil {
+				audit.denied[host] = make(map[string]int)
+			}
+			// Rules only match whole paths, so the path turned away names the directive that did it
+			audit.denied[host]["Disallow: "+event.site.Path]++
+		}
+		audit.mutex.Unlock()
+		audit.caughtUp.see(event)
+	}
+}
+
+// summarize fills the report with a row for every host whose robots.txt rules were loaded, sorted by host
+func (audit *robotsAudit) summarize(view robots.RulesView) {
+	audit.caughtUp.wait()
+
+	audit.mutex.Lock()
+	defer audit.mutex.Unlock()
+
+	for _, host := range view.Domains() {
+		rules, ok := view.Get(host)
+		if !ok {
+			continue
+		}
+
+		skipped, directives := 0, make([]string, 0, len(audit.denied[host]))
+		for directive, count := range audit.denied[host] {
+			skipped += count
+			directives = append(directives, fmt.Sprintf("%s (%d)", directive, count))
+		}
+		sort.Strings(directives)
+
+		delayUsed := rules.Delay.String()
+		if audit.slow[host] > 0 {
+			delayUsed = "skipped"
+		}
+
+		audit.report.Add(host, audit.agent, strings.Join(robotshistory.Lines(rules), "; "), strconv.Itoa(audit.crawled[host]),
+			strconv.Itoa(skipped), strings.Join(directives, "; "), strconv.Itoa(audit.slow[host]),
+			rules.RequestedDelay.String(), delayUsed, strings.Join(rules.Sitemaps, " "))
+	}
+}
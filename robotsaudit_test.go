@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jrokun/crawler/pkg/robots"
+)
+
+// robotsTransport answers every request with the same robots.txt
+type robotsTransport string
+
+func (body robotsTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain"}},
+		Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+		Request:    request,
+	}, nil
+}
+
+func TestRobotsAuditCatchesUp(t *testing.T) {
+	rules := robots.NewRulesIndex(&http.Client{Transport: robotsTransport("User-agent: *\nDisallow: /private\nCrawl-delay: 2\n")})
+	if _, err := rules.Get("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	audit := newRobotsAudit(robots.DefaultAgent)
+
+	bus := newEventBus()
+	events, err := bus.subscribe(1000, overflowBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go audit.watch(events)
+
+	for i := 0; i < 300; i++ {
+		bus.publish(fetchCompleted{crawled: crawledPage(t, "http://example.com/")})
+		bus.publish(robotsDenied{site: crawledPage(t, "http://example.com/private").website})
+	}
+	bus.publish(robotsDenied{site: crawledPage(t, "http://example.com/slow").website, slow: true})
+	bus.publish(crawlFinished{observed: make(chan struct{})})
+	audit.summarize(rules.View())
+
+	rows := audit.report.Rows()
+	if len(rows) != 1 {
+		t.Fatalf("Expected a row for the one host, got %v", rows)
+	}
+	expected := []string{"example.com", robots.DefaultAgent, "Disallow: /private", "300", "300", "Disallow: /private (300)", "1", "2s", "skipped", ""}
+	if !reflect.DeepEqual(rows[0], expected) {
+		t.Errorf("Expected every event published before the finish to be counted in %v, got %v", expected, rows[0])
+	}
+}
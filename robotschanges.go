@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/notify"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/robotshistory"
+)
+
+// robotsChangeTracker notes the paths crawled and turned away on every host, then once the crawl is done
+// compares each host's robots.txt rules with those the last crawl saw, see -robotsChanges
+// Rules newly blocking paths crawled before, or allowing paths turned away before, are alerted on
+type robotsChangeTracker struct {
+	store    *robotshistory.Store
+	notifier notify.Notifier
+
+	mutex   sync.Mutex
+	crawled map[string]robots.Set
+	denied  map[string]robots.Set
+
+	// Only compared once the subscription has caught up
+	caughtUp caughtUp
+
+	report *report.Report
+}
+
+func newRobotsChangeTracker(store *robotshistory.Store, notifier notify.Notifier) *robotsChangeTracker {
+	return &robotsChangeTracker{
+		store:    store,
+		notifier: notifier,
+		crawled:  make(map[string]robots.Set),
+		denied:   make(map[string]robots.Set),
+		caughtUp: newCaughtUp(),
+		report:   report.New("robots-changes", "host", "change", "paths", "rules added", "rules removed"),
+	}
+}
+
+func (tracker *robotsChangeTracker) watch(events <-chan crawlEvent) {
+	for event := range events {
+		switch event := event.(type) {
+		case fetchCompleted:
+			tracker.note(tracker.crawled, event.crawled.Hostname(), event.crawled.Path)
+		case robotsDenied:
+			if event.slow {
+				continue
+			}
+			tracker.note(tracker.denied, event.site.Hostname(), event.site.Path)
+		}
+		tracker.caughtUp.see(event)
+	}
+}
+
+func (tracker *robotsChangeTracker) note(paths map[string]robots.Set, host string, path string) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if paths[host] == nil {
+		paths[host] = make(robots.Set)
+	}
+	paths[host][path] = true
+}
+
+// compare checks the rules of every host the crawl fetched them for against the last crawl's, then
+// remembers them for the next
+func (tracker *robotsChangeTracker) compare(view robots.RulesView) {
+	tracker.caughtUp.wait()
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	for _, host := range view.Domains() {
+		rules, ok := view.Get(host)
+		if !ok {
+			continue
+		}
+		previous, found, err := tracker.store.Previous(host)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		if found {
+			tracker.reportChange(host, robotshistory.Compare(previous, rules))
+		}
+
+		current := robotshistory.Snapshot{
+			Host:     host,
+			Rules:    robotshistory.Lines(rules),
+			Crawled:  setItems(tracker.crawled[host]),
+			Denied:   setItems(tracker.denied[host]),
+			Recorded: time.Now(),
+		}
+		if err := tracker.store.Save(robotshistory.Merge(previous, current)); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func (tracker *robotsChangeTracker) reportChange(host string, change robotshistory.Change) {
+	if !change.Changed() {
+		return
+	}
+	added, removed := strings.Join(change.Added, "; "), strings.Join(change.Removed, "; ")
+
+	if len(change.Blocked) == 0 && len(change.Unblocked) == 0 {
+		tracker.report.Add(host, "rules changed", "", added, removed)
+		return
+	}
+	for _, affected := range []struct {
+		change string
+		paths  []string
+	}{
+		{"blocked", change.Blocked},
+		{"unblocked", change.Unblocked},
+	} {
+		if len(affected.paths) == 0 {
+			continue
+		}
+		tracker.report.Add(host, affected.change, strings.Join(affected.paths, " "), added, removed)
+
+		message := fmt.Sprintf("robots.txt now %s %d paths: %s", affected.change, len(affected.paths), strings.Join(affected.paths, " "))
+		if err := tracker.notifier.Notify(host, message); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func setItems(set robots.Set) []string {
+	items := make([]string, 0, len(set))
+	for item := range set {
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// robotsConflictChecker reports pages whose X-Robots-Tag header and meta robots tag contradict each other
+// Crawlers obey the more restrictive of the two, which is rarely what whoever set the other one intended
+type robotsConflictChecker struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newRobotsConflictChecker() *robotsConflictChecker {
+	return &robotsConflictChecker{
+		seen:   make(map[string]bool),
+		report: report.New("robots-conflicts", "url", "header says", "meta says", "x-robots-tag", "meta robots"),
+	}
+}
+
+func (checker *robotsConflictChecker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	headerValues := crawled.header["X-Robots-Tag"]
+	if len(headerValues) == 0 {
+		return
+	}
+
+	checker.mutex.Lock()
+	seen := checker.seen[crawled.String()]
+	checker.seen[crawled.String()] = true
+	checker.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	header, meta := extract.HeaderRobots(headerValues), extract.MetaRobots(crawled.body)
+	for _, conflict := range extract.RobotsConflicts(header, meta) {
+		checker.report.Add(crawled.String(), conflict.Header, conflict.Meta, strings.Join(headerValues, "; "), strings.Join(meta, ", "))
+	}
+}
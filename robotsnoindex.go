@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/robots"
+)
+
+// robotsNoindexChecker reports pages a Noindex line in robots.txt covers that are indexable all the
+// same, with no noindex in a meta robots tag or X-Robots-Tag header
+// Search engines stopped honoring Noindex in robots.txt, so these pages end up in results the site
+// meant to keep them out of
+type robotsNoindexChecker struct {
+	// The crawl's own robots.txt rules, read while vetting pages before they're crawled
+	rules robots.RulesView
+
+	report *report.Report
+}
+
+func newRobotsNoindexChecker(c *crawler) *robotsNoindexChecker {
+	return &robotsNoindexChecker{
+		rules:  c.rules.View(),
+		report: report.New("robots-noindex", "url", "noindex rule", "status"),
+	}
+}
+
+func (checker *robotsNoindexChecker) watch(events <-chan crawlEvent) {
+	for event := range events {
+		if completed, ok := event.(fetchCompleted); ok && !completed.crawled.revisit {
+			checker.check(completed.crawled)
+		}
+	}
+}
+
+func (checker *robotsNoindexChecker) check(crawled page) {
+	if crawled.status < 200 || crawled.status > 299 || !crawled.html {
+		return
+	}
+
+	rules, ok := checker.rules.Get(crawled.Hostname())
+	if !ok {
+		return
+	}
+	rule, ok := rules.Noindex(crawled.URL.RequestURI())
+	if !ok {
+		return
+	}
+
+	directives := append(extract.HeaderRobots(crawled.header["X-Robots-Tag"]), extract.MetaRobots(crawled.body)...)
+	for _, directive := range directives {
+		if directive == "noindex" || directive == "none" {
+			return
+		}
+	}
+	checker.report.Add(crawled.String(), "Noindex: "+rule, strconv.Itoa(crawled.status))
+}
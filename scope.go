@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Scope modes, deciding which discovered links are crawled
+const (
+	scopeAll    string = "all"
+	scopeHost   string = "host"
+	scopeDomain string = "domain"
+)
+
+// crawlScope decides whether a link is part of the crawl, based on the seeds it started from
+type crawlScope struct {
+	mode string
+
+	// Hosts (or domains) of the seeds
+	seeds map[string]bool
+}
+
+func newCrawlScope(mode string, seeds []url.URL) (*crawlScope, error) {
+	switch mode {
+	case scopeAll, scopeHost, scopeDomain:
+	default:
+		return nil, fmt.Errorf("unknown scope %q, expected one of %s, %s or %s", mode, scopeAll, scopeHost, scopeDomain)
+	}
+
+	scope := &crawlScope{mode: mode, seeds: make(map[string]bool)}
+	for _, seed := range seeds {
+		scope.seeds[scope.key(seed)] = true
+	}
+	return scope, nil
+}
+
+// contains is true when the link should be crawled rather than treated as external
+func (scope *crawlScope) contains(link url.URL) bool {
+	if scope.mode == scopeAll {
+		return true
+	}
+	return scope.seeds[scope.key(link)]
+}
+
+func (scope *crawlScope) key(link url.URL) string {
+	host := strings.ToLower(link.Hostname())
+	if scope.mode != scopeDomain {
+		return host
+	}
+
+	// The registrable domain, going by the public suffix list, so a.example.co.uk and b.example.co.uk
+	// share example.co.uk rather than co.uk
+	// Hosts without one, like localhost and IP addresses, are their own domain
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jrokun/crawler/pkg/headless"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// screenshotter saves a screenshot of every crawled page for visual audits
+type screenshotter struct {
+	browser *headless.Browser
+
+	// Where screenshots are saved, and where the HTML report referencing them lives
+	dir       string
+	reportDir string
+
+	report *report.Report
+}
+
+func newScreenshotter(browser *headless.Browser, dir string, reportDir string) (*screenshotter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	shots := &screenshotter{
+		browser:   browser,
+		dir:       dir,
+		reportDir: reportDir,
+		report:    report.New("screenshots", "url", "screenshot"),
+	}
+	shots.report.Images = []string{"screenshot"}
+
+	return shots, nil
+}
+
+func (shots *screenshotter) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	file := filepath.Join(shots.dir, sink.NodeID(crawled.URL)+".png")
+	if err := shots.browser.Screenshot(crawled.String(), file); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The HTML report links to screenshots relative to itself
+	if relative, err := filepath.Rel(shots.reportDir, file); err == nil {
+		file = relative
+	}
+	shots.report.Add(crawled.String(), filepath.ToSlash(file))
+}
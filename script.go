@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/score"
+	"github.com/jrokun/crawler/pkg/script"
+)
+
+// scriptHooks plugs a user's Starlark script into the crawl
+// extract() results are collected into a report, score() ranks the links to crawl
+type scriptHooks struct {
+	hooks  *script.Hooks
+	report *report.Report
+
+	// Pages converted for score(), by URL, as it's called for every link on a page
+	mutex    sync.Mutex
+	prepared map[string]script.Prepared
+}
+
+// Prepared pages are forgotten in bulk once there are this many, there's usually only one per worker
+const scriptPageCacheSize = 32
+
+func newScriptHooks(path string) (*scriptHooks, error) {
+	hooks, err := script.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scriptHooks{
+		hooks:    hooks,
+		report:   report.New("extracted", "url", "name", "value"),
+		prepared: make(map[string]script.Prepared),
+	}, nil
+}
+
+func (s *scriptHooks) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || !s.hooks.CanExtract() {
+		return
+	}
+
+	values, err := s.hooks.Extract(scriptPage(crawled))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s.report.Add(crawled.String(), name, values[name])
+	}
+}
+
+// score is the script's score() as a score.Func
+// Links score as neutral if the script fails, so a bug in a script can't silently stop a crawl
+func (s *scriptHooks) score(from score.Page, link score.Link) float64 {
+	if !s.hooks.CanScore() {
+		return score.Neutral
+	}
+
+	scored, err := s.hooks.Score(s.prepare(from), link.URL.String())
+	if err != nil {
+		fmt.Println(err)
+		return score.Neutral
+	}
+	return scored
+}
+
+// prepare converts a page for score() the first time one of its links is scored
+func (s *scriptHooks) prepare(from score.Page) script.Prepared {
+	key := from.URL.String()
+
+	s.mutex.Lock()
+	prepared, ok := s.prepared[key]
+	s.mutex.Unlock()
+	if ok {
+		return prepared
+	}
+
+	prepared = script.Prepare(scoredPage(from))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.prepared) >= scriptPageCacheSize {
+		s.prepared = make(map[string]script.Prepared)
+	}
+	s.prepared[key] = prepared
+	return prepared
+}
+
+func scriptPage(crawled page) script.Page {
+	links := make([]string, len(crawled.links))
+	for i, link := range crawled.links {
+		links[i] = link.String()
+	}
+
+	scripted := script.Page{
+		URL:    crawled.String(),
+		Status: crawled.status,
+		Header: crawled.header,
+		Body:   crawled.body,
+		Links:  links,
+	}
+	if crawled.referrer.Hostname() != "" {
+		scripted.Referrer = crawled.referrer.String()
+	}
+	return scripted
+}
+
+func scoredPage(from score.Page) script.Page {
+	links := make([]string, len(from.Links))
+	for i, link := range from.Links {
+		links[i] = link.URL.String()
+	}
+
+	scripted := script.Page{
+		URL:    from.URL.String(),
+		Status: from.Status,
+		Header: from.Header,
+		Body:   from.Body,
+		Links:  links,
+	}
+	if from.Referrer.Hostname() != "" {
+		scripted.Referrer = from.Referrer.String()
+	}
+	return scripted
+}
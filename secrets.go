@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/secrets"
+)
+
+// secretScanner reports credentials and personal data leaked in page bodies
+// Matches are redacted in the report so it doesn't become a leak of its own
+type secretScanner struct {
+	rules []secrets.Rule
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+// readSecretRules reads extra rules, one name=pattern per line, from a list file
+func readSecretRules(path string) ([]secrets.Rule, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []secrets.Rule
+	for _, line := range lines {
+		rule, err := secrets.ParseRule(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newSecretScanner(rules []secrets.Rule) *secretScanner {
+	return &secretScanner{
+		rules:  rules,
+		seen:   make(map[string]bool),
+		report: report.New("secrets", "url", "rule", "match", "snippet"),
+	}
+}
+
+func (scanner *secretScanner) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	scanner.mutex.Lock()
+	seen := scanner.seen[crawled.String()]
+	scanner.seen[crawled.String()] = true
+	scanner.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	for _, match := range secrets.Scan(crawled.body, scanner.rules) {
+		scanner.report.Add(crawled.String(), match.Rule, match.Redacted, match.Snippet)
+	}
+}
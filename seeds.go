@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/cdx"
+	"github.com/jrokun/crawler/pkg/score"
+)
+
+// indexedSeeds asks a CDX index for up to limit URLs it knows of on the start URL's host, see -discoverSeeds
+// Failing to is only worth a mention, the crawl can still start from the start URL
+func indexedSeeds(client *http.Client, index string, start url.URL, limit int) []website {
+	// Index queries are slow, far slower than the pages being crawled
+	patient := *client
+	patient.Timeout = time.Minute
+
+	urls, err := cdx.New(&patient, index).URLs(start.Host, limit)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+
+	seeds := make([]website, 0, len(urls))
+	for _, indexed := range urls {
+		parsed, err := url.Parse(indexed)
+		if err != nil || !crawlable(*parsed) {
+			continue
+		}
+		seeds = append(seeds, website{score: score.Neutral, URL: *parsed})
+	}
+	fmt.Printf("Found %d more urls to start from in %s\n", len(seeds), index)
+	return seeds
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sync/atomic"
+)
+
+// sideRequests decides where checkers and probes, which make requests of their own on the side of the
+// crawl, may send them: only where vetting would let the crawl itself go, in scope and allowed by
+// -allow-domains and -block-domains
+type sideRequests struct {
+	scope *crawlScope
+
+	// Optional
+	domains *domainFilter
+}
+
+func (side sideRequests) allows(target url.URL) bool {
+	if side.domains != nil && !side.domains.allows(target.Hostname()) {
+		return false
+	}
+	return side.scope == nil || side.scope.contains(target)
+}
+
+// droppedWork counts what a checker's observer had to drop because its workers were behind, since
+// observers run one after another and a blocked one would hold up every other
+type droppedWork struct {
+	count int64
+}
+
+func (dropped *droppedWork) add() {
+	atomic.AddInt64(&dropped.count, 1)
+}
+
+// summarize prints how much was dropped, if anything, meant for finalizers
+func (dropped *droppedWork) summarize(what string) {
+	if count := atomic.LoadInt64(&dropped.count); count > 0 {
+		fmt.Printf("Dropped %d %s, the workers couldn't keep up\n", count, what)
+	}
+}
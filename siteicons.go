@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// newHostsReport is the per-host report, filled in by the site icon checker and the well-known prober
+func newHostsReport() *report.Report {
+	return report.New("hosts", "host", "asset", "url", "declared on", "status", "error", "contents")
+}
+
+// siteIconChecker finds the favicons, apple-touch-icons and web app manifest of every host, from the
+// first page crawled there, and checks that they resolve
+type siteIconChecker struct {
+	client *http.Client
+	side   sideRequests
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	pages   chan page
+	dropped droppedWork
+	report  *report.Report
+}
+
+func newSiteIconChecker(client *http.Client, side sideRequests, hosts *report.Report, queueSize int) *siteIconChecker {
+	checker := &siteIconChecker{
+		client: client,
+		side:   side,
+		seen:   make(map[string]bool),
+		pages:  make(chan page, queueSize),
+		report: hosts,
+	}
+
+	go checker.run()
+	return checker
+}
+
+func (checker *siteIconChecker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || crawled.status != 200 {
+		return
+	}
+
+	checker.mutex.Lock()
+	seen := checker.seen[crawled.Host]
+	checker.seen[crawled.Host] = true
+	checker.mutex.Unlock()
+
+	if seen {
+		return
+	}
+
+	select {
+	case checker.pages <- crawled:
+	default:
+		// Left for another page of the host to try again
+		checker.dropped.add()
+		checker.mutex.Lock()
+		delete(checker.seen, crawled.Host)
+		checker.mutex.Unlock()
+	}
+}
+
+func (checker *siteIconChecker) run() {
+	for crawled := range checker.pages {
+		icons := extract.Icons(crawled.body)
+
+		// Browsers ask for /favicon.ico when a page doesn't declare a favicon, so it had better be there
+		declaresFavicon := false
+		for _, icon := range icons {
+			declaresFavicon = declaresFavicon || icon.Kind == extract.IconFavicon
+		}
+		if !declaresFavicon {
+			icons = append(icons, extract.Icon{Kind: extract.IconFavicon, URL: "/favicon.ico"})
+		}
+
+		declaredOn := crawled.String()
+		for _, icon := range icons {
+			resolved, err := crawled.final.Parse(icon.URL)
+			if err != nil {
+				checker.report.Add(crawled.Host, icon.Kind, icon.URL, declaredOn, "", err.Error(), "")
+				continue
+			}
+			if resolved.Scheme == "data" {
+				checker.report.Add(crawled.Host, icon.Kind, "(inline data)", declaredOn, "", "", "")
+				continue
+			}
+			if !checker.side.allows(*resolved) {
+				checker.report.Add(crawled.Host, icon.Kind, resolved.String(), declaredOn, "", "not checked, outside the crawl", "")
+				continue
+			}
+
+			status, err := headCheck(checker.client, resolved.String())
+			if err != nil {
+				checker.report.Add(crawled.Host, icon.Kind, resolved.String(), declaredOn, "", err.Error(), "")
+				continue
+			}
+			checker.report.Add(crawled.Host, icon.Kind, resolved.String(), declaredOn, strconv.Itoa(status), "", "")
+		}
+	}
+}
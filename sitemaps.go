@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/sitemap"
+)
+
+// sitemapConflicts reports URLs listed in a site's sitemaps that robots.txt disallows, or that are
+// marked noindex by a meta robots tag or X-Robots-Tag header
+// Sitemaps are read in the background once the crawl has read a host's robots.txt. Only crawled pages can be
+// checked for noindex, and only listed URLs on hosts whose robots.txt the crawl has read for being disallowed.
+type sitemapConflicts struct {
+	client    *http.Client
+	scope     *crawlScope
+	canonical canonical.Chain
+
+	// The crawl's own robots.txt rules
+	rules robots.RulesView
+
+	// Only ever used from watch, so this doesn't need locking
+	checked map[string]bool
+
+	// Filled as sitemaps are read, with the crawled pages asking not to be indexed by URL, for sitemaps
+	// read after the pages were crawled
+	mutex     sync.Mutex
+	sitemaps  map[string]string
+	noindexed map[string]string
+
+	report *report.Report
+}
+
+func newSitemapConflicts(c *crawler) *sitemapConflicts {
+	return &sitemapConflicts{
+		client:    c.client,
+		scope:     c.scope,
+		canonical: c.canonical,
+		rules:     c.rules.View(),
+		checked:   make(map[string]bool),
+		sitemaps:  make(map[string]string),
+		noindexed: make(map[string]string),
+		report:    report.New("sitemap-conflicts", "url", "sitemap", "conflict"),
+	}
+}
+
+func (conflicts *sitemapConflicts) watch(events <-chan crawlEvent) {
+	for event := range events {
+		switch event := event.(type) {
+		case robotsDenied:
+			conflicts.checkHost(event.site.URL)
+		case fetchCompleted:
+			conflicts.checkHost(event.crawled.URL)
+			conflicts.checkPage(event.crawled)
+		}
+	}
+}
+
+// checkHost starts reading the sitemaps of a host once the crawl has its robots.txt
+func (conflicts *sitemapConflicts) checkHost(site url.URL) {
+	if conflicts.checked[site.Hostname()] || !conflicts.scope.contains(site) {
+		return
+	}
+	rules, ok := conflicts.rules.Get(site.Hostname())
+	if !ok {
+		return
+	}
+	conflicts.checked[site.Hostname()] = true
+
+	// Without a Sitemap directive, the conventional location is the best guess
+	locations := rules.Sitemaps
+	if len(locations) == 0 {
+		root := url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/sitemap.xml"}
+		locations = []string{root.String()}
+	}
+	go conflicts.readSitemaps(locations)
+}
+
+// readSitemaps reports every URL the sitemaps list that robots.txt won't let us crawl, or that was crawled
+// and asked not to be indexed
+func (conflicts *sitemapConflicts) readSitemaps(locations []string) {
+	entries, err := sitemap.Fetch(conflicts.client, locations)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	for _, entry := range entries {
+		listed, err := url.Parse(entry.URL)
+		if err != nil {
+			conflicts.report.Add(entry.URL, entry.Sitemap, "invalid URL")
+			continue
+		}
+		*listed = conflicts.canonical.Apply(*listed)
+
+		if rules, ok := conflicts.rules.Get(listed.Hostname()); ok && !rules.Test(listed.Path) {
+			conflicts.report.Add(listed.String(), entry.Sitemap, "disallowed by robots.txt")
+		}
+
+		conflicts.mutex.Lock()
+		conflicts.sitemaps[listed.String()] = entry.Sitemap
+		conflict, noindexed := conflicts.noindexed[listed.String()]
+		conflicts.mutex.Unlock()
+		if noindexed {
+			conflicts.report.Add(listed.String(), entry.Sitemap, conflict)
+		}
+	}
+}
+
+// checkPage reports a crawled page listed in a sitemap that asks not to be indexed, or remembers it for
+// sitemaps not read yet
+func (conflicts *sitemapConflicts) checkPage(crawled page) {
+	conflict := noindexConflict(crawled)
+	if conflict == "" {
+		return
+	}
+
+	conflicts.mutex.Lock()
+	listedIn, ok := conflicts.sitemaps[crawled.String()]
+	if !ok {
+		conflicts.noindexed[crawled.String()] = conflict
+	}
+	conflicts.mutex.Unlock()
+	if ok {
+		conflicts.report.Add(crawled.String(), listedIn, conflict)
+	}
+}
+
+// noindexConflict is how a crawled page asks not to be indexed, empty when it doesn't
+func noindexConflict(crawled page) string {
+	for _, directive := range extract.HeaderRobots(crawled.header["X-Robots-Tag"]) {
+		if directive == "noindex" || directive == "none" {
+			return "noindex in X-Robots-Tag header"
+		}
+	}
+	for _, directive := range extract.MetaRobots(crawled.body) {
+		if directive == "noindex" || directive == "none" {
+			return "noindex in meta robots"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/hoststats"
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/score"
+)
+
+// hostThrottle keeps a few slow or erroring hosts from taking up every worker
+// Links to bad hosts are scored down so healthier hosts are crawled first, and bad hosts are
+// crawled one page at a time instead of as many as the queue hands out
+type hostThrottle struct {
+	stats *hoststats.Tracker
+
+	mutex sync.Mutex
+	slots map[string]chan struct{}
+
+	report *report.Report
+}
+
+func newHostThrottle(limits hoststats.Limits) *hostThrottle {
+	return &hostThrottle{
+		stats:  hoststats.New(limits),
+		slots:  make(map[string]chan struct{}),
+		report: report.New("slow-hosts", "host", "requests", "failures", "average latency", "error rate", "problem"),
+	}
+}
+
+func (throttle *hostThrottle) observe(crawled page, crawlErr error) {
+	// Failures before any request was sent, like an unreachable robots.txt, say nothing about latency
+	if crawled.elapsed == 0 {
+		return
+	}
+
+	// Only failures worth retrying are the host's fault, a 404 is just a bad link
+	_, failed := crawlErr.(retryableError)
+	throttle.stats.Record(crawled.Hostname(), crawled.elapsed, failed)
+}
+
+// score deprioritizes links to bad hosts, the worse the host the lower the score
+func (throttle *hostThrottle) score(from score.Page, link score.Link) float64 {
+	return score.Neutral * throttle.stats.Penalty(link.URL.Hostname())
+}
+
+// acquire waits until a page of host may be crawled, call the returned func once it has been
+// Bad hosts get a single slot, everyone else is never held up
+func (throttle *hostThrottle) acquire(host string) func() {
+	if !throttle.stats.Bad(host) {
+		return func() {}
+	}
+
+	throttle.mutex.Lock()
+	slot, ok := throttle.slots[host]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		throttle.slots[host] = slot
+	}
+	throttle.mutex.Unlock()
+
+	slot <- struct{}{}
+	return func() { <-slot }
+}
+
+// summarize fills the report with every host that was slow or erroring by the end of the crawl
+func (throttle *hostThrottle) summarize() {
+	limits := throttle.stats.Limits
+	for _, host := range throttle.stats.Hosts() {
+		stats, _ := throttle.stats.Stats(host)
+
+		var problem string
+		switch {
+		case stats.Slow(limits) && stats.Erroring(limits):
+			problem = "slow, erroring"
+		case stats.Slow(limits):
+			problem = "slow"
+		case stats.Erroring(limits):
+			problem = "erroring"
+		default:
+			continue
+		}
+
+		throttle.report.Add(
+			host,
+			strconv.Itoa(stats.Requests),
+			strconv.Itoa(stats.Failures),
+			stats.Latency.Round(time.Millisecond).String(),
+			fmt.Sprintf("%.2f", stats.ErrorRate),
+			problem,
+		)
+	}
+}
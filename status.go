@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/revisit"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// How many upcoming revisits and past crawls the status page lists, and how many crawls a trend covers
+const (
+	statusUpcoming = 20
+	statusCrawls   = 10
+	statusTrend    = 30
+)
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"sparkline": sparkline,
+	"tags":      formatTags,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Grawler Status</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Grawler Status</h1>
+<p>{{.Health.State}} for {{.Health.Uptime}}, {{.Health.Crawled}} pages crawled, {{.Health.Frontier.Queued}} queued</p>
+<h2>Upcoming revisits</h2>
+{{if .Upcoming}}<table>
+<tr><th>due</th><th>url</th><th>interval</th><th>changes</th></tr>
+{{range .Upcoming}}<tr><td>{{.Due.Format "2006-01-02 15:04:05"}}</td><td>{{.URL}}</td><td>{{.Interval}}</td><td>{{.Changes}} of {{.Crawls}}</td></tr>
+{{end}}</table>{{else}}<p>None scheduled, recrawls are scheduled with -revisit</p>{{end}}
+<h2>Last crawls</h2>
+{{if .Crawls}}<table>
+<tr><th>crawl</th><th>started</th><th>pages</th><th>failed</th><th>tags</th></tr>
+{{range .Crawls}}<tr><td>{{.Name}}</td><td>{{.Started.Format "2006-01-02 15:04:05"}}</td><td>{{.Pages}}</td><td>{{.Failed}}</td><td>{{tags .Tags}}</td></tr>
+{{end}}</table>{{else}}<p>None stored, crawls are stored with -output-format bolt</p>{{end}}
+{{if .Trends}}<h2>Trends</h2>
+<table>
+<tr><th>site</th><th>crawls</th><th>pages</th><th>failed</th></tr>
+{{range .Trends}}<tr><td>{{.Site}}</td><td>{{len .Pages}}</td><td>{{sparkline .Pages "black"}} {{.LatestPages}}</td><td>{{sparkline .Failed "red"}} {{.LatestFailed}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// siteTrend is how a site's crawls went over time, oldest first
+type siteTrend struct {
+	Site   string
+	Pages  []int
+	Failed []int
+
+	LatestPages  int
+	LatestFailed int
+}
+
+// statusHandler serves /status, a page for people rather than probes, showing the crawl under way, the
+// pages -revisit is going to recrawl next, and how the crawls stored in -output-format bolt went, with
+// each site's pages and failures over time
+func statusHandler(h *health) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.mutex.RLock()
+		revisits, stored := h.revisits, h.stored
+		h.mutex.RUnlock()
+
+		var upcoming []revisit.Record
+		if revisits != nil {
+			var err error
+			if upcoming, err = revisits.Upcoming(time.Now(), statusUpcoming); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		var crawls []sink.Crawl
+		if stored != nil {
+			var err error
+			if crawls, err = stored.Crawls(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		trends := crawlTrends(crawls)
+
+		// Latest first, unlike the trends
+		latest := make([]sink.Crawl, 0, statusCrawls)
+		for i := len(crawls) - 1; i >= 0 && len(latest) < statusCrawls; i-- {
+			latest = append(latest, crawls[i])
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := statusTemplate.Execute(w, struct {
+			Health   healthReport
+			Upcoming []revisit.Record
+			Crawls   []sink.Crawl
+			Trends   []siteTrend
+		}{h.report(), upcoming, latest, trends})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// crawlTrends groups crawls, oldest first, by the site they're tagged with
+func crawlTrends(crawls []sink.Crawl) []siteTrend {
+	bySite := make(map[string]*siteTrend)
+	var sites []string
+	for _, crawl := range crawls {
+		site := crawl.Tags["site"]
+		if site == "" {
+			continue
+		}
+		trend, ok := bySite[site]
+		if !ok {
+			trend = &siteTrend{Site: site}
+			bySite[site] = trend
+			sites = append(sites, site)
+		}
+		trend.Pages = append(trend.Pages, crawl.Pages)
+		trend.Failed = append(trend.Failed, crawl.Failed)
+		trend.LatestPages, trend.LatestFailed = crawl.Pages, crawl.Failed
+	}
+	sort.Strings(sites)
+
+	trends := make([]siteTrend, 0, len(sites))
+	for _, site := range sites {
+		trend := bySite[site]
+		if len(trend.Pages) > statusTrend {
+			trend.Pages = trend.Pages[len(trend.Pages)-statusTrend:]
+			trend.Failed = trend.Failed[len(trend.Failed)-statusTrend:]
+		}
+		trends = append(trends, *trend)
+	}
+	return trends
+}
+
+// sparkline draws values as a small inline SVG line, scaled to the largest of them
+func sparkline(values []int, color string) template.HTML {
+	const width, height = 120, 24
+
+	largest := 1
+	for _, value := range values {
+		if value > largest {
+			largest = value
+		}
+	}
+
+	// A single crawl is drawn flat across, as there's nothing to join it to
+	if len(values) == 1 {
+		values = append(values, values[0])
+	}
+
+	points := make([]string, len(values))
+	for i, value := range values {
+		x := float64(i) * width / float64(len(values)-1)
+		y := height - float64(value)*(height-2)/float64(largest) - 1
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	return template.HTML(fmt.Sprintf(`<svg width="%d" height="%d" viewBox="0 0 %d %d"><polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/></svg>`,
+		width, height, width, height, template.HTMLEscapeString(color), strings.Join(points, " ")))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/extract"
+	"github.com/jrokun/crawler/pkg/fingerprint"
+	"github.com/jrokun/crawler/pkg/report"
+)
+
+// wordCount is how many words of text a page has, and the template it was built from
+type wordCount struct {
+	url      string
+	words    int
+	template string
+}
+
+// thinContentTracker counts the words on every page and flags the thin ones, grouped by template
+// A template full of thin pages usually means a whole section needs work, rather than a few pages
+type thinContentTracker struct {
+	// Pages with fewer words than this are thin
+	minWords int
+
+	mutex  sync.Mutex
+	seen   map[string]bool
+	counts []wordCount
+
+	report    *report.Report
+	templates *report.Report
+}
+
+func newThinContentTracker(minWords int) *thinContentTracker {
+	return &thinContentTracker{
+		minWords:  minWords,
+		seen:      make(map[string]bool),
+		report:    report.New("word-counts", "url", "words", "thin", "template"),
+		templates: report.New("thin-content", "template", "pages", "thin pages", "median words", "example"),
+	}
+}
+
+func (tracker *thinContentTracker) observe(crawled page, crawlErr error) {
+	if crawlErr != nil || crawled.status != 200 {
+		return
+	}
+
+	tracker.mutex.Lock()
+	seen := tracker.seen[crawled.String()]
+	tracker.seen[crawled.String()] = true
+	tracker.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	count := wordCount{
+		url:      crawled.String(),
+		words:    len(strings.Fields(extract.Text(crawled.body))),
+		template: fingerprint.Template(crawled.body)[:12],
+	}
+
+	tracker.mutex.Lock()
+	tracker.counts = append(tracker.counts, count)
+	tracker.mutex.Unlock()
+}
+
+// summarize reports every page's word count, then each template with thin pages, the thinnest first
+func (tracker *thinContentTracker) summarize() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	sort.Slice(tracker.counts, func(i, j int) bool {
+		if tracker.counts[i].template != tracker.counts[j].template {
+			return tracker.counts[i].template < tracker.counts[j].template
+		}
+		return tracker.counts[i].words < tracker.counts[j].words
+	})
+
+	byTemplate := make(map[string][]wordCount)
+	var templates []string
+	for _, count := range tracker.counts {
+		tracker.report.Add(count.url, strconv.Itoa(count.words), strconv.FormatBool(count.words < tracker.minWords), count.template)
+
+		if _, ok := byTemplate[count.template]; !ok {
+			templates = append(templates, count.template)
+		}
+		byTemplate[count.template] = append(byTemplate[count.template], count)
+	}
+
+	// Counts are sorted by words within a template, so the first is the thinnest and the middle the median
+	thin := func(template string) int {
+		thin := 0
+		for _, count := range byTemplate[template] {
+			if count.words < tracker.minWords {
+				thin++
+			}
+		}
+		return thin
+	}
+	sort.SliceStable(templates, func(i, j int) bool {
+		return thin(templates[i]) > thin(templates[j])
+	})
+
+	for _, template := range templates {
+		pages := byTemplate[template]
+		if thin(template) == 0 {
+			continue
+		}
+		tracker.templates.Add(template, strconv.Itoa(len(pages)), strconv.Itoa(thin(template)),
+			strconv.Itoa(pages[len(pages)/2].words), pages[0].url)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/trend"
+)
+
+// trendMeter averages how long requests took over the whole crawl and counts the pages that failed in
+// it, however many attempts they took, for -trendCSV and -pushgateway
+type trendMeter struct {
+	mutex    sync.Mutex
+	total    time.Duration
+	requests int
+	failed   map[string]bool
+
+	// The sample is only taken once the subscription has caught up
+	caughtUp caughtUp
+}
+
+func newTrendMeter() *trendMeter {
+	return &trendMeter{failed: make(map[string]bool), caughtUp: newCaughtUp()}
+}
+
+func (meter *trendMeter) watch(events <-chan crawlEvent) {
+	for event := range events {
+		switch event := event.(type) {
+		case fetchCompleted:
+			meter.add(event.crawled.elapsed)
+		case fetchFailed:
+			meter.add(event.crawled.elapsed)
+
+			meter.mutex.Lock()
+			meter.failed[event.crawled.String()] = true
+			meter.mutex.Unlock()
+		}
+		meter.caughtUp.see(event)
+	}
+}
+
+// add counts a request, unless it never got as far as being sent
+func (meter *trendMeter) add(elapsed time.Duration) {
+	if elapsed == 0 {
+		return
+	}
+
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+	meter.total += elapsed
+	meter.requests++
+}
+
+func (meter *trendMeter) failures() int {
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+	return len(meter.failed)
+}
+
+func (meter *trendMeter) average() time.Duration {
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+
+	if meter.requests == 0 {
+		return 0
+	}
+	return meter.total / time.Duration(meter.requests)
+}
+
+// exportTrend records how the crawl went as a point of a time series, in a CSV file and on a Pushgateway,
+// whichever are given
+// The gateway is usually on a private network, so it's reached without the crawl's guard against those
+func exportTrend(csvPath string, gateway string, job string, summary trend.Summary) []error {
+	var errs []error
+	if csvPath != "" {
+		if err := trend.AppendCSV(csvPath, summary); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if gateway != "" {
+		client := &http.Client{Timeout: 10 * time.Second}
+		if err := trend.Push(client, gateway, job, summary); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
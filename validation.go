@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/validate"
+)
+
+// htmlValidator records the well-formedness problems of every crawled page that has any
+type htmlValidator struct {
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	report *report.Report
+}
+
+func newHTMLValidator() *htmlValidator {
+	return &htmlValidator{
+		seen:   make(map[string]bool),
+		report: report.New("html-validation", "url", "issues", "unclosed", "stray end tags", "duplicate ids", "h1s", "details"),
+	}
+}
+
+func (validator *htmlValidator) observe(crawled page, crawlErr error) {
+	if crawlErr != nil {
+		return
+	}
+
+	validator.mutex.Lock()
+	seen := validator.seen[crawled.String()]
+	validator.seen[crawled.String()] = true
+	validator.mutex.Unlock()
+	if seen {
+		return
+	}
+
+	result := validate.Check(crawled.body)
+	if result.Issues() == 0 {
+		return
+	}
+
+	var details []string
+	if len(result.Unclosed) > 0 {
+		details = append(details, "unclosed "+strings.Join(result.Unclosed, " "))
+	}
+	if len(result.Stray) > 0 {
+		details = append(details, "stray "+strings.Join(result.Stray, " "))
+	}
+	if len(result.DuplicateIDs) > 0 {
+		details = append(details, "duplicate #"+strings.Join(result.DuplicateIDs, " #"))
+	}
+	if result.H1s > 1 {
+		details = append(details, strconv.Itoa(result.H1s)+" h1s")
+	}
+
+	validator.report.Add(crawled.String(), strconv.Itoa(result.Issues()),
+		strconv.Itoa(len(result.Unclosed)), strconv.Itoa(len(result.Stray)),
+		strconv.Itoa(len(result.DuplicateIDs)), strconv.Itoa(result.H1s), strings.Join(details, "; "))
+}
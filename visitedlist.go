@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// visitedList collects every URL the crawl attempted and writes them to a file on exit, see -visitedList
+type visitedList struct {
+	path       string
+	withStatus bool
+
+	mutex sync.Mutex
+	// The status of the latest attempt, 0 when there was no response at all
+	statuses map[string]int
+}
+
+func newVisitedList(path string, withStatus bool) *visitedList {
+	return &visitedList{path: path, withStatus: withStatus, statuses: make(map[string]int)}
+}
+
+func (list *visitedList) observe(crawled page, crawlErr error) {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+	list.statuses[crawled.String()] = crawled.status
+}
+
+// save writes the URLs one per line, sorted, each followed by a tab and its status if asked for
+func (list *visitedList) save() {
+	list.mutex.Lock()
+	defer list.mutex.Unlock()
+
+	urls := make([]string, 0, len(list.statuses))
+	for visitedURL := range list.statuses {
+		urls = append(urls, visitedURL)
+	}
+	sort.Strings(urls)
+
+	file, err := os.Create(list.path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, visitedURL := range urls {
+		if list.withStatus {
+			fmt.Fprintf(writer, "%s\t%d\n", visitedURL, list.statuses[visitedURL])
+		} else {
+			fmt.Fprintln(writer, visitedURL)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jrokun/crawler/pkg/canonical"
+	"github.com/jrokun/crawler/pkg/robots"
+	"github.com/jrokun/crawler/pkg/sink"
+)
+
+// loadSeen reads the URLs a previous crawl visited, for -seen
+// The file is either that crawl's .jsonl output or a plain list of URLs, one per line, and every URL
+// is normalized the way this crawl normalizes URLs so they match what it finds
+func loadSeen(path string, normalize canonical.Chain) (robots.Set, error) {
+	var urls []string
+	if strings.HasSuffix(path, ".jsonl") {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		records, err := sink.ReadJSONL(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, record := range records {
+			urls = append(urls, record.URL)
+		}
+	} else {
+		lines, err := readLines(path)
+		if err != nil {
+			return nil, err
+		}
+		// Lines may carry more after the URL, like the statuses of -visitedStatus
+		for _, line := range lines {
+			urls = append(urls, strings.Fields(line)[0])
+		}
+	}
+
+	seen := make(robots.Set, len(urls))
+	for _, link := range urls {
+		parsedURL, err := url.Parse(link)
+		if err != nil || !crawlable(*parsedURL) {
+			continue
+		}
+		normalized := normalize.Apply(*parsedURL)
+		seen[normalized.String()] = true
+	}
+	return seen, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrokun/crawler/pkg/report"
+	"github.com/jrokun/crawler/pkg/securitytxt"
+)
+
+// The well-known files probed on every host, by the asset name they're reported under, along with
+// how to find problems in the ones that are there
+var wellKnownFiles = []struct {
+	asset string
+	path  string
+	check func(contents string) []string
+}{
+	{"security.txt", "/.well-known/security.txt", checkSecurityTxt},
+	{"humans.txt", "/humans.txt", nil},
+	{"ads.txt", "/ads.txt", nil},
+}
+
+// How much of a well-known file is read, and how much of it ends up in the report
+const (
+	wellKnownMaxBytes    = 64 * 1024
+	wellKnownMaxContents = 2048
+)
+
+// wellKnownProber fetches the standard well-known files of every host the crawl visits, reporting
+// whether each is there and what it says
+type wellKnownProber struct {
+	client *http.Client
+	side   sideRequests
+
+	mutex sync.Mutex
+	seen  map[string]bool
+
+	pages   chan page
+	dropped droppedWork
+	report  *report.Report
+}
+
+func newWellKnownProber(client *http.Client, side sideRequests, hosts *report.Report, queueSize int) *wellKnownProber {
+	prober := &wellKnownProber{
+		client: client,
+		side:   side,
+		seen:   make(map[string]bool),
+		pages:  make(chan page, queueSize),
+		report: hosts,
+	}
+
+	go prober.run()
+	return prober
+}
+
+func (prober *wellKnownProber) observe(crawled page, crawlErr error) {
+	if crawled.status == 0 {
+		return
+	}
+
+	prober.mutex.Lock()
+	seen := prober.seen[crawled.Host]
+	prober.seen[crawled.Host] = true
+	prober.mutex.Unlock()
+
+	if seen {
+		return
+	}
+
+	select {
+	case prober.pages <- crawled:
+	default:
+		// Left for another page of the host to try again
+		prober.dropped.add()
+		prober.mutex.Lock()
+		delete(prober.seen, crawled.Host)
+		prober.mutex.Unlock()
+	}
+}
+
+func (prober *wellKnownProber) run() {
+	for crawled := range prober.pages {
+		for _, file := range wellKnownFiles {
+			resolved, err := crawled.URL.Parse(file.path)
+			if err != nil {
+				continue
+			}
+			if !prober.side.allows(*resolved) {
+				prober.report.Add(crawled.Host, file.asset, resolved.String(), "", "", "not fetched, outside the crawl", "")
+				continue
+			}
+
+			status, mediaType, contents, err := prober.fetch(resolved.String())
+			switch {
+			case err != nil:
+				prober.report.Add(crawled.Host, file.asset, resolved.String(), "", "", err.Error(), "")
+			case status != http.StatusOK:
+				prober.report.Add(crawled.Host, file.asset, resolved.String(), "", strconv.Itoa(status), "missing", "")
+			case mediaType == "text/html":
+				// Usually a site answering every path with a page, rather than the file being there
+				prober.report.Add(crawled.Host, file.asset, resolved.String(), "", strconv.Itoa(status), "missing, served an HTML page", "")
+			default:
+				var problems []string
+				if file.check != nil {
+					problems = file.check(contents)
+				}
+				if len(contents) > wellKnownMaxContents {
+					contents = contents[:wellKnownMaxContents]
+				}
+				prober.report.Add(crawled.Host, file.asset, resolved.String(), "", strconv.Itoa(status), strings.Join(problems, ", "), contents)
+			}
+		}
+	}
+}
+
+// fetch gets a well-known file, returning its status, media type and contents
+func (prober *wellKnownProber) fetch(link string) (int, string, string, error) {
+	response, err := prober.client.Get(link)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer response.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if response.StatusCode != http.StatusOK || mediaType == "text/html" {
+		return response.StatusCode, mediaType, "", nil
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(response.Body, wellKnownMaxBytes))
+	if err != nil {
+		return response.StatusCode, mediaType, "", err
+	}
+	return response.StatusCode, mediaType, strings.TrimSpace(string(contents)), nil
+}
+
+// checkSecurityTxt flags a security.txt with nobody to contact or that has expired
+func checkSecurityTxt(contents string) []string {
+	return securitytxt.Parse(contents).Problems(time.Now())
+}